@@ -0,0 +1,101 @@
+// Command importusers creates accounts from the user export of another
+// system, keeping their password hashes so users log in with their existing
+// passwords.
+//
+// Usage:
+//
+//	importusers [-format json|csv] [-dry-run] <file>
+//
+// The format defaults to the file extension. JSON exports are an array of
+// records, or one record per line:
+//
+//	{"username": "alice", "email": "alice@example.com", "password_hash": "$2b$10$...", "email_verified": true}
+//
+// CSV exports start with a header row naming the username, email,
+// password_hash and optional email_verified columns. Hashes may be argon2id,
+// bcrypt, scrypt or PBKDF2-SHA256; each is moved to argon2id on the user's
+// first login. Records that cannot be imported are listed and skipped.
+package main
+
+import (
+	"authorization/internal/config"
+	"authorization/internal/database"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/service"
+	"authorization/internal/store"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	format := flag.String("format", "", "export format, json or csv (default from the file extension)")
+	dryRun := flag.Bool("dry-run", false, "check the records without creating accounts")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+
+	path := flag.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if *format == "jsonl" || *format == "ndjson" {
+			*format = "json"
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal("Failed to open export:", err)
+	}
+	defer file.Close()
+
+	records, err := service.ReadImportRecords(file, *format)
+	if err != nil {
+		log.Fatal("Failed to read export: ", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	// Initialize logger
+	if err := logger.Init(cfg.AppEnv); err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	defer logger.Sync()
+
+	db, err := database.Connect(cfg.Database.URL)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
+	importService := service.NewUserImportService(store.NewUserRepository(db), store.NewRoleRepository(db))
+	result, err := importService.Import(records, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, skip := range result.Skipped {
+		fmt.Printf("Skipped record %d (%s): %s\n", skip.Record, skip.Username, skip.Reason)
+	}
+	if *dryRun {
+		fmt.Printf("Dry run: %d of %d records would be imported\n", result.Created, len(records))
+	} else {
+		fmt.Printf("Imported %d of %d records\n", result.Created, len(records))
+	}
+	if len(result.Skipped) > 0 {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: importusers [-format json|csv] [-dry-run] <file>")
+	os.Exit(2)
+}
@@ -0,0 +1,119 @@
+// Command jwtkeys manages the database backed JWT signing keyring.
+//
+// Usage:
+//
+//	jwtkeys list      Show every key and its status
+//	jwtkeys rotate    Publish a new key to replace the active key
+//	jwtkeys retire    Retire keys whose tokens have all expired
+//
+// A new key is published for JWT_KEY_SYNC_INTERVAL plus the JWKS max-age
+// before running servers start signing with it, so that every server and
+// verifier knows it by then. Running it again once that time has passed
+// makes the key sign right away, otherwise the servers do on schedule.
+package main
+
+import (
+	"authorization/internal/config"
+	"authorization/internal/database"
+	"authorization/internal/model"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/service"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		usage()
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	if cfg.JWT.KeyStore != config.KeyStoreDatabase {
+		log.Fatalf("JWT_KEY_STORE must be %q to manage keys", config.KeyStoreDatabase)
+	}
+
+	// Initialize logger
+	if err := logger.Init(cfg.AppEnv); err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	defer logger.Sync()
+
+	db, err := database.Connect(cfg.Database.URL)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
+	secretBox, err := utils.NewSecretBox([]byte(cfg.JWT.KeyEncryptionSecret), "jwt-signing-keys")
+	if err != nil {
+		log.Fatal("Invalid JWT_KEY_ENCRYPTION_SECRET:", err)
+	}
+
+	keyService := service.NewKeyService(store.NewSigningKeyRepository(db), secretBox, cfg.JWT.Algorithm, cfg.JWT.KeyRotationInterval, cfg.JWT.KeySyncInterval, cfg.JWT.AccessTokenExp)
+
+	switch os.Args[1] {
+	case "list":
+		err = listKeys(keyService)
+	case "rotate":
+		key, rotateErr := keyService.Rotate()
+		switch {
+		case rotateErr != nil:
+		case key == nil:
+			fmt.Println("Another server created the first key meanwhile")
+		case key.Status == model.SigningKeyActive:
+			fmt.Printf("Rotated: new active key %s (%s)\n", key.ID, key.Algorithm)
+		default:
+			fmt.Printf("Published: key %s (%s) signs from %s\n", key.ID, key.Algorithm, keyService.SigningFrom(key).Format(time.RFC3339))
+		}
+		err = rotateErr
+	case "retire":
+		retired, retireErr := keyService.RetireExpired()
+		if retireErr == nil {
+			fmt.Printf("Retired %d key(s)\n", retired)
+		}
+		err = retireErr
+	default:
+		usage()
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func listKeys(keyService *service.KeyService) error {
+	keys, err := keyService.ListKeys()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tSTATUS\tCREATED\tROTATED\tRETIRE AFTER")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Algorithm, key.Status,
+			key.CreatedAt.Format(time.RFC3339), formatTime(key.RotatedAt), formatTime(key.RetireAfter))
+	}
+	return w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: jwtkeys list|rotate|retire")
+	os.Exit(2)
+}
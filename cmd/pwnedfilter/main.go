@@ -0,0 +1,86 @@
+// Command pwnedfilter builds a Bloom filter of breached passwords from a
+// downloaded copy of the Pwned Passwords corpus, for PASSWORD_BREACH_CORPUS.
+//
+// Usage:
+//
+//	pwnedfilter [-min-count n] [-fp-rate p] -o <filter> <corpus>
+//
+// The corpus is the SHA-1 range dataset directory fetched by the Pwned
+// Passwords downloader, or a single file of "HASH:COUNT" lines. Only
+// passwords seen at least min-count times are kept, which makes the filter
+// much smaller; the server must use the same PASSWORD_BREACH_MIN_COUNT. The
+// corpus is read twice, first to size the filter.
+package main
+
+import (
+	"authorization/internal/pwned"
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	minCount := flag.Int("min-count", 1, "keep passwords seen at least this many times")
+	fpRate := flag.Float64("fp-rate", 0.001, "rate of passwords wrongly reported as breached")
+	output := flag.String("o", "", "file to write the filter to")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 || *output == "" {
+		usage()
+	}
+	corpus := flag.Arg(0)
+
+	var n uint64
+	err := pwned.ReadCorpus(corpus, func(_ [sha1.Size]byte, count int) error {
+		if count >= *minCount {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal("Failed to read corpus: ", err)
+	}
+
+	filter, err := pwned.NewFilter(n, *fpRate, *minCount)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = pwned.ReadCorpus(corpus, func(hash [sha1.Size]byte, count int) error {
+		if count >= *minCount {
+			filter.Add(hash)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal("Failed to read corpus: ", err)
+	}
+
+	// Write next to the target and rename, so a running server never loads
+	// half a filter
+	tmp := *output + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		log.Fatal("Failed to create filter: ", err)
+	}
+	if _, err := filter.WriteTo(file); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		log.Fatal("Failed to write filter: ", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		log.Fatal("Failed to write filter: ", err)
+	}
+	if err := os.Rename(tmp, *output); err != nil {
+		log.Fatal("Failed to write filter: ", err)
+	}
+
+	fmt.Printf("Wrote %d passwords seen at least %d times to %s (%.1f MiB)\n", filter.Len(), *minCount, *output, float64(filter.Size())/(1<<20))
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pwnedfilter [-min-count n] [-fp-rate p] -o <filter> <corpus>")
+	os.Exit(2)
+}
@@ -0,0 +1,85 @@
+// Command roles manages role assignments from the command line, which is how
+// the first administrator is created.
+//
+// Usage:
+//
+//	roles show <username>            Show the roles and permissions of a user
+//	roles grant <username> <role>    Give a user a role
+//	roles revoke <username> <role>   Take a role away from a user
+//
+// Running servers see changes once their AUTHZ_CACHE_TTL has passed, and
+// access tokens carry them from the next refresh.
+package main
+
+import (
+	"authorization/internal/config"
+	"authorization/internal/database"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/service"
+	"authorization/internal/store"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	// Initialize logger
+	if err := logger.Init(cfg.AppEnv); err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	defer logger.Sync()
+
+	db, err := database.Connect(cfg.Database.URL)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
+	userRepo := store.NewUserRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	roleService := service.NewRoleService(roleRepo, userRepo, service.NewAuthzEvaluator(roleRepo, 0))
+
+	user, err := userRepo.GetByUsername(os.Args[2])
+	if err != nil {
+		log.Fatalf("Failed to find user %q: %v", os.Args[2], err)
+	}
+
+	var roles *dto.UserRolesResponse
+	switch {
+	case os.Args[1] == "show" && len(os.Args) == 3:
+		roles, err = roleService.GetUserRoles(user.ID)
+	case os.Args[1] == "grant" && len(os.Args) == 4:
+		roles, err = roleService.AssignRole(service.OperatorActor, user.ID, os.Args[3])
+	case os.Args[1] == "revoke" && len(os.Args) == 4:
+		if err = roleService.RevokeRole(user.ID, os.Args[3]); err == nil {
+			roles, err = roleService.GetUserRoles(user.ID)
+		}
+	default:
+		usage()
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("User:        %s (%s)\n", user.Username, user.ID)
+	fmt.Printf("Roles:       %s\n", strings.Join(roles.Roles, ", "))
+	fmt.Printf("Effective:   %s\n", strings.Join(roles.EffectiveRoles, ", "))
+	fmt.Printf("Permissions: %s\n", strings.Join(roles.Permissions, ", "))
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: roles show <username> | roles grant|revoke <username> <role>")
+	os.Exit(2)
+}
@@ -2,12 +2,21 @@ package main
 
 import (
 	"authorization/internal/config"
+	"authorization/internal/database"
+	"authorization/internal/denylist"
 	"authorization/internal/handler"
+	"authorization/internal/middleware"
+	"authorization/internal/notify"
 	"authorization/internal/pkg/logger"
+	"authorization/internal/policy"
+	"authorization/internal/pwned"
+	"authorization/internal/ratelimit"
+	"authorization/internal/rebac"
 	"authorization/internal/server"
 	"authorization/internal/service"
 	"authorization/internal/store"
 	"authorization/internal/utils"
+	"authorization/internal/webauthn"
 	"context"
 	"fmt"
 	"log"
@@ -18,7 +27,6 @@ import (
 	"time"
 
 	"go.uber.org/zap"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -38,28 +46,231 @@ func main() {
 	logger.Info("Starting authorization service", zap.String("env", cfg.AppEnv), zap.String("port", cfg.Port))
 
 	// Initialize database
-	db, err := initDatabase(cfg.Database.URL)
+	db, err := database.Connect(cfg.Database.URL)
 	if err != nil {
 		logger.Fatal("Failed to initialize database", zap.Error(err))
 	}
+	logger.Info("Run 'make migrate-up' to apply database migrations")
 
 	// Initialize repositories
 	userRepo := store.NewUserRepository(db)
 	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	relationTupleRepo := store.NewRelationTupleRepository(db)
+	policyRepo := store.NewPolicyRepository(db)
+	orgRepo := store.NewOrganizationRepository(db)
+	resetRepo := store.NewPasswordResetRepository(db)
+	verifyRepo := store.NewEmailVerificationRepository(db)
+	mfaRepo := store.NewMFARepository(db)
+	webauthnRepo := store.NewWebAuthnRepository(db)
+	throttleRepo := store.NewLoginThrottleRepository(db)
+	historyRepo := store.NewPasswordHistoryRepository(db)
+	mfaChallengeRepo := store.NewMFAChallengeRepository(db)
+
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
 
 	// Initialize JWT manager
-	jwtManager := utils.NewJWTManager(cfg.JWT.Secret, cfg.JWT.AccessTokenExp, cfg.JWT.RefreshTokenExp)
+	var jwtManager *utils.JWTManager
+	if cfg.JWT.KeyStore == config.KeyStoreDatabase {
+		keyService, err := newKeyService(db, cfg.JWT)
+		if err != nil {
+			logger.Fatal("Failed to initialize JWT keyring", zap.Error(err))
+		}
+		jwtManager = utils.NewJWTManagerWithKeyring(keyService.Keyring(), cfg.JWT.AccessTokenExp, cfg.JWT.RefreshTokenExp)
+		logger.Info("JWT keyring loaded", zap.String("active_kid", keyService.Keyring().Active().ID))
+
+		go keyService.Run(jobsCtx)
+	} else {
+		signingKey, err := loadSigningKey(cfg.JWT)
+		if err != nil {
+			logger.Fatal("Failed to load JWT signing key", zap.Error(err))
+		}
+		jwtManager = utils.NewJWTManagerWithKey(signingKey, cfg.JWT.AccessTokenExp, cfg.JWT.RefreshTokenExp)
+		logger.Info("JWT signing key loaded", zap.String("alg", signingKey.Method.Alg()), zap.String("kid", signingKey.ID))
+	}
+
+	// Resolved permissions are cached per user
+	evaluator := service.NewAuthzEvaluator(roleRepo, cfg.Authz.CacheTTL)
+
+	// Relation schema for relationship based access
+	relationSchema := rebac.DefaultSchema()
+	if cfg.Relations.SchemaFile != "" {
+		relationSchema, err = rebac.LoadSchema(cfg.Relations.SchemaFile)
+		if err != nil {
+			logger.Fatal("Failed to load relation schema", zap.Error(err))
+		}
+	}
+	relationEngine := service.NewRelationEngine(relationTupleRepo, relationSchema)
+
+	// Attribute based policies from files and the database
+	var filePolicies []*policy.Policy
+	if cfg.Policy.Dir != "" {
+		filePolicies, err = policy.LoadDir(cfg.Policy.Dir)
+		if err != nil {
+			logger.Fatal("Failed to load policies", zap.Error(err))
+		}
+	}
+	policyService, err := service.NewPolicyService(policyRepo, policy.Algorithm(cfg.Policy.Algorithm), filePolicies)
+	if err != nil {
+		logger.Fatal("Failed to initialize policy engine", zap.Error(err))
+	}
+	logger.Info("Policies loaded", zap.String("algorithm", cfg.Policy.Algorithm), zap.Int("policies", len(policyService.Engine().Policies())))
+	go policyService.Run(jobsCtx, cfg.Policy.ReloadInterval)
+
+	// Stored hashes keep their own parameters; logins upgrade weaker ones
+	if err := utils.SetPasswordParams(cfg.PasswordHash); err != nil {
+		logger.Fatal("Invalid password hash parameters", zap.Error(err))
+	}
+
+	// Likewise hashes record their pepper version, and logins move them to
+	// the active pepper
+	if cfg.PasswordPepper.File != "" {
+		peppers, err := utils.LoadPasswordPeppers(cfg.PasswordPepper.File, cfg.PasswordPepper.ActiveVersion)
+		if err != nil {
+			logger.Fatal("Failed to load PASSWORD_PEPPER_FILE", zap.Error(err))
+		}
+		utils.SetPasswordPeppers(peppers)
+		logger.Info("Peppering password hashes", zap.Int("active_version", peppers.Active), zap.Ints("versions", peppers.Versions()))
+	}
+
+	// Stored token hashes record their key version; tokens hashed with an
+	// older key are moved to the active one when they are next used
+	if cfg.TokenHash.KeyFile != "" {
+		keys, err := utils.LoadTokenHashKeys(cfg.TokenHash.KeyFile, cfg.TokenHash.ActiveVersion)
+		if err != nil {
+			logger.Fatal("Failed to load TOKEN_HASH_KEY_FILE", zap.Error(err))
+		}
+		keys.AcceptLegacy = cfg.TokenHash.AcceptLegacy
+		utils.SetTokenHashKeys(keys)
+		logger.Info("Keying token hashes", zap.Int("active_version", keys.Active), zap.Ints("versions", keys.Versions()), zap.Bool("accept_legacy", keys.AcceptLegacy))
+	} else {
+		logger.Warn("Token hashes are bare SHA-256, set TOKEN_HASH_KEY_FILE")
+	}
+
+	// Account messages are posted to a webhook, or written to the log
+	// without their links unless NOTIFY_LOG_LINKS is set for development
+	var notifier notify.Notifier
+	if cfg.Notify.Notifier == config.NotifierWebhook {
+		notifier = notify.NewWebhookNotifier(cfg.Notify.WebhookURL, cfg.Notify.WebhookSecret)
+	} else {
+		notifier = notify.NewLogNotifier(cfg.Notify.LogLinks)
+		if !cfg.Notify.LogLinks {
+			logger.Warn("Reset and verification links are not delivered, set NOTIFIER=webhook")
+		}
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, tokenRepo, jwtManager)
-	userService := service.NewUserService(userRepo)
+	emailVerificationService := service.NewEmailVerificationService(userRepo, verifyRepo, notifier, cfg.EmailVerification.TokenExp, cfg.EmailVerification.ResendInterval, cfg.EmailVerification.URL)
+	relyingParty, err := webauthn.NewRelyingParty(cfg.WebAuthn.RPID, cfg.WebAuthn.RPName, cfg.WebAuthn.Origins)
+	if err != nil {
+		logger.Fatal("Invalid WebAuthn configuration", zap.Error(err))
+	}
+	webauthnService := service.NewWebAuthnService(webauthnRepo, userRepo, relyingParty)
+	lockoutService := service.NewLockoutService(throttleRepo, userRepo, service.LockoutPolicy{
+		Account:     service.LockoutLimits{BackoffAfter: cfg.Lockout.BackoffAfter, LockAfter: cfg.Lockout.Threshold},
+		IP:          service.LockoutLimits{BackoffAfter: cfg.Lockout.IPBackoffAfter, LockAfter: cfg.Lockout.IPThreshold},
+		BackoffBase: cfg.Lockout.BackoffBase,
+		BackoffMax:  cfg.Lockout.BackoffMax,
+		Duration:    cfg.Lockout.Duration,
+	})
+
+	// Breached passwords are looked up in a local copy of Pwned Passwords
+	var breaches pwned.Source
+	if cfg.PasswordBreaches.Corpus != "" {
+		breaches, err = pwned.Open(cfg.PasswordBreaches.Corpus, cfg.PasswordBreaches.MinCount)
+		if err != nil {
+			logger.Fatal("Failed to open PASSWORD_BREACH_CORPUS", zap.Error(err))
+		}
+		logger.Info("Checking passwords against breach corpus", zap.String("corpus", cfg.PasswordBreaches.Corpus), zap.Int("min_count", cfg.PasswordBreaches.MinCount))
+	}
+	passwordPolicyService := service.NewPasswordPolicyService(cfg.PasswordPolicy, historyRepo, breaches)
+
+	// Revoked access tokens are denied per node unless the denylist is
+	// shared in the database
+	var accessTokenDenylist denylist.Store = denylist.NewMemoryStore()
+	if cfg.Denylist.Store == config.DenylistStoreDatabase {
+		denylistRepo := store.NewAccessTokenDenylistRepository(db)
+		accessTokenDenylist = denylistRepo
+		go clearExpiredDenials(jobsCtx, denylistRepo, time.Minute)
+	}
+	go clearExpiredMFAChallenges(jobsCtx, mfaChallengeRepo, time.Minute)
+	go clearStaleLockouts(jobsCtx, lockoutService, time.Minute)
+
+	accessTokenRevoker := service.NewAccessTokenRevoker(accessTokenDenylist, tokenRepo, cfg.JWT.AccessTokenExp)
+
+	authOptions := []service.AuthOption{
+		service.WithRefreshReuseGrace(cfg.JWT.RefreshReuseGrace),
+		service.WithRoles(roleRepo, evaluator),
+		service.WithOrganizations(orgRepo),
+		service.WithEmailVerification(emailVerificationService, cfg.EmailVerification.Required),
+		service.WithWebAuthn(webauthnService),
+		service.WithMFAChallenges(mfaChallengeRepo),
+		service.WithLockout(lockoutService),
+		service.WithPasswordPolicy(passwordPolicyService),
+		service.WithAccessTokenRevoker(accessTokenRevoker),
+	}
+	if cfg.Registration.EnumerationSafe {
+		authOptions = append(authOptions, service.WithEnumerationSafeRegistration(notifier))
+	}
+
+	// Two-factor authentication needs a key to encrypt TOTP secrets with
+	var mfaService *service.MFAService
+	if cfg.MFA.EncryptionSecret != "" {
+		mfaSecretBox, err := utils.NewSecretBox([]byte(cfg.MFA.EncryptionSecret), "mfa-secrets")
+		if err != nil {
+			logger.Fatal("Invalid MFA_ENCRYPTION_SECRET", zap.Error(err))
+		}
+		mfaService = service.NewMFAService(mfaRepo, userRepo, mfaSecretBox, cfg.MFA.Issuer)
+		authOptions = append(authOptions, service.WithMFA(mfaService))
+	} else {
+		logger.Info("Two-factor authentication disabled, MFA_ENCRYPTION_SECRET is not set")
+	}
+
+	authService := service.NewAuthService(userRepo, tokenRepo, jwtManager, authOptions...)
+	userService := service.NewUserService(userRepo, tokenRepo, evaluator, accessTokenRevoker)
+	sessionService := service.NewSessionService(tokenRepo, accessTokenRevoker)
+	roleService := service.NewRoleService(roleRepo, userRepo, evaluator)
+	authzService := service.NewAuthzService(evaluator, userRepo)
+	relationService := service.NewRelationService(relationEngine, evaluator)
+	orgService := service.NewOrganizationService(orgRepo, roleRepo, userRepo, evaluator)
+	passwordService := service.NewPasswordService(userRepo, tokenRepo, resetRepo, passwordPolicyService, accessTokenRevoker, notifier, cfg.PasswordReset.TokenExp, cfg.PasswordReset.ResendInterval, cfg.PasswordReset.URL)
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService)
 	userHandler := handler.NewUserHandler(userService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	jwksHandler := handler.NewJWKSHandler(jwtManager)
+	roleHandler := handler.NewRoleHandler(roleService)
+	authzHandler := handler.NewAuthzHandler(authzService)
+	relationHandler := handler.NewRelationHandler(relationService)
+	policyHandler := handler.NewPolicyHandler(policyService)
+	orgHandler := handler.NewOrganizationHandler(orgService)
+	passwordHandler := handler.NewPasswordHandler(passwordService)
+	emailVerificationHandler := handler.NewEmailVerificationHandler(emailVerificationService)
+	webauthnHandler := handler.NewWebAuthnHandler(webauthnService, authService)
+	lockoutHandler := handler.NewLockoutHandler(lockoutService)
+	var mfaHandler *handler.MFAHandler
+	if mfaService != nil {
+		mfaHandler = handler.NewMFAHandler(mfaService)
+	}
+
+	// Rate limits count per node unless their state is shared in the database
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == config.RateLimitStoreDatabase {
+		rateLimitRepo := store.NewRateLimitRepository(db)
+		rateLimitStore = rateLimitRepo
+		go clearExpiredRateLimits(jobsCtx, rateLimitRepo, time.Minute)
+	}
+	rateLimiter := middleware.NewRateLimiter(rateLimitStore, cfg.RateLimit.Policies)
+
+	// Access tokens issued before a password change are rejected, as are
+	// revoked ones
+	authMiddleware := middleware.NewAuthMiddleware(jwtManager, middleware.WithPasswordChanges(userRepo), middleware.WithDenylist(accessTokenDenylist))
 
 	// Setup router
-	router := server.NewRouter(authHandler, userHandler, jwtManager)
+	router := server.NewRouter(authHandler, userHandler, sessionHandler, jwksHandler, roleHandler, authzHandler, relationHandler, policyHandler, orgHandler, passwordHandler, emailVerificationHandler, mfaHandler, webauthnHandler, lockoutHandler, authMiddleware, rateLimiter, cfg.TrustedProxies)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -84,6 +295,7 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopJobs()
 
 	// Create a context with timeout for graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -92,54 +304,102 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
+	authService.WaitForRehashes()
+	passwordService.WaitForResetLinks()
 
 	logger.Info("Server exited")
 }
 
-func initDatabase(databaseURL string) (*gorm.DB, error) {
-	var db *gorm.DB
-	var err error
-	
-	// Retry connection up to 10 times with increasing delay
-	for i := 0; i < 10; i++ {
-		db, err = gorm.Open(postgres.Open(databaseURL), &gorm.Config{})
-		if err == nil {
-			break
-		}
-		
-		logger.Info("Failed to connect to database, retrying...", 
-			zap.Int("attempt", i+1), 
-			zap.Error(err))
-		
-		// Wait before retry (exponential backoff)
-		waitTime := time.Duration(i+1) * time.Second
-		time.Sleep(waitTime)
-	}
-	
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database after retries: %w", err)
+// loadSigningKey builds the access token signing key from configuration
+func loadSigningKey(cfg config.JWTConfig) (*utils.SigningKey, error) {
+	if cfg.Algorithm == utils.AlgorithmHS256 {
+		return utils.NewHMACSigningKey(cfg.KeyID, []byte(cfg.Secret)), nil
 	}
+	return utils.LoadSigningKey(cfg.Algorithm, cfg.PrivateKeyFile, cfg.KeyID)
+}
 
-	// Test connection
-	sqlDB, err := db.DB()
+// newKeyService loads the database backed keyring
+func newKeyService(db *gorm.DB, cfg config.JWTConfig) (*service.KeyService, error) {
+	secretBox, err := utils.NewSecretBox([]byte(cfg.KeyEncryptionSecret), "jwt-signing-keys")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get database instance: %w", err)
+		return nil, fmt.Errorf("invalid JWT_KEY_ENCRYPTION_SECRET: %w", err)
 	}
 
-	if err := sqlDB.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	keyService := service.NewKeyService(store.NewSigningKeyRepository(db), secretBox, cfg.Algorithm, cfg.KeyRotationInterval, cfg.KeySyncInterval, cfg.AccessTokenExp)
+	if err := keyService.Sync(); err != nil {
+		return nil, err
 	}
+	return keyService, nil
+}
 
-	// Set connection pool settings
-	sqlDB.SetMaxOpenConns(25)
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+// clearExpiredDenials periodically removes access token denylist entries
+// whose tokens have expired from the database
+func clearExpiredDenials(ctx context.Context, repo *store.AccessTokenDenylistRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	// Note: Database schema is managed by golang-migrate migrations
-	// Run: make migrate-up to apply migrations
-	// Migration files are in db/migrations/ directory
-	logger.Info("Database connected successfully")
-	logger.Info("Run 'make migrate-up' to apply database migrations")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := repo.DeleteExpired(); err != nil {
+				logger.Error("Failed to clear expired access token denials", zap.Error(err))
+			}
+		}
+	}
+}
+
+// clearExpiredMFAChallenges periodically removes the attempt counts of
+// expired MFA challenges from the database
+func clearExpiredMFAChallenges(ctx context.Context, repo *store.MFAChallengeRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := repo.DeleteExpired(); err != nil {
+				logger.Error("Failed to clear expired MFA challenges", zap.Error(err))
+			}
+		}
+	}
+}
 
-	return db, nil
+// clearStaleLockouts periodically removes failed logins that no longer count
+// from the database
+func clearStaleLockouts(ctx context.Context, lockoutService *service.LockoutService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := lockoutService.ClearStale(); err != nil {
+				logger.Error("Failed to clear stale failed logins", zap.Error(err))
+			}
+		}
+	}
+}
+
+// clearExpiredRateLimits periodically removes expired rate limit state from
+// the database
+func clearExpiredRateLimits(ctx context.Context, repo *store.RateLimitRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := repo.DeleteExpired(); err != nil {
+				logger.Error("Failed to clear expired rate limits", zap.Error(err))
+			}
+		}
+	}
 }
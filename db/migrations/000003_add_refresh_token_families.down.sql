@@ -0,0 +1,11 @@
+-- Remove refresh token family tracking
+-- This reverses the changes made in 000003_add_refresh_token_families.up.sql
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_refresh_tokens_parent_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+
+-- Drop columns
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS revoked_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,16 @@
+-- Track refresh token rotation chains as families
+-- Based on RefreshToken struct (FamilyID, ParentID, RevokedAt)
+
+-- Add family tracking columns
+ALTER TABLE refresh_tokens ADD COLUMN family_id VARCHAR(36);
+ALTER TABLE refresh_tokens ADD COLUMN parent_id VARCHAR(36);
+ALTER TABLE refresh_tokens ADD COLUMN revoked_at TIMESTAMP WITH TIME ZONE;
+
+-- Existing tokens become the root of their own family
+UPDATE refresh_tokens SET family_id = id WHERE family_id IS NULL;
+
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+-- Create indexes for family lookups (corresponds to GORM index tags)
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_parent_id ON refresh_tokens(parent_id);
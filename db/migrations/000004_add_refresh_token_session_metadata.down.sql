@@ -0,0 +1,11 @@
+-- Remove session metadata from refresh tokens
+-- This reverses the changes made in 000004_add_refresh_token_session_metadata.up.sql
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_refresh_tokens_user_active;
+
+-- Drop columns
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS device_label;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS session_started_at;
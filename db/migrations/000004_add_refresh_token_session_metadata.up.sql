@@ -0,0 +1,23 @@
+-- Record session metadata on refresh tokens
+-- Based on RefreshToken struct (SessionStartedAt, UserAgent, IPAddress, DeviceLabel)
+
+-- Add session metadata columns
+ALTER TABLE refresh_tokens ADD COLUMN session_started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE refresh_tokens ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip_address VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN device_label VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Sessions started before this migration are dated by their oldest token
+UPDATE refresh_tokens rt
+SET session_started_at = family.started_at
+FROM (
+    SELECT family_id, MIN(created_at) AS started_at
+    FROM refresh_tokens
+    GROUP BY family_id
+) family
+WHERE rt.family_id = family.family_id;
+
+ALTER TABLE refresh_tokens ALTER COLUMN session_started_at SET NOT NULL;
+
+-- Index for listing a user's active sessions
+CREATE INDEX idx_refresh_tokens_user_active ON refresh_tokens(user_id, expires_at) WHERE revoked = false;
@@ -0,0 +1,9 @@
+-- Drop signing_keys table and related objects
+-- This reverses the changes made in 000005_create_signing_keys_table.up.sql
+
+-- Drop indexes (they will be dropped automatically with the table, but explicit for clarity)
+DROP INDEX IF EXISTS idx_signing_keys_single_active;
+DROP INDEX IF EXISTS idx_signing_keys_status;
+
+-- Drop signing_keys table
+DROP TABLE IF EXISTS signing_keys;
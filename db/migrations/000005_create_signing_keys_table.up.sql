@@ -0,0 +1,20 @@
+-- Create signing_keys table
+-- Based on SigningKey struct
+
+-- Create signing_keys table
+CREATE TABLE signing_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rotated_at TIMESTAMP WITH TIME ZONE,
+    retire_after TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT chk_signing_keys_status CHECK (status IN ('active', 'verify', 'retired'))
+);
+
+-- Create index for loading usable keys (corresponds to GORM index tag)
+CREATE INDEX idx_signing_keys_status ON signing_keys(status);
+
+-- At most one key may sign at a time, even with several replicas rotating
+CREATE UNIQUE INDEX idx_signing_keys_single_active ON signing_keys(status) WHERE status = 'active';
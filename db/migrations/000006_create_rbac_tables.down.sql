@@ -0,0 +1,13 @@
+-- Drop role based access control tables
+-- This reverses the changes made in 000006_create_rbac_tables.up.sql
+
+-- Drop join tables first
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_roles_updated_at ON roles;
+
+-- Drop tables
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,68 @@
+-- Create role based access control tables
+-- Based on Role, Permission and UserRole structs
+
+-- Create roles table
+CREATE TABLE roles (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_roles_name ON roles(name);
+
+CREATE TRIGGER update_roles_updated_at
+    BEFORE UPDATE ON roles
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Create permissions table
+CREATE TABLE permissions (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_permissions_name ON permissions(name);
+
+-- Create role_permissions join table (corresponds to GORM many2many:role_permissions)
+CREATE TABLE role_permissions (
+    role_id VARCHAR(36) NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id VARCHAR(36) NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE INDEX idx_role_permissions_permission_id ON role_permissions(permission_id);
+
+-- Create user_roles table
+CREATE TABLE user_roles (
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id VARCHAR(36) NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX idx_user_roles_role_id ON user_roles(role_id);
+
+-- Seed the built-in roles (constants.RoleUser, constants.RoleAdmin)
+INSERT INTO roles (id, name, description) VALUES
+    (uuid_generate_v4()::text, 'user', 'Default role of every registered user'),
+    (uuid_generate_v4()::text, 'admin', 'Administrator with access to every admin endpoint');
+
+-- Seed the built-in permissions (constants.Perm*)
+INSERT INTO permissions (id, name, description) VALUES
+    (uuid_generate_v4()::text, 'users:read', 'Read any user account'),
+    (uuid_generate_v4()::text, 'users:write', 'Modify any user account'),
+    (uuid_generate_v4()::text, 'roles:read', 'List roles, permissions and role assignments'),
+    (uuid_generate_v4()::text, 'roles:write', 'Create roles and change their permissions'),
+    (uuid_generate_v4()::text, 'roles:assign', 'Assign and revoke roles of users');
+
+-- Admins get every built-in permission
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin';
+
+-- Existing users get the default role
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u CROSS JOIN roles r WHERE r.name = 'user' AND u.is_deleted = false;
@@ -0,0 +1,6 @@
+-- Remove role inheritance and the authorization check permission
+-- This reverses the changes made in 000007_add_role_inheritance.up.sql
+
+DELETE FROM permissions WHERE name = 'authz:check';
+
+DROP TABLE IF EXISTS role_inherits;
@@ -0,0 +1,19 @@
+-- Add role inheritance and the authorization check permission
+-- A role holds the permissions of every role it inherits from
+
+-- Create role_inherits join table (corresponds to GORM many2many:role_inherits)
+CREATE TABLE role_inherits (
+    role_id VARCHAR(36) NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    inherited_role_id VARCHAR(36) NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, inherited_role_id),
+    CONSTRAINT chk_role_inherits_not_self CHECK (role_id <> inherited_role_id)
+);
+
+CREATE INDEX idx_role_inherits_inherited_role_id ON role_inherits(inherited_role_id);
+
+-- Callers of POST /api/v1/authz/check asking about other users need authz:check
+INSERT INTO permissions (id, name, description) VALUES
+    (uuid_generate_v4()::text, 'authz:check', 'Check the permissions of any user');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin' AND p.name = 'authz:check';
@@ -0,0 +1,8 @@
+-- Drop relation tuple tables and their permissions
+-- This reverses the changes made in 000008_create_relation_tuples.up.sql
+
+DELETE FROM permissions WHERE name IN ('relations:read', 'relations:write');
+
+-- Drop tables
+DROP TABLE IF EXISTS relation_tuple_revisions;
+DROP TABLE IF EXISTS relation_tuples;
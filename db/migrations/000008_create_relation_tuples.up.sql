@@ -0,0 +1,41 @@
+-- Create relation tuple tables for relationship based access control
+-- Tuples are versioned by revision so reads can be evaluated at a snapshot
+
+-- Create relation_tuples table. A tuple is live while deleted_revision is NULL
+-- and visible at revision R when created_revision <= R < deleted_revision.
+CREATE TABLE relation_tuples (
+    id BIGSERIAL PRIMARY KEY,
+    namespace VARCHAR(64) NOT NULL,
+    object_id VARCHAR(255) NOT NULL,
+    relation VARCHAR(64) NOT NULL,
+    subject_namespace VARCHAR(64) NOT NULL,
+    subject_object_id VARCHAR(255) NOT NULL,
+    subject_relation VARCHAR(64) NOT NULL DEFAULT '',
+    created_revision BIGINT NOT NULL,
+    deleted_revision BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A tuple can only be live once
+CREATE UNIQUE INDEX idx_relation_tuples_live ON relation_tuples(namespace, object_id, relation, subject_namespace, subject_object_id, subject_relation)
+    WHERE deleted_revision IS NULL;
+
+CREATE INDEX idx_relation_tuples_object ON relation_tuples(namespace, object_id, relation, created_revision);
+
+-- Create relation_tuple_revisions table holding the single revision counter.
+-- Writers increment it in their transaction, which serialises them.
+CREATE TABLE relation_tuple_revisions (
+    id SMALLINT PRIMARY KEY CHECK (id = 1),
+    revision BIGINT NOT NULL
+);
+
+INSERT INTO relation_tuple_revisions (id, revision) VALUES (1, 0);
+
+-- Writing tuples needs relations:write; reading, expanding and checking on
+-- behalf of other users needs relations:read
+INSERT INTO permissions (id, name, description) VALUES
+    (uuid_generate_v4()::text, 'relations:read', 'Read, expand and check relation tuples'),
+    (uuid_generate_v4()::text, 'relations:write', 'Write and delete relation tuples');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin' AND p.name IN ('relations:read', 'relations:write');
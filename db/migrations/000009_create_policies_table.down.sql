@@ -0,0 +1,10 @@
+-- Drop policies table and its permissions
+-- This reverses the changes made in 000009_create_policies_table.up.sql
+
+DELETE FROM permissions WHERE name IN ('policies:read', 'policies:write', 'policies:evaluate');
+
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_policies_updated_at ON policies;
+
+-- Drop table
+DROP TABLE IF EXISTS policies;
@@ -0,0 +1,30 @@
+-- Create policies table for the attribute based policy engine
+-- Based on Policy struct
+
+-- Create policies table. document holds the JSON policy; policies are
+-- evaluated after those loaded from POLICY_DIR, by priority then id.
+CREATE TABLE policies (
+    id VARCHAR(100) PRIMARY KEY,
+    document TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_policies_priority ON policies(priority, id) WHERE enabled = TRUE;
+
+CREATE TRIGGER update_policies_updated_at
+    BEFORE UPDATE ON policies
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Managing policies needs policies:read and policies:write; asking for a
+-- decision needs policies:evaluate
+INSERT INTO permissions (id, name, description) VALUES
+    (uuid_generate_v4()::text, 'policies:read', 'List access policies'),
+    (uuid_generate_v4()::text, 'policies:write', 'Create, replace and delete access policies'),
+    (uuid_generate_v4()::text, 'policies:evaluate', 'Evaluate access policies');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin' AND p.name IN ('policies:read', 'policies:write', 'policies:evaluate');
@@ -0,0 +1,23 @@
+-- Drop multi-tenant organization tables
+-- This reverses the changes made in 000010_create_organizations.up.sql
+
+DELETE FROM permissions WHERE name IN ('orgs:read', 'orgs:write', 'members:read', 'members:write');
+DELETE FROM roles WHERE scope = 'organization';
+
+-- Drop the active organization of sessions
+DROP INDEX IF EXISTS idx_refresh_tokens_organization_id;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS organization_id;
+
+-- Drop membership tables first
+DROP TABLE IF EXISTS organization_member_roles;
+DROP TABLE IF EXISTS organization_members;
+
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_organizations_updated_at ON organizations;
+
+-- Drop table
+DROP TABLE IF EXISTS organizations;
+
+-- Drop role scopes
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS chk_roles_scope;
+ALTER TABLE roles DROP COLUMN IF EXISTS scope;
@@ -0,0 +1,75 @@
+-- Create multi-tenant organization tables
+-- Based on Organization, OrganizationMember and OrganizationMemberRole structs
+
+-- Roles are either assigned globally (user_roles) or within an organization
+-- (organization_member_roles)
+ALTER TABLE roles ADD COLUMN scope VARCHAR(20) NOT NULL DEFAULT 'global';
+ALTER TABLE roles ADD CONSTRAINT chk_roles_scope CHECK (scope IN ('global', 'organization'));
+
+-- Create organizations table
+CREATE TABLE organizations (
+    id VARCHAR(36) PRIMARY KEY,
+    slug VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_organizations_slug ON organizations(slug);
+
+CREATE TRIGGER update_organizations_updated_at
+    BEFORE UPDATE ON organizations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Create organization_members table. Users may belong to many organizations.
+CREATE TABLE organization_members (
+    organization_id VARCHAR(36) NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+-- Create organization_member_roles table. Removing a member removes their
+-- roles in the organization.
+CREATE TABLE organization_member_roles (
+    organization_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    role_id VARCHAR(36) NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id, role_id),
+    FOREIGN KEY (organization_id, user_id) REFERENCES organization_members(organization_id, user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_organization_member_roles_role_id ON organization_member_roles(role_id);
+
+-- The organization a session is working in, carried by its access tokens as
+-- org_id
+ALTER TABLE refresh_tokens ADD COLUMN organization_id VARCHAR(36) REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_refresh_tokens_organization_id ON refresh_tokens(organization_id);
+
+-- Seed the built-in organization roles (constants.RoleOrgAdmin, constants.RoleOrgMember)
+INSERT INTO roles (id, name, description, scope) VALUES
+    (uuid_generate_v4()::text, 'org_admin', 'Manages the members of an organization', 'organization'),
+    (uuid_generate_v4()::text, 'org_member', 'Member of an organization', 'organization');
+
+-- Managing organizations needs orgs:read and orgs:write. members:read and
+-- members:write only count when granted by an organization role, and then
+-- only within that organization.
+INSERT INTO permissions (id, name, description) VALUES
+    (uuid_generate_v4()::text, 'orgs:read', 'List organizations and the members of any organization'),
+    (uuid_generate_v4()::text, 'orgs:write', 'Create organizations and manage the members of any organization'),
+    (uuid_generate_v4()::text, 'members:read', 'List the members of the organization'),
+    (uuid_generate_v4()::text, 'members:write', 'Add, change and remove members of the organization');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin' AND p.name IN ('orgs:read', 'orgs:write');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'org_admin' AND p.name IN ('members:read', 'members:write');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'org_member' AND p.name = 'members:read';
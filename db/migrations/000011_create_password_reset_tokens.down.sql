@@ -0,0 +1,8 @@
+-- Drop password_reset_tokens table
+-- This reverses the changes made in 000011_create_password_reset_tokens.up.sql
+
+DROP INDEX IF EXISTS idx_password_reset_tokens_expires_at;
+DROP INDEX IF EXISTS idx_password_reset_tokens_user_id;
+DROP INDEX IF EXISTS idx_password_reset_tokens_token_hash;
+
+DROP TABLE IF EXISTS password_reset_tokens;
@@ -0,0 +1,4 @@
+-- Drop users.password_changed_at
+-- This reverses the changes made in 000012_add_users_password_changed_at.up.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
@@ -0,0 +1,5 @@
+-- Record when users last changed their password
+-- Based on User struct
+
+-- Access tokens issued before password_changed_at are rejected
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP WITH TIME ZONE;
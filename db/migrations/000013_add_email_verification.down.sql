@@ -0,0 +1,10 @@
+-- Drop email verification
+-- This reverses the changes made in 000013_add_email_verification.up.sql
+
+DROP INDEX IF EXISTS idx_email_verification_tokens_expires_at;
+DROP INDEX IF EXISTS idx_email_verification_tokens_user_id;
+DROP INDEX IF EXISTS idx_email_verification_tokens_token_hash;
+
+DROP TABLE IF EXISTS email_verification_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,24 @@
+-- Add email verification
+-- Based on User and EmailVerificationToken structs
+
+-- Existing accounts are treated as verified so that requiring verification
+-- does not lock them out
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+UPDATE users SET email_verified_at = created_at;
+
+-- Only a hash of a token is stored, v<version>$<HMAC-SHA256> under the token
+-- hash key or bare SHA-256 without one. used_at is set once the token
+-- verifies the email or a newer token is issued for the user.
+CREATE TABLE email_verification_tokens (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_email_verification_tokens_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_email_verification_tokens_token_hash ON email_verification_tokens(token_hash);
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
+CREATE INDEX idx_email_verification_tokens_expires_at ON email_verification_tokens(expires_at);
@@ -0,0 +1,9 @@
+-- Drop TOTP two-factor authentication tables
+-- This reverses the changes made in 000014_create_mfa_tables.up.sql
+
+DROP INDEX IF EXISTS idx_mfa_recovery_codes_user_id;
+DROP INDEX IF EXISTS idx_mfa_recovery_codes_code_hash;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+DROP TRIGGER IF EXISTS update_user_mfa_updated_at ON user_mfa;
+DROP TABLE IF EXISTS user_mfa;
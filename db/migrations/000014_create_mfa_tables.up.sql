@@ -0,0 +1,34 @@
+-- Create TOTP two-factor authentication tables
+-- Based on UserMFA and MFARecoveryCode structs
+
+-- One TOTP factor per user. secret is encrypted with MFA_ENCRYPTION_SECRET;
+-- the factor is enforced once enabled_at is set by the confirmation step.
+CREATE TABLE user_mfa (
+    user_id VARCHAR(36) PRIMARY KEY,
+    secret TEXT NOT NULL,
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    last_counter BIGINT NOT NULL DEFAULT 0,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user_mfa_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER update_user_mfa_updated_at
+    BEFORE UPDATE ON user_mfa
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE mfa_recovery_codes (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_mfa_recovery_codes_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_mfa_recovery_codes_code_hash ON mfa_recovery_codes(code_hash);
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
@@ -0,0 +1,11 @@
+-- Drop WebAuthn (passkey) tables
+-- This reverses the changes made in 000015_create_webauthn_tables.up.sql
+
+DROP INDEX IF EXISTS idx_webauthn_challenges_expires_at;
+DROP INDEX IF EXISTS idx_webauthn_challenges_user_id;
+DROP INDEX IF EXISTS idx_webauthn_challenges_challenge_hash;
+DROP TABLE IF EXISTS webauthn_challenges;
+
+DROP INDEX IF EXISTS idx_webauthn_credentials_user_id;
+DROP INDEX IF EXISTS idx_webauthn_credentials_credential_id;
+DROP TABLE IF EXISTS webauthn_credentials;
@@ -0,0 +1,40 @@
+-- Create WebAuthn (passkey) tables
+-- Based on WebAuthnCredential and WebAuthnChallenge structs
+
+-- Registered credentials. credential_id is the base64url encoded ID chosen
+-- by the authenticator, public_key the COSE encoded credential key.
+CREATE TABLE webauthn_credentials (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    credential_id VARCHAR(1400) NOT NULL,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid VARCHAR(36),
+    attestation_format VARCHAR(32),
+    transports VARCHAR(255),
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    name VARCHAR(100),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_webauthn_credentials_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_webauthn_credentials_credential_id ON webauthn_credentials(credential_id);
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+-- Single-use ceremony challenges, stored as SHA-256 hashes. user_id is NULL
+-- for passwordless logins.
+CREATE TABLE webauthn_challenges (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36),
+    ceremony VARCHAR(16) NOT NULL,
+    challenge_hash TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_webauthn_challenges_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_webauthn_challenges_challenge_hash ON webauthn_challenges(challenge_hash);
+CREATE INDEX idx_webauthn_challenges_user_id ON webauthn_challenges(user_id);
+CREATE INDEX idx_webauthn_challenges_expires_at ON webauthn_challenges(expires_at);
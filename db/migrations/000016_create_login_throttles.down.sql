@@ -0,0 +1,5 @@
+-- Drop failed login tracking table
+-- This reverses the changes made in 000016_create_login_throttles.up.sql
+
+DROP INDEX IF EXISTS idx_login_throttles_last_failed_at;
+DROP TABLE IF EXISTS login_throttles;
@@ -0,0 +1,16 @@
+-- Create failed login tracking table
+-- Based on LoginThrottle struct
+
+-- Failed password logins per username (scope 'account') and per client IP
+-- address (scope 'ip'). Usernames are tracked whether or not an account
+-- exists, so lockouts reveal nothing about which accounts exist.
+CREATE TABLE login_throttles (
+    scope VARCHAR(16) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    blocked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE INDEX idx_login_throttles_last_failed_at ON login_throttles(last_failed_at);
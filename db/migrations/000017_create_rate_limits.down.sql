@@ -0,0 +1,5 @@
+-- Drop rate limit state table
+-- This reverses the changes made in 000017_create_rate_limits.up.sql
+
+DROP INDEX IF EXISTS idx_rate_limits_expires_at;
+DROP TABLE IF EXISTS rate_limits;
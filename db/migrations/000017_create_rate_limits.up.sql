@@ -0,0 +1,14 @@
+-- Create rate limit state table
+-- Based on RateLimit struct
+
+-- Rate limit state shared by all nodes with RATE_LIMIT_STORE=database. Rows
+-- past expires_at hold no state worth keeping and are cleared out.
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    previous DOUBLE PRECISION NOT NULL DEFAULT 0,
+    window_time TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_expires_at ON rate_limits(expires_at);
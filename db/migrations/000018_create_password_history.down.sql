@@ -0,0 +1,6 @@
+-- Drop password_history table
+-- This reverses the changes made in 000018_create_password_history.up.sql
+
+DROP INDEX IF EXISTS idx_password_history_user_id;
+
+DROP TABLE IF EXISTS password_history;
@@ -0,0 +1,14 @@
+-- Create password_history table
+-- Based on PasswordHistory struct
+
+-- Earlier password hashes of each user, so that the password policy can
+-- reject reused passwords. Rows beyond PASSWORD_HISTORY per user are deleted.
+CREATE TABLE password_history (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_password_history_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_password_history_user_id ON password_history(user_id);
@@ -0,0 +1,5 @@
+-- Drop access token denylist table
+-- This reverses the changes made in 000019_create_access_token_denylist.up.sql
+
+DROP INDEX IF EXISTS idx_access_token_denylist_expires_at;
+DROP TABLE IF EXISTS access_token_denylist;
@@ -0,0 +1,12 @@
+-- Create access token denylist table
+-- Based on AccessTokenDenial struct
+
+-- Revoked access tokens and sessions, checked by every authenticated request
+-- with ACCESS_TOKEN_DENYLIST_STORE=database. Rows past expires_at only match
+-- tokens that have expired anyway and are cleared out.
+CREATE TABLE access_token_denylist (
+    key TEXT PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_access_token_denylist_expires_at ON access_token_denylist(expires_at);
@@ -0,0 +1,4 @@
+-- Drop users.disabled_at
+-- This reverses the changes made in 000020_add_users_disabled_at.up.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
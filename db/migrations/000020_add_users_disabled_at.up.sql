@@ -0,0 +1,5 @@
+-- Record when administrators disabled users
+-- Based on User struct
+
+-- Disabled users cannot log in and their sessions are revoked
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
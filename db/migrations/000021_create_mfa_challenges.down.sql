@@ -0,0 +1,5 @@
+-- Drop MFA challenges table
+-- This reverses the changes made in 000021_create_mfa_challenges.up.sql
+
+DROP INDEX IF EXISTS idx_mfa_challenges_expires_at;
+DROP TABLE IF EXISTS mfa_challenges;
@@ -0,0 +1,15 @@
+-- Create MFA challenges table
+-- Based on MFAChallenge struct
+
+-- Attempts made with each MFA challenge token, by its jti. A challenge
+-- allows a few attempts and completes one login. Rows past expires_at belong
+-- to expired challenges and are cleared out.
+CREATE TABLE mfa_challenges (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL,
+    consumed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges(expires_at);
@@ -0,0 +1,211 @@
+package authz
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store provides the role definitions and assignments the evaluator works on
+type Store interface {
+	// Roles returns every role with its inheritance and direct grants
+	Roles() ([]RoleDefinition, error)
+	// UserRoles returns the names of the roles assigned to the user
+	UserRoles(userID string) ([]string, error)
+}
+
+// Grants is what a user is allowed through their roles
+type Grants struct {
+	// AssignedRoles are the roles assigned to the user directly
+	AssignedRoles []string
+	// Roles are the assigned roles and every role they inherit from
+	Roles []string
+	// Permissions are the grants of Roles, which may contain wildcards
+	Permissions []string
+
+	set    *PermissionSet
+	source map[string]string
+}
+
+// Decision is the answer to a permission check. When allowed, Grant and Role
+// tell which grant of which role covered the permission.
+type Decision struct {
+	Allowed bool
+	Grant   string
+	Role    string
+}
+
+// Check decides whether the grants cover permission
+func (g *Grants) Check(permission string) Decision {
+	grant, ok := g.set.Match(permission)
+	if !ok {
+		return Decision{}
+	}
+	return Decision{Allowed: true, Grant: grant, Role: g.source[grant]}
+}
+
+// Evaluator resolves and caches the grants of users. Cached entries expire
+// after the TTL, so changes made by other processes are picked up; changes
+// made through this process should call InvalidateUser or InvalidateAll.
+type Evaluator struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu         sync.Mutex
+	generation uint64
+	hierarchy  *Hierarchy
+	loadedAt   time.Time
+	users      map[string]cachedGrants
+	// userGenerations count the invalidations of each user since the last
+	// InvalidateAll
+	userGenerations map[string]uint64
+}
+
+type cachedGrants struct {
+	grants     *Grants
+	resolvedAt time.Time
+}
+
+// NewEvaluator creates an evaluator caching resolved grants for ttl. A ttl of
+// zero disables caching.
+func NewEvaluator(store Store, ttl time.Duration) *Evaluator {
+	return &Evaluator{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+		users: make(map[string]cachedGrants),
+
+		userGenerations: make(map[string]uint64),
+	}
+}
+
+// Resolve returns the grants of the user
+func (e *Evaluator) Resolve(userID string) (*Grants, error) {
+	e.mu.Lock()
+	generation, userGeneration := e.generation, e.userGenerations[userID]
+	if cached, ok := e.users[userID]; ok && e.fresh(cached.resolvedAt) {
+		e.mu.Unlock()
+		return cached.grants, nil
+	}
+	e.mu.Unlock()
+
+	hierarchy, err := e.loadHierarchy()
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := e.store.UserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	grants := newGrants(hierarchy, assigned)
+
+	// Do not cache what was resolved before an invalidation, of every user
+	// or of this one
+	e.mu.Lock()
+	if e.ttl > 0 && e.generation == generation && e.userGenerations[userID] == userGeneration {
+		e.users[userID] = cachedGrants{grants: grants, resolvedAt: e.now()}
+	}
+	e.mu.Unlock()
+
+	return grants, nil
+}
+
+// ResolveRoles returns the grants of holding the given roles, such as the
+// roles a user holds within an organization. The result is not cached.
+func (e *Evaluator) ResolveRoles(assigned ...string) (*Grants, error) {
+	hierarchy, err := e.loadHierarchy()
+	if err != nil {
+		return nil, err
+	}
+	return newGrants(hierarchy, append([]string(nil), assigned...)), nil
+}
+
+// Check decides whether the user holds permission
+func (e *Evaluator) Check(userID, permission string) (Decision, error) {
+	grants, err := e.Resolve(userID)
+	if err != nil {
+		return Decision{}, err
+	}
+	return grants.Check(permission), nil
+}
+
+// Hierarchy returns the current role hierarchy
+func (e *Evaluator) Hierarchy() (*Hierarchy, error) {
+	return e.loadHierarchy()
+}
+
+// InvalidateUser drops the cached grants of the user, after their role
+// assignments changed. Grants of the user still being resolved are not
+// cached either, since they may have been read before the change.
+func (e *Evaluator) InvalidateUser(userID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.userGenerations[userID]++
+	delete(e.users, userID)
+}
+
+// InvalidateAll drops every cached entry, after roles, their grants or their
+// inheritance changed
+func (e *Evaluator) InvalidateAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.generation++
+	e.hierarchy = nil
+	e.users = make(map[string]cachedGrants)
+	e.userGenerations = make(map[string]uint64)
+}
+
+func (e *Evaluator) loadHierarchy() (*Hierarchy, error) {
+	e.mu.Lock()
+	generation := e.generation
+	if e.hierarchy != nil && e.fresh(e.loadedAt) {
+		hierarchy := e.hierarchy
+		e.mu.Unlock()
+		return hierarchy, nil
+	}
+	e.mu.Unlock()
+
+	roles, err := e.store.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy, err := NewHierarchy(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if e.ttl > 0 && e.generation == generation {
+		e.hierarchy = hierarchy
+		e.loadedAt = e.now()
+	}
+	e.mu.Unlock()
+
+	return hierarchy, nil
+}
+
+func newGrants(hierarchy *Hierarchy, assigned []string) *Grants {
+	sort.Strings(assigned)
+
+	source := hierarchy.Permissions(assigned...)
+	permissions := make([]string, 0, len(source))
+	for grant := range source {
+		permissions = append(permissions, grant)
+	}
+
+	grants := &Grants{
+		AssignedRoles: assigned,
+		Roles:         hierarchy.EffectiveRoles(assigned...),
+		set:           NewPermissionSet(permissions...),
+		source:        source,
+	}
+	grants.Permissions = grants.set.Grants()
+	return grants
+}
+
+func (e *Evaluator) fresh(at time.Time) bool {
+	return e.ttl > 0 && e.now().Sub(at) < e.ttl
+}
@@ -0,0 +1,162 @@
+package authz
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	roles     []RoleDefinition
+	userRoles map[string][]string
+	loads     int
+	// afterUserRoles runs once the assignments of a user have been read
+	afterUserRoles func()
+}
+
+func (s *fakeStore) Roles() ([]RoleDefinition, error) {
+	s.loads++
+	return s.roles, nil
+}
+
+func (s *fakeStore) UserRoles(userID string) ([]string, error) {
+	roles := s.userRoles[userID]
+	if s.afterUserRoles != nil {
+		s.afterUserRoles()
+	}
+	return roles, nil
+}
+
+func TestEvaluator_CachesUntilInvalidated(t *testing.T) {
+	// Setup
+	store := &fakeStore{
+		roles: []RoleDefinition{
+			{Name: "viewer", Permissions: []string{"billing:invoices:read"}},
+			{Name: "accountant", Inherits: []string{"viewer"}, Permissions: []string{"billing:*"}},
+		},
+		userRoles: map[string][]string{"user-1": {"viewer"}},
+	}
+	evaluator := NewEvaluator(store, time.Hour)
+
+	decision, err := evaluator.Check("user-1", "billing:invoices:read")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !decision.Allowed || decision.Role != "viewer" || decision.Grant != "billing:invoices:read" {
+		t.Errorf("Unexpected decision: %+v", decision)
+	}
+
+	// Execute: a cached user does not see a new assignment until invalidated
+	store.userRoles["user-1"] = []string{"accountant"}
+
+	decision, _ = evaluator.Check("user-1", "billing:invoices:void")
+	if decision.Allowed {
+		t.Error("Expected cached grants to be used before invalidation")
+	}
+
+	evaluator.InvalidateUser("user-1")
+	decision, _ = evaluator.Check("user-1", "billing:invoices:void")
+
+	// Assert
+	if !decision.Allowed || decision.Grant != "billing:*" || decision.Role != "accountant" {
+		t.Errorf("Expected wildcard grant after invalidation, got %+v", decision)
+	}
+
+	if store.loads != 1 {
+		t.Errorf("Expected the role hierarchy to be loaded once, got %d", store.loads)
+	}
+
+	evaluator.InvalidateAll()
+	if _, err := evaluator.Resolve("user-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if store.loads != 2 {
+		t.Errorf("Expected the role hierarchy to be reloaded after InvalidateAll, got %d loads", store.loads)
+	}
+}
+
+func TestEvaluator_InvalidateDuringResolve(t *testing.T) {
+	// Setup
+	store := &fakeStore{
+		roles: []RoleDefinition{
+			{Name: "viewer", Permissions: []string{"docs:read"}},
+			{Name: "editor", Permissions: []string{"docs:write"}},
+		},
+		userRoles: map[string][]string{"user-1": {"viewer"}},
+	}
+	evaluator := NewEvaluator(store, time.Hour)
+
+	// Execute: the assignment changes after a resolve read the old one
+	store.afterUserRoles = func() {
+		store.afterUserRoles = nil
+		store.userRoles["user-1"] = []string{"editor"}
+		evaluator.InvalidateUser("user-1")
+	}
+	if _, err := evaluator.Resolve("user-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assert: what that resolve read was not cached
+	decision, err := evaluator.Check("user-1", "docs:write")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !decision.Allowed {
+		t.Error("Expected grants resolved before an invalidation not to be cached")
+	}
+}
+
+func TestEvaluator_Expires(t *testing.T) {
+	// Setup
+	store := &fakeStore{
+		roles:     []RoleDefinition{{Name: "viewer", Permissions: []string{"docs:read"}}},
+		userRoles: map[string][]string{},
+	}
+	evaluator := NewEvaluator(store, time.Minute)
+	now := time.Now()
+	evaluator.now = func() time.Time { return now }
+
+	if decision, _ := evaluator.Check("user-1", "docs:read"); decision.Allowed {
+		t.Fatal("Expected user without roles to be denied")
+	}
+
+	// Execute
+	store.userRoles["user-1"] = []string{"viewer"}
+	now = now.Add(2 * time.Minute)
+	decision, _ := evaluator.Check("user-1", "docs:read")
+
+	// Assert
+	if !decision.Allowed {
+		t.Error("Expected expired cache entry to be resolved again")
+	}
+}
+
+func TestEvaluator_ResolveRoles(t *testing.T) {
+	// Setup
+	store := &fakeStore{
+		roles: []RoleDefinition{
+			{Name: "member", Permissions: []string{"members:read"}},
+			{Name: "owner", Inherits: []string{"member"}, Permissions: []string{"members:write"}},
+		},
+		userRoles: map[string][]string{},
+	}
+	evaluator := NewEvaluator(store, time.Hour)
+
+	// Execute
+	grants, err := evaluator.ResolveRoles("owner")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decision := grants.Check("members:read"); !decision.Allowed || decision.Role != "member" {
+		t.Errorf("Expected the inherited grant, got %+v", decision)
+	}
+	if len(grants.Roles) != 2 || len(grants.AssignedRoles) != 1 {
+		t.Errorf("Expected owner and member, got %v", grants.Roles)
+	}
+
+	grants, _ = evaluator.ResolveRoles()
+	if grants.Check("members:read").Allowed {
+		t.Error("Expected no grants without roles")
+	}
+}
@@ -0,0 +1,142 @@
+package authz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoleDefinition is a role, the roles it inherits from and the grants it
+// holds directly
+type RoleDefinition struct {
+	Name        string
+	Inherits    []string
+	Permissions []string
+}
+
+// CycleError reports a role that ends up inheriting from itself
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "role inheritance cycle: " + strings.Join(e.Path, " -> ")
+}
+
+// Hierarchy is an acyclic role inheritance graph. A role holds the grants of
+// every role it inherits from, directly or transitively.
+type Hierarchy struct {
+	roles map[string]*RoleDefinition
+	// ancestors[role] is the role itself and every role it inherits from
+	ancestors map[string][]string
+}
+
+// NewHierarchy builds the hierarchy of roles, failing with a *CycleError if
+// inheritance is cyclic or an error if a role inherits from an unknown role
+func NewHierarchy(roles []RoleDefinition) (*Hierarchy, error) {
+	h := &Hierarchy{
+		roles:     make(map[string]*RoleDefinition, len(roles)),
+		ancestors: make(map[string][]string, len(roles)),
+	}
+	for i := range roles {
+		h.roles[roles[i].Name] = &roles[i]
+	}
+
+	for _, role := range roles {
+		for _, parent := range role.Inherits {
+			if _, ok := h.roles[parent]; !ok {
+				return nil, fmt.Errorf("role %q inherits from unknown role %q", role.Name, parent)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(roles))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			// Report the cycle starting from its first role
+			for i, role := range path {
+				if role == name {
+					return &CycleError{Path: append(append([]string(nil), path[i:]...), name)}
+				}
+			}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+
+		seen := map[string]bool{name: true}
+		ancestors := []string{name}
+		for _, parent := range h.roles[name].Inherits {
+			if err := visit(parent); err != nil {
+				return err
+			}
+			for _, ancestor := range h.ancestors[parent] {
+				if !seen[ancestor] {
+					seen[ancestor] = true
+					ancestors = append(ancestors, ancestor)
+				}
+			}
+		}
+		sort.Strings(ancestors)
+		h.ancestors[name] = ancestors
+
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	names := make([]string, 0, len(roles))
+	for name := range h.roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// EffectiveRoles returns the given roles and every role they inherit from,
+// sorted. Unknown roles are left out.
+func (h *Hierarchy) EffectiveRoles(roles ...string) []string {
+	seen := make(map[string]bool)
+	var effective []string
+	for _, role := range roles {
+		for _, ancestor := range h.ancestors[role] {
+			if !seen[ancestor] {
+				seen[ancestor] = true
+				effective = append(effective, ancestor)
+			}
+		}
+	}
+	sort.Strings(effective)
+	return effective
+}
+
+// Permissions returns the grants held through roles, keyed by grant with the
+// role holding it directly as value
+func (h *Hierarchy) Permissions(roles ...string) map[string]string {
+	grants := make(map[string]string)
+	for _, role := range h.EffectiveRoles(roles...) {
+		for _, grant := range h.roles[role].Permissions {
+			if _, ok := grants[grant]; !ok {
+				grants[grant] = role
+			}
+		}
+	}
+	return grants
+}
@@ -0,0 +1,60 @@
+package authz
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHierarchy_Inheritance(t *testing.T) {
+	// Setup
+	hierarchy, err := NewHierarchy([]RoleDefinition{
+		{Name: "viewer", Permissions: []string{"docs:read"}},
+		{Name: "editor", Inherits: []string{"viewer"}, Permissions: []string{"docs:write"}},
+		{Name: "admin", Inherits: []string{"editor"}, Permissions: []string{"docs:*"}},
+		{Name: "billing", Permissions: []string{"billing:*"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Execute
+	roles := hierarchy.EffectiveRoles("admin")
+	grants := hierarchy.Permissions("admin")
+
+	// Assert
+	if expected := []string{"admin", "editor", "viewer"}; !reflect.DeepEqual(roles, expected) {
+		t.Errorf("Expected roles %v, got %v", expected, roles)
+	}
+
+	expected := map[string]string{"docs:read": "viewer", "docs:write": "editor", "docs:*": "admin"}
+	if !reflect.DeepEqual(grants, expected) {
+		t.Errorf("Expected grants %v, got %v", expected, grants)
+	}
+}
+
+func TestHierarchy_DetectsCycles(t *testing.T) {
+	_, err := NewHierarchy([]RoleDefinition{
+		{Name: "viewer", Inherits: []string{"admin"}},
+		{Name: "editor", Inherits: []string{"viewer"}},
+		{Name: "admin", Inherits: []string{"editor"}},
+	})
+
+	var cycle *CycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("Expected cycle error, got %v", err)
+	}
+
+	if len(cycle.Path) != 4 || cycle.Path[0] != cycle.Path[3] {
+		t.Errorf("Expected a closed path through all three roles, got %v", cycle.Path)
+	}
+}
+
+func TestHierarchy_DetectsSelfInheritance(t *testing.T) {
+	_, err := NewHierarchy([]RoleDefinition{{Name: "admin", Inherits: []string{"admin"}}})
+
+	var cycle *CycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("Expected cycle error, got %v", err)
+	}
+}
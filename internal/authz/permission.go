@@ -0,0 +1,126 @@
+// Package authz evaluates hierarchical permissions such as
+// "billing:invoices:read" against the grants a user holds through roles.
+//
+// A grant is a permission string whose segments may be "*". A "*" in the
+// last segment matches the rest of the permission, however many segments
+// that is, while a "*" elsewhere matches exactly one segment:
+//
+//	billing:*            matches billing:invoices and billing:invoices:read
+//	billing:*:read       matches billing:invoices:read but not billing:read
+//	*                    matches everything
+package authz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Separator separates the segments of a permission
+const Separator = ":"
+
+// Wildcard is the segment matching any segment
+const Wildcard = "*"
+
+// ValidatePermission checks that name is a well formed permission or grant:
+// non-empty segments of lowercase letters, digits, '_', '-' and '.', or a
+// lone "*"
+func ValidatePermission(name string) error {
+	if name == "" {
+		return fmt.Errorf("permission must not be empty")
+	}
+	if len(name) > 255 {
+		return fmt.Errorf("permission must be at most 255 characters long")
+	}
+
+	for _, segment := range strings.Split(name, Separator) {
+		if segment == Wildcard {
+			continue
+		}
+		if segment == "" {
+			return fmt.Errorf("permission %q has an empty segment", name)
+		}
+		for _, c := range segment {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+				return fmt.Errorf("permission %q contains invalid character %q", name, c)
+			}
+		}
+	}
+	return nil
+}
+
+// Match reports whether grant covers permission
+func Match(grant, permission string) bool {
+	if grant == permission {
+		return true
+	}
+	return matchSegments(strings.Split(grant, Separator), strings.Split(permission, Separator))
+}
+
+func matchSegments(grant, permission []string) bool {
+	for i, segment := range grant {
+		if i >= len(permission) {
+			return false
+		}
+		if segment == Wildcard {
+			if i == len(grant)-1 {
+				return true
+			}
+			continue
+		}
+		if segment != permission[i] {
+			return false
+		}
+	}
+	return len(grant) == len(permission)
+}
+
+// PermissionSet is a set of grants optimised for repeated checks
+type PermissionSet struct {
+	exact     map[string]bool
+	wildcards [][]string
+	grants    []string
+}
+
+// NewPermissionSet builds a set from grants, ignoring duplicates
+func NewPermissionSet(grants ...string) *PermissionSet {
+	set := &PermissionSet{exact: make(map[string]bool, len(grants))}
+	for _, grant := range grants {
+		if set.exact[grant] {
+			continue
+		}
+		set.exact[grant] = true
+		set.grants = append(set.grants, grant)
+		if strings.Contains(grant, Wildcard) {
+			set.wildcards = append(set.wildcards, strings.Split(grant, Separator))
+		}
+	}
+	sort.Strings(set.grants)
+	return set
+}
+
+// Allows reports whether any grant in the set covers permission
+func (s *PermissionSet) Allows(permission string) bool {
+	_, ok := s.Match(permission)
+	return ok
+}
+
+// Match returns the grant covering permission, preferring an exact grant
+func (s *PermissionSet) Match(permission string) (string, bool) {
+	if s.exact[permission] {
+		return permission, true
+	}
+
+	segments := strings.Split(permission, Separator)
+	for _, grant := range s.wildcards {
+		if matchSegments(grant, segments) {
+			return strings.Join(grant, Separator), true
+		}
+	}
+	return "", false
+}
+
+// Grants returns the grants in the set, sorted
+func (s *PermissionSet) Grants() []string {
+	return append([]string(nil), s.grants...)
+}
@@ -0,0 +1,53 @@
+package authz
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		grant      string
+		permission string
+		expected   bool
+	}{
+		{"billing:invoices:read", "billing:invoices:read", true},
+		{"billing:invoices:read", "billing:invoices:write", false},
+		{"billing:invoices", "billing:invoices:read", false},
+		{"billing:*", "billing:invoices", true},
+		{"billing:*", "billing:invoices:read", true},
+		{"billing:*", "billing", false},
+		{"billing:*", "shipping:orders", false},
+		{"billing:*:read", "billing:invoices:read", true},
+		{"billing:*:read", "billing:invoices:write", false},
+		{"billing:*:read", "billing:read", false},
+		{"billing:*:read", "billing:invoices:lines:read", false},
+		{"*", "anything:at:all", true},
+		{"*:read", "billing:read", true},
+		{"*:read", "billing:invoices:read", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.grant, tt.permission); got != tt.expected {
+			t.Errorf("Match(%q, %q) = %v, expected %v", tt.grant, tt.permission, got, tt.expected)
+		}
+
+		// PermissionSet must agree with Match
+		if got := NewPermissionSet(tt.grant).Allows(tt.permission); got != tt.expected {
+			t.Errorf("PermissionSet(%q).Allows(%q) = %v, expected %v", tt.grant, tt.permission, got, tt.expected)
+		}
+	}
+}
+
+func TestValidatePermission(t *testing.T) {
+	valid := []string{"users:read", "billing:invoices:read", "billing:*", "*", "api.v2:keys:rotate"}
+	for _, name := range valid {
+		if err := ValidatePermission(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+
+	invalid := []string{"", "billing::read", "billing:", "Billing:read", "billing:inv*", "billing read"}
+	for _, name := range invalid {
+		if err := ValidatePermission(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
@@ -1,19 +1,44 @@
 package config
 
 import (
+	"authorization/internal/constants"
+	"authorization/internal/passwordpolicy"
+	"authorization/internal/ratelimit"
+	"authorization/internal/utils"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	AppEnv          string
-	Port            string
-	Database        DatabaseConfig
-	JWT             JWTConfig
+	AppEnv            string
+	Port              string
+	TrustedProxies    []netip.Prefix
+	Database          DatabaseConfig
+	JWT               JWTConfig
+	TokenHash         TokenHashConfig
+	Denylist          DenylistConfig
+	Authz             AuthzConfig
+	Relations         RelationsConfig
+	Policy            PolicyConfig
+	Registration      RegistrationConfig
+	PasswordHash      utils.PasswordParams
+	PasswordPepper    PasswordPepperConfig
+	PasswordPolicy    passwordpolicy.Policy
+	PasswordBreaches  PasswordBreachConfig
+	Notify            NotifyConfig
+	PasswordReset     PasswordResetConfig
+	EmailVerification EmailVerificationConfig
+	MFA               MFAConfig
+	WebAuthn          WebAuthnConfig
+	Lockout           LockoutConfig
+	RateLimit         RateLimitConfig
 }
 
 type DatabaseConfig struct {
@@ -25,10 +50,184 @@ type DatabaseConfig struct {
 	URL      string
 }
 
+// Key stores for JWT signing keys
+const (
+	KeyStoreStatic   = "static"
+	KeyStoreDatabase = "database"
+)
+
 type JWTConfig struct {
-	Secret           string
-	AccessTokenExp   time.Duration
-	RefreshTokenExp  time.Duration
+	Algorithm           string
+	Secret              string
+	PrivateKeyFile      string
+	KeyID               string
+	KeyStore            string
+	KeyEncryptionSecret string
+	KeyRotationInterval time.Duration
+	KeySyncInterval     time.Duration
+	AccessTokenExp      time.Duration
+	RefreshTokenExp     time.Duration
+	RefreshReuseGrace   time.Duration
+}
+
+type AuthzConfig struct {
+	// CacheTTL bounds how long resolved permissions are cached, and so how
+	// long role changes made by other replicas take to be seen
+	CacheTTL time.Duration
+}
+
+type RelationsConfig struct {
+	// SchemaFile is the JSON relation schema. Empty uses the built-in schema
+	// for groups, folders and documents.
+	SchemaFile string
+}
+
+type PolicyConfig struct {
+	// Dir holds JSON policy files, evaluated before stored policies. Empty
+	// loads stored policies only.
+	Dir string
+	// Algorithm combines the decisions of the loaded policies
+	Algorithm string
+	// ReloadInterval bounds how long policy changes made by other replicas
+	// take to be seen
+	ReloadInterval time.Duration
+}
+
+type RegistrationConfig struct {
+	// EnumerationSafe answers every registration with "check your email",
+	// so that it does not reveal which usernames and emails have accounts
+	EnumerationSafe bool
+}
+
+type TokenHashConfig struct {
+	// KeyFile holds the keys refresh, reset and verification tokens are
+	// hashed with by version; empty stores bare SHA-256 hashes
+	KeyFile string
+	// ActiveVersion is the version new hashes are made with, 0 for the
+	// highest in KeyFile
+	ActiveVersion int
+	// AcceptLegacy keeps matching tokens stored as bare SHA-256 hashes
+	// before KeyFile was set
+	AcceptLegacy bool
+}
+
+// Stores for the access token denylist
+const (
+	DenylistStoreMemory   = "memory"
+	DenylistStoreDatabase = "database"
+)
+
+type DenylistConfig struct {
+	// Store keeps revoked access tokens in memory, per node, or in the
+	// database, shared by all nodes
+	Store string
+}
+
+type PasswordPepperConfig struct {
+	// File holds the pepper secrets by version; empty disables peppering
+	File string
+	// ActiveVersion is the version new hashes are peppered with, 0 for the
+	// highest in File
+	ActiveVersion int
+}
+
+type PasswordBreachConfig struct {
+	// Corpus is a Pwned Passwords range dataset directory or a filter built
+	// from it with the pwnedfilter command; empty disables the check
+	Corpus string
+	// MinCount is how often a password must have been seen in breaches to
+	// be rejected
+	MinCount int
+}
+
+// Notifiers for account messages
+const (
+	NotifierLog     = "log"
+	NotifierWebhook = "webhook"
+)
+
+type NotifyConfig struct {
+	// Notifier delivers reset and verification links: log writes them to
+	// the application log, webhook posts them to WebhookURL
+	Notifier string
+	// LogLinks lets the log notifier include links, which work for anyone
+	// who can read the log. It is for development and refused in
+	// production.
+	LogLinks bool
+	// WebhookURL receives messages as JSON with the webhook notifier
+	WebhookURL string
+	// WebhookSecret signs webhook requests; empty leaves them unsigned
+	WebhookSecret string
+}
+
+type PasswordResetConfig struct {
+	// TokenExp is how long a reset link works
+	TokenExp time.Duration
+	// ResendInterval is the least time between two links sent to a user
+	ResendInterval time.Duration
+	// URL is the page that accepts reset links, which receives the token in
+	// its "token" query parameter
+	URL string
+}
+
+type EmailVerificationConfig struct {
+	// Required blocks login until the user has verified their email
+	Required bool
+	// TokenExp is how long a verification link works
+	TokenExp time.Duration
+	// ResendInterval is the least time between two links sent to a user
+	ResendInterval time.Duration
+	// URL is the page that accepts verification links, which receives the
+	// token in its "token" query parameter
+	URL string
+}
+
+type MFAConfig struct {
+	// EncryptionSecret encrypts TOTP secrets at rest. Two-factor
+	// authentication is unavailable while it is empty.
+	EncryptionSecret string
+	// Issuer is the name authenticator apps show next to the account
+	Issuer string
+}
+
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to. It must be the domain of the
+	// origins or a parent domain of it.
+	RPID string
+	// RPName is the name authenticators show when creating a passkey
+	RPName string
+	// Origins are the exact origins browser ceremonies may come from
+	Origins []string
+}
+
+// Stores for rate limit state
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStoreDatabase = "database"
+)
+
+type RateLimitConfig struct {
+	// Store keeps rate limit state in memory, per node, or in the database,
+	// shared by all nodes
+	Store string
+	// Policies are the rate limits of each route
+	Policies map[string]ratelimit.Policy
+}
+
+type LockoutConfig struct {
+	// BackoffAfter failed logins for a username delay each further attempt,
+	// starting at BackoffBase and doubling up to BackoffMax
+	BackoffAfter int
+	// Threshold failed logins for a username lock it for Duration
+	Threshold int
+	// IPBackoffAfter and IPThreshold are the same limits per client address
+	IPBackoffAfter int
+	IPThreshold    int
+	BackoffBase    time.Duration
+	BackoffMax     time.Duration
+	// Duration is how long a lockout lasts. Failed logins are forgotten once
+	// none failed for as long.
+	Duration time.Duration
 }
 
 func Load() (*Config, error) {
@@ -40,6 +239,11 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid DB_PORT: %w", err)
 	}
 
+	trustedProxies, err := parsePrefixes(getEnv("TRUSTED_PROXIES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
 	accessTokenStr := getEnv("ACCESS_TOKEN_EXP", "15m")
 	accessTokenExp, err := time.ParseDuration(accessTokenStr)
 	if err != nil {
@@ -52,9 +256,226 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid REFRESH_TOKEN_EXP: %w", err)
 	}
 
+	refreshReuseGraceStr := getEnv("REFRESH_REUSE_GRACE", "10s")
+	refreshReuseGrace, err := time.ParseDuration(refreshReuseGraceStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REFRESH_REUSE_GRACE: %w", err)
+	}
+
+	tokenHashVersionStr := getEnv("TOKEN_HASH_ACTIVE_VERSION", "0")
+	tokenHashVersion, err := strconv.Atoi(tokenHashVersionStr)
+	if err != nil || tokenHashVersion < 0 {
+		return nil, fmt.Errorf("invalid TOKEN_HASH_ACTIVE_VERSION: %s", tokenHashVersionStr)
+	}
+
+	tokenHashLegacyStr := getEnv("TOKEN_HASH_ACCEPT_LEGACY", "true")
+	tokenHashLegacy, err := strconv.ParseBool(tokenHashLegacyStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOKEN_HASH_ACCEPT_LEGACY: %s", tokenHashLegacyStr)
+	}
+
+	keyRotationStr := getEnv("JWT_KEY_ROTATION_INTERVAL", "720h")
+	keyRotationInterval, err := time.ParseDuration(keyRotationStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_KEY_ROTATION_INTERVAL: %w", err)
+	}
+
+	keySyncStr := getEnv("JWT_KEY_SYNC_INTERVAL", "1m")
+	keySyncInterval, err := time.ParseDuration(keySyncStr)
+	if err != nil || keySyncInterval <= 0 {
+		return nil, fmt.Errorf("invalid JWT_KEY_SYNC_INTERVAL: %s", keySyncStr)
+	}
+
+	authzCacheStr := getEnv("AUTHZ_CACHE_TTL", "1m")
+	authzCacheTTL, err := time.ParseDuration(authzCacheStr)
+	if err != nil || authzCacheTTL < 0 {
+		return nil, fmt.Errorf("invalid AUTHZ_CACHE_TTL: %s", authzCacheStr)
+	}
+
+	policyReloadStr := getEnv("POLICY_RELOAD_INTERVAL", "1m")
+	policyReloadInterval, err := time.ParseDuration(policyReloadStr)
+	if err != nil || policyReloadInterval <= 0 {
+		return nil, fmt.Errorf("invalid POLICY_RELOAD_INTERVAL: %s", policyReloadStr)
+	}
+
+	resetTokenStr := getEnv("PASSWORD_RESET_TOKEN_EXP", "30m")
+	resetTokenExp, err := time.ParseDuration(resetTokenStr)
+	if err != nil || resetTokenExp <= 0 {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_TOKEN_EXP: %s", resetTokenStr)
+	}
+
+	resetResendStr := getEnv("PASSWORD_RESET_RESEND_INTERVAL", "1m")
+	resetResendInterval, err := time.ParseDuration(resetResendStr)
+	if err != nil || resetResendInterval < 0 {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_RESEND_INTERVAL: %s", resetResendStr)
+	}
+
+	enumerationSafeStr := getEnv("REGISTRATION_ENUMERATION_SAFE", "false")
+	enumerationSafe, err := strconv.ParseBool(enumerationSafeStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REGISTRATION_ENUMERATION_SAFE: %s", enumerationSafeStr)
+	}
+
+	hashMemoryStr := getEnv("PASSWORD_HASH_MEMORY", "65536")
+	hashMemory, err := strconv.ParseUint(hashMemoryStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_HASH_MEMORY: %s", hashMemoryStr)
+	}
+
+	hashTimeStr := getEnv("PASSWORD_HASH_TIME", "1")
+	hashTime, err := strconv.ParseUint(hashTimeStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_HASH_TIME: %s", hashTimeStr)
+	}
+
+	hashThreadsStr := getEnv("PASSWORD_HASH_THREADS", "4")
+	hashThreads, err := strconv.ParseUint(hashThreadsStr, 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_HASH_THREADS: %s", hashThreadsStr)
+	}
+
+	pepperVersionStr := getEnv("PASSWORD_PEPPER_ACTIVE_VERSION", "0")
+	pepperVersion, err := strconv.Atoi(pepperVersionStr)
+	if err != nil || pepperVersion < 0 {
+		return nil, fmt.Errorf("invalid PASSWORD_PEPPER_ACTIVE_VERSION: %s", pepperVersionStr)
+	}
+
+	passwordMinLengthStr := getEnv("PASSWORD_MIN_LENGTH", "8")
+	passwordMinLength, err := strconv.Atoi(passwordMinLengthStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH: %s", passwordMinLengthStr)
+	}
+
+	passwordMaxLengthStr := getEnv("PASSWORD_MAX_LENGTH", "256")
+	passwordMaxLength, err := strconv.Atoi(passwordMaxLengthStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_MAX_LENGTH: %s", passwordMaxLengthStr)
+	}
+
+	requiredClasses, err := passwordpolicy.ParseCharClasses(getEnv("PASSWORD_REQUIRED_CLASSES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_REQUIRED_CLASSES: %w", err)
+	}
+
+	passwordMinClassesStr := getEnv("PASSWORD_MIN_CLASSES", "0")
+	passwordMinClasses, err := strconv.Atoi(passwordMinClassesStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_CLASSES: %s", passwordMinClassesStr)
+	}
+
+	passwordMinStrengthStr := getEnv("PASSWORD_MIN_STRENGTH", "2")
+	passwordMinStrength, err := strconv.Atoi(passwordMinStrengthStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_STRENGTH: %s", passwordMinStrengthStr)
+	}
+
+	rejectPersonalInfoStr := getEnv("PASSWORD_REJECT_PERSONAL_INFO", "true")
+	rejectPersonalInfo, err := strconv.ParseBool(rejectPersonalInfoStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_REJECT_PERSONAL_INFO: %s", rejectPersonalInfoStr)
+	}
+
+	passwordHistoryStr := getEnv("PASSWORD_HISTORY", "5")
+	passwordHistory, err := strconv.Atoi(passwordHistoryStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_HISTORY: %s", passwordHistoryStr)
+	}
+
+	breachMinCountStr := getEnv("PASSWORD_BREACH_MIN_COUNT", "1")
+	breachMinCount, err := strconv.Atoi(breachMinCountStr)
+	if err != nil || breachMinCount < 1 {
+		return nil, fmt.Errorf("invalid PASSWORD_BREACH_MIN_COUNT: %s", breachMinCountStr)
+	}
+
+	notifyLogLinksStr := getEnv("NOTIFY_LOG_LINKS", "false")
+	notifyLogLinks, err := strconv.ParseBool(notifyLogLinksStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NOTIFY_LOG_LINKS: %s", notifyLogLinksStr)
+	}
+
+	requireVerificationStr := getEnv("REQUIRE_EMAIL_VERIFICATION", "false")
+	requireVerification, err := strconv.ParseBool(requireVerificationStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REQUIRE_EMAIL_VERIFICATION: %s", requireVerificationStr)
+	}
+
+	verifyTokenStr := getEnv("EMAIL_VERIFICATION_TOKEN_EXP", "24h")
+	verifyTokenExp, err := time.ParseDuration(verifyTokenStr)
+	if err != nil || verifyTokenExp <= 0 {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_TOKEN_EXP: %s", verifyTokenStr)
+	}
+
+	verifyResendStr := getEnv("EMAIL_VERIFICATION_RESEND_INTERVAL", "1m")
+	verifyResendInterval, err := time.ParseDuration(verifyResendStr)
+	if err != nil || verifyResendInterval < 0 {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_RESEND_INTERVAL: %s", verifyResendStr)
+	}
+
+	backoffAfterStr := getEnv("LOGIN_BACKOFF_AFTER", "3")
+	backoffAfter, err := strconv.Atoi(backoffAfterStr)
+	if err != nil || backoffAfter < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_BACKOFF_AFTER: %s", backoffAfterStr)
+	}
+
+	lockoutThresholdStr := getEnv("LOGIN_LOCKOUT_THRESHOLD", "10")
+	lockoutThreshold, err := strconv.Atoi(lockoutThresholdStr)
+	if err != nil || lockoutThreshold < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_THRESHOLD: %s", lockoutThresholdStr)
+	}
+
+	ipBackoffAfterStr := getEnv("LOGIN_IP_BACKOFF_AFTER", "20")
+	ipBackoffAfter, err := strconv.Atoi(ipBackoffAfterStr)
+	if err != nil || ipBackoffAfter < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_IP_BACKOFF_AFTER: %s", ipBackoffAfterStr)
+	}
+
+	ipLockoutThresholdStr := getEnv("LOGIN_IP_LOCKOUT_THRESHOLD", "100")
+	ipLockoutThreshold, err := strconv.Atoi(ipLockoutThresholdStr)
+	if err != nil || ipLockoutThreshold < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_IP_LOCKOUT_THRESHOLD: %s", ipLockoutThresholdStr)
+	}
+
+	backoffBaseStr := getEnv("LOGIN_BACKOFF_BASE", "1s")
+	backoffBase, err := time.ParseDuration(backoffBaseStr)
+	if err != nil || backoffBase <= 0 {
+		return nil, fmt.Errorf("invalid LOGIN_BACKOFF_BASE: %s", backoffBaseStr)
+	}
+
+	backoffMaxStr := getEnv("LOGIN_BACKOFF_MAX", "1m")
+	backoffMax, err := time.ParseDuration(backoffMaxStr)
+	if err != nil || backoffMax <= 0 {
+		return nil, fmt.Errorf("invalid LOGIN_BACKOFF_MAX: %s", backoffMaxStr)
+	}
+
+	lockoutDurationStr := getEnv("LOGIN_LOCKOUT_DURATION", "15m")
+	lockoutDuration, err := time.ParseDuration(lockoutDurationStr)
+	if err != nil || lockoutDuration <= 0 {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_DURATION: %s", lockoutDurationStr)
+	}
+
+	rateLimitPolicies := make(map[string]ratelimit.Policy)
+	for route, defaultPolicy := range map[string]string{
+		constants.RateLimitRegister: "ip:10/1h:sliding-window",
+		constants.RateLimitLogin:    "ip:30/1m,username:10/1m",
+		constants.RateLimitRefresh:  "ip:60/1m",
+		constants.RateLimitPassword: "ip:10/1h:sliding-window",
+		constants.RateLimitMFA:      "ip:30/1m",
+		constants.RateLimitWebAuthn: "ip:30/1m",
+		constants.RateLimitEmail:    "ip:20/1h:sliding-window",
+	} {
+		key := "RATE_LIMIT_" + strings.ToUpper(route)
+		policy, err := ratelimit.ParsePolicy(getEnv(key, defaultPolicy))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		rateLimitPolicies[route] = policy
+	}
+
 	cfg := &Config{
 		AppEnv: getEnv("APP_ENV", "development"),
 		Port:   getEnv("PORT", "8080"),
+		// Forwarding headers are only honoured from these hops
+		TrustedProxies: trustedProxies,
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     dbPort,
@@ -64,9 +485,100 @@ func Load() (*Config, error) {
 			URL:      getEnv("DATABASE_URL", ""),
 		},
 		JWT: JWTConfig{
-			Secret:          getEnv("JWT_SECRET", ""),
-			AccessTokenExp:  accessTokenExp,
-			RefreshTokenExp: refreshTokenExp,
+			Algorithm:           getEnv("JWT_ALGORITHM", "HS256"),
+			Secret:              getEnv("JWT_SECRET", ""),
+			PrivateKeyFile:      getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			KeyID:               getEnv("JWT_KEY_ID", ""),
+			KeyStore:            getEnv("JWT_KEY_STORE", KeyStoreStatic),
+			KeyEncryptionSecret: getEnv("JWT_KEY_ENCRYPTION_SECRET", ""),
+			KeyRotationInterval: keyRotationInterval,
+			KeySyncInterval:     keySyncInterval,
+			AccessTokenExp:      accessTokenExp,
+			RefreshTokenExp:     refreshTokenExp,
+			RefreshReuseGrace:   refreshReuseGrace,
+		},
+		TokenHash: TokenHashConfig{
+			KeyFile:       getEnv("TOKEN_HASH_KEY_FILE", ""),
+			ActiveVersion: tokenHashVersion,
+			AcceptLegacy:  tokenHashLegacy,
+		},
+		Denylist: DenylistConfig{
+			Store: getEnv("ACCESS_TOKEN_DENYLIST_STORE", DenylistStoreMemory),
+		},
+		Authz: AuthzConfig{
+			CacheTTL: authzCacheTTL,
+		},
+		Relations: RelationsConfig{
+			SchemaFile: getEnv("RELATION_SCHEMA_FILE", ""),
+		},
+		Policy: PolicyConfig{
+			Dir:            getEnv("POLICY_DIR", ""),
+			Algorithm:      getEnv("POLICY_ALGORITHM", "deny-overrides"),
+			ReloadInterval: policyReloadInterval,
+		},
+		Registration: RegistrationConfig{
+			EnumerationSafe: enumerationSafe,
+		},
+		PasswordHash: utils.PasswordParams{
+			Memory:  uint32(hashMemory),
+			Time:    uint32(hashTime),
+			Threads: uint8(hashThreads),
+		},
+		PasswordPepper: PasswordPepperConfig{
+			File:          getEnv("PASSWORD_PEPPER_FILE", ""),
+			ActiveVersion: pepperVersion,
+		},
+		PasswordPolicy: passwordpolicy.Policy{
+			MinLength:          passwordMinLength,
+			MaxLength:          passwordMaxLength,
+			RequiredClasses:    requiredClasses,
+			MinClasses:         passwordMinClasses,
+			MinStrength:        passwordMinStrength,
+			RejectPersonalInfo: rejectPersonalInfo,
+			History:            passwordHistory,
+		},
+		PasswordBreaches: PasswordBreachConfig{
+			Corpus:   getEnv("PASSWORD_BREACH_CORPUS", ""),
+			MinCount: breachMinCount,
+		},
+		Notify: NotifyConfig{
+			Notifier:      getEnv("NOTIFIER", NotifierLog),
+			LogLinks:      notifyLogLinks,
+			WebhookURL:    getEnv("NOTIFY_WEBHOOK_URL", ""),
+			WebhookSecret: getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+		},
+		PasswordReset: PasswordResetConfig{
+			TokenExp:       resetTokenExp,
+			ResendInterval: resetResendInterval,
+			URL:            getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		},
+		EmailVerification: EmailVerificationConfig{
+			Required:       requireVerification,
+			TokenExp:       verifyTokenExp,
+			ResendInterval: verifyResendInterval,
+			URL:            getEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+		},
+		MFA: MFAConfig{
+			EncryptionSecret: getEnv("MFA_ENCRYPTION_SECRET", ""),
+			Issuer:           getEnv("MFA_ISSUER", "Authorization"),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:  getEnv("WEBAUTHN_RP_NAME", "Authorization"),
+			Origins: splitList(getEnv("WEBAUTHN_ORIGINS", "http://localhost:3000")),
+		},
+		Lockout: LockoutConfig{
+			BackoffAfter:   backoffAfter,
+			Threshold:      lockoutThreshold,
+			IPBackoffAfter: ipBackoffAfter,
+			IPThreshold:    ipLockoutThreshold,
+			BackoffBase:    backoffBase,
+			BackoffMax:     backoffMax,
+			Duration:       lockoutDuration,
+		},
+		RateLimit: RateLimitConfig{
+			Store:    getEnv("RATE_LIMIT_STORE", RateLimitStoreMemory),
+			Policies: rateLimitPolicies,
 		},
 	}
 
@@ -75,16 +587,148 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("DATABASE_URL is required")
 	}
 
-	if cfg.JWT.Secret == "" {
-		return nil, fmt.Errorf("JWT_SECRET is required")
+	if err := cfg.JWT.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.PasswordHash.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_HASH_* settings: %w", err)
+	}
+
+	if err := cfg.PasswordPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid password policy settings: %w", err)
+	}
+
+	// Anyone who can read the database could check guessed tokens against
+	// bare SHA-256 hashes
+	if cfg.TokenHash.KeyFile == "" && cfg.AppEnv == "production" {
+		return nil, fmt.Errorf("TOKEN_HASH_KEY_FILE is required in production")
+	}
+
+	switch cfg.Notify.Notifier {
+	case NotifierLog:
+		// Anyone who can read the log could reset any account
+		if cfg.Notify.LogLinks && cfg.AppEnv == "production" {
+			return nil, fmt.Errorf("NOTIFY_LOG_LINKS must not be set in production")
+		}
+	case NotifierWebhook:
+		webhookURL, err := url.Parse(cfg.Notify.WebhookURL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			return nil, fmt.Errorf("NOTIFY_WEBHOOK_URL of http or https is required for the webhook notifier")
+		}
+	default:
+		return nil, fmt.Errorf("invalid NOTIFIER: %s", cfg.Notify.Notifier)
+	}
+
+	if _, err := url.Parse(cfg.PasswordReset.URL); err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_URL: %w", err)
+	}
+
+	if _, err := url.Parse(cfg.EmailVerification.URL); err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_URL: %w", err)
+	}
+
+	// Otherwise logging in right after registering would tell whether the
+	// account was created
+	if cfg.Registration.EnumerationSafe && !cfg.EmailVerification.Required {
+		return nil, fmt.Errorf("REGISTRATION_ENUMERATION_SAFE requires REQUIRE_EMAIL_VERIFICATION")
+	}
+
+	if cfg.MFA.EncryptionSecret != "" && len(cfg.MFA.EncryptionSecret) < 32 {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_SECRET must be at least 32 characters")
+	}
+
+	// Failed logins are forgotten after the lockout duration, so no delay may
+	// outlast it
+	if cfg.Lockout.BackoffMax < cfg.Lockout.BackoffBase || cfg.Lockout.BackoffMax > cfg.Lockout.Duration {
+		return nil, fmt.Errorf("LOGIN_BACKOFF_MAX must be between LOGIN_BACKOFF_BASE and LOGIN_LOCKOUT_DURATION")
+	}
+
+	switch cfg.RateLimit.Store {
+	case RateLimitStoreMemory, RateLimitStoreDatabase:
+	default:
+		return nil, fmt.Errorf("invalid RATE_LIMIT_STORE: %s", cfg.RateLimit.Store)
+	}
+
+	switch cfg.Denylist.Store {
+	case DenylistStoreMemory, DenylistStoreDatabase:
+	default:
+		return nil, fmt.Errorf("invalid ACCESS_TOKEN_DENYLIST_STORE: %s", cfg.Denylist.Store)
+	}
+
+	switch cfg.Policy.Algorithm {
+	case "deny-overrides", "permit-overrides", "first-applicable":
+	default:
+		return nil, fmt.Errorf("invalid POLICY_ALGORITHM: %s", cfg.Policy.Algorithm)
 	}
 
 	return cfg, nil
 }
 
+func (c JWTConfig) validate() error {
+	switch c.Algorithm {
+	case "HS256", "RS256", "ES256", "EdDSA":
+	default:
+		return fmt.Errorf("invalid JWT_ALGORITHM: %s", c.Algorithm)
+	}
+
+	switch c.KeyStore {
+	case KeyStoreStatic:
+		// The signing key comes from configuration
+		if c.Algorithm == "HS256" && c.Secret == "" {
+			return fmt.Errorf("JWT_SECRET is required")
+		}
+		if c.Algorithm != "HS256" && c.PrivateKeyFile == "" {
+			return fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", c.Algorithm)
+		}
+	case KeyStoreDatabase:
+		// Signing keys are generated and stored encrypted in the database
+		if len(c.KeyEncryptionSecret) < 32 {
+			return fmt.Errorf("JWT_KEY_ENCRYPTION_SECRET of at least 32 characters is required for the database key store")
+		}
+	default:
+		return fmt.Errorf("invalid JWT_KEY_STORE: %s", c.KeyStore)
+	}
+
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePrefixes parses a comma separated list of IP addresses and CIDR
+// ranges. Addresses become single address ranges.
+func parsePrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range splitList(value) {
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
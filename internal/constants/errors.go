@@ -12,6 +12,8 @@ const (
 	ErrInternal          = "INTERNAL_ERROR"
 	ErrUnauthorized      = "UNAUTHORIZED"
 	ErrForbidden         = "FORBIDDEN"
+	ErrSessionNotFound   = "SESSION_NOT_FOUND"
+	ErrRoleNotFound      = "ROLE_NOT_FOUND"
 )
 
 // Error messages
@@ -25,6 +27,42 @@ const (
 	MsgUnauthorized       = "Authentication required"
 	MsgForbidden          = "Access denied"
 	MsgInternalError      = "Internal server error"
+	MsgSessionNotFound    = "Session not found"
+	MsgRoleNotFound       = "Role not found"
+	MsgRoleAlreadyExists  = "Role already exists"
+	MsgUnknownPermission  = "Unknown permission"
+	MsgPermissionExists   = "Permission already exists"
+	MsgInheritanceCycle   = "Role inheritance would create a cycle"
+	MsgLastAdmin          = "Cannot remove the admin role from the last administrator"
+	MsgLastActiveAdmin    = "Cannot disable the last active administrator"
+	MsgCannotDisableSelf  = "You cannot disable your own account"
+	MsgInvalidRelation    = "Invalid relation request"
+	MsgInvalidZookie      = "Invalid zookie"
+	MsgInvalidPolicy      = "Invalid policy"
+	MsgPolicyNotFound     = "Policy not found"
+	MsgPolicyFromFile     = "Policy is loaded from a file and cannot be changed through the API"
+	MsgOrgNotFound        = "Organization not found"
+	MsgOrgAlreadyExists   = "Organization already exists"
+	MsgNotMember          = "User is not a member of the organization"
+	MsgRoleWrongScope     = "Role cannot be assigned in this scope"
+	MsgOrgRoleTooBroad    = "Organization roles can only inherit organization roles and cannot grant global permissions"
+	MsgGrantNotHeld       = "Cannot grant a permission you do not hold"
+	MsgInvalidResetToken  = "Invalid or expired password reset token"
+	MsgWrongPassword      = "Current password is incorrect"
+	MsgSamePassword       = "New password must be different from the current password"
+	MsgInvalidVerifyToken = "Invalid or expired email verification token"
+	MsgEmailNotVerified   = "Email address has not been verified"
+	MsgMFANotEnabled      = "Two-factor authentication is not enabled"
+	MsgMFAAlreadyEnabled  = "Two-factor authentication is already enabled"
+	MsgMFANoEnrollment    = "No two-factor enrollment to confirm"
+	MsgInvalidMFACode     = "Invalid two-factor code"
+	MsgMFALocked          = "Too many invalid two-factor codes, try again later"
+	MsgWebAuthnFailed     = "Passkey verification failed"
+	MsgPasskeyExists      = "Passkey is already registered"
+	MsgPasskeyNotFound    = "Passkey not found"
+	MsgLoginLocked        = "Too many failed login attempts, try again later"
+	MsgRateLimited        = "Too many requests, try again later"
+	MsgAccountDisabled    = "Account is disabled"
 )
 
 // Success messages
@@ -33,4 +71,20 @@ const (
 	MsgLoginSuccess    = "Login successful"
 	MsgLogoutSuccess   = "Logged out successfully"
 	MsgTokenRefreshed  = "Token refreshed successfully"
+	MsgSessionRevoked  = "Session revoked successfully"
+	MsgSessionsRevoked = "Sessions revoked successfully"
+	MsgRoleRevoked     = "Role revoked successfully"
+	MsgPolicyDeleted   = "Policy deleted successfully"
+	MsgMemberRemoved   = "Member removed successfully"
+	MsgResetLinkSent   = "If the email belongs to an account, a password reset link has been sent"
+	MsgPasswordReset   = "Password has been reset"
+	MsgPasswordChanged = "Password changed successfully"
+	MsgEmailVerified   = "Email verified successfully"
+	MsgVerifyLinkSent  = "If the email belongs to an unverified account, a verification link has been sent"
+	MsgMFADisabled     = "Two-factor authentication disabled"
+	MsgPasskeyRemoved  = "Passkey removed"
+	MsgLockoutCleared  = "Failed login attempts cleared"
+	MsgRegisterPending = "Check your email to finish registering"
+	MsgUserDisabled    = "User disabled"
+	MsgUserEnabled     = "User enabled"
 )
@@ -0,0 +1,27 @@
+package constants
+
+// Security events
+const (
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	EventAccessDenied      = "access_denied"
+	EventRoleAssigned      = "role_assigned"
+	EventRoleRevoked       = "role_revoked"
+	EventMemberUpdated     = "organization_member_updated"
+	EventMemberRemoved     = "organization_member_removed"
+	EventResetRequested    = "password_reset_requested"
+	EventPasswordReset     = "password_reset"
+	EventPasswordChanged   = "password_changed"
+	EventEmailVerified     = "email_verified"
+	EventMFAEnabled        = "mfa_enabled"
+	EventMFADisabled       = "mfa_disabled"
+	EventMFAFailed         = "mfa_failed"
+	EventMFALocked         = "mfa_locked"
+	EventRecoveryCodeUsed  = "mfa_recovery_code_used"
+	EventPasskeyAdded      = "passkey_registered"
+	EventPasskeyRemoved    = "passkey_removed"
+	EventPasskeyCloned     = "passkey_sign_count_regressed"
+	EventLoginLocked       = "login_locked"
+	EventLockoutCleared    = "login_lockout_cleared"
+	EventUserDisabled      = "user_disabled"
+	EventUserEnabled       = "user_enabled"
+)
@@ -0,0 +1,12 @@
+package constants
+
+// Routes with their own rate limit policy, configured by RATE_LIMIT_<ROUTE>
+const (
+	RateLimitRegister = "register"
+	RateLimitLogin    = "login"
+	RateLimitRefresh  = "refresh"
+	RateLimitPassword = "password"
+	RateLimitMFA      = "mfa"
+	RateLimitWebAuthn = "webauthn"
+	RateLimitEmail    = "email"
+)
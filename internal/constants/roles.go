@@ -1,10 +1,54 @@
 package constants
 
-// User roles (for future extension)
+// User roles
 const (
 	RoleUser  = "user"
 	RoleAdmin = "admin"
 )
 
+// Organization roles, assigned to members within an organization
+const (
+	RoleOrgAdmin  = "org_admin"
+	RoleOrgMember = "org_member"
+)
+
+// Role scopes. Global roles are assigned to users, organization roles to
+// members of an organization.
+const (
+	RoleScopeGlobal       = "global"
+	RoleScopeOrganization = "organization"
+)
+
 // Default role
 const DefaultUserRole = RoleUser
+
+// Built-in permissions, seeded by the migrations
+const (
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermRolesRead        = "roles:read"
+	PermRolesWrite       = "roles:write"
+	PermRolesAssign      = "roles:assign"
+	PermAuthzCheck       = "authz:check"
+	PermRelationsRead    = "relations:read"
+	PermRelationsWrite   = "relations:write"
+	PermPoliciesRead     = "policies:read"
+	PermPoliciesWrite    = "policies:write"
+	PermPoliciesEvaluate = "policies:evaluate"
+	PermOrgsRead         = "orgs:read"
+	PermOrgsWrite        = "orgs:write"
+	PermMembersRead      = "members:read"
+	PermMembersWrite     = "members:write"
+)
+
+// GlobalPermissions are the built-in permissions that act beyond a single
+// organization. Organization roles cannot grant them, not even through a
+// wildcard.
+var GlobalPermissions = []string{
+	PermUsersRead, PermUsersWrite,
+	PermRolesRead, PermRolesWrite, PermRolesAssign,
+	PermAuthzCheck,
+	PermRelationsRead, PermRelationsWrite,
+	PermPoliciesRead, PermPoliciesWrite, PermPoliciesEvaluate,
+	PermOrgsRead, PermOrgsWrite,
+}
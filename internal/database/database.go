@@ -0,0 +1,59 @@
+package database
+
+import (
+	"authorization/internal/pkg/logger"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Connect opens the PostgreSQL database, retrying while it is starting up
+func Connect(databaseURL string) (*gorm.DB, error) {
+	var db *gorm.DB
+	var err error
+
+	// Retry connection up to 10 times with increasing delay
+	for i := 0; i < 10; i++ {
+		db, err = gorm.Open(postgres.Open(databaseURL), &gorm.Config{})
+		if err == nil {
+			break
+		}
+
+		logger.Info("Failed to connect to database, retrying...",
+			zap.Int("attempt", i+1),
+			zap.Error(err))
+
+		// Wait before retry (exponential backoff)
+		waitTime := time.Duration(i+1) * time.Second
+		time.Sleep(waitTime)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database after retries: %w", err)
+	}
+
+	// Test connection
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	// Set connection pool settings
+	sqlDB.SetMaxOpenConns(25)
+	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+
+	// Note: Database schema is managed by golang-migrate migrations
+	// Run: make migrate-up to apply migrations
+	// Migration files are in db/migrations/ directory
+	logger.Info("Database connected successfully")
+
+	return db, nil
+}
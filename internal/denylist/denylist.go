@@ -0,0 +1,93 @@
+// Package denylist remembers revoked access tokens until they expire.
+//
+// Access tokens are verified without a database lookup, so signing out or
+// disabling an account would leave them working until their exp. Instead
+// the token ID (the jti claim) or the session ID (the sid claim) is denied
+// for as long as a token it matches can still be valid; after that the
+// entry expires on its own.
+package denylist
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds denied keys until they expire
+type Store interface {
+	// Add denies the key until expiresAt. Adding a key again keeps the later
+	// of the two expiry times.
+	Add(ctx context.Context, key string, expiresAt time.Time) error
+	// Contains reports whether any of the keys is denied
+	Contains(ctx context.Context, keys ...string) (bool, error)
+}
+
+// TokenKey is the key denying the access token with the given jti
+func TokenKey(tokenID string) string {
+	return "jti:" + tokenID
+}
+
+// SessionKey is the key denying every access token of the session with the
+// given sid
+func SessionKey(sessionID string) string {
+	return "sid:" + sessionID
+}
+
+// sweepInterval is how often expired keys are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore keeps denied keys in memory. Each node only knows the keys it
+// denied itself, so behind a load balancer a revoked token keeps working on
+// the other nodes.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Add(ctx context.Context, key string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+	if expiresAt.After(s.entries[key]) {
+		s.entries[key] = expiresAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) Contains(ctx context.Context, keys ...string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+	now := s.now()
+	for _, key := range keys {
+		if expiresAt, ok := s.entries[key]; ok && now.Before(expiresAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sweep drops expired keys, at most once every sweepInterval
+func (s *MemoryStore) sweep() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	for key, expiresAt := range s.entries {
+		if !now.Before(expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,39 @@
+package denylist
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	store.Add(ctx, TokenKey("token-1"), now.Add(15*time.Minute))
+	store.Add(ctx, SessionKey("session-1"), now.Add(5*time.Minute))
+
+	if denied, _ := store.Contains(ctx, TokenKey("token-2"), SessionKey("session-1")); !denied {
+		t.Error("Expected a token of the denied session to be denied")
+	}
+	if denied, _ := store.Contains(ctx, TokenKey("token-2"), SessionKey("session-2")); denied {
+		t.Error("Expected other tokens to be allowed")
+	}
+
+	// Adding a key again never shortens its entry
+	store.Add(ctx, TokenKey("token-1"), now.Add(time.Minute))
+
+	// Entries end when the tokens they match would have expired anyway
+	now = now.Add(10 * time.Minute)
+	if denied, _ := store.Contains(ctx, SessionKey("session-1")); denied {
+		t.Error("Expected the session entry to have expired")
+	}
+	if denied, _ := store.Contains(ctx, TokenKey("token-1")); !denied {
+		t.Error("Expected the token entry to keep its later expiry")
+	}
+	if _, ok := store.entries[SessionKey("session-1")]; ok {
+		t.Error("Expected expired entries to be swept")
+	}
+}
@@ -4,16 +4,26 @@ package dto
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
 }
 
 // LoginRequest represents login request payload
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Username    string     `json:"username" validate:"required"`
+	Password    string     `json:"password" validate:"required"`
+	DeviceLabel string     `json:"device_label,omitempty" validate:"max=100"`
+	Client      ClientInfo `json:"-"`
 }
 
 // RefreshRequest represents refresh token request payload
 type RefreshRequest struct {
-	RefreshToken string `json:"refresh_token" validate:"required"`
+	RefreshToken string     `json:"refresh_token" validate:"required"`
+	Client       ClientInfo `json:"-"`
+}
+
+// ClientInfo describes the client a request came from. It is filled in by
+// handlers from the HTTP request, never from the request body.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
 }
@@ -8,23 +8,37 @@ type LoginResponse struct {
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	User         UserInfo  `json:"user"`
+
+	// MFAChallenge is set instead of the tokens when the user still has to
+	// present a second factor; handlers respond with it alone
+	MFAChallenge *MFAChallengeResponse `json:"-"`
 }
 
 // UserInfo represents user information in responses
 type UserInfo struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            string    `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Roles         []string  `json:"roles,omitempty"`
+	Permissions   []string  `json:"permissions,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
-// RegisterResponse represents registration response payload
+// RegisterResponse represents registration response payload. User is left
+// out when registration is enumeration safe, as the response must not tell
+// whether an account was created.
 type RegisterResponse struct {
-	Message string   `json:"message"`
-	User    UserInfo `json:"user"`
+	Message string    `json:"message"`
+	User    *UserInfo `json:"user,omitempty"`
 }
 
 // LogoutResponse represents logout response payload
 type LogoutResponse struct {
 	Message string `json:"message"`
 }
+
+// UserStatusResponse represents disable and enable user response payload
+type UserStatusResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+// AuthzCheckRequest asks whether a user holds a permission. An empty UserID
+// checks the caller.
+type AuthzCheckRequest struct {
+	UserID     string `json:"user_id"`
+	Permission string `json:"permission"`
+}
+
+// AuthzCheckResponse is the answer to a permission check. Role and Grant
+// name the role and grant that allowed it.
+type AuthzCheckResponse struct {
+	UserID     string `json:"user_id"`
+	Permission string `json:"permission"`
+	Allowed    bool   `json:"allowed"`
+	Role       string `json:"role,omitempty"`
+	Grant      string `json:"grant,omitempty"`
+}
@@ -0,0 +1,21 @@
+package dto
+
+// VerifyEmailRequest verifies an email with the token from a verification link
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// VerifyEmailResponse represents email verification response payload
+type VerifyEmailResponse struct {
+	Message string `json:"message"`
+}
+
+// ResendVerificationRequest asks for a new verification link
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResendVerificationResponse is the same whether or not a link was sent
+type ResendVerificationResponse struct {
+	Message string `json:"message"`
+}
@@ -7,8 +7,10 @@ type ErrorResponse struct {
 	Details interface{} `json:"details,omitempty"`
 }
 
-// ValidationError represents validation error details
+// ValidationError represents validation error details. Rule names the rule
+// that failed, where there is one, such as a rule of the password policy.
 type ValidationError struct {
 	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
 	Message string `json:"message"`
 }
@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+// LockoutInfo represents the failed logins counted against a username
+// (scope "account") or a client IP address (scope "ip")
+type LockoutInfo struct {
+	Scope          string     `json:"scope"`
+	Subject        string     `json:"subject"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LastFailedAt   *time.Time `json:"last_failed_at,omitempty"`
+	BlockedUntil   *time.Time `json:"blocked_until,omitempty"`
+	Locked         bool       `json:"locked"`
+}
+
+// LockoutListResponse represents the usernames and addresses currently held
+// back
+type LockoutListResponse struct {
+	Lockouts []LockoutInfo `json:"lockouts"`
+}
+
+// ClearLockoutResponse represents clear lockout response payload
+type ClearLockoutResponse struct {
+	Message string `json:"message"`
+}
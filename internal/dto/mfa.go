@@ -0,0 +1,52 @@
+package dto
+
+import "time"
+
+// MFAChallengeResponse is returned by login when a second factor is needed.
+// MFAToken is exchanged for access and refresh tokens at /auth/mfa/verify.
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Methods     []string  `json:"methods"`
+}
+
+// MFAVerifyRequest completes a login with either a TOTP code or a recovery
+// code
+type MFAVerifyRequest struct {
+	MFAToken     string     `json:"mfa_token" validate:"required"`
+	Code         string     `json:"code,omitempty"`
+	RecoveryCode string     `json:"recovery_code,omitempty"`
+	Client       ClientInfo `json:"-"`
+}
+
+// MFACodeRequest proves possession of the second factor for changes to it
+type MFACodeRequest struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFAStatusResponse describes the second factor of the user
+type MFAStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	Pending                bool       `json:"pending"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// MFAEnrollResponse carries a new TOTP secret. Secret is for manual entry,
+// ProvisioningURI is meant to be shown as a QR code.
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFARecoveryCodesResponse carries recovery codes. They are only shown once.
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFADisableResponse represents disable MFA response payload
+type MFADisableResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,83 @@
+package dto
+
+import "time"
+
+// CreateOrganizationRequest represents create organization request payload.
+// OwnerID, if set, becomes the first member with the org_admin role.
+type CreateOrganizationRequest struct {
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	OwnerID string `json:"owner_id,omitempty"`
+}
+
+// OrganizationInfo represents an organization in responses
+type OrganizationInfo struct {
+	ID        string    `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationListResponse represents the list of organizations
+type OrganizationListResponse struct {
+	Organizations []OrganizationInfo `json:"organizations"`
+}
+
+// MembershipInfo represents an organization the user belongs to and the
+// roles they hold there
+type MembershipInfo struct {
+	OrganizationInfo
+	Roles  []string `json:"roles"`
+	Active bool     `json:"active"`
+}
+
+// MembershipListResponse represents the organizations of the current user
+type MembershipListResponse struct {
+	Organizations []MembershipInfo `json:"organizations"`
+}
+
+// SetMemberRequest adds a user to an organization or replaces the
+// organization roles of a member
+type SetMemberRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// MemberInfo represents a member of an organization in responses
+type MemberInfo struct {
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Roles    []string  `json:"roles"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// MemberListResponse represents the members of an organization
+type MemberListResponse struct {
+	OrganizationID string       `json:"organization_id"`
+	Members        []MemberInfo `json:"members"`
+}
+
+// RemoveMemberResponse represents the result of removing a member
+type RemoveMemberResponse struct {
+	Message string `json:"message"`
+}
+
+// SwitchOrganizationRequest selects the organization the session works in.
+// An empty OrgID leaves the current organization.
+type SwitchOrganizationRequest struct {
+	OrgID string `json:"org_id"`
+}
+
+// SwitchOrganizationResponse carries an access token for the selected
+// organization. The refresh token is unchanged and keeps the selection.
+// Roles and Permissions are global, OrgRoles and OrgPermissions only hold
+// within the organization.
+type SwitchOrganizationResponse struct {
+	AccessToken    string    `json:"access_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	OrgID          string    `json:"org_id,omitempty"`
+	Roles          []string  `json:"roles"`
+	Permissions    []string  `json:"permissions"`
+	OrgRoles       []string  `json:"org_roles"`
+	OrgPermissions []string  `json:"org_permissions"`
+}
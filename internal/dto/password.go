@@ -0,0 +1,41 @@
+package dto
+
+import "time"
+
+// ForgotPasswordRequest asks for a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ForgotPasswordResponse is the same whether or not the email belongs to an
+// account
+type ForgotPasswordResponse struct {
+	Message string `json:"message"`
+}
+
+// ResetPasswordRequest sets a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ResetPasswordResponse represents password reset response payload
+type ResetPasswordResponse struct {
+	Message string `json:"message"`
+}
+
+// ChangePasswordRequest changes the password of the authenticated user
+type ChangePasswordRequest struct {
+	CurrentPassword     string     `json:"current_password" validate:"required"`
+	NewPassword         string     `json:"new_password" validate:"required"`
+	RevokeOtherSessions bool       `json:"revoke_other_sessions"`
+	Client              ClientInfo `json:"-"`
+}
+
+// ChangePasswordResponse carries a new access token for the current session,
+// since access tokens issued before the change are no longer accepted
+type ChangePasswordResponse struct {
+	Message     string    `json:"message"`
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
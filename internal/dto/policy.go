@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Policy sources
+const (
+	PolicySourceFile     = "file"
+	PolicySourceDatabase = "database"
+)
+
+// PolicyEvaluateRequest holds the attributes to decide on
+type PolicyEvaluateRequest struct {
+	Subject     map[string]any `json:"subject"`
+	Resource    map[string]any `json:"resource"`
+	Action      string         `json:"action"`
+	Environment map[string]any `json:"environment"`
+}
+
+// PolicyDecisionResponse is an explained policy decision. Decision is
+// permit, deny, not_applicable or indeterminate; only permit is allowed.
+type PolicyDecisionResponse struct {
+	Decision string             `json:"decision"`
+	Allowed  bool               `json:"allowed"`
+	Policy   string             `json:"policy,omitempty"`
+	Rule     string             `json:"rule,omitempty"`
+	Reason   string             `json:"reason"`
+	Results  []PolicyRuleResult `json:"results"`
+}
+
+// PolicyRuleResult is the effect of one evaluated rule. An empty rule is a
+// policy target that did not match or failed to evaluate.
+type PolicyRuleResult struct {
+	Policy string `json:"policy"`
+	Rule   string `json:"rule,omitempty"`
+	Effect string `json:"effect"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PutPolicyRequest creates or replaces a stored policy
+type PutPolicyRequest struct {
+	Policy   json.RawMessage `json:"policy"`
+	Priority int             `json:"priority"`
+	Enabled  *bool           `json:"enabled"`
+}
+
+// PolicyInfo represents a policy in responses
+type PolicyInfo struct {
+	ID        string          `json:"id"`
+	Source    string          `json:"source"`
+	Priority  int             `json:"priority"`
+	Enabled   bool            `json:"enabled"`
+	Policy    json.RawMessage `json:"policy"`
+	UpdatedAt *time.Time      `json:"updated_at,omitempty"`
+}
+
+// PolicyListResponse lists policies in evaluation order
+type PolicyListResponse struct {
+	Algorithm string       `json:"algorithm"`
+	Policies  []PolicyInfo `json:"policies"`
+}
+
+// DeletePolicyResponse represents the result of deleting a policy
+type DeletePolicyResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,115 @@
+package dto
+
+// Relation tuple update operations
+const (
+	RelationTouch  = "touch"
+	RelationDelete = "delete"
+)
+
+// Relation read consistency modes
+const (
+	ConsistencyMinimizeLatency = "minimize_latency"
+	ConsistencyAtLeastAsFresh  = "at_least_as_fresh"
+	ConsistencyAtExactSnapshot = "at_exact_snapshot"
+)
+
+// RelationTuple relates a subject ("user:alice" or "group:eng#member") to an
+// object ("doc:readme") through a relation ("viewer")
+type RelationTuple struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	Subject  string `json:"subject"`
+}
+
+// RelationUpdate touches (creates if missing) or deletes a tuple
+type RelationUpdate struct {
+	Operation string        `json:"operation"`
+	Tuple     RelationTuple `json:"tuple"`
+}
+
+// WriteRelationsRequest applies updates atomically
+type WriteRelationsRequest struct {
+	Updates []RelationUpdate `json:"updates"`
+}
+
+// WriteRelationsResponse returns the zookie of the revision the updates were
+// written at
+type WriteRelationsResponse struct {
+	Zookie string `json:"zookie"`
+}
+
+// RelationConsistency chooses the snapshot a read is evaluated at. Without a
+// zookie reads see the latest revision. With one, at_least_as_fresh (the
+// default) sees at least the zookie's revision and at_exact_snapshot exactly
+// it.
+type RelationConsistency struct {
+	Zookie      string `json:"zookie,omitempty"`
+	Consistency string `json:"consistency,omitempty"`
+}
+
+// ReadRelationsRequest lists the tuples of an object, optionally of one
+// relation
+type ReadRelationsRequest struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	RelationConsistency
+}
+
+type ReadRelationsResponse struct {
+	Tuples []RelationTuple `json:"tuples"`
+	Zookie string          `json:"zookie"`
+}
+
+// CheckRelationRequest asks whether subject has relation to object
+type CheckRelationRequest struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	Subject  string `json:"subject"`
+	RelationConsistency
+}
+
+type CheckRelationResponse struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	Subject  string `json:"subject"`
+	Allowed  bool   `json:"allowed"`
+	Zookie   string `json:"zookie"`
+}
+
+// ExpandRelationRequest asks for the userset tree of object's relation
+type ExpandRelationRequest struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	RelationConsistency
+}
+
+type ExpandRelationResponse struct {
+	Tree   *UsersetTree `json:"tree"`
+	Zookie string       `json:"zookie"`
+}
+
+// UsersetTree is a node of an expansion. Leaves list the subjects of a
+// relation's own tuples; other nodes combine their children by Operation.
+type UsersetTree struct {
+	Operation string         `json:"operation"`
+	Object    string         `json:"object"`
+	Relation  string         `json:"relation"`
+	Subjects  []string       `json:"subjects,omitempty"`
+	Children  []*UsersetTree `json:"children,omitempty"`
+}
+
+// ListObjectsRequest asks which objects of a namespace subject has relation to
+type ListObjectsRequest struct {
+	Namespace string `json:"namespace"`
+	Relation  string `json:"relation"`
+	Subject   string `json:"subject"`
+	RelationConsistency
+}
+
+type ListObjectsResponse struct {
+	Namespace string   `json:"namespace"`
+	Relation  string   `json:"relation"`
+	Subject   string   `json:"subject"`
+	Objects   []string `json:"objects"`
+	Zookie    string   `json:"zookie"`
+}
@@ -0,0 +1,33 @@
+package dto
+
+// CreateRoleRequest represents create role request payload. Scope is
+// "global" (the default) or "organization".
+type CreateRoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Scope       string   `json:"scope"`
+	Permissions []string `json:"permissions"`
+	Inherits    []string `json:"inherits"`
+}
+
+// SetRolePermissionsRequest represents the permissions a role should grant
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+// SetRoleInheritsRequest represents the roles a role should inherit from
+type SetRoleInheritsRequest struct {
+	Inherits []string `json:"inherits"`
+}
+
+// CreatePermissionRequest represents create permission request payload. The
+// name may contain "*" segments to grant a whole subtree.
+type CreatePermissionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// AssignRoleRequest represents assign role request payload
+type AssignRoleRequest struct {
+	Role string `json:"role"`
+}
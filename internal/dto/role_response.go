@@ -0,0 +1,45 @@
+package dto
+
+import "time"
+
+// RoleInfo represents a role in responses
+type RoleInfo struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Scope       string    `json:"scope"`
+	Permissions []string  `json:"permissions"`
+	Inherits    []string  `json:"inherits"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// RoleListResponse represents the list of roles
+type RoleListResponse struct {
+	Roles []RoleInfo `json:"roles"`
+}
+
+// PermissionInfo represents a permission in responses
+type PermissionInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// PermissionListResponse represents the list of permissions
+type PermissionListResponse struct {
+	Permissions []PermissionInfo `json:"permissions"`
+}
+
+// UserRolesResponse represents the roles assigned to a user, the roles they
+// hold through inheritance and the permissions all of those grant
+type UserRolesResponse struct {
+	UserID         string   `json:"user_id"`
+	Roles          []string `json:"roles"`
+	EffectiveRoles []string `json:"effective_roles"`
+	Permissions    []string `json:"permissions"`
+}
+
+// RevokeRoleResponse represents the result of revoking a role
+type RevokeRoleResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+// SessionInfo represents an active login session
+type SessionInfo struct {
+	ID          string    `json:"id"`
+	DeviceLabel string    `json:"device_label"`
+	UserAgent   string    `json:"user_agent"`
+	IPAddress   string    `json:"ip_address"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Current     bool      `json:"current"`
+}
+
+// SessionListResponse represents the list of active sessions
+type SessionListResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// RevokeSessionsResponse represents the result of revoking sessions
+type RevokeSessionsResponse struct {
+	Message string `json:"message"`
+}
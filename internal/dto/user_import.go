@@ -0,0 +1,25 @@
+package dto
+
+// ImportUserRecord is an account in the export of a system users are
+// imported from. PasswordHash is kept as it is, so it must be in a format
+// the password hashing layer verifies.
+type ImportUserRecord struct {
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	PasswordHash  string `json:"password_hash"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// ImportUsersResult reports what an import created and what it skipped
+type ImportUsersResult struct {
+	Created int              `json:"created"`
+	Skipped []ImportUserSkip `json:"skipped"`
+}
+
+// ImportUserSkip is a record an import did not create an account for.
+// Record counts from 1 in the order of the export.
+type ImportUserSkip struct {
+	Record   int    `json:"record"`
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
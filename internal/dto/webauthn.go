@@ -0,0 +1,127 @@
+package dto
+
+import "time"
+
+// The option and credential types below follow the JSON forms of the
+// WebAuthn specification, so that browsers can pass them to
+// PublicKeyCredential.parseCreationOptionsFromJSON and
+// parseRequestOptionsFromJSON and send back credential.toJSON() unchanged.
+// Binary values are base64url encoded without padding.
+
+// WebAuthnRelyingParty identifies the relying party to the authenticator
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUserEntity identifies the account a credential is created for
+type WebAuthnUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// WebAuthnCredentialParam is an accepted credential type and algorithm
+type WebAuthnCredentialParam struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// WebAuthnCredentialDescriptor names an existing credential
+type WebAuthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// WebAuthnAuthenticatorSelection states what authenticators may be used
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// WebAuthnCreationOptions starts a registration ceremony
+type WebAuthnCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParam      `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnRequestOptions starts a login ceremony. AllowCredentials is empty
+// for passwordless logins, where the user picks one of their passkeys.
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnCredential is the credential returned by the browser
+type WebAuthnCredential struct {
+	ID       string                        `json:"id"`
+	RawID    string                        `json:"rawId"`
+	Type     string                        `json:"type"`
+	Response WebAuthnAuthenticatorResponse `json:"response"`
+}
+
+// WebAuthnAuthenticatorResponse holds the authenticator response of either
+// ceremony: AttestationObject on registration, AuthenticatorData, Signature
+// and UserHandle on login
+type WebAuthnAuthenticatorResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject,omitempty"`
+	Transports        []string `json:"transports,omitempty"`
+	AuthenticatorData string   `json:"authenticatorData,omitempty"`
+	Signature         string   `json:"signature,omitempty"`
+	UserHandle        string   `json:"userHandle,omitempty"`
+}
+
+// WebAuthnRegisterRequest completes a registration ceremony
+type WebAuthnRegisterRequest struct {
+	Name       string             `json:"name,omitempty" validate:"max=100"`
+	Credential WebAuthnCredential `json:"credential"`
+}
+
+// WebAuthnLoginBeginRequest starts a login ceremony. With MFAToken the
+// passkey is the second factor of a password login; without it the login is
+// passwordless.
+type WebAuthnLoginBeginRequest struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+// WebAuthnLoginRequest completes a login ceremony. MFAToken must be the one
+// the ceremony was started with.
+type WebAuthnLoginRequest struct {
+	MFAToken    string             `json:"mfa_token,omitempty"`
+	Credential  WebAuthnCredential `json:"credential"`
+	DeviceLabel string             `json:"device_label,omitempty" validate:"max=100"`
+	Client      ClientInfo         `json:"-"`
+}
+
+// WebAuthnCredentialInfo represents a registered credential in responses
+type WebAuthnCredentialInfo struct {
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	AAGUID            string     `json:"aaguid"`
+	AttestationFormat string     `json:"attestation_format"`
+	BackupEligible    bool       `json:"backup_eligible"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnCredentialListResponse represents the user's credentials
+type WebAuthnCredentialListResponse struct {
+	Credentials []WebAuthnCredentialInfo `json:"credentials"`
+}
+
+// DeleteWebAuthnCredentialResponse represents delete credential response
+// payload
+type DeleteWebAuthnCredentialResponse struct {
+	Message string `json:"message"`
+}
@@ -7,8 +7,13 @@ import (
 	"authorization/internal/pkg/response"
 	"authorization/internal/service"
 	"encoding/json"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 )
@@ -37,13 +42,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.Password) < 6 {
-		response.BadRequest(w, "Password must be at least 6 characters long")
-		return
-	}
-
 	resp, err := h.authService.Register(&req)
 	if err != nil {
+		if passwordPolicyError(w, err, "password") {
+			return
+		}
 		logger.Error("Registration failed", zap.Error(err), zap.String("username", req.Username))
 		
 		if strings.Contains(err.Error(), "already exists") {
@@ -54,6 +57,13 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Enumeration safe registration does not say whether an account was
+	// created
+	if resp.User == nil {
+		response.Accepted(w, resp)
+		return
+	}
+
 	logger.Info("User registered successfully", zap.String("user_id", resp.User.ID), zap.String("username", resp.User.Username))
 	response.Created(w, resp)
 }
@@ -72,10 +82,29 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(req.Username) > 255 {
+		response.BadRequest(w, "Username must be at most 255 characters long")
+		return
+	}
+
+	if len(req.DeviceLabel) > 100 {
+		response.BadRequest(w, "Device label must be at most 100 characters long")
+		return
+	}
+
+	req.Client = clientInfo(r)
+
 	resp, err := h.authService.Login(&req)
 	if err != nil {
 		logger.Error("Login failed", zap.Error(err), zap.String("username", req.Username))
 		
+		if loginLocked(w, err) {
+			return
+		}
+		if err.Error() == constants.MsgEmailNotVerified || err.Error() == constants.MsgAccountDisabled {
+			response.Forbidden(w, err.Error())
+			return
+		}
 		if strings.Contains(err.Error(), constants.MsgInvalidCredentials) {
 			response.Unauthorized(w, constants.MsgInvalidCredentials)
 			return
@@ -84,6 +113,50 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if resp.MFAChallenge != nil {
+		logger.Info("Login awaiting second factor", zap.String("username", req.Username))
+		response.Success(w, resp.MFAChallenge)
+		return
+	}
+
+	logger.Info("User logged in successfully", zap.String("user_id", resp.User.ID), zap.String("username", resp.User.Username))
+	response.Success(w, resp)
+}
+
+// VerifyMFA completes a login with the challenge token returned by Login and
+// a TOTP or recovery code
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req dto.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode MFA verify request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		response.BadRequest(w, "MFA token and a code or recovery code are required")
+		return
+	}
+
+	req.Client = clientInfo(r)
+
+	resp, err := h.authService.VerifyMFA(&req)
+	if err != nil {
+		logger.Error("MFA verification failed", zap.Error(err))
+
+		switch err.Error() {
+		case constants.MsgInvalidToken, constants.MsgInvalidMFACode:
+			response.Unauthorized(w, err.Error())
+		case constants.MsgMFALocked:
+			response.TooManyRequests(w, err.Error())
+		case constants.MsgAccountDisabled:
+			response.Forbidden(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
 	logger.Info("User logged in successfully", zap.String("user_id", resp.User.ID), zap.String("username", resp.User.Username))
 	response.Success(w, resp)
 }
@@ -101,11 +174,14 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Client = clientInfo(r)
+
 	resp, err := h.authService.RefreshToken(&req)
 	if err != nil {
 		logger.Error("Token refresh failed", zap.Error(err))
 		
-		if strings.Contains(err.Error(), "invalid") || strings.Contains(err.Error(), "expired") {
+		switch err.Error() {
+		case constants.MsgInvalidToken, constants.MsgTokenExpired, constants.MsgTokenRevoked:
 			response.Unauthorized(w, err.Error())
 			return
 		}
@@ -142,3 +218,127 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		Message: constants.MsgLogoutSuccess,
 	})
 }
+
+// SwitchOrganization issues an access token for the organization the session
+// switches to
+func (h *AuthHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+	sessionID, _ := r.Context().Value("session_id").(string)
+
+	var req dto.SwitchOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode switch organization request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	resp, err := h.authService.SwitchOrganization(userID, sessionID, req.OrgID)
+	if err != nil {
+		logger.Error("Failed to switch organization", zap.Error(err), zap.String("user_id", userID), zap.String("org_id", req.OrgID))
+
+		switch err.Error() {
+		case constants.MsgOrgNotFound:
+			response.NotFound(w, err.Error())
+		case constants.MsgSessionNotFound:
+			response.Unauthorized(w, constants.MsgInvalidToken)
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("Organization switched", zap.String("user_id", userID), zap.String("session_id", sessionID), zap.String("org_id", resp.OrgID))
+	response.Success(w, resp)
+}
+
+// ChangePassword changes the password of the authenticated user and returns
+// a new access token for the current session
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+	sessionID, _ := r.Context().Value("session_id").(string)
+	tokenID, _ := r.Context().Value("token_id").(string)
+	orgID, _ := r.Context().Value("org_id").(string)
+
+	var req dto.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode change password request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		response.BadRequest(w, "Current and new password are required")
+		return
+	}
+
+	req.Client = clientInfo(r)
+
+	resp, err := h.authService.ChangePassword(userID, sessionID, tokenID, orgID, &req)
+	if err != nil {
+		if passwordPolicyError(w, err, "new_password") || loginLocked(w, err) {
+			return
+		}
+		logger.Error("Password change failed", zap.Error(err), zap.String("user_id", userID))
+
+		switch err.Error() {
+		case constants.MsgWrongPassword:
+			response.Forbidden(w, err.Error())
+		case constants.MsgSamePassword:
+			response.BadRequest(w, err.Error())
+		case constants.MsgUserNotFound:
+			response.NotFound(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("Password changed", zap.String("user_id", userID), zap.Bool("revoked_other_sessions", req.RevokeOtherSessions))
+	response.Success(w, resp)
+}
+
+// loginLocked writes 429 with Retry-After if err is a password check held
+// back by earlier failures, and reports whether it was
+func loginLocked(w http.ResponseWriter, err error) bool {
+	var locked *service.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	response.TooManyRequests(w, err.Error())
+	return true
+}
+
+// clientInfo captures the request metadata recorded on sessions. RemoteAddr
+// already holds the client address when the RealIP middleware is in use.
+func clientInfo(r *http.Request) dto.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	// Cut on a character boundary so that the stored value stays valid UTF-8
+	userAgent := strings.ToValidUTF8(r.UserAgent(), "")
+	if len(userAgent) > 512 {
+		cut := 512
+		for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+			cut--
+		}
+		userAgent = userAgent[:cut]
+	}
+
+	return dto.ClientInfo{
+		UserAgent: userAgent,
+		IPAddress: ip,
+	}
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/service"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+const concurrentRefreshes = 25
+
+// setupSQLiteDB opens a file backed database so that concurrent requests use
+// separate connections. Transactions take the write lock up front, which is
+// the closest SQLite gets to row locking.
+func setupSQLiteDB(t *testing.T) *gorm.DB {
+	dsn := filepath.Join(t.TempDir(), "auth.db") + "?_busy_timeout=10000&_journal_mode=WAL&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	if err := db.AutoMigrate(&model.User{}, &model.RefreshToken{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	return db
+}
+
+// setupPostgresDB migrates a throwaway schema in the database pointed to by
+// TEST_DATABASE_URL and skips the test when it is not set
+func setupPostgresDB(t *testing.T) *gorm.DB {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping PostgreSQL test")
+	}
+
+	admin, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	separator := "?"
+	if strings.Contains(databaseURL, "?") {
+		separator = "&"
+	}
+	db, err := gorm.Open(postgres.Open(databaseURL+separator+"search_path="+schema), &gorm.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to connect to test schema: %v", err)
+	}
+
+	if err := db.AutoMigrate(&model.User{}, &model.RefreshToken{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	return db
+}
+
+// loginForRefreshTest registers a user and returns the handler under test
+// together with a fresh refresh token
+func loginForRefreshTest(t *testing.T, db *gorm.DB, opts ...service.AuthOption) (*AuthHandler, string) {
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := service.NewAuthService(userRepo, tokenRepo, jwtManager, opts...)
+
+	_, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	return NewAuthHandler(authService), loginResp.RefreshToken
+}
+
+// hammerRefresh sends the same refresh token from many goroutines at once and
+// returns the status codes of every response
+func hammerRefresh(h *AuthHandler, refreshToken string) []int {
+	body, _ := json.Marshal(dto.RefreshRequest{RefreshToken: refreshToken})
+
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		codes = make([]int, concurrentRefreshes)
+	)
+	for i := 0; i < concurrentRefreshes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+			h.RefreshToken(rec, req)
+			codes[i] = rec.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	return codes
+}
+
+func countStatus(codes []int, status int) int {
+	count := 0
+	for _, code := range codes {
+		if code == status {
+			count++
+		}
+	}
+	return count
+}
+
+func countActiveTokens(t *testing.T, db *gorm.DB) int64 {
+	var count int64
+	if err := db.Model(&model.RefreshToken{}).Where("revoked = false").Count(&count).Error; err != nil {
+		t.Fatalf("Failed to count active tokens: %v", err)
+	}
+	return count
+}
+
+func testConcurrentRefreshStrict(t *testing.T, db *gorm.DB) {
+	h, refreshToken := loginForRefreshTest(t, db)
+
+	// Execute
+	codes := hammerRefresh(h, refreshToken)
+
+	// Assert: one request wins the rotation, every other one is a replay of
+	// a rotated token, which revokes the family including the winner's token
+	if ok := countStatus(codes, http.StatusOK); ok != 1 {
+		t.Errorf("Expected exactly 1 successful refresh, got %d (codes %v)", ok, codes)
+	}
+
+	if unauthorized := countStatus(codes, http.StatusUnauthorized); unauthorized != concurrentRefreshes-1 {
+		t.Errorf("Expected %d rejected refreshes, got %d (codes %v)", concurrentRefreshes-1, unauthorized, codes)
+	}
+
+	if active := countActiveTokens(t, db); active != 0 {
+		t.Errorf("Expected family to be revoked after concurrent reuse, got %d active tokens", active)
+	}
+}
+
+func testConcurrentRefreshWithGrace(t *testing.T, db *gorm.DB) {
+	h, refreshToken := loginForRefreshTest(t, db, service.WithRefreshReuseGrace(time.Minute))
+
+	// Execute
+	codes := hammerRefresh(h, refreshToken)
+
+	// Assert: retries inside the grace window succeed but each one replaces
+	// the previous descendant, so only one valid token is left
+	if ok := countStatus(codes, http.StatusOK); ok != concurrentRefreshes {
+		t.Errorf("Expected every refresh within the grace window to succeed, got %d (codes %v)", ok, codes)
+	}
+
+	if active := countActiveTokens(t, db); active != 1 {
+		t.Errorf("Expected exactly 1 active token, got %d", active)
+	}
+}
+
+func TestAuthHandler_RefreshToken_ConcurrentStrict_SQLite(t *testing.T) {
+	testConcurrentRefreshStrict(t, setupSQLiteDB(t))
+}
+
+func TestAuthHandler_RefreshToken_ConcurrentWithGrace_SQLite(t *testing.T) {
+	testConcurrentRefreshWithGrace(t, setupSQLiteDB(t))
+}
+
+func TestAuthHandler_RefreshToken_ConcurrentStrict_Postgres(t *testing.T) {
+	testConcurrentRefreshStrict(t, setupPostgresDB(t))
+}
+
+func TestAuthHandler_RefreshToken_ConcurrentWithGrace_Postgres(t *testing.T) {
+	testConcurrentRefreshWithGrace(t, setupPostgresDB(t))
+}
+
+func TestClientInfo_TruncatesUserAgentOnCharacterBoundary(t *testing.T) {
+	// Setup: 511 bytes followed by a three byte character that straddles the
+	// limit, and an invalid byte
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+	req.Header.Set("User-Agent", "\xff"+strings.Repeat("a", 511)+"€€")
+
+	// Execute
+	info := clientInfo(req)
+
+	// Assert
+	if !utf8.ValidString(info.UserAgent) {
+		t.Fatalf("Expected a valid UTF-8 user agent, got %q", info.UserAgent)
+	}
+	if info.UserAgent != strings.Repeat("a", 511) {
+		t.Errorf("Expected the user agent to be cut before the split character, got %d bytes", len(info.UserAgent))
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"authorization/internal/authz"
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type AuthzHandler struct {
+	authzService *service.AuthzService
+}
+
+func NewAuthzHandler(authzService *service.AuthzService) *AuthzHandler {
+	return &AuthzHandler{
+		authzService: authzService,
+	}
+}
+
+// Check answers whether a user holds a permission, so other services do not
+// have to evaluate roles themselves
+func (h *AuthzHandler) Check(w http.ResponseWriter, r *http.Request) {
+	callerID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	var req dto.AuthzCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode authorization check request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := authz.ValidatePermission(req.Permission); err != nil {
+		response.BadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := h.authzService.Check(callerID, &req)
+	if err != nil {
+		logger.Error("Authorization check failed", zap.Error(err), zap.String("caller_id", callerID), zap.String("user_id", req.UserID))
+
+		switch err.Error() {
+		case constants.MsgForbidden:
+			response.Forbidden(w, constants.MsgForbidden)
+		case constants.MsgUserNotFound:
+			response.NotFound(w, constants.MsgUserNotFound)
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	response.Success(w, resp)
+}
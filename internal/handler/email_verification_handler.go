@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type EmailVerificationHandler struct {
+	emailVerificationService *service.EmailVerificationService
+}
+
+func NewEmailVerificationHandler(emailVerificationService *service.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{
+		emailVerificationService: emailVerificationService,
+	}
+}
+
+func (h *EmailVerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req dto.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode verify email request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" {
+		response.BadRequest(w, "Token is required")
+		return
+	}
+
+	resp, err := h.emailVerificationService.VerifyEmail(&req)
+	if err != nil {
+		logger.Error("Email verification failed", zap.Error(err))
+
+		if err.Error() == constants.MsgInvalidVerifyToken {
+			response.BadRequest(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// ResendVerification sends a new verification link if the email belongs to
+// an unverified account. The response does not say whether it does.
+func (h *EmailVerificationHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req dto.ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode resend verification request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" {
+		response.BadRequest(w, "Email is required")
+		return
+	}
+
+	resp, err := h.emailVerificationService.ResendVerification(&req)
+	if err != nil {
+		logger.Error("Resending verification failed", zap.Error(err))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"authorization/internal/pkg/response"
+	"authorization/internal/utils"
+	"fmt"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	jwtManager *utils.JWTManager
+}
+
+func NewJWKSHandler(jwtManager *utils.JWTManager) *JWKSHandler {
+	return &JWKSHandler{
+		jwtManager: jwtManager,
+	}
+}
+
+// JWKS publishes the public keys other services verify access tokens with
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(utils.JWKSMaxAge.Seconds())))
+	response.Success(w, h.jwtManager.JWKS())
+}
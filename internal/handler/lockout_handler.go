@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type LockoutHandler struct {
+	lockoutService *service.LockoutService
+}
+
+func NewLockoutHandler(lockoutService *service.LockoutService) *LockoutHandler {
+	return &LockoutHandler{
+		lockoutService: lockoutService,
+	}
+}
+
+// ListLockouts returns the usernames and addresses whose logins are held back
+func (h *LockoutHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.lockoutService.List()
+	if err != nil {
+		logger.Error("Failed to list lockouts", zap.Error(err))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *LockoutHandler) GetUserLockout(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	resp, err := h.lockoutService.UserStatus(userID)
+	if err != nil {
+		logger.Error("Failed to get lockout", zap.Error(err), zap.String("user_id", userID))
+
+		if err.Error() == constants.MsgUserNotFound {
+			response.NotFound(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *LockoutHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	resp, err := h.lockoutService.UnlockUser(userID)
+	if err != nil {
+		logger.Error("Failed to unlock user", zap.Error(err), zap.String("user_id", userID))
+
+		if err.Error() == constants.MsgUserNotFound {
+			response.NotFound(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	logger.SecurityEvent(constants.EventLockoutCleared,
+		zap.String("user_id", userID),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, resp)
+}
+
+func (h *LockoutHandler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	parsed := net.ParseIP(chi.URLParam(r, "ip"))
+	if parsed == nil {
+		response.BadRequest(w, "Invalid IP address")
+		return
+	}
+	ip := parsed.String()
+
+	resp, err := h.lockoutService.UnlockIP(ip)
+	if err != nil {
+		logger.Error("Failed to unlock IP address", zap.Error(err), zap.String("ip_address", ip))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	logger.SecurityEvent(constants.EventLockoutCleared,
+		zap.String("ip_address", ip),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, resp)
+}
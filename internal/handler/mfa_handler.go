@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type MFAHandler struct {
+	mfaService *service.MFAService
+}
+
+func NewMFAHandler(mfaService *service.MFAService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+	}
+}
+
+func (h *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	resp, err := h.mfaService.Status(userID)
+	if err != nil {
+		logger.Error("Failed to get MFA status", zap.Error(err), zap.String("user_id", userID))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// Enroll generates a TOTP secret for the user to add to an authenticator app
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	resp, err := h.mfaService.Enroll(userID)
+	if err != nil {
+		logger.Error("MFA enrollment failed", zap.Error(err), zap.String("user_id", userID))
+
+		switch err.Error() {
+		case constants.MsgMFAAlreadyEnabled:
+			response.Conflict(w, err.Error())
+		case constants.MsgUserNotFound:
+			response.NotFound(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// Confirm enables the enrolled secret and returns the recovery codes
+func (h *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	var req dto.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode MFA confirm request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Code == "" {
+		response.BadRequest(w, "Code is required")
+		return
+	}
+
+	resp, err := h.mfaService.Confirm(userID, req.Code)
+	if err != nil {
+		logger.Error("MFA confirmation failed", zap.Error(err), zap.String("user_id", userID))
+
+		switch err.Error() {
+		case constants.MsgInvalidMFACode, constants.MsgMFANoEnrollment:
+			response.BadRequest(w, err.Error())
+		case constants.MsgMFAAlreadyEnabled:
+			response.Conflict(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("MFA enabled", zap.String("user_id", userID))
+	response.Success(w, resp)
+}
+
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	h.withCode(w, r, "disable MFA", func(userID string, req *dto.MFACodeRequest) (interface{}, error) {
+		return h.mfaService.Disable(userID, req)
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	h.withCode(w, r, "regenerate recovery codes", func(userID string, req *dto.MFACodeRequest) (interface{}, error) {
+		return h.mfaService.RegenerateRecoveryCodes(userID, req)
+	})
+}
+
+// withCode handles requests that need a current TOTP or recovery code
+func (h *MFAHandler) withCode(w http.ResponseWriter, r *http.Request, action string, fn func(userID string, req *dto.MFACodeRequest) (interface{}, error)) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	var req dto.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode MFA request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		response.BadRequest(w, "Code or recovery code is required")
+		return
+	}
+
+	resp, err := fn(userID, &req)
+	if err != nil {
+		logger.Error("Failed to "+action, zap.Error(err), zap.String("user_id", userID))
+
+		switch err.Error() {
+		case constants.MsgInvalidMFACode:
+			response.Forbidden(w, err.Error())
+		case constants.MsgMFANotEnabled:
+			response.BadRequest(w, err.Error())
+		case constants.MsgMFALocked:
+			response.TooManyRequests(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	response.Success(w, resp)
+}
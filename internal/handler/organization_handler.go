@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// orgSlugPattern keeps organization slugs usable in URLs
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,99}$`)
+
+type OrganizationHandler struct {
+	orgService *service.OrganizationService
+}
+
+func NewOrganizationHandler(orgService *service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.orgService.ListOrganizations()
+	if err != nil {
+		logger.Error("Failed to list organizations", zap.Error(err))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode create organization request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if !orgSlugPattern.MatchString(req.Slug) {
+		response.BadRequest(w, "Slug must be 2 to 100 lowercase letters, digits and '-', starting with a letter or digit")
+		return
+	}
+	if req.Name == "" || len(req.Name) > 255 {
+		response.BadRequest(w, "Name is required and must be at most 255 characters long")
+		return
+	}
+
+	resp, err := h.orgService.CreateOrganization(&req)
+	if err != nil {
+		logger.Error("Failed to create organization", zap.Error(err), zap.String("slug", req.Slug))
+
+		switch err.Error() {
+		case constants.MsgOrgAlreadyExists:
+			response.Conflict(w, err.Error())
+		case constants.MsgUserNotFound:
+			response.BadRequest(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("Organization created", zap.String("org_id", resp.ID), zap.String("slug", resp.Slug), zap.String("owner_id", req.OwnerID), zap.String("actor_id", actorID(r)))
+	response.Created(w, resp)
+}
+
+// ListMemberships lists the organizations of the current user
+func (h *OrganizationHandler) ListMemberships(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+	orgID, _ := r.Context().Value("org_id").(string)
+
+	resp, err := h.orgService.ListMemberships(userID, orgID)
+	if err != nil {
+		logger.Error("Failed to list organizations", zap.Error(err), zap.String("user_id", userID))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "id")
+	resp, err := h.orgService.ListMembers(actorID(r), orgID)
+	if err != nil {
+		logger.Error("Failed to list members", zap.Error(err), zap.String("org_id", orgID), zap.String("actor_id", actorID(r)))
+		organizationError(w, err)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *OrganizationHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	var req dto.SetMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode set member request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	orgID := chi.URLParam(r, "id")
+	userID := chi.URLParam(r, "userID")
+	resp, err := h.orgService.SetMember(actorID(r), orgID, userID, &req)
+	if err != nil {
+		logger.Error("Failed to set member", zap.Error(err), zap.String("org_id", orgID), zap.String("user_id", userID), zap.String("actor_id", actorID(r)))
+		organizationError(w, err)
+		return
+	}
+
+	logger.SecurityEvent(constants.EventMemberUpdated,
+		zap.String("org_id", orgID),
+		zap.String("user_id", userID),
+		zap.Strings("roles", resp.Roles),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, resp)
+}
+
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "id")
+	userID := chi.URLParam(r, "userID")
+	if err := h.orgService.RemoveMember(actorID(r), orgID, userID); err != nil {
+		logger.Error("Failed to remove member", zap.Error(err), zap.String("org_id", orgID), zap.String("user_id", userID), zap.String("actor_id", actorID(r)))
+		organizationError(w, err)
+		return
+	}
+
+	logger.SecurityEvent(constants.EventMemberRemoved,
+		zap.String("org_id", orgID),
+		zap.String("user_id", userID),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, dto.RemoveMemberResponse{
+		Message: constants.MsgMemberRemoved,
+	})
+}
+
+func organizationError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case constants.MsgOrgNotFound, constants.MsgUserNotFound, constants.MsgNotMember, constants.MsgRoleNotFound:
+		response.NotFound(w, err.Error())
+	case constants.MsgForbidden:
+		response.Forbidden(w, err.Error())
+	case constants.MsgRoleWrongScope:
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalError(w, constants.MsgInternalError)
+	}
+}
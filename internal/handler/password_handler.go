@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/passwordpolicy"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type PasswordHandler struct {
+	passwordService *service.PasswordService
+}
+
+func NewPasswordHandler(passwordService *service.PasswordService) *PasswordHandler {
+	return &PasswordHandler{
+		passwordService: passwordService,
+	}
+}
+
+// ForgotPassword sends a reset link if the email belongs to an account. The
+// response does not say whether it does.
+func (h *PasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode forgot password request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" {
+		response.BadRequest(w, "Email is required")
+		return
+	}
+
+	resp, err := h.passwordService.ForgotPassword(&req)
+	if err != nil {
+		logger.Error("Forgot password failed", zap.Error(err))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode reset password request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		response.BadRequest(w, "Token and new password are required")
+		return
+	}
+
+	resp, err := h.passwordService.ResetPassword(&req)
+	if err != nil {
+		if passwordPolicyError(w, err, "new_password") {
+			return
+		}
+		logger.Error("Password reset failed", zap.Error(err))
+
+		if err.Error() == constants.MsgInvalidResetToken {
+			response.BadRequest(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// passwordPolicyError sends the rules of the password policy a new password
+// broke as validation errors of field and reports whether err was such a
+// violation
+func passwordPolicyError(w http.ResponseWriter, err error, field string) bool {
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	details := make([]dto.ValidationError, len(policyErr.Failures))
+	for i, failure := range policyErr.Failures {
+		details[i] = dto.ValidationError{Field: field, Rule: failure.Rule, Message: failure.Message}
+	}
+	response.ValidationError(w, details)
+	return true
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// maxPolicyBodySize bounds the size of a stored policy
+const maxPolicyBodySize = 64 << 10
+
+type PolicyHandler struct {
+	policyService *service.PolicyService
+}
+
+func NewPolicyHandler(policyService *service.PolicyService) *PolicyHandler {
+	return &PolicyHandler{
+		policyService: policyService,
+	}
+}
+
+// Evaluate decides a request against the loaded policies
+func (h *PolicyHandler) Evaluate(w http.ResponseWriter, r *http.Request) {
+	var req dto.PolicyEvaluateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode policy evaluate request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Action == "" {
+		response.BadRequest(w, "Action is required")
+		return
+	}
+
+	resp := h.policyService.Evaluate(&req)
+	response.Success(w, resp)
+}
+
+func (h *PolicyHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.policyService.ListPolicies()
+	if err != nil {
+		logger.Error("Failed to list policies", zap.Error(err))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *PolicyHandler) PutPolicy(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPolicyBodySize)
+
+	var req dto.PutPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode put policy request", zap.Error(err))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(w, http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE", "Policy must be at most 64 KiB")
+			return
+		}
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if len(req.Policy) == 0 {
+		response.BadRequest(w, "Policy is required")
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	resp, err := h.policyService.PutPolicy(id, &req)
+	if err != nil {
+		logger.Error("Failed to save policy", zap.Error(err), zap.String("policy", id))
+		policyError(w, err)
+		return
+	}
+
+	logger.Info("Policy saved", zap.String("policy", id), zap.Bool("enabled", resp.Enabled), zap.String("actor_id", actorID(r)))
+	response.Success(w, resp)
+}
+
+func (h *PolicyHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.policyService.DeletePolicy(id); err != nil {
+		logger.Error("Failed to delete policy", zap.Error(err), zap.String("policy", id))
+		policyError(w, err)
+		return
+	}
+
+	logger.Info("Policy deleted", zap.String("policy", id), zap.String("actor_id", actorID(r)))
+	response.Success(w, dto.DeletePolicyResponse{
+		Message: constants.MsgPolicyDeleted,
+	})
+}
+
+func policyError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == constants.MsgPolicyNotFound:
+		response.NotFound(w, err.Error())
+	case err.Error() == constants.MsgPolicyFromFile:
+		response.Conflict(w, err.Error())
+	case strings.HasPrefix(err.Error(), constants.MsgInvalidPolicy):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalError(w, constants.MsgInternalError)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type RelationHandler struct {
+	relationService *service.RelationService
+}
+
+func NewRelationHandler(relationService *service.RelationService) *RelationHandler {
+	return &RelationHandler{
+		relationService: relationService,
+	}
+}
+
+// Write touches or deletes relation tuples
+func (h *RelationHandler) Write(w http.ResponseWriter, r *http.Request) {
+	var req dto.WriteRelationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode write relations request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	resp, err := h.relationService.Write(&req)
+	if err != nil {
+		logger.Error("Failed to write relations", zap.Error(err), zap.String("actor_id", actorID(r)))
+		relationError(w, err)
+		return
+	}
+
+	logger.Info("Relations written", zap.Int("updates", len(req.Updates)), zap.String("zookie", resp.Zookie), zap.String("actor_id", actorID(r)))
+	response.Success(w, resp)
+}
+
+// Read lists the tuples of an object
+func (h *RelationHandler) Read(w http.ResponseWriter, r *http.Request) {
+	var req dto.ReadRelationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode read relations request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	resp, err := h.relationService.Read(&req)
+	if err != nil {
+		logger.Error("Failed to read relations", zap.Error(err), zap.String("object", req.Object))
+		relationError(w, err)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// Check answers whether a subject has a relation to an object
+func (h *RelationHandler) Check(w http.ResponseWriter, r *http.Request) {
+	callerID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	var req dto.CheckRelationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode check relation request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	resp, err := h.relationService.Check(callerID, &req)
+	if err != nil {
+		logger.Error("Relation check failed", zap.Error(err), zap.String("caller_id", callerID), zap.String("object", req.Object), zap.String("subject", req.Subject))
+		relationError(w, err)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// Expand returns the userset tree of an object's relation
+func (h *RelationHandler) Expand(w http.ResponseWriter, r *http.Request) {
+	var req dto.ExpandRelationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode expand relation request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	resp, err := h.relationService.Expand(&req)
+	if err != nil {
+		logger.Error("Failed to expand relation", zap.Error(err), zap.String("object", req.Object), zap.String("relation", req.Relation))
+		relationError(w, err)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// ListObjects returns the objects a subject has a relation to
+func (h *RelationHandler) ListObjects(w http.ResponseWriter, r *http.Request) {
+	callerID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	var req dto.ListObjectsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode list objects request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	resp, err := h.relationService.ListObjects(callerID, &req)
+	if err != nil {
+		logger.Error("Failed to list objects", zap.Error(err), zap.String("caller_id", callerID), zap.String("namespace", req.Namespace), zap.String("subject", req.Subject))
+		relationError(w, err)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// relationError maps relation service errors to responses
+func relationError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), constants.MsgInvalidRelation), err.Error() == constants.MsgInvalidZookie:
+		response.BadRequest(w, err.Error())
+	case err.Error() == constants.MsgForbidden:
+		response.Forbidden(w, constants.MsgForbidden)
+	default:
+		response.InternalError(w, constants.MsgInternalError)
+	}
+}
@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"authorization/internal/authz"
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// roleNamePattern keeps role names usable in URLs and token claims
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,99}$`)
+
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.roleService.ListRoles()
+	if err != nil {
+		logger.Error("Failed to list roles", zap.Error(err))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *RoleHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode create role request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if !roleNamePattern.MatchString(req.Name) {
+		response.BadRequest(w, "Role name must start with a lowercase letter and contain only lowercase letters, digits, '-' and '_'")
+		return
+	}
+
+	switch req.Scope {
+	case "", constants.RoleScopeGlobal, constants.RoleScopeOrganization:
+	default:
+		response.BadRequest(w, "Scope must be global or organization")
+		return
+	}
+
+	resp, err := h.roleService.CreateRole(actorID(r), &req)
+	if err != nil {
+		logger.Error("Failed to create role", zap.Error(err), zap.String("role", req.Name))
+
+		switch {
+		case err.Error() == constants.MsgRoleAlreadyExists:
+			response.Conflict(w, err.Error())
+		case err.Error() == constants.MsgOrgRoleTooBroad:
+			response.BadRequest(w, err.Error())
+		case strings.HasPrefix(err.Error(), constants.MsgUnknownPermission):
+			response.BadRequest(w, err.Error())
+		case strings.HasPrefix(err.Error(), constants.MsgGrantNotHeld):
+			response.Forbidden(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("Role created", zap.String("role", resp.Name), zap.String("actor_id", actorID(r)))
+	response.Created(w, resp)
+}
+
+func (h *RoleHandler) SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	var req dto.SetRolePermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode role permissions request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	resp, err := h.roleService.SetRolePermissions(actorID(r), name, &req)
+	if err != nil {
+		logger.Error("Failed to set role permissions", zap.Error(err), zap.String("role", name))
+
+		switch {
+		case err.Error() == constants.MsgRoleNotFound:
+			response.NotFound(w, err.Error())
+		case err.Error() == constants.MsgOrgRoleTooBroad:
+			response.BadRequest(w, err.Error())
+		case strings.HasPrefix(err.Error(), constants.MsgUnknownPermission):
+			response.BadRequest(w, err.Error())
+		case strings.HasPrefix(err.Error(), constants.MsgGrantNotHeld):
+			response.Forbidden(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("Role permissions updated", zap.String("role", name), zap.Strings("permissions", resp.Permissions), zap.String("actor_id", actorID(r)))
+	response.Success(w, resp)
+}
+
+func (h *RoleHandler) SetRoleInherits(w http.ResponseWriter, r *http.Request) {
+	var req dto.SetRoleInheritsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode role inheritance request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	resp, err := h.roleService.SetRoleInherits(actorID(r), name, &req)
+	if err != nil {
+		logger.Error("Failed to set role inheritance", zap.Error(err), zap.String("role", name))
+
+		switch {
+		case err.Error() == constants.MsgRoleNotFound:
+			response.NotFound(w, err.Error())
+		case err.Error() == constants.MsgOrgRoleTooBroad:
+			response.BadRequest(w, err.Error())
+		case strings.HasPrefix(err.Error(), constants.MsgInheritanceCycle):
+			response.Conflict(w, err.Error())
+		case strings.HasPrefix(err.Error(), constants.MsgGrantNotHeld):
+			response.Forbidden(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("Role inheritance updated", zap.String("role", name), zap.Strings("inherits", resp.Inherits), zap.String("actor_id", actorID(r)))
+	response.Success(w, resp)
+}
+
+func (h *RoleHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.roleService.ListPermissions()
+	if err != nil {
+		logger.Error("Failed to list permissions", zap.Error(err))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *RoleHandler) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreatePermissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode create permission request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := authz.ValidatePermission(req.Name); err != nil {
+		response.BadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := h.roleService.CreatePermission(&req)
+	if err != nil {
+		logger.Error("Failed to create permission", zap.Error(err), zap.String("permission", req.Name))
+
+		if err.Error() == constants.MsgPermissionExists {
+			response.Conflict(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	logger.Info("Permission created", zap.String("permission", resp.Name), zap.String("actor_id", actorID(r)))
+	response.Created(w, resp)
+}
+
+func (h *RoleHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	resp, err := h.roleService.GetUserRoles(userID)
+	if err != nil {
+		logger.Error("Failed to get user roles", zap.Error(err), zap.String("user_id", userID))
+
+		if err.Error() == constants.MsgUserNotFound {
+			response.NotFound(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *RoleHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	var req dto.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode assign role request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Role == "" {
+		response.BadRequest(w, "Role is required")
+		return
+	}
+
+	userID := chi.URLParam(r, "id")
+	resp, err := h.roleService.AssignRole(actorID(r), userID, req.Role)
+	if err != nil {
+		logger.Error("Failed to assign role", zap.Error(err), zap.String("user_id", userID), zap.String("role", req.Role))
+
+		switch {
+		case err.Error() == constants.MsgUserNotFound, err.Error() == constants.MsgRoleNotFound:
+			response.NotFound(w, err.Error())
+		case err.Error() == constants.MsgRoleWrongScope:
+			response.BadRequest(w, err.Error())
+		case strings.HasPrefix(err.Error(), constants.MsgGrantNotHeld):
+			response.Forbidden(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.SecurityEvent(constants.EventRoleAssigned,
+		zap.String("user_id", userID),
+		zap.String("role", req.Role),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, resp)
+}
+
+func (h *RoleHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	role := chi.URLParam(r, "role")
+
+	if err := h.roleService.RevokeRole(userID, role); err != nil {
+		logger.Error("Failed to revoke role", zap.Error(err), zap.String("user_id", userID), zap.String("role", role))
+
+		switch err.Error() {
+		case constants.MsgUserNotFound, constants.MsgRoleNotFound:
+			response.NotFound(w, err.Error())
+		case constants.MsgLastAdmin:
+			response.Conflict(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.SecurityEvent(constants.EventRoleRevoked,
+		zap.String("user_id", userID),
+		zap.String("role", role),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, dto.RevokeRoleResponse{
+		Message: constants.MsgRoleRevoked,
+	})
+}
+
+// actorID returns the authenticated user performing an admin action
+func actorID(r *http.Request) string {
+	userID, _ := r.Context().Value("user_id").(string)
+	return userID
+}
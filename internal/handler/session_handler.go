@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type SessionHandler struct {
+	sessionService *service.SessionService
+}
+
+func NewSessionHandler(sessionService *service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+	sessionID, _ := r.Context().Value("session_id").(string)
+
+	resp, err := h.sessionService.ListSessions(userID, sessionID)
+	if err != nil {
+		logger.Error("Failed to list sessions", zap.Error(err), zap.String("user_id", userID))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	sessionID := chi.URLParam(r, "id")
+	if err := h.sessionService.RevokeSession(userID, sessionID); err != nil {
+		logger.Error("Failed to revoke session", zap.Error(err), zap.String("user_id", userID), zap.String("session_id", sessionID))
+
+		if err.Error() == constants.MsgSessionNotFound {
+			response.NotFound(w, constants.MsgSessionNotFound)
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	logger.Info("Session revoked", zap.String("user_id", userID), zap.String("session_id", sessionID))
+	response.Success(w, dto.RevokeSessionsResponse{
+		Message: constants.MsgSessionRevoked,
+	})
+}
+
+// RevokeAll signs the user out of every other session, or of every session
+// including the current one when include_current=true
+func (h *SessionHandler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+	sessionID, _ := r.Context().Value("session_id").(string)
+
+	var err error
+	if r.URL.Query().Get("include_current") == "true" || sessionID == "" {
+		err = h.sessionService.RevokeAllSessions(userID)
+	} else {
+		err = h.sessionService.RevokeOtherSessions(userID, sessionID)
+	}
+	if err != nil {
+		logger.Error("Failed to revoke sessions", zap.Error(err), zap.String("user_id", userID))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	logger.Info("Sessions revoked", zap.String("user_id", userID))
+	response.Success(w, dto.RevokeSessionsResponse{
+		Message: constants.MsgSessionsRevoked,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"authorization/internal/service"
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
@@ -43,3 +44,55 @@ func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
 	logger.Info("User info retrieved successfully", zap.String("user_id", userID))
 	response.Success(w, user)
 }
+
+// Disable stops a user from logging in and revokes their sessions and
+// access tokens
+func (h *UserHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	resp, err := h.userService.DisableUser(actorID(r), userID)
+	if err != nil {
+		logger.Error("Failed to disable user", zap.Error(err), zap.String("user_id", userID))
+
+		switch err.Error() {
+		case constants.MsgUserNotFound:
+			response.NotFound(w, err.Error())
+		case constants.MsgCannotDisableSelf:
+			response.BadRequest(w, err.Error())
+		case constants.MsgLastActiveAdmin:
+			response.Conflict(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.SecurityEvent(constants.EventUserDisabled,
+		zap.String("user_id", userID),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, resp)
+}
+
+// Enable lets a disabled user log in again
+func (h *UserHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	resp, err := h.userService.EnableUser(userID)
+	if err != nil {
+		logger.Error("Failed to enable user", zap.Error(err), zap.String("user_id", userID))
+
+		if err.Error() == constants.MsgUserNotFound {
+			response.NotFound(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	logger.SecurityEvent(constants.EventUserEnabled,
+		zap.String("user_id", userID),
+		zap.String("actor_id", actorID(r)),
+	)
+	response.Success(w, resp)
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/service"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type WebAuthnHandler struct {
+	webauthnService *service.WebAuthnService
+	authService     *service.AuthService
+}
+
+func NewWebAuthnHandler(webauthnService *service.WebAuthnService, authService *service.AuthService) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webauthnService: webauthnService,
+		authService:     authService,
+	}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create
+func (h *WebAuthnHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	resp, err := h.webauthnService.BeginRegistration(userID)
+	if err != nil {
+		logger.Error("Failed to begin passkey registration", zap.Error(err), zap.String("user_id", userID))
+
+		if err.Error() == constants.MsgUserNotFound {
+			response.NotFound(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *WebAuthnHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	var req dto.WebAuthnRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode passkey registration request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Credential.Response.ClientDataJSON == "" || req.Credential.Response.AttestationObject == "" {
+		response.BadRequest(w, "Credential is required")
+		return
+	}
+
+	if len(req.Name) > 100 {
+		response.BadRequest(w, "Name must be at most 100 characters long")
+		return
+	}
+
+	resp, err := h.webauthnService.FinishRegistration(userID, &req)
+	if err != nil {
+		logger.Error("Passkey registration failed", zap.Error(err), zap.String("user_id", userID))
+
+		switch err.Error() {
+		case constants.MsgWebAuthnFailed:
+			response.BadRequest(w, err.Error())
+		case constants.MsgPasskeyExists:
+			response.Conflict(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("Passkey registered", zap.String("user_id", userID), zap.String("passkey_id", resp.ID))
+	response.Created(w, resp)
+}
+
+// BeginLogin returns the options for navigator.credentials.get
+func (h *WebAuthnHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	var req dto.WebAuthnLoginBeginRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Error("Failed to decode passkey login request", zap.Error(err))
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	resp, err := h.authService.BeginWebAuthnLogin(&req)
+	if err != nil {
+		logger.Error("Failed to begin passkey login", zap.Error(err))
+
+		switch err.Error() {
+		case constants.MsgInvalidToken:
+			response.Unauthorized(w, err.Error())
+		case constants.MsgPasskeyNotFound:
+			response.BadRequest(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+// FinishLogin completes a passkey login and returns the same tokens as a
+// password login
+func (h *WebAuthnHandler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	var req dto.WebAuthnLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Failed to decode passkey login request", zap.Error(err))
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Credential.RawID == "" || req.Credential.Response.ClientDataJSON == "" {
+		response.BadRequest(w, "Credential is required")
+		return
+	}
+
+	if len(req.DeviceLabel) > 100 {
+		response.BadRequest(w, "Device label must be at most 100 characters long")
+		return
+	}
+
+	req.Client = clientInfo(r)
+
+	resp, err := h.authService.LoginWithWebAuthn(&req)
+	if err != nil {
+		logger.Error("Passkey login failed", zap.Error(err))
+
+		switch err.Error() {
+		case constants.MsgInvalidToken, constants.MsgWebAuthnFailed:
+			response.Unauthorized(w, err.Error())
+		case constants.MsgEmailNotVerified, constants.MsgAccountDisabled:
+			response.Forbidden(w, err.Error())
+		default:
+			response.InternalError(w, constants.MsgInternalError)
+		}
+		return
+	}
+
+	logger.Info("User logged in with passkey", zap.String("user_id", resp.User.ID), zap.String("username", resp.User.Username))
+	response.Success(w, resp)
+}
+
+func (h *WebAuthnHandler) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	resp, err := h.webauthnService.ListCredentials(userID)
+	if err != nil {
+		logger.Error("Failed to list passkeys", zap.Error(err), zap.String("user_id", userID))
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
+
+func (h *WebAuthnHandler) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		logger.Error("User ID not found in context")
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	resp, err := h.webauthnService.DeleteCredential(userID, chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Error("Failed to delete passkey", zap.Error(err), zap.String("user_id", userID))
+
+		if err.Error() == constants.MsgPasskeyNotFound {
+			response.NotFound(w, err.Error())
+			return
+		}
+		response.InternalError(w, constants.MsgInternalError)
+		return
+	}
+
+	response.Success(w, resp)
+}
@@ -1,22 +1,61 @@
 package middleware
 
 import (
+	"authorization/internal/authz"
 	"authorization/internal/constants"
+	"authorization/internal/denylist"
+	"authorization/internal/pkg/logger"
 	"authorization/internal/pkg/response"
 	"authorization/internal/utils"
 	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
+// PasswordChanges reports when a user last changed their password, nil if
+// never. Unknown users are reported with gorm.ErrRecordNotFound.
+type PasswordChanges interface {
+	PasswordChangedAt(userID string) (*time.Time, error)
+}
+
 type AuthMiddleware struct {
-	jwtManager *utils.JWTManager
+	jwtManager      *utils.JWTManager
+	passwordChanges PasswordChanges
+	denylist        denylist.Store
+}
+
+// AuthMiddlewareOption configures optional AuthMiddleware behaviour
+type AuthMiddlewareOption func(*AuthMiddleware)
+
+// WithPasswordChanges rejects access tokens issued before the user last
+// changed their password, and those of users that no longer exist
+func WithPasswordChanges(passwordChanges PasswordChanges) AuthMiddlewareOption {
+	return func(m *AuthMiddleware) {
+		m.passwordChanges = passwordChanges
+	}
+}
+
+// WithDenylist rejects access tokens that were revoked, by their jti or
+// their session, before they expired
+func WithDenylist(denylist denylist.Store) AuthMiddlewareOption {
+	return func(m *AuthMiddleware) {
+		m.denylist = denylist
+	}
 }
 
-func NewAuthMiddleware(jwtManager *utils.JWTManager) *AuthMiddleware {
-	return &AuthMiddleware{
+func NewAuthMiddleware(jwtManager *utils.JWTManager, opts ...AuthMiddlewareOption) *AuthMiddleware {
+	m := &AuthMiddleware{
 		jwtManager: jwtManager,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
@@ -39,10 +78,132 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		if m.passwordChanges != nil {
+			current, err := m.issuedAfterPasswordChange(claims)
+			if err != nil {
+				logger.Error("Failed to check password change", zap.Error(err), zap.String("user_id", claims.UserID))
+				response.InternalError(w, constants.MsgInternalError)
+				return
+			}
+			if !current {
+				response.Unauthorized(w, constants.MsgInvalidToken)
+				return
+			}
+		}
+
+		if m.denylist != nil {
+			denied, err := m.denylist.Contains(r.Context(), denylistKeys(claims)...)
+			if err != nil {
+				logger.Error("Failed to check access token denylist", zap.Error(err), zap.String("user_id", claims.UserID))
+				response.InternalError(w, constants.MsgInternalError)
+				return
+			}
+			if denied {
+				response.Unauthorized(w, constants.MsgTokenRevoked)
+				return
+			}
+		}
+
 		// Add user info to context
 		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 		ctx = context.WithValue(ctx, "username", claims.Username)
+		ctx = context.WithValue(ctx, "session_id", claims.SessionID)
+		ctx = context.WithValue(ctx, "token_id", claims.ID)
+		ctx = context.WithValue(ctx, "org_id", claims.OrgID)
+		ctx = context.WithValue(ctx, "roles", claims.Roles)
+		ctx = context.WithValue(ctx, "permissions", claims.Permissions)
 		
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// denylistKeys returns the denylist keys that can revoke the token
+func denylistKeys(claims *utils.Claims) []string {
+	var keys []string
+	if claims.ID != "" {
+		keys = append(keys, denylist.TokenKey(claims.ID))
+	}
+	if claims.SessionID != "" {
+		keys = append(keys, denylist.SessionKey(claims.SessionID))
+	}
+	return keys
+}
+
+// issuedAfterPasswordChange reports whether the token was issued after the
+// user's last password change. Issue times have second precision, so tokens
+// issued within the second of the change are accepted.
+func (m *AuthMiddleware) issuedAfterPasswordChange(claims *utils.Claims) (bool, error) {
+	changedAt, err := m.passwordChanges.PasswordChangedAt(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if changedAt == nil {
+		return true, nil
+	}
+	if claims.IssuedAt == nil {
+		return false, nil
+	}
+	return !claims.IssuedAt.Time.Before(changedAt.Truncate(time.Second)), nil
+}
+
+// RequireRole allows the request if the authenticated user holds any of the
+// given roles, directly or through inheritance. It must run after RequireAuth.
+func (m *AuthMiddleware) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			granted, _ := r.Context().Value("roles").([]string)
+			for _, role := range roles {
+				if containsString(granted, role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			denyAccess(w, r, zap.Strings("required_roles", roles))
+		})
+	}
+}
+
+// RequirePermission allows the request if the global grants of the
+// authenticated user, which may contain wildcards, cover all of the given
+// permissions. Grants held within an organization never count. It must run
+// after RequireAuth.
+func (m *AuthMiddleware) RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			granted, _ := r.Context().Value("permissions").([]string)
+			grants := authz.NewPermissionSet(granted...)
+			for _, permission := range permissions {
+				if !grants.Allows(permission) {
+					denyAccess(w, r, zap.Strings("required_permissions", permissions))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func denyAccess(w http.ResponseWriter, r *http.Request, required zap.Field) {
+	userID, _ := r.Context().Value("user_id").(string)
+	logger.SecurityEvent(constants.EventAccessDenied,
+		zap.String("user_id", userID),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		required,
+	)
+	response.Forbidden(w, constants.MsgForbidden)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/denylist"
+	"authorization/internal/utils"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestAuthMiddleware_RequirePermission(t *testing.T) {
+	// Setup
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	authMiddleware := NewAuthMiddleware(jwtManager)
+
+	handler := authMiddleware.RequireAuth(
+		authMiddleware.RequirePermission(constants.PermRolesRead, constants.PermRolesAssign)(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		),
+	)
+
+	tests := []struct {
+		name        string
+		permissions []string
+		expected    int
+	}{
+		{"all permissions", []string{constants.PermRolesAssign, constants.PermRolesRead, constants.PermUsersRead}, http.StatusNoContent},
+		{"wildcard grant", []string{"roles:*"}, http.StatusNoContent},
+		{"missing one permission", []string{constants.PermRolesRead}, http.StatusForbidden},
+		{"no permissions", nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{
+				UserID:      "user-1",
+				Username:    "testuser",
+				Roles:       []string{constants.RoleAdmin},
+				Permissions: tt.permissions,
+			})
+			if err != nil {
+				t.Fatalf("Failed to generate access token: %v", err)
+			}
+
+			// Execute
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/roles", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			// Assert
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_RequireRole(t *testing.T) {
+	// Setup
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	authMiddleware := NewAuthMiddleware(jwtManager)
+
+	handler := authMiddleware.RequireAuth(
+		authMiddleware.RequireRole(constants.RoleAdmin)(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		),
+	)
+
+	for role, expected := range map[string]int{
+		constants.RoleAdmin: http.StatusNoContent,
+		constants.RoleUser:  http.StatusForbidden,
+	} {
+		token, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{
+			UserID:   "user-1",
+			Username: "testuser",
+			Roles:    []string{role},
+		})
+		if err != nil {
+			t.Fatalf("Failed to generate access token: %v", err)
+		}
+
+		// Execute
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		// Assert
+		if rec.Code != expected {
+			t.Errorf("Expected status %d for role %s, got %d", expected, role, rec.Code)
+		}
+	}
+}
+
+// passwordChanges is a fixed map of password change times
+type passwordChanges map[string]*time.Time
+
+func (p passwordChanges) PasswordChangedAt(userID string) (*time.Time, error) {
+	changedAt, ok := p[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return changedAt, nil
+}
+
+func TestAuthMiddleware_PasswordChanges(t *testing.T) {
+	// Setup
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	token, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{UserID: "user-1", Username: "testuser"})
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+	otherToken, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{UserID: "user-2", Username: "other"})
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+	deletedToken, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{UserID: "user-3", Username: "deleted"})
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now().Add(time.Hour)
+	authMiddleware := NewAuthMiddleware(jwtManager, WithPasswordChanges(passwordChanges{
+		"user-1": &later,
+		"user-2": &earlier,
+	}))
+
+	handler := authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{"issued before the change", token, http.StatusUnauthorized},
+		{"issued after the change", otherToken, http.StatusNoContent},
+		{"unknown user", deletedToken, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			// Assert
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_Denylist(t *testing.T) {
+	// Setup
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	issue := func(sessionID string) (string, *utils.Claims) {
+		token, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{UserID: "user-1", Username: "testuser", SessionID: sessionID})
+		if err != nil {
+			t.Fatalf("Failed to generate access token: %v", err)
+		}
+		claims, _ := jwtManager.ValidateAccessToken(token)
+		return token, claims
+	}
+	revokedToken, revokedClaims := issue("session-1")
+	sameSessionToken, _ := issue("session-1")
+	sessionToken, _ := issue("session-2")
+	otherToken, _ := issue("session-3")
+
+	store := denylist.NewMemoryStore()
+	expiresAt := time.Now().Add(15 * time.Minute)
+	store.Add(context.Background(), denylist.TokenKey(revokedClaims.ID), expiresAt)
+	store.Add(context.Background(), denylist.SessionKey("session-2"), expiresAt)
+
+	authMiddleware := NewAuthMiddleware(jwtManager, WithDenylist(store))
+	handler := authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{"revoked token", revokedToken, http.StatusUnauthorized},
+		{"another token of its session", sameSessionToken, http.StatusNoContent},
+		{"token of a revoked session", sessionToken, http.StatusUnauthorized},
+		{"token of another session", otherToken, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			// Assert
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pkg/response"
+	"authorization/internal/ratelimit"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ClientIDHeader is the header callers name their client in, for limits
+// keyed by client. It is not authenticated, so such limits share capacity
+// fairly between well behaved clients rather than stopping attackers.
+const ClientIDHeader = "X-Client-ID"
+
+// maxKeyBodySize bounds how much of a request body is read for its username
+const maxKeyBodySize = 64 << 10
+
+// RateLimiter applies the rate limit policies of named routes
+type RateLimiter struct {
+	store    ratelimit.Store
+	policies map[string]ratelimit.Policy
+	now      func() time.Time
+}
+
+func NewRateLimiter(store ratelimit.Store, policies map[string]ratelimit.Policy) *RateLimiter {
+	return &RateLimiter{
+		store:    store,
+		policies: policies,
+		now:      time.Now,
+	}
+}
+
+// Limit applies the policy of the route. Routes without a policy are not
+// limited. Responses carry the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers of the rule closest to its
+// limit, and denied requests get 429 with Retry-After. Requests are let
+// through if the store fails, so that an outage of a shared store does not
+// take logins down with it.
+func (l *RateLimiter) Limit(route string) func(http.Handler) http.Handler {
+	policy := l.policies[route]
+	return func(next http.Handler) http.Handler {
+		if len(policy) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := l.now()
+
+			var (
+				tightest   *ratelimit.Result
+				denied     bool
+				retryAfter time.Duration
+			)
+			for _, rule := range policy {
+				key := requestKey(r, rule.KeyBy)
+				if key == "" {
+					continue
+				}
+
+				result, err := ratelimit.Allow(r.Context(), l.store, route+":"+string(rule.KeyBy)+":"+key, rule.Limit, now)
+				if err != nil {
+					logger.Error("Failed to apply rate limit", zap.Error(err), zap.String("route", route))
+					continue
+				}
+
+				if !result.Allowed {
+					denied = true
+					retryAfter = max(retryAfter, result.RetryAfter)
+				}
+				if tightest == nil || result.Remaining < tightest.Remaining {
+					tightest = &result
+				}
+			}
+
+			if tightest != nil {
+				header := w.Header()
+				header.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit.Requests))
+				header.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+				header.Set("RateLimit-Reset", seconds(tightest.Reset))
+				header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", tightest.Limit.Requests, seconds(tightest.Limit.Window)))
+			}
+
+			if denied {
+				logger.Warn("Rate limit exceeded",
+					zap.String("route", route),
+					zap.String("remote_addr", r.RemoteAddr),
+				)
+				w.Header().Set("Retry-After", seconds(retryAfter))
+				response.TooManyRequests(w, constants.MsgRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestKey returns what the request is counted by for the rule, or "" if
+// the request has no such key
+func requestKey(r *http.Request, keyBy ratelimit.KeyBy) string {
+	switch keyBy {
+	case ratelimit.KeyByIP:
+		return ipKey(r.RemoteAddr)
+	case ratelimit.KeyByUsername:
+		return bodyUsername(r)
+	case ratelimit.KeyByClient:
+		return r.Header.Get(ClientIDHeader)
+	}
+	return ""
+}
+
+// ipKey returns the key of a client address. RemoteAddr already holds the
+// client address when the RealIP middleware is in use, which only takes it
+// from forwarding headers set by trusted proxies. IPv6 clients are usually
+// handed a whole /64, so they are counted by it rather than by address.
+func ipKey(remoteAddr string) string {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	if ip = ip.Unmap(); ip.Is6() {
+		return netip.PrefixFrom(ip, 64).Masked().String()
+	}
+	return ip.String()
+}
+
+// bodyUsername returns the username field of a JSON request body, leaving
+// the body for the handler to read
+func bodyUsername(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxKeyBodySize))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return payload.Username
+}
+
+// seconds formats a duration as whole seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"authorization/internal/ratelimit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Limit(t *testing.T) {
+	// Setup
+	policy, err := ratelimit.ParsePolicy("ip:3/1m,username:2/1m")
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	rateLimiter := NewRateLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Policy{"login": policy})
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	rateLimiter.now = func() time.Time { return now }
+
+	// The handler still gets the body the username was read from
+	handler := rateLimiter.Limit("login")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"username"`) {
+			t.Errorf("Expected the request body to be intact, got %q", body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	login := func(ip, username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"username": "`+username+`", "password": "secret"}`))
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Execute and assert
+	rec := login("10.0.0.1", "alice")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "1" || rec.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Errorf("Expected the headers of the username limit, got %v", rec.Header())
+	}
+
+	// The username runs out first
+	login("10.0.0.1", "alice")
+	rec = login("10.0.0.2", "alice")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "30" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected to retry after 30s, got %v", rec.Header())
+	}
+
+	// Then the address, whatever the username
+	if rec := login("10.0.0.1", "bob"); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec := login("10.0.0.1", "carol"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+
+	// Limits recover over time
+	now = now.Add(30 * time.Second)
+	if rec := login("10.0.0.2", "alice"); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d after waiting, got %d", http.StatusNoContent, rec.Code)
+	}
+
+	// Routes without a policy are not limited
+	unlimited := rateLimiter.Limit("refresh")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec = httptest.NewRecorder()
+	unlimited.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Expected an unlimited response, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestRateLimiter_KeyByIP(t *testing.T) {
+	// Setup
+	policy, err := ratelimit.ParsePolicy("ip:1/1m")
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	rateLimiter := NewRateLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Policy{"login": policy})
+	handler := RealIP(nil)(rateLimiter.Limit("login")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	send := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Execute and assert: forwarding headers from untrusted peers do not
+	// give a client a fresh address
+	if code := send("203.0.113.7:1234", "198.51.100.1"); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, code)
+	}
+	if code := send("203.0.113.7:1234", "198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a spoofed X-Forwarded-For to be ignored, got %d", code)
+	}
+
+	// IPv6 clients are counted by their /64
+	if code := send("[2001:db8::1]:1234", ""); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, code)
+	}
+	if code := send("[2001:db8::2]:1234", ""); code != http.StatusTooManyRequests {
+		t.Errorf("Expected another address of the same /64 to be limited, got %d", code)
+	}
+	if code := send("[2001:db8:0:1::1]:1234", ""); code != http.StatusNoContent {
+		t.Errorf("Expected another /64 to have its own limit, got %d", code)
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets RemoteAddr to the address of the client. X-Forwarded-For and
+// X-Real-IP are only believed when the request came through one of the
+// trusted proxies: anyone else could put any address in them to dodge the
+// limits keyed by it. X-Forwarded-For is read from the right, skipping the
+// trusted proxies, so that the client is the first hop none of them vouches
+// for. Without trusted proxies the headers are ignored.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip, ok := clientIP(r, trustedProxies); ok {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the address of the client the request came from, or false
+// if the peer address cannot be parsed
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	peer = peer.Unmap()
+	if !trusted(peer, trustedProxies) {
+		return peer, true
+	}
+
+	// Each proxy appends the address it got the request from
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) == 0 {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return realIP.Unmap(), true
+		}
+		return peer, true
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0 && trusted(client, trustedProxies); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// What comes before a malformed hop cannot be trusted either
+			break
+		}
+		client = hop.Unmap()
+	}
+	return client, true
+}
+
+func trusted(ip netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
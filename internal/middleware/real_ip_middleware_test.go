@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	// Setup
+	trustedProxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		trustProxies bool
+		expectedAddr string
+	}{
+		{"direct client", "203.0.113.7:4321", nil, "", true, "203.0.113.7"},
+		{"spoofed header from a client", "203.0.113.7:4321", []string{"198.51.100.1"}, "198.51.100.2", true, "203.0.113.7"},
+		{"no trusted proxies", "10.0.0.2:4321", []string{"198.51.100.1"}, "", false, "10.0.0.2"},
+		{"through a trusted proxy", "10.0.0.2:4321", []string{"198.51.100.1"}, "", true, "198.51.100.1"},
+		{"spoofed hop before the proxy", "10.0.0.2:4321", []string{"1.2.3.4, 198.51.100.1"}, "", true, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.2:4321", []string{"198.51.100.1, 192.168.1.1", "10.1.1.1"}, "", true, "198.51.100.1"},
+		{"malformed hop", "10.0.0.2:4321", []string{"198.51.100.1, bogus, 10.1.1.1"}, "", true, "10.1.1.1"},
+		{"real IP from a trusted proxy", "10.0.0.2:4321", nil, "198.51.100.3", true, "198.51.100.3"},
+		{"mapped IPv4 peer", "[::ffff:203.0.113.7]:4321", nil, "", true, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proxies []netip.Prefix
+			if tt.trustProxies {
+				proxies = trustedProxies
+			}
+
+			var got string
+			handler := RealIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, header := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", header)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			// Execute
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// Assert
+			if got != tt.expectedAddr {
+				t.Errorf("Expected client address %s, got %s", tt.expectedAddr, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// AccessTokenDenial model
+//
+// A revoked access token (key "jti:<token id>") or session (key
+// "sid:<session id>"), shared by all nodes when the denylist is kept in the
+// database. Rows past ExpiresAt only match tokens that have expired anyway.
+type AccessTokenDenial struct {
+	Key       string    `json:"key" gorm:"type:text;primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// TableName returns the table name for GORM
+func (AccessTokenDenial) TableName() string {
+	return "access_token_denylist"
+}
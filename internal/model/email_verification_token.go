@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// EmailVerificationToken model
+//
+// Sent to the user's email to prove they own it. Like password reset tokens
+// only the hash is stored and a token can be used once: UsedAt is set when it
+// verifies the email or is superseded by a newer token.
+type EmailVerificationToken struct {
+	ID        string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID    string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (EmailVerificationToken) TableName() string {
+	return "email_verification_tokens"
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// LoginThrottle model
+//
+// Failed password logins counted against one key: a username (scope
+// "account") or a client IP address (scope "ip"). BlockedUntil holds back
+// further attempts, first for growing delays and eventually for a lockout.
+// The count starts over once no attempt failed for a lockout duration.
+type LoginThrottle struct {
+	Scope          string     `json:"scope" gorm:"type:varchar(16);primaryKey"`
+	Subject        string     `json:"subject" gorm:"type:varchar(255);primaryKey"`
+	FailedAttempts int        `json:"failed_attempts" gorm:"not null;default:0"`
+	LastFailedAt   time.Time  `json:"last_failed_at" gorm:"not null;index"`
+	BlockedUntil   *time.Time `json:"blocked_until,omitempty"`
+}
+
+// TableName returns the table name for GORM
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// UserMFA model
+//
+// A user's TOTP second factor. Secret is encrypted at rest. Enrollment
+// creates the row with EnabledAt unset; the factor is only enforced once the
+// user has confirmed it with a code. LastCounter is the last time step a code
+// was accepted for, so codes cannot be replayed. FailedAttempts counts
+// consecutive wrong codes and LockedUntil stops further attempts after too
+// many of them.
+type UserMFA struct {
+	UserID         string     `json:"user_id" gorm:"type:varchar(36);primaryKey"`
+	Secret         string     `json:"-" gorm:"type:text;not null"`
+	EnabledAt      *time.Time `json:"enabled_at,omitempty"`
+	LastCounter    int64      `json:"-" gorm:"not null;default:0"`
+	FailedAttempts int        `json:"-" gorm:"not null;default:0"`
+	LockedUntil    *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table name for GORM
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode model
+//
+// One-time codes that stand in for a TOTP code when the authenticator is
+// lost. Only their hash is stored.
+type MFARecoveryCode struct {
+	ID        string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID    string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	CodeHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// MFAChallenge model
+//
+// The attempts made at completing the MFA challenge token with ID (its
+// jti). A challenge allows a few attempts and completes one login, after
+// which ConsumedAt is set. Rows past ExpiresAt belong to expired challenges
+// and are cleared out.
+type MFAChallenge struct {
+	ID         string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID     string     `json:"user_id" gorm:"type:varchar(36);not null"`
+	Attempts   int        `json:"attempts" gorm:"not null"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null;index"`
+}
+
+// TableName returns the table name for GORM
+func (MFAChallenge) TableName() string {
+	return "mfa_challenges"
+}
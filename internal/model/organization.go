@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Organization model. Users belong to organizations through
+// OrganizationMember and hold organization scoped roles there.
+type Organization struct {
+	ID        string    `json:"id" gorm:"type:varchar(36);primaryKey"`
+	Slug      string    `json:"slug" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"type:varchar(255);not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for GORM
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember makes a user a member of an organization
+type OrganizationMember struct {
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);primaryKey"`
+	UserID         string    `json:"user_id" gorm:"type:varchar(36);primaryKey;index"`
+	CreatedAt      time.Time `json:"created_at"`
+	User           User      `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// TableName returns the table name for GORM
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+// OrganizationMemberRole assigns an organization scoped role to a member
+type OrganizationMemberRole struct {
+	OrganizationID string    `json:"organization_id" gorm:"type:varchar(36);primaryKey"`
+	UserID         string    `json:"user_id" gorm:"type:varchar(36);primaryKey"`
+	RoleID         string    `json:"role_id" gorm:"type:varchar(36);primaryKey;index"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (OrganizationMemberRole) TableName() string {
+	return "organization_member_roles"
+}
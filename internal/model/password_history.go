@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// PasswordHistory model
+//
+// Hashes of passwords a user had before, kept so that they cannot be chosen
+// again. Only the most recent ones the password policy looks at are kept.
+type PasswordHistory struct {
+	ID           string    `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID       string    `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// PasswordResetToken model
+//
+// Like refresh tokens only the hash of a reset token is stored. A token can be
+// used once: UsedAt is set when it resets the password or is superseded by a
+// newer token for the same user.
+type PasswordResetToken struct {
+	ID        string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID    string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
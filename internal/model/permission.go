@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Permission model
+type Permission struct {
+	ID          string    `json:"id" gorm:"type:varchar(36);primaryKey"`
+	Name        string    `json:"name" gorm:"type:varchar(255);uniqueIndex;not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (Permission) TableName() string {
+	return "permissions"
+}
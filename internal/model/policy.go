@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Policy model. Document holds the JSON policy evaluated by the policy
+// engine; its id matches ID.
+type Policy struct {
+	ID        string    `json:"id" gorm:"type:varchar(100);primaryKey"`
+	Document  string    `json:"document" gorm:"type:text;not null"`
+	Priority  int       `json:"priority" gorm:"not null"`
+	Enabled   bool      `json:"enabled" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for GORM
+func (Policy) TableName() string {
+	return "policies"
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// RateLimit model
+//
+// The state of a rate limited key, shared by all nodes when rate limits are
+// kept in the database. Value and Previous are the tokens left in a bucket or
+// the requests counted in the current and previous window, WindowTime when
+// the bucket was last refilled or the current window started.
+type RateLimit struct {
+	Key        string    `json:"key" gorm:"type:text;primaryKey"`
+	Value      float64   `json:"value" gorm:"not null;default:0"`
+	Previous   float64   `json:"previous" gorm:"not null;default:0"`
+	WindowTime time.Time `json:"window_time"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// TableName returns the table name for GORM
+func (RateLimit) TableName() string {
+	return "rate_limits"
+}
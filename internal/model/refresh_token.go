@@ -3,14 +3,29 @@ package model
 import "time"
 
 // RefreshToken model
+//
+// Every rotation chain started by a login forms a family. FamilyID is the ID of
+// the first token in the chain and ParentID points at the token that was
+// exchanged to mint this one. A family is what users see as a session; the
+// request metadata is recorded on every token so the active one describes
+// the latest use of the session. OrganizationID is the organization the
+// session is working in, if any.
 type RefreshToken struct {
-	ID        string    `json:"id" gorm:"type:varchar(36);primaryKey"`
-	UserID    string    `json:"user_id" gorm:"not null;index"`
-	TokenHash string    `json:"-" gorm:"uniqueIndex;not null"`
-	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
-	CreatedAt time.Time `json:"created_at"`
-	Revoked   bool      `json:"revoked" gorm:"default:false;index"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
+	ID               string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID           string     `json:"user_id" gorm:"not null;index"`
+	FamilyID         string     `json:"family_id" gorm:"type:varchar(36);not null;index"`
+	ParentID         *string    `json:"parent_id,omitempty" gorm:"type:varchar(36);index"`
+	TokenHash        string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Revoked          bool       `json:"revoked" gorm:"default:false;index"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	SessionStartedAt time.Time  `json:"session_started_at" gorm:"not null"`
+	UserAgent        string     `json:"user_agent" gorm:"type:varchar(512)"`
+	IPAddress        string     `json:"ip_address" gorm:"type:varchar(45)"`
+	DeviceLabel      string     `json:"device_label" gorm:"type:varchar(100)"`
+	OrganizationID   *string    `json:"organization_id,omitempty" gorm:"type:varchar(36);index"`
+	User             User       `json:"user" gorm:"foreignKey:UserID"`
 }
 
 // TableName returns the table name for GORM
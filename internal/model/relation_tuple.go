@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// RelationTuple model
+//
+// Tuples are never updated in place. Writing one inserts a row at the write's
+// revision and deleting one sets DeletedRevision, so reads at an earlier
+// revision still see it.
+type RelationTuple struct {
+	ID               int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Namespace        string    `json:"namespace" gorm:"type:varchar(64);not null;uniqueIndex:idx_relation_tuples_live,where:deleted_revision IS NULL"`
+	ObjectID         string    `json:"object_id" gorm:"type:varchar(255);not null;uniqueIndex:idx_relation_tuples_live"`
+	Relation         string    `json:"relation" gorm:"type:varchar(64);not null;uniqueIndex:idx_relation_tuples_live"`
+	SubjectNamespace string    `json:"subject_namespace" gorm:"type:varchar(64);not null;uniqueIndex:idx_relation_tuples_live"`
+	SubjectObjectID  string    `json:"subject_object_id" gorm:"type:varchar(255);not null;uniqueIndex:idx_relation_tuples_live"`
+	SubjectRelation  string    `json:"subject_relation" gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_relation_tuples_live"`
+	CreatedRevision  int64     `json:"created_revision" gorm:"not null"`
+	DeletedRevision  *int64    `json:"deleted_revision,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (RelationTuple) TableName() string {
+	return "relation_tuples"
+}
+
+// RelationTupleRevision is the single-row counter of tuple store revisions
+type RelationTupleRevision struct {
+	ID       int   `json:"id" gorm:"primaryKey"`
+	Revision int64 `json:"revision" gorm:"not null"`
+}
+
+// TableName returns the table name for GORM
+func (RelationTupleRevision) TableName() string {
+	return "relation_tuple_revisions"
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Role model. A role holds its own permissions and those of every role it
+// inherits from. Scope says whether the role is assigned to users directly or
+// to members of an organization.
+type Role struct {
+	ID          string       `json:"id" gorm:"type:varchar(36);primaryKey"`
+	Name        string       `json:"name" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Description string       `json:"description"`
+	Scope       string       `json:"scope" gorm:"type:varchar(20);not null;default:global"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+	Inherits    []Role       `json:"inherits" gorm:"many2many:role_inherits;joinForeignKey:RoleID;joinReferences:InheritedRoleID"`
+}
+
+// TableName returns the table name for GORM
+func (Role) TableName() string {
+	return "roles"
+}
+
+// UserRole assigns a role to a user
+type UserRole struct {
+	UserID    string    `json:"user_id" gorm:"type:varchar(36);primaryKey"`
+	RoleID    string    `json:"role_id" gorm:"type:varchar(36);primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (UserRole) TableName() string {
+	return "user_roles"
+}
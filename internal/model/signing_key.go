@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Signing key statuses
+const (
+	SigningKeyActive  = "active"
+	SigningKeyVerify  = "verify"
+	SigningKeyRetired = "retired"
+)
+
+// SigningKey model
+//
+// Exactly one key is active and signs new access tokens. A new key is first
+// published for verification only, with no RetireAfter, and starts signing
+// once every replica and verifier has had time to load it. The key it
+// replaces then only verifies until RetireAfter, by which time every token
+// it signed has expired, and is then retired and its key material erased.
+type SigningKey struct {
+	ID          string     `json:"id" gorm:"type:varchar(64);primaryKey"`
+	Algorithm   string     `json:"algorithm" gorm:"type:varchar(10);not null"`
+	PrivateKey  string     `json:"-" gorm:"type:text;not null"`
+	Status      string     `json:"status" gorm:"type:varchar(10);not null;index;uniqueIndex:idx_signing_keys_single_active,where:status = 'active'"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RotatedAt   *time.Time `json:"rotated_at,omitempty"`
+	RetireAfter *time.Time `json:"retire_after,omitempty"`
+}
+
+// TableName returns the table name for GORM
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
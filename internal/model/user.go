@@ -1,11 +1,21 @@
 package model
 
+import "time"
+
 // User model
+//
+// PasswordChangedAt is when the user last changed or reset their password;
+// access tokens issued before then are no longer accepted. EmailVerifiedAt is
+// when the user proved they own Email, nil until they do. DisabledAt is when
+// an administrator disabled the user, who cannot log in until enabled again.
 type User struct {
 	BaseModel
-	Username     string `json:"username" gorm:"uniqueIndex:idx_username_active,where:is_deleted=false;not null"`
-	Email        string `json:"email" gorm:"uniqueIndex:idx_email_active,where:is_deleted=false;not null"`
-	PasswordHash string `json:"-" gorm:"not null"`
+	Username          string     `json:"username" gorm:"uniqueIndex:idx_username_active,where:is_deleted=false;not null"`
+	Email             string     `json:"email" gorm:"uniqueIndex:idx_email_active,where:is_deleted=false;not null"`
+	PasswordHash      string     `json:"-" gorm:"not null"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at,omitempty"`
+	DisabledAt        *time.Time `json:"disabled_at,omitempty"`
 }
 
 // TableName returns the table name for GORM
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// WebAuthnCredential model
+//
+// A passkey or security key registered by a user. CredentialID is the
+// base64url encoded ID the authenticator chose and PublicKey the COSE
+// encoded key assertions are verified with. SignCount is the last counter
+// the authenticator reported; a counter that does not increase suggests a
+// cloned authenticator.
+type WebAuthnCredential struct {
+	ID                string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID            string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	CredentialID      string     `json:"credential_id" gorm:"type:varchar(1400);uniqueIndex;not null"`
+	PublicKey         []byte     `json:"-" gorm:"not null"`
+	SignCount         int64      `json:"-" gorm:"not null;default:0"`
+	AAGUID            string     `json:"aaguid" gorm:"type:varchar(36)"`
+	AttestationFormat string     `json:"attestation_format" gorm:"type:varchar(32)"`
+	Transports        string     `json:"-" gorm:"type:varchar(255)"`
+	BackupEligible    bool       `json:"backup_eligible" gorm:"not null;default:false"`
+	Name              string     `json:"name" gorm:"type:varchar(100)"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthnChallenge model
+//
+// A challenge handed to the browser for one registration or login ceremony.
+// Only its hash is stored and it can be used once. UserID is the user the
+// ceremony is for, or nil for a passwordless login where the passkey names
+// the user.
+type WebAuthnChallenge struct {
+	ID            string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	UserID        *string    `json:"user_id,omitempty" gorm:"type:varchar(36);index"`
+	Ceremony      string     `json:"ceremony" gorm:"type:varchar(16);not null"`
+	ChallengeHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (WebAuthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
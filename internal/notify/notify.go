@@ -0,0 +1,65 @@
+// Package notify delivers account messages, such as password reset links,
+// to users. The service only decides what to send; a Notifier decides how.
+package notify
+
+import (
+	"authorization/internal/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Message kinds
+const (
+	KindPasswordReset     = "password_reset"
+	KindEmailVerification = "email_verification"
+	// KindAccountExists tells the owner of an email that someone tried to
+	// register another account with it. It carries no link.
+	KindAccountExists = "account_exists"
+	// KindUsernameTaken tells someone registering that the username they
+	// chose belongs to another account. It carries no link.
+	KindUsernameTaken = "username_taken"
+)
+
+// Message is a link sent to a user so they can complete an account action
+type Message struct {
+	Kind      string
+	UserID    string
+	Username  string
+	Email     string
+	Link      string
+	ExpiresAt time.Time
+}
+
+// Notifier delivers messages to users. Implementations should return once the
+// message is accepted for delivery; errors are logged and never shown to the
+// requester.
+type Notifier interface {
+	Notify(msg *Message) error
+}
+
+// LogNotifier writes messages to the application log instead of delivering
+// them. Links are left out unless logLinks is set, as the log would then
+// hold links that work for anyone who can read it; that is meant for
+// development only.
+type LogNotifier struct {
+	logLinks bool
+}
+
+func NewLogNotifier(logLinks bool) *LogNotifier {
+	return &LogNotifier{logLinks: logLinks}
+}
+
+func (n *LogNotifier) Notify(msg *Message) error {
+	fields := []zap.Field{
+		zap.String("kind", msg.Kind),
+		zap.String("user_id", msg.UserID),
+		zap.String("email", msg.Email),
+		zap.Time("expires_at", msg.ExpiresAt),
+	}
+	if n.logLinks {
+		fields = append(fields, zap.String("link", msg.Link))
+	}
+	logger.Info("Notification", fields...)
+	return nil
+}
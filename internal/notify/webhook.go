@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds how long delivering a message to the webhook may take
+const webhookTimeout = 10 * time.Second
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body
+// under the webhook secret, so that the receiver can tell the messages come
+// from this service
+const WebhookSignatureHeader = "X-Notification-Signature"
+
+// webhookPayload is the JSON body posted for a message
+type webhookPayload struct {
+	Kind      string    `json:"kind"`
+	UserID    string    `json:"user_id,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Email     string    `json:"email"`
+	Link      string    `json:"link,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// WebhookNotifier posts messages as JSON to a URL, typically a mail
+// delivery service, which turns them into emails
+type WebhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url. Requests are signed
+// with secret in WebhookSignatureHeader unless it is empty.
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (n *WebhookNotifier) Notify(msg *Message) error {
+	body, err := json.Marshal(webhookPayload{
+		Kind:      msg.Kind,
+		UserID:    msg.UserID,
+		Username:  msg.Username,
+		Email:     msg.Email,
+		Link:      msg.Link,
+		ExpiresAt: msg.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding notification: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	// Setup
+	var body []byte
+	var signature string
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, "webhook-secret")
+	msg := &Message{
+		Kind:      KindPasswordReset,
+		UserID:    "user-1",
+		Email:     "test@example.com",
+		Link:      "https://app.example.com/reset?token=abc",
+		ExpiresAt: time.Now(),
+	}
+
+	// Execute
+	if err := notifier.Notify(msg); err != nil {
+		t.Fatalf("Failed to notify: %v", err)
+	}
+
+	// Assert
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Invalid payload %s: %v", body, err)
+	}
+	if payload.Kind != msg.Kind || payload.Email != msg.Email || payload.Link != msg.Link {
+		t.Errorf("Unexpected payload %+v", payload)
+	}
+
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write(body)
+	if signature != hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Unexpected signature %q", signature)
+	}
+
+	// Failed deliveries are reported
+	status = http.StatusInternalServerError
+	if err := notifier.Notify(msg); err == nil {
+		t.Error("Expected an error for a failed delivery, got nil")
+	}
+}
@@ -0,0 +1,410 @@
+package passwordpolicy
+
+import (
+	_ "embed"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// commonPasswordsList holds common passwords, most common first
+//
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+// commonPasswords maps each common password to its rank
+var commonPasswords = rankedDictionary(strings.Fields(commonPasswordsList))
+
+func rankedDictionary(words []string) map[string]int {
+	ranks := make(map[string]int, len(words))
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
+
+// userDictionary ranks the details of the user, which an attacker targeting
+// them tries first
+func userDictionary(userInputs []string) map[string]int {
+	return rankedDictionary(personalTokens(userInputs))
+}
+
+const (
+	warningCommon   = "it is a commonly used password"
+	warningContains = "it contains a commonly used password"
+	warningPersonal = "it contains your username or email"
+	warningSpatial  = "keyboard patterns like qwerty are easy to guess"
+	warningSequence = "sequences like abc or 6543 are easy to guess"
+	warningRepeat   = "repeats like aaa or abcabc are easy to guess"
+	warningYear     = "recent years are easy to guess"
+)
+
+func findMatches(runes []rune, userDict map[string]int) []*match {
+	var matches []*match
+	for _, m := range dictionaryMatches(runes, commonPasswords, warningCommon) {
+		if m.i > 0 || m.j < len(runes)-1 {
+			m.warning = warningContains
+		}
+		matches = append(matches, m)
+	}
+	matches = append(matches, dictionaryMatches(runes, userDict, warningPersonal)...)
+	matches = append(matches, spatialMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+	return matches
+}
+
+// l33tVariants map characters to the letters they commonly stand for. "1"
+// stands for both "i" and "l", so there are two.
+var l33tVariants = []map[rune]rune{
+	{'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '9': 'g', '1': 'i', '!': 'i', '|': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z'},
+	{'1': 'l'},
+}
+
+// dictionaryMatches finds words of the dictionary in the password, also
+// reversed, capitalized or with l33t substitutions
+func dictionaryMatches(runes []rune, dict map[string]int, warning string) []*match {
+	if len(dict) == 0 {
+		return nil
+	}
+
+	var matches []*match
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length; positions would not line up
+		return nil
+	}
+	for i := range lower {
+		for j := i; j < len(lower); j++ {
+			token := runes[i : j+1]
+			word := string(lower[i : j+1])
+			variations := uppercaseVariations(token)
+
+			if rank, ok := dict[word]; ok {
+				matches = append(matches, &match{i: i, j: j, guesses: float64(rank) * variations, warning: warning})
+			}
+			if reversed := reverse(word); reversed != word {
+				if rank, ok := dict[reversed]; ok {
+					matches = append(matches, &match{i: i, j: j, guesses: float64(rank) * variations * 2, warning: warning})
+				}
+			}
+			for _, table := range l33tVariants {
+				unl33ted, subs := unl33t(lower[i:j+1], table)
+				if len(subs) == 0 {
+					continue
+				}
+				if rank, ok := dict[unl33ted]; ok {
+					guesses := float64(rank) * variations * l33tVariations(lower[i:j+1], subs)
+					matches = append(matches, &match{i: i, j: j, guesses: guesses, warning: warning})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// uppercaseVariations is how many ways of capitalizing the word an attacker
+// tries before the one of the token. Capitalizing the first or last letter
+// or all of them is tried first.
+func uppercaseVariations(token []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(token[0]) || unicode.IsUpper(token[len(token)-1]))) {
+		return 2
+	}
+
+	variations := 0.0
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return variations
+}
+
+// unl33t undoes the substitutions of the table and returns the word together
+// with the characters substituted
+func unl33t(word []rune, table map[rune]rune) (string, map[rune]rune) {
+	subs := make(map[rune]rune)
+	result := make([]rune, len(word))
+	for i, r := range word {
+		if letter, ok := table[r]; ok {
+			result[i] = letter
+			subs[r] = letter
+		} else {
+			result[i] = r
+		}
+	}
+	return string(result), subs
+}
+
+// l33tVariations is how many ways of substituting the letters an attacker
+// tries before the one of the word
+func l33tVariations(word []rune, subs map[rune]rune) float64 {
+	variations := 1.0
+	for sub, letter := range subs {
+		subbed, unsubbed := 0, 0
+		for _, r := range word {
+			switch r {
+			case sub:
+				subbed++
+			case letter:
+				unsubbed++
+			}
+		}
+		if unsubbed == 0 {
+			variations *= 2
+			continue
+		}
+		possibilities := 0.0
+		for k := 1; k <= min(subbed, unsubbed); k++ {
+			possibilities += binomial(subbed+unsubbed, k)
+		}
+		variations *= possibilities
+	}
+	return variations
+}
+
+// keyboardRows is a US qwerty keyboard, unshifted and shifted. The rows are
+// staggered by keyboardOffsets keys.
+var (
+	keyboardRows        = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+	keyboardShiftedRows = []string{"~!@#$%^&*()_+", "QWERTYUIOP{}|", "ASDFGHJKL:\"", "ZXCVBNM<>?"}
+	keyboardOffsets     = []float64{0, 1.5, 1.75, 2.25}
+)
+
+type keyPosition struct {
+	row int
+	x   float64
+}
+
+var (
+	// keyPositions maps unshifted keys to where they are
+	keyPositions = make(map[rune]keyPosition)
+	// unshifted maps shifted characters to their key
+	unshifted = make(map[rune]rune)
+	// keyboardDegree is how many neighbors a key has on average
+	keyboardDegree float64
+)
+
+func init() {
+	for row, keys := range keyboardRows {
+		shifted := []rune(keyboardShiftedRows[row])
+		for i, key := range []rune(keys) {
+			keyPositions[key] = keyPosition{row: row, x: keyboardOffsets[row] + float64(i)}
+			unshifted[shifted[i]] = key
+		}
+	}
+
+	neighbors := 0
+	for a := range keyPositions {
+		for b := range keyPositions {
+			if adjacentKeys(a, b) {
+				neighbors++
+			}
+		}
+	}
+	keyboardDegree = float64(neighbors) / float64(len(keyPositions))
+}
+
+// adjacentKeys reports whether two unshifted keys are next to each other,
+// in the same row or the rows above and below
+func adjacentKeys(a, b rune) bool {
+	pa, okA := keyPositions[a]
+	pb, okB := keyPositions[b]
+	if !okA || !okB || a == b {
+		return false
+	}
+	dx := math.Abs(pa.x - pb.x)
+	switch pa.row - pb.row {
+	case 0:
+		return dx == 1
+	case 1, -1:
+		return dx < 1
+	}
+	return false
+}
+
+// keyOf returns the key of a character and whether it takes shift
+func keyOf(r rune) (rune, bool) {
+	if key, ok := unshifted[r]; ok {
+		return key, true
+	}
+	return r, false
+}
+
+// spatialMatches finds walks of three or more neighboring keys
+func spatialMatches(runes []rune) []*match {
+	var matches []*match
+	for i := 0; i < len(runes)-2; {
+		j, turns, shifted := i, 0, 0
+		prevKey, isShifted := keyOf(runes[i])
+		if isShifted {
+			shifted++
+		}
+		var direction [2]float64
+		for j+1 < len(runes) {
+			key, isShifted := keyOf(runes[j+1])
+			if !adjacentKeys(prevKey, key) {
+				break
+			}
+			from, to := keyPositions[prevKey], keyPositions[key]
+			step := [2]float64{to.x - from.x, float64(to.row - from.row)}
+			if j == i || step != direction {
+				turns++
+				direction = step
+			}
+			if isShifted {
+				shifted++
+			}
+			prevKey = key
+			j++
+		}
+
+		if length := j - i + 1; length >= 3 {
+			matches = append(matches, &match{i: i, j: j, guesses: spatialGuesses(length, turns, shifted), warning: warningSpatial})
+			i = j
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+func spatialGuesses(length, turns, shifted int) float64 {
+	startingPositions := float64(len(keyPositions))
+	guesses := 0.0
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= min(turns, i-1); j++ {
+			guesses += binomial(i-1, j-1) * startingPositions * math.Pow(keyboardDegree, float64(j))
+		}
+	}
+
+	unshiftedCount := length - shifted
+	switch {
+	case shifted == 0:
+	case unshiftedCount == 0:
+		guesses *= 2
+	default:
+		variations := 0.0
+		for k := 1; k <= min(shifted, unshiftedCount); k++ {
+			variations += binomial(length, k)
+		}
+		guesses *= variations
+	}
+	return guesses
+}
+
+// sequenceMatches finds runs of three or more characters of the same kind
+// that step by the same small amount, like abc, 2468 or zyx
+func sequenceMatches(runes []rune) []*match {
+	var matches []*match
+	for i := 0; i < len(runes)-2; {
+		delta := runes[i+1] - runes[i]
+		if delta == 0 || delta < -5 || delta > 5 || classOf(runes[i]) != classOf(runes[i+1]) {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == delta && classOf(runes[j+1]) == classOf(runes[i]) {
+			j++
+		}
+		if length := j - i + 1; length >= 3 {
+			matches = append(matches, &match{i: i, j: j, guesses: sequenceGuesses(runes[i], length, delta < 0), warning: warningSequence})
+			i = j
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+func sequenceGuesses(first rune, length int, descending bool) float64 {
+	var base float64
+	switch {
+	case strings.ContainsRune("aAzZ019", first):
+		// Obvious starting points
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	default:
+		base = 26
+	}
+	if descending {
+		base *= 2
+	}
+	return base * float64(length)
+}
+
+// repeatMatches finds a character or block of characters repeated right
+// after itself, like aaa or abcabc
+func repeatMatches(runes []rune) []*match {
+	var matches []*match
+	for i := 0; i < len(runes)-1; i++ {
+		var best *match
+		for size := 1; i+2*size <= len(runes); size++ {
+			block := string(runes[i : i+size])
+			count := 1
+			for i+(count+1)*size <= len(runes) && string(runes[i+count*size:i+(count+1)*size]) == block {
+				count++
+			}
+			if count < 2 {
+				continue
+			}
+			if end := i + count*size - 1; best == nil || end > best.j {
+				guesses := Estimate(block).Guesses * float64(count)
+				best = &match{i: i, j: end, guesses: guesses, warning: warningRepeat}
+			}
+		}
+		if best != nil {
+			matches = append(matches, best)
+		}
+	}
+	return matches
+}
+
+// Years guessed around the reference year
+const (
+	minYear      = 1900
+	maxYear      = 2099
+	minYearSpace = 20
+)
+
+// yearMatches finds four digit years
+func yearMatches(runes []rune) []*match {
+	var matches []*match
+	referenceYear := time.Now().Year()
+	for i := 0; i+4 <= len(runes); i++ {
+		token := string(runes[i : i+4])
+		year, err := strconv.Atoi(token)
+		if err != nil || year < minYear || year > maxYear || strings.ContainsAny(token, "+-") {
+			continue
+		}
+		space := math.Max(math.Abs(float64(year-referenceYear)), minYearSpace)
+		matches = append(matches, &match{i: i, j: i + 3, guesses: space, warning: warningYear})
+	}
+	return matches
+}
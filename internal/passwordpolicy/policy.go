@@ -0,0 +1,225 @@
+// Package passwordpolicy decides which passwords users may choose: how long
+// they are, which kinds of characters they mix, how hard they are to guess
+// and whether they contain the user's own details. Reuse of earlier
+// passwords and appearing in breaches need data the policy does not hold, so
+// callers check them themselves and report them with RuleReused and
+// RuleBreached.
+package passwordpolicy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules a password can break
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleCharClass    = "char_class"
+	RuleMinClasses   = "min_classes"
+	RuleStrength     = "strength"
+	RulePersonalInfo = "personal_info"
+	RuleReused       = "reused"
+	RuleBreached     = "breached"
+)
+
+// CharClass is a kind of character
+type CharClass string
+
+const (
+	// ClassLower is lowercase letters, and letters of scripts without case
+	ClassLower CharClass = "lower"
+	ClassUpper CharClass = "upper"
+	ClassDigit CharClass = "digit"
+	// ClassSymbol is everything else, including spaces
+	ClassSymbol CharClass = "symbol"
+)
+
+var classNames = map[CharClass]string{
+	ClassLower:  "a lowercase letter",
+	ClassUpper:  "an uppercase letter",
+	ClassDigit:  "a digit",
+	ClassSymbol: "a symbol",
+}
+
+// ParseCharClasses parses a comma separated list of classes, such as
+// "lower,upper,digit"
+func ParseCharClasses(s string) ([]CharClass, error) {
+	var classes []CharClass
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		class := CharClass(item)
+		if _, ok := classNames[class]; !ok {
+			return nil, fmt.Errorf("unknown character class %q", item)
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func classOf(r rune) CharClass {
+	switch {
+	case unicode.IsUpper(r):
+		return ClassUpper
+	case unicode.IsLetter(r):
+		return ClassLower
+	case unicode.IsDigit(r):
+		return ClassDigit
+	}
+	return ClassSymbol
+}
+
+// Policy is the rules new passwords have to follow. The zero Policy allows
+// any password.
+type Policy struct {
+	// MinLength and MaxLength bound the length in Unicode code points, so
+	// that passwords in any script are measured alike; 0 means no bound
+	MinLength int
+	MaxLength int
+	// RequiredClasses must each appear at least once
+	RequiredClasses []CharClass
+	// MinClasses is how many different classes have to appear
+	MinClasses int
+	// MinStrength is the least Score of Estimate, from 0 to 4
+	MinStrength int
+	// RejectPersonalInfo rejects passwords containing the username or email
+	RejectPersonalInfo bool
+	// History is how many of the user's latest passwords, the current one
+	// included, cannot be chosen again
+	History int
+}
+
+// Default is the policy recommended for new deployments: long enough,
+// not easily guessed and not made of the user's own details, without
+// composition rules.
+func Default() Policy {
+	return Policy{
+		MinLength:          8,
+		MaxLength:          256,
+		MinStrength:        2,
+		RejectPersonalInfo: true,
+		History:            5,
+	}
+}
+
+// Validate checks that the policy can be followed
+func (p Policy) Validate() error {
+	if p.MinLength < 0 || p.MaxLength < 0 || (p.MaxLength > 0 && p.MaxLength < p.MinLength) {
+		return fmt.Errorf("password length bounds must be positive and the maximum at least the minimum")
+	}
+	if p.MinClasses < 0 || p.MinClasses > len(classNames) {
+		return fmt.Errorf("minimum character classes must be between 0 and %d", len(classNames))
+	}
+	for _, class := range p.RequiredClasses {
+		if _, ok := classNames[class]; !ok {
+			return fmt.Errorf("unknown character class %q", class)
+		}
+	}
+	if p.MinStrength < 0 || p.MinStrength > 4 {
+		return fmt.Errorf("minimum strength must be between 0 and 4")
+	}
+	if p.History < 0 {
+		return fmt.Errorf("password history must not be negative")
+	}
+	return nil
+}
+
+// Failure is a rule a password breaks
+type Failure struct {
+	Rule    string
+	Message string
+}
+
+// Error is returned for a password that breaks the policy
+type Error struct {
+	Failures []Failure
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		messages[i] = failure.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Check returns the rules the password breaks, nil if it follows the
+// policy. personal holds the user's details, such as their username and
+// email, which the password must not contain and which make it easier to
+// guess.
+func (p Policy) Check(password string, personal ...string) []Failure {
+	var failures []Failure
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		failures = append(failures, Failure{RuleMinLength, fmt.Sprintf("Password must be at least %d characters long", p.MinLength)})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		// Estimating the strength of an overlong password is wasted work
+		return append(failures, Failure{RuleMaxLength, fmt.Sprintf("Password must be at most %d characters long", p.MaxLength)})
+	}
+
+	present := make(map[CharClass]bool)
+	for _, r := range password {
+		present[classOf(r)] = true
+	}
+	for _, class := range p.RequiredClasses {
+		if !present[class] {
+			failures = append(failures, Failure{RuleCharClass, "Password must contain " + classNames[class]})
+		}
+	}
+	if len(present) < p.MinClasses {
+		failures = append(failures, Failure{RuleMinClasses, fmt.Sprintf("Password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)})
+	}
+
+	if p.RejectPersonalInfo && containsPersonalInfo(password, personal) {
+		failures = append(failures, Failure{RulePersonalInfo, "Password must not contain your username or email"})
+	}
+
+	if p.MinStrength > 0 {
+		if strength := Estimate(password, personal...); strength.Score < p.MinStrength {
+			message := "Password is too easy to guess"
+			if strength.Warning != "" {
+				message += ": " + strength.Warning
+			}
+			failures = append(failures, Failure{RuleStrength, message})
+		}
+	}
+	return failures
+}
+
+// minPersonalLength keeps short details, which may well appear in a
+// password by chance, from being rejected
+const minPersonalLength = 3
+
+func containsPersonalInfo(password string, personal []string) bool {
+	password = strings.ToLower(password)
+	for _, detail := range personalTokens(personal) {
+		if utf8.RuneCountInString(detail) >= minPersonalLength && strings.Contains(password, detail) {
+			return true
+		}
+	}
+	return false
+}
+
+// personalTokens returns the lowercase details together with the local part
+// of emails among them
+func personalTokens(personal []string) []string {
+	var tokens []string
+	for _, detail := range personal {
+		detail = strings.ToLower(strings.TrimSpace(detail))
+		if detail == "" {
+			continue
+		}
+		tokens = append(tokens, detail)
+		if local, _, ok := strings.Cut(detail, "@"); ok && local != "" {
+			tokens = append(tokens, local)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,122 @@
+package passwordpolicy
+
+import (
+	"strings"
+	"testing"
+)
+
+func rules(failures []Failure) []string {
+	var names []string
+	for _, failure := range failures {
+		names = append(names, failure.Rule)
+	}
+	return names
+}
+
+func TestPolicy_Check(t *testing.T) {
+	policy := Policy{
+		MinLength:          8,
+		MaxLength:          20,
+		RequiredClasses:    []CharClass{ClassDigit},
+		MinClasses:         3,
+		MinStrength:        2,
+		RejectPersonalInfo: true,
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"strong", "Tulip-Gravel-42", nil},
+		{"short", "Ab1!", []string{RuleMinLength, RuleStrength}},
+		{"too long", strings.Repeat("Ab1!", 6), []string{RuleMaxLength}},
+		{"missing class", "Tulip-Gravel-Orbit", []string{RuleCharClass}},
+		{"few classes", "tulipgravel42", []string{RuleMinClasses}},
+		{"personal", "Alice-Orbit-42", []string{RulePersonalInfo}},
+		{"common", "Password123", []string{RuleStrength}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := policy.Check(tt.password, "alice", "alice@example.com")
+			if got := rules(failures); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v to break %v, got %v", tt.password, tt.want, failures)
+			}
+			for _, failure := range failures {
+				if failure.Message == "" {
+					t.Errorf("Expected a message for %s", failure.Rule)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicy_Check_CountsCodePoints(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 8}
+
+	// Eight code points, but 24 bytes
+	if failures := policy.Check("パスワードを守る"); failures != nil {
+		t.Errorf("Expected eight code points to pass, got %v", failures)
+	}
+	if failures := policy.Check("パスワード"); len(failures) != 1 || failures[0].Rule != RuleMinLength {
+		t.Errorf("Expected five code points to be too short, got %v", failures)
+	}
+}
+
+func TestPolicy_Check_ZeroAllowsAnything(t *testing.T) {
+	if failures := (Policy{}).Check("a", "a"); failures != nil {
+		t.Errorf("Expected the zero policy to allow any password, got %v", failures)
+	}
+}
+
+func TestPolicy_Check_ShortPersonalInfo(t *testing.T) {
+	policy := Policy{RejectPersonalInfo: true}
+
+	// Details too short to be telling are ignored
+	if failures := policy.Check("Tulip-Gravel-42", "al", "al@example.com"); failures != nil {
+		t.Errorf("Expected a short username to be ignored, got %v", failures)
+	}
+	if failures := policy.Check("my-ALICE-pass", "bob", "Alice@example.com"); len(failures) != 1 {
+		t.Errorf("Expected the local part of the email to be rejected in any case, got %v", failures)
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Expected the default policy to be valid, got %v", err)
+	}
+
+	for name, policy := range map[string]Policy{
+		"max below min":   {MinLength: 10, MaxLength: 8},
+		"too many":        {MinClasses: 5},
+		"unknown class":   {RequiredClasses: []CharClass{"emoji"}},
+		"strength":        {MinStrength: 5},
+		"history":         {History: -1},
+		"negative length": {MinLength: -1},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("%s: expected %+v to be invalid", name, policy)
+		}
+	}
+}
+
+func TestParseCharClasses(t *testing.T) {
+	classes, err := ParseCharClasses(" lower, digit,,symbol ")
+	if err != nil {
+		t.Fatalf("Failed to parse classes: %v", err)
+	}
+	if len(classes) != 3 || classes[0] != ClassLower || classes[1] != ClassDigit || classes[2] != ClassSymbol {
+		t.Errorf("Expected lower, digit and symbol, got %v", classes)
+	}
+
+	if _, err := ParseCharClasses("lower,emoji"); err == nil {
+		t.Error("Expected an unknown class to be rejected")
+	}
+}
+
+func TestError(t *testing.T) {
+	err := &Error{Failures: []Failure{{RuleMinLength, "too short"}, {RuleStrength, "too weak"}}}
+	if err.Error() != "too short; too weak" {
+		t.Errorf("Expected the messages joined, got %q", err.Error())
+	}
+}
@@ -0,0 +1,179 @@
+package passwordpolicy
+
+import (
+	"math"
+)
+
+// The estimator follows zxcvbn: it finds the guessable patterns in a
+// password, such as common passwords, keyboard walks, sequences, repeats and
+// years, and looks for the sequence of patterns and brute forced gaps that
+// covers the password with the fewest guesses.
+
+// Strength is how hard a password is to guess
+type Strength struct {
+	// Guesses estimates how many guesses an attacker needs
+	Guesses float64
+	// Score buckets Guesses from 0, guessed within a thousand tries, to 4,
+	// taking more than ten billion
+	Score int
+	// Warning describes the most telling pattern found, "" if none
+	Warning string
+}
+
+const (
+	// maxEstimateLength bounds the work of estimating long passwords. Their
+	// first characters alone decide whether they are weak.
+	maxEstimateLength = 64
+
+	// bruteforceCardinality is the guesses per brute forced character
+	bruteforceCardinality = 10
+
+	// minGuessesBeforeGrowingSequence makes covering a password with more
+	// patterns cost more, as the attacker has to try their combinations
+	minGuessesBeforeGrowingSequence = 10000
+
+	// Least guesses of a pattern within a longer password
+	minSubmatchGuessesSingleChar = 10
+	minSubmatchGuessesMultiChar  = 50
+)
+
+// scoreThresholds are the guesses needed for each score above 0
+var scoreThresholds = []float64{1e3, 1e6, 1e8, 1e10}
+
+// match is a pattern covering runes i to j of a password
+type match struct {
+	i, j    int
+	guesses float64
+	warning string
+}
+
+// Estimate returns how hard the password is to guess. userInputs, such as
+// the username and email, are guessed first, like common passwords.
+func Estimate(password string, userInputs ...string) Strength {
+	runes := []rune(password)
+	if len(runes) > maxEstimateLength {
+		runes = runes[:maxEstimateLength]
+	}
+	if len(runes) == 0 {
+		return Strength{Guesses: 1}
+	}
+
+	matches := findMatches(runes, userDictionary(userInputs))
+	guesses, sequence := mostGuessableSequence(runes, matches)
+
+	strength := Strength{Guesses: guesses}
+	for _, threshold := range scoreThresholds {
+		if guesses >= threshold {
+			strength.Score++
+		}
+	}
+
+	// The longest pattern says most about what to avoid
+	longest := -1
+	for _, m := range sequence {
+		if m.warning != "" && m.j-m.i > longest {
+			longest = m.j - m.i
+			strength.Warning = m.warning
+		}
+	}
+	return strength
+}
+
+// step is the best way found to cover the runes up to a position with a
+// given number of patterns
+type step struct {
+	product float64
+	guesses float64
+	match   *match
+	prevLen int
+}
+
+// mostGuessableSequence returns the fewest guesses of any sequence of the
+// matches and brute forced gaps covering the runes, and the sequence
+func mostGuessableSequence(runes []rune, matches []*match) (float64, []*match) {
+	n := len(runes)
+	byEnd := make([][]*match, n)
+	for _, m := range matches {
+		m.guesses = math.Max(m.guesses, minGuesses(m, n))
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	best := make([]map[int]*step, n)
+	update := func(m *match, length int, product float64, prevLen int) {
+		guesses := factorial(length)*product + math.Pow(minGuessesBeforeGrowingSequence, float64(length-1))
+		// A sequence of no more patterns that needs no more guesses is
+		// better in every way
+		for l, s := range best[m.j] {
+			if l <= length && s.guesses <= guesses {
+				return
+			}
+		}
+		best[m.j][length] = &step{product: product, guesses: guesses, match: m, prevLen: prevLen}
+	}
+	extend := func(m *match) {
+		if m.i == 0 {
+			update(m, 1, m.guesses, 0)
+			return
+		}
+		for l, prev := range best[m.i-1] {
+			update(m, l+1, prev.product*m.guesses, l)
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		best[k] = make(map[int]*step)
+		for _, m := range byEnd[k] {
+			extend(m)
+		}
+		for i := 0; i <= k; i++ {
+			m := &match{i: i, j: k, guesses: math.Pow(bruteforceCardinality, float64(k-i+1))}
+			m.guesses = math.Max(m.guesses, minGuesses(m, n)+1)
+			extend(m)
+		}
+	}
+
+	bestLen := 0
+	for l, s := range best[n-1] {
+		if bestLen == 0 || s.guesses < best[n-1][bestLen].guesses {
+			bestLen = l
+		}
+	}
+	guesses := best[n-1][bestLen].guesses
+
+	var sequence []*match
+	for k, l := n-1, bestLen; k >= 0; {
+		s := best[k][l]
+		sequence = append([]*match{s.match}, sequence...)
+		k, l = s.match.i-1, s.prevLen
+	}
+	return guesses, sequence
+}
+
+// minGuesses is the least guesses a pattern counts for. A pattern that is
+// the whole password may be guessed right away; within a password the
+// attacker still has to guess where it is.
+func minGuesses(m *match, n int) float64 {
+	switch {
+	case m.j-m.i+1 == n:
+		return 1
+	case m.i == m.j:
+		return minSubmatchGuessesSingleChar
+	}
+	return minSubmatchGuessesMultiChar
+}
+
+func factorial(n int) float64 {
+	return math.Gamma(float64(n) + 1)
+}
+
+// binomial returns n choose k
+func binomial(n, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package passwordpolicy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimate_Scores(t *testing.T) {
+	tests := []struct {
+		password string
+		minScore int
+		maxScore int
+		warning  string
+	}{
+		{"password", 0, 0, warningCommon},
+		{"P@ssw0rd", 0, 1, warningCommon},
+		{"drowssap", 0, 0, warningCommon},
+		{"qwertyuiop", 0, 1, ""},
+		{"zxcvbnm,./", 0, 1, warningSpatial},
+		{"abcdefgh", 0, 1, warningSequence},
+		{"aaaaaaaaaaaa", 0, 1, warningRepeat},
+		{"password456", 1, 1, warningContains},
+		{"securepassword123", 3, 4, ""},
+		{"correcthorsebatterystaple", 4, 4, ""},
+		{"Tulip-Gravel-42", 3, 4, ""},
+	}
+	for _, tt := range tests {
+		strength := Estimate(tt.password)
+		if strength.Score < tt.minScore || strength.Score > tt.maxScore {
+			t.Errorf("Expected %q to score %d to %d, got %d (%.0f guesses)", tt.password, tt.minScore, tt.maxScore, strength.Score, strength.Guesses)
+		}
+		if tt.warning != "" && strength.Warning != tt.warning {
+			t.Errorf("Expected %q to warn %q, got %q", tt.password, tt.warning, strength.Warning)
+		}
+	}
+}
+
+func TestEstimate_UserInputs(t *testing.T) {
+	without := Estimate("jdoe-2011-fox")
+	with := Estimate("jdoe-2011-fox", "jdoe", "jdoe@example.com")
+
+	if with.Guesses >= without.Guesses {
+		t.Errorf("Expected the username to make the password easier to guess, got %.0f and %.0f", with.Guesses, without.Guesses)
+	}
+	if with.Warning != warningPersonal {
+		t.Errorf("Expected a warning about personal info, got %q", with.Warning)
+	}
+}
+
+func TestEstimate_Empty(t *testing.T) {
+	if strength := Estimate(""); strength.Score != 0 || strength.Guesses != 1 {
+		t.Errorf("Expected an empty password to be guessed at once, got %+v", strength)
+	}
+}
+
+func TestEstimate_LongPasswordIsQuick(t *testing.T) {
+	start := time.Now()
+	Estimate(strings.Repeat("ab1!", 256))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a long password to be estimated quickly, took %v", elapsed)
+	}
+}
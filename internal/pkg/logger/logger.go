@@ -63,3 +63,11 @@ func Sync() {
 		Logger.Sync()
 	}
 }
+
+// SecurityEvent logs a security relevant event (token reuse, lockouts, ...)
+// so it can be picked up by alerting on the "security_event" field
+func SecurityEvent(event string, fields ...zap.Field) {
+	if Logger != nil {
+		Logger.Warn("Security event", append([]zap.Field{zap.String("security_event", event)}, fields...)...)
+	}
+}
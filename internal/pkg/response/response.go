@@ -25,6 +25,11 @@ func Created(w http.ResponseWriter, data interface{}) {
 	JSON(w, http.StatusCreated, data)
 }
 
+// Accepted sends an accepted response
+func Accepted(w http.ResponseWriter, data interface{}) {
+	JSON(w, http.StatusAccepted, data)
+}
+
 // Error sends an error response
 func Error(w http.ResponseWriter, statusCode int, code, message string) {
 	JSON(w, statusCode, dto.ErrorResponse{
@@ -67,6 +72,11 @@ func Conflict(w http.ResponseWriter, message string) {
 	Error(w, http.StatusConflict, "CONFLICT", message)
 }
 
+// TooManyRequests sends a too many requests response
+func TooManyRequests(w http.ResponseWriter, message string) {
+	Error(w, http.StatusTooManyRequests, "TOO_MANY_REQUESTS", message)
+}
+
 // InternalError sends an internal server error response
 func InternalError(w http.ResponseWriter, message string) {
 	Error(w, http.StatusInternalServerError, "INTERNAL_ERROR", message)
@@ -0,0 +1,212 @@
+package policy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Request holds the attributes a decision is made on. Attribute values may
+// be any JSON-like Go value; times are compared as RFC 3339 strings. Unless
+// Environment sets "time", env.time is the time of evaluation.
+type Request struct {
+	Subject     map[string]any
+	Resource    map[string]any
+	Action      string
+	Environment map[string]any
+}
+
+func (r *Request) attributes(now time.Time) *attributes {
+	attrs := &attributes{
+		subject:  objectAttributes(r.Subject),
+		resource: objectAttributes(r.Resource),
+		action:   r.Action,
+		env:      objectAttributes(r.Environment),
+	}
+	if _, ok := attrs.env["time"]; !ok {
+		attrs.env["time"] = now.UTC().Format(time.RFC3339)
+	}
+	return attrs
+}
+
+func objectAttributes(values map[string]any) map[string]any {
+	if values == nil {
+		return map[string]any{}
+	}
+	return normalize(values).(map[string]any)
+}
+
+// Decision is the outcome of an evaluation. Policy and Rule name what
+// decided it, and Results hold every rule evaluated on the way.
+type Decision struct {
+	Effect  Effect
+	Policy  string
+	Rule    string
+	Reason  string
+	Results []RuleResult
+}
+
+// Allowed reports whether the decision permits the request. Anything other
+// than an explicit permit is a denial.
+func (d Decision) Allowed() bool {
+	return d.Effect == Permit
+}
+
+// RuleResult is the effect of one rule. Results with an empty Rule are
+// policy targets that did not match or failed to evaluate.
+type RuleResult struct {
+	Policy string
+	Rule   string
+	Effect Effect
+	Error  string
+}
+
+// Engine evaluates requests against a set of policies combined by an
+// algorithm. It is safe for concurrent use, and its policies can be
+// replaced while it serves requests.
+type Engine struct {
+	algorithm Algorithm
+	now       func() time.Time
+
+	mu       sync.RWMutex
+	policies []*Policy
+}
+
+// NewEngine creates an engine over the policies, compiling them if needed
+func NewEngine(algorithm Algorithm, policies ...*Policy) (*Engine, error) {
+	if _, err := ParseAlgorithm(string(algorithm)); err != nil {
+		return nil, err
+	}
+
+	e := &Engine{algorithm: algorithm, now: time.Now}
+	if err := e.Replace(policies); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Algorithm returns the algorithm combining policies
+func (e *Engine) Algorithm() Algorithm {
+	return e.algorithm
+}
+
+// Replace swaps the engine's policies. Policies are evaluated in order,
+// which matters for first-applicable.
+func (e *Engine) Replace(policies []*Policy) error {
+	seen := make(map[string]bool, len(policies))
+	for _, p := range policies {
+		if !p.compiled {
+			if err := p.Compile(); err != nil {
+				return err
+			}
+		}
+		if seen[p.ID] {
+			return fmt.Errorf("duplicate policy %s", p.ID)
+		}
+		seen[p.ID] = true
+	}
+
+	e.mu.Lock()
+	e.policies = append([]*Policy(nil), policies...)
+	e.mu.Unlock()
+	return nil
+}
+
+// Policies returns the policies in evaluation order
+func (e *Engine) Policies() []*Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]*Policy(nil), e.policies...)
+}
+
+// Evaluate decides the request
+func (e *Engine) Evaluate(req *Request) Decision {
+	attrs := req.attributes(e.now())
+	policies := e.Policies()
+
+	var results []RuleResult
+	rules := make([]string, len(policies))
+
+	c := newCombiner(e.algorithm)
+	for i, p := range policies {
+		var effect Effect
+		effect, rules[i] = p.evaluate(attrs, &results)
+		c.add(i, effect)
+		if c.stop() {
+			break
+		}
+	}
+
+	effect, i := c.result()
+	decision := Decision{Effect: effect, Results: results}
+	if i >= 0 {
+		decision.Policy = policies[i].ID
+		decision.Rule = rules[i]
+	}
+	decision.Reason = reason(decision)
+	return decision
+}
+
+// evaluate returns the policy's effect and the rule that decided it
+func (p *Policy) evaluate(attrs *attributes, results *[]RuleResult) (Effect, string) {
+	if p.target != nil {
+		applies, err := p.target.evalBool(attrs)
+		if err != nil {
+			*results = append(*results, RuleResult{Policy: p.ID, Effect: Indeterminate, Error: err.Error()})
+			return Indeterminate, ""
+		}
+		if !applies {
+			*results = append(*results, RuleResult{Policy: p.ID, Effect: NotApplicable})
+			return NotApplicable, ""
+		}
+	}
+
+	c := newCombiner(p.Algorithm)
+	for i, rule := range p.Rules {
+		result := RuleResult{Policy: p.ID, Rule: rule.ID, Effect: rule.Effect}
+		if rule.condition != nil {
+			holds, err := rule.condition.evalBool(attrs)
+			switch {
+			case err != nil:
+				result.Effect = Indeterminate
+				result.Error = err.Error()
+			case !holds:
+				result.Effect = NotApplicable
+			}
+		}
+
+		*results = append(*results, result)
+		c.add(i, result.Effect)
+		if c.stop() {
+			break
+		}
+	}
+
+	effect, i := c.result()
+	if i < 0 {
+		return effect, ""
+	}
+	return effect, p.Rules[i].ID
+}
+
+func reason(d Decision) string {
+	where := d.Policy
+	if d.Rule != "" {
+		where += "/" + d.Rule
+	}
+
+	switch d.Effect {
+	case Permit:
+		return "permitted by " + where
+	case Deny:
+		return "denied by " + where
+	case Indeterminate:
+		for _, result := range d.Results {
+			if result.Policy == d.Policy && result.Rule == d.Rule && result.Error != "" {
+				return fmt.Sprintf("%s could not be evaluated: %s", where, result.Error)
+			}
+		}
+		return where + " could not be evaluated"
+	}
+	return "no policy applies"
+}
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const documentsPolicy = `{
+  "id": "documents",
+  "target": "resource.type == \"document\"",
+  "algorithm": "deny-overrides",
+  "rules": [
+    {"id": "owner", "effect": "permit", "condition": "resource.owner == subject.id"},
+    {"id": "department-hours", "effect": "permit",
+     "condition": "subject.department == resource.department and time_between(env.time, \"09:00\", \"17:00\")"},
+    {"id": "suspended", "effect": "deny", "condition": "subject.status == \"suspended\""}
+  ]
+}`
+
+func TestEngine_Evaluate(t *testing.T) {
+	// Setup
+	p, err := Parse([]byte(documentsPolicy))
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	engine, err := NewEngine(DenyOverrides, p)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	doc := map[string]any{"type": "document", "owner": "user-1", "department": "sales"}
+	duringHours := map[string]any{"time": "2026-10-14T10:00:00Z"}
+	afterHours := map[string]any{"time": "2026-10-14T20:00:00Z"}
+
+	tests := []struct {
+		name     string
+		req      *Request
+		effect   Effect
+		rule     string
+		policyID string
+	}{
+		{"owner", &Request{Subject: map[string]any{"id": "user-1"}, Resource: doc, Environment: afterHours}, Permit, "owner", "documents"},
+		{"department in hours", &Request{Subject: map[string]any{"id": "user-2", "department": "sales"}, Resource: doc, Environment: duringHours}, Permit, "department-hours", "documents"},
+		{"department after hours", &Request{Subject: map[string]any{"id": "user-2", "department": "sales"}, Resource: doc, Environment: afterHours}, NotApplicable, "", ""},
+		{"suspended owner", &Request{Subject: map[string]any{"id": "user-1", "status": "suspended"}, Resource: doc, Environment: duringHours}, Deny, "suspended", "documents"},
+		{"other resource type", &Request{Subject: map[string]any{"id": "user-1"}, Resource: map[string]any{"type": "invoice", "owner": "user-1"}}, NotApplicable, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			decision := engine.Evaluate(tt.req)
+
+			// Assert
+			if decision.Effect != tt.effect || decision.Rule != tt.rule || decision.Policy != tt.policyID {
+				t.Errorf("Expected %s by %s/%s, got %+v", tt.effect, tt.policyID, tt.rule, decision)
+			}
+			if decision.Allowed() != (tt.effect == Permit) {
+				t.Errorf("Expected Allowed() to be %v", tt.effect == Permit)
+			}
+			if decision.Reason == "" || len(decision.Results) == 0 {
+				t.Errorf("Expected an explained decision, got %+v", decision)
+			}
+		})
+	}
+}
+
+func TestEngine_CombiningAlgorithms(t *testing.T) {
+	permit := &Policy{ID: "permit", Algorithm: DenyOverrides, Rules: []*Rule{{ID: "always", Effect: Permit}}}
+	deny := &Policy{ID: "deny", Algorithm: DenyOverrides, Rules: []*Rule{{ID: "always", Effect: Deny}}}
+	broken := &Policy{ID: "broken", Algorithm: DenyOverrides, Rules: []*Rule{{ID: "typed", Effect: Permit, Condition: "subject.level > 3"}}}
+
+	tests := []struct {
+		algorithm Algorithm
+		policies  []*Policy
+		effect    Effect
+		decidedBy string
+	}{
+		{DenyOverrides, []*Policy{permit, deny}, Deny, "deny"},
+		{DenyOverrides, []*Policy{permit, broken}, Indeterminate, "broken"},
+		{PermitOverrides, []*Policy{deny, permit}, Permit, "permit"},
+		{PermitOverrides, []*Policy{deny, broken}, Indeterminate, "broken"},
+		{FirstApplicable, []*Policy{deny, permit}, Deny, "deny"},
+		{FirstApplicable, []*Policy{permit, deny}, Permit, "permit"},
+		{FirstApplicable, nil, NotApplicable, ""},
+	}
+
+	for _, tt := range tests {
+		engine, err := NewEngine(tt.algorithm, tt.policies...)
+		if err != nil {
+			t.Fatalf("Failed to create engine: %v", err)
+		}
+
+		decision := engine.Evaluate(&Request{Subject: map[string]any{"level": "high"}})
+		if decision.Effect != tt.effect || decision.Policy != tt.decidedBy {
+			t.Errorf("%s: expected %s by %q, got %s by %q (%s)", tt.algorithm, tt.effect, tt.decidedBy, decision.Effect, decision.Policy, decision.Reason)
+		}
+	}
+}
+
+func TestEngine_IndeterminateExplained(t *testing.T) {
+	// Setup
+	p := &Policy{ID: "levels", Algorithm: PermitOverrides, Rules: []*Rule{
+		{ID: "senior", Effect: Permit, Condition: "subject.level >= 5"},
+	}}
+	engine, err := NewEngine(DenyOverrides, p)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Execute
+	decision := engine.Evaluate(&Request{Subject: map[string]any{}})
+
+	// Assert
+	if decision.Effect != Indeterminate || decision.Allowed() {
+		t.Fatalf("Expected an indeterminate denial, got %+v", decision)
+	}
+	if decision.Results[0].Error == "" || decision.Rule != "senior" {
+		t.Errorf("Expected the failing rule to be reported, got %+v", decision)
+	}
+}
+
+func TestEngine_DefaultTime(t *testing.T) {
+	p := &Policy{ID: "hours", Algorithm: DenyOverrides, Rules: []*Rule{
+		{ID: "office", Effect: Permit, Condition: `time_between(env.time, "09:00", "17:00")`},
+	}}
+	engine, err := NewEngine(DenyOverrides, p)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	engine.now = func() time.Time { return time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) }
+	if !engine.Evaluate(&Request{}).Allowed() {
+		t.Error("Expected noon to be within office hours")
+	}
+
+	engine.now = func() time.Time { return time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC) }
+	if engine.Evaluate(&Request{}).Allowed() {
+		t.Error("Expected 23:00 to be outside office hours")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "10-documents.json"), []byte(documentsPolicy), 0o600); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a policy"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Execute
+	policies, err := LoadDir(dir)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(policies) != 1 || policies[0].ID != "documents" {
+		t.Errorf("Expected the documents policy, got %v", policies)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "20-broken.json"), []byte(`{"id": "broken", "algorithm": "deny-overrides", "rules": [{"id": "r", "effect": "permit", "condition": "subject.id =="}]}`), 0o600); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	if _, err := LoadDir(dir); err == nil {
+		t.Error("Expected a policy with an invalid condition to be rejected")
+	}
+}
@@ -0,0 +1,517 @@
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"time"
+
+	// Time zone arguments must work in images without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// Values are JSON-like: nil, bool, float64, string, []any and map[string]any
+
+type node interface {
+	eval(attrs *attributes) (any, error)
+}
+
+// attributes are the normalised attributes of a request
+type attributes struct {
+	subject  map[string]any
+	resource map[string]any
+	action   string
+	env      map[string]any
+}
+
+func (a *attributes) root(name string) any {
+	switch name {
+	case RootSubject:
+		return a.subject
+	case RootResource:
+		return a.resource
+	case RootAction:
+		return a.action
+	default:
+		return a.env
+	}
+}
+
+// Eval evaluates the expression against a request, which must produce a
+// boolean
+func (e *Expression) Eval(req *Request) (bool, error) {
+	return e.evalBool(req.attributes(time.Now()))
+}
+
+func (e *Expression) evalBool(attrs *attributes) (bool, error) {
+	value, err := e.root.eval(attrs)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression is %s, not a boolean", typeName(value))
+	}
+	return result, nil
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(*attributes) (any, error) {
+	return n.value, nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(attrs *attributes) (any, error) {
+	values := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(attrs)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// pathNode reads an attribute. Missing attributes are null.
+type pathNode struct {
+	path []string
+}
+
+func (n *pathNode) eval(attrs *attributes) (any, error) {
+	value := attrs.root(n.path[0])
+	for i, name := range n.path[1:] {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[name]
+		case nil:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("%s is %s, not an object", strings.Join(n.path[:i+1], "."), typeName(value))
+		}
+	}
+	return value, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(attrs *attributes) (any, error) {
+	value, err := n.operand.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("not needs a boolean, got %s", typeName(value))
+	}
+	return !b, nil
+}
+
+type logicalNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicalNode) eval(attrs *attributes) (any, error) {
+	op := "or"
+	if n.and {
+		op = "and"
+	}
+
+	left, err := n.left.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := left.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s needs booleans, got %s", op, typeName(left))
+	}
+	if l != n.and {
+		// false and ..., true or ...
+		return l, nil
+	}
+
+	right, err := n.right.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := right.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s needs booleans, got %s", op, typeName(right))
+	}
+	return r, nil
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(attrs *attributes) (any, error) {
+	left, err := n.left.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return n.equal(left, right), nil
+	case "!=":
+		return !n.equal(left, right), nil
+	case "in":
+		return in(left, right)
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %s", typeName(right))
+		}
+		cmp = compareOrdered(l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %s", typeName(right))
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("cannot order %s", typeName(left))
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *callNode) eval(attrs *attributes) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(attrs)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	result, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// equal compares operands. Two missing attributes are not equal, so that
+// resource.owner == subject.id does not hold when neither is known; a
+// missing attribute only equals the null literal.
+func (n *compareNode) equal(left, right any) bool {
+	if left == nil && right == nil {
+		_, leftLiteral := n.left.(*literalNode)
+		_, rightLiteral := n.right.(*literalNode)
+		return leftLiteral || rightLiteral
+	}
+	return equal(left, right)
+}
+
+func compareOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func equal(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// in reports whether needle is an element of a list, a substring of a
+// string or a key of an object
+func in(needle, haystack any) (bool, error) {
+	switch h := haystack.(type) {
+	case []any:
+		for _, item := range h {
+			if equal(needle, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		s, ok := needle.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot look for %s in a string", typeName(needle))
+		}
+		return strings.Contains(h, s), nil
+	case map[string]any:
+		s, ok := needle.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot look for %s in an object", typeName(needle))
+		}
+		_, found := h[s]
+		return found, nil
+	case nil:
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot look into %s", typeName(haystack))
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// normalize converts Go values passed by in-process callers into the JSON
+// value types expressions work on
+func normalize(value any) any {
+	switch v := value.(type) {
+	case nil, bool, float64, string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = normalize(item)
+		}
+		return out
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = normalize(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = normalize(iter.Value().Interface())
+		}
+		return out
+	}
+	return fmt.Sprint(value)
+}
+
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []any) (any, error)
+}
+
+func (f function) arity() string {
+	if f.minArgs == f.maxArgs {
+		return fmt.Sprint(f.minArgs)
+	}
+	return fmt.Sprintf("%d to %d", f.minArgs, f.maxArgs)
+}
+
+// functions callable from expressions. Times are RFC 3339 strings; the
+// optional last argument of the time functions is an IANA time zone the time
+// is converted to first.
+var functions = map[string]function{
+	// time_between(t, "09:00", "17:00"[, zone]) reports whether the clock
+	// time of t is in [from, to). Ranges may wrap midnight.
+	"time_between": {minArgs: 3, maxArgs: 4, call: func(args []any) (any, error) {
+		t, err := timeArg(args[0], args[3:])
+		if err != nil {
+			return nil, err
+		}
+		from, err := clockArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := clockArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+
+		now := t.Hour()*60 + t.Minute()
+		if from <= to {
+			return from <= now && now < to, nil
+		}
+		return now >= from || now < to, nil
+	}},
+	// weekday(t[, zone]) returns the lowercase day name, e.g. "monday"
+	"weekday": {minArgs: 1, maxArgs: 2, call: func(args []any) (any, error) {
+		t, err := timeArg(args[0], args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToLower(t.Weekday().String()), nil
+	}},
+	// hour(t[, zone]) returns the hour of the day
+	"hour": {minArgs: 1, maxArgs: 2, call: func(args []any) (any, error) {
+		t, err := timeArg(args[0], args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return float64(t.Hour()), nil
+	}},
+	"contains": {minArgs: 2, maxArgs: 2, call: func(args []any) (any, error) {
+		return in(args[1], args[0])
+	}},
+	"starts_with": {minArgs: 2, maxArgs: 2, call: func(args []any) (any, error) {
+		s, prefix, err := stringArgs(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasPrefix(s, prefix), nil
+	}},
+	"ends_with": {minArgs: 2, maxArgs: 2, call: func(args []any) (any, error) {
+		s, suffix, err := stringArgs(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasSuffix(s, suffix), nil
+	}},
+	"lower": {minArgs: 1, maxArgs: 1, call: func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("needs a string, got %s", typeName(args[0]))
+		}
+		return strings.ToLower(s), nil
+	}},
+	"len": {minArgs: 1, maxArgs: 1, call: func(args []any) (any, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []any:
+			return float64(len(v)), nil
+		case map[string]any:
+			return float64(len(v)), nil
+		case nil:
+			return float64(0), nil
+		}
+		return nil, fmt.Errorf("cannot take the length of %s", typeName(args[0]))
+	}},
+	// cidr_match(ip, "10.0.0.0/8") reports whether ip is in the network
+	"cidr_match": {minArgs: 2, maxArgs: 2, call: func(args []any) (any, error) {
+		ip, cidr, err := stringArgs(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q", ip)
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", cidr)
+		}
+		return prefix.Contains(addr.Unmap()), nil
+	}},
+}
+
+func timeArg(value any, zone []any) (time.Time, error) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("needs an RFC 3339 time, got %s", typeName(value))
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+
+	if len(zone) == 0 {
+		return t, nil
+	}
+	name, ok := zone[0].(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("time zone must be a string, got %s", typeName(zone[0]))
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown time zone %q", name)
+	}
+	return t.In(location), nil
+}
+
+// clockArg parses "15:04" into minutes after midnight
+func clockArg(value any) (int, error) {
+	s, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("needs a time of day like \"09:00\", got %s", typeName(value))
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func stringArgs(a, b any) (string, string, error) {
+	s, ok := a.(string)
+	if !ok {
+		return "", "", fmt.Errorf("needs strings, got %s", typeName(a))
+	}
+	t, ok := b.(string)
+	if !ok {
+		return "", "", fmt.Errorf("needs strings, got %s", typeName(b))
+	}
+	return s, t, nil
+}
@@ -0,0 +1,380 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Attribute roots an expression may refer to
+const (
+	RootSubject  = "subject"
+	RootResource = "resource"
+	RootAction   = "action"
+	RootEnv      = "env"
+)
+
+// Expression is a compiled condition
+type Expression struct {
+	source string
+	root   node
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Compile parses an expression such as
+//
+//	resource.owner == subject.id or
+//	  (subject.department == resource.department and time_between(env.time, "09:00", "17:00"))
+//
+// Operators, from lowest to highest precedence, are or (||), and (&&),
+// not (!), and the comparisons ==, !=, <, <=, >, >= and in. Operands are
+// attribute paths under subject, resource, action and env, string, number,
+// boolean and null literals, lists ([1, 2]) and function calls.
+func Compile(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value any
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != c; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start:i]), value: b.String(), pos: start})
+
+		case unicode.IsDigit(c) || c == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", text, start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, value: value, pos: start})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch {
+			case two == "==" || two == "!=" || two == "<=" || two == ">=" || two == "&&" || two == "||":
+				tokens = append(tokens, token{kind: tokenOperator, text: two, pos: start})
+				i += 2
+			case c == '<' || c == '>' || c == '!':
+				tokens = append(tokens, token{kind: tokenOperator, text: string(c), pos: start})
+				i++
+			case strings.ContainsRune("().,[]", c):
+				tokens = append(tokens, token{kind: tokenPunct, text: string(c), pos: start})
+				i++
+			default:
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, start)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// maxDepth bounds how deeply expressions nest, so that a crafted policy
+// cannot exhaust the stack of the parser or of evaluations
+const maxDepth = 64
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// descend enters a nested expression; leave it with ascend
+func (p *parser) descend() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return fmt.Errorf("expression nested deeper than %d levels at offset %d", maxDepth, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *parser) ascend() {
+	p.depth--
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of texts
+func (p *parser) accept(texts ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind == tokenString || tok.kind == tokenNumber || tok.kind == tokenEOF {
+		return "", false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		tok := p.peek()
+		return fmt.Errorf("expected %q, got %s at offset %d", text, tok, tok.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	if err := p.descend(); err != nil {
+		return nil, err
+	}
+	defer p.ascend()
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("or", "||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: false, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("and", "&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.accept("not", "!"); ok {
+		if err := p.descend(); err != nil {
+			return nil, err
+		}
+		defer p.ascend()
+
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenString, tokenNumber:
+		return &literalNode{value: tok.value}, nil
+
+	case tokenPunct:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+
+		if _, ok := p.accept("("); ok {
+			fn, ok := functions[tok.text]
+			if !ok {
+				return nil, fmt.Errorf("unknown function %q at offset %d", tok.text, tok.pos)
+			}
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+				return nil, fmt.Errorf("%s takes %s arguments, got %d", tok.text, fn.arity(), len(args))
+			}
+			return &callNode{name: tok.text, fn: fn, args: args}, nil
+		}
+
+		return p.parsePath(tok)
+	}
+
+	return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+}
+
+func (p *parser) parsePath(root token) (node, error) {
+	switch root.text {
+	case RootSubject, RootResource, RootAction, RootEnv:
+	default:
+		return nil, fmt.Errorf("unknown attribute %q at offset %d, attributes start with subject, resource, action or env", root.text, root.pos)
+	}
+
+	path := []string{root.text}
+	for {
+		if _, ok := p.accept("."); !ok {
+			return &pathNode{path: path}, nil
+		}
+		tok := p.next()
+		if tok.kind != tokenIdent {
+			return nil, fmt.Errorf("expected attribute name, got %s at offset %d", tok, tok.pos)
+		}
+		path = append(path, tok.text)
+	}
+}
+
+// parseList parses comma separated expressions up to the closing token
+func (p *parser) parseList(closing string) ([]node, error) {
+	var items []node
+	if _, ok := p.accept(closing); ok {
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if _, ok := p.accept(closing); ok {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
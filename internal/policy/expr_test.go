@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompile_Eval(t *testing.T) {
+	req := &Request{
+		Subject: map[string]any{
+			"id":         "user-1",
+			"department": "sales",
+			"roles":      []string{"editor", "viewer"},
+			"level":      3,
+		},
+		Resource: map[string]any{
+			"owner":      "user-2",
+			"department": "sales",
+			"tags":       []any{"public"},
+		},
+		Action: "read",
+		Environment: map[string]any{
+			"time": "2026-10-14T10:30:00Z", // a Wednesday
+			"ip":   "10.1.2.3",
+		},
+	}
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`resource.owner == subject.id`, false},
+		{`resource.owner == subject.id or subject.department == resource.department`, true},
+		{`resource.owner == subject.id || subject.department == resource.department && action == "write"`, false},
+		{`not (action == "write")`, true},
+		{`!(action == 'read')`, false},
+		{`"editor" in subject.roles`, true},
+		{`"admin" in subject.roles`, false},
+		{`action in ["read", "list"]`, true},
+		{`subject.level >= 3 and subject.level < 5`, true},
+		{`subject.manager == null`, true},
+		{`subject.manager == resource.manager`, false},
+		{`subject.manager != resource.manager`, true},
+		{`time_between(env.time, "09:00", "17:00")`, true},
+		{`time_between(env.time, "22:00", "06:00")`, false},
+		{`time_between(env.time, "09:00", "17:00", "America/Los_Angeles")`, false},
+		{`weekday(env.time) in ["saturday", "sunday"]`, false},
+		{`hour(env.time) == 10`, true},
+		{`cidr_match(env.ip, "10.0.0.0/8")`, true},
+		{`starts_with(subject.id, "user-") and len(resource.tags) == 1`, true},
+		{`contains(resource.tags, "public")`, true},
+		{`lower("SALES") == subject.department`, true},
+	}
+
+	for _, tt := range tests {
+		expr, err := Compile(tt.expression)
+		if err != nil {
+			t.Errorf("Compile(%s) failed: %v", tt.expression, err)
+			continue
+		}
+
+		got, err := expr.Eval(req)
+		if err != nil {
+			t.Errorf("Eval(%s) failed: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Eval(%s) = %v, expected %v", tt.expression, got, tt.expected)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	invalid := []string{
+		``,
+		`user.id == "x"`,
+		`subject.id ==`,
+		`unknown_fn(subject.id)`,
+		`time_between(env.time)`,
+		`(subject.id == "x"`,
+		`subject.id == "unterminated`,
+		`subject.id = "x"`,
+		`subject.id == "x" extra`,
+		strings.Repeat("(", 100) + `subject.id == "x"` + strings.Repeat(")", 100),
+		strings.Repeat("not ", 100) + `subject.admin`,
+		strings.Repeat("[", 100) + strings.Repeat("]", 100) + ` == subject.id`,
+	}
+
+	for _, expression := range invalid {
+		if _, err := Compile(expression); err == nil {
+			t.Errorf("Expected %q to be rejected", expression)
+		}
+	}
+}
+
+func TestCompile_Depth(t *testing.T) {
+	// Nesting up to the limit is fine
+	nested := strings.Repeat("(", maxDepth-1) + `subject.id == "x"` + strings.Repeat(")", maxDepth-1)
+	if _, err := Compile(nested); err != nil {
+		t.Errorf("Expected %d levels to compile, got %v", maxDepth, err)
+	}
+
+	_, err := Compile("(" + nested + ")")
+	if err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Errorf("Expected deeper nesting to be rejected, got %v", err)
+	}
+}
+
+func TestEval_TypeErrors(t *testing.T) {
+	req := &Request{Subject: map[string]any{"id": "user-1", "level": 3}}
+
+	for _, expression := range []string{
+		`subject.level > "2"`,
+		`subject.id and true`,
+		`subject.missing < 3`,
+		`subject.id.name == "x"`,
+		`subject.id`,
+	} {
+		expr, err := Compile(expression)
+		if err != nil {
+			t.Fatalf("Compile(%s) failed: %v", expression, err)
+		}
+		if _, err := expr.Eval(req); err == nil {
+			t.Errorf("Expected %s to fail to evaluate", expression)
+		}
+	}
+}
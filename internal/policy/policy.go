@@ -0,0 +1,223 @@
+// Package policy is an attribute based access control engine. Policies are
+// JSON documents holding rules whose conditions are expressions over the
+// attributes of the subject, the resource, the action and the environment:
+//
+//	{
+//	  "id": "documents",
+//	  "target": "resource.type == \"document\"",
+//	  "algorithm": "deny-overrides",
+//	  "rules": [
+//	    {"id": "owner", "effect": "permit", "condition": "resource.owner == subject.id"},
+//	    {"id": "department-hours", "effect": "permit",
+//	     "condition": "subject.department == resource.department and time_between(env.time, \"09:00\", \"17:00\")"},
+//	    {"id": "suspended", "effect": "deny", "condition": "subject.status == \"suspended\""}
+//	  ]
+//	}
+//
+// In process, build an Engine from policies and call Evaluate:
+//
+//	engine, err := policy.NewEngine(policy.DenyOverrides, policies...)
+//	decision := engine.Evaluate(&policy.Request{
+//		Subject:  map[string]any{"id": userID, "department": "sales"},
+//		Resource: map[string]any{"type": "document", "owner": ownerID},
+//		Action:   "read",
+//	})
+//	if decision.Allowed() { ... }
+//
+// Decisions explain themselves: they name the policy and rule that decided
+// and carry the result of every rule evaluated.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Effect is the outcome of a rule, a policy or a whole evaluation
+type Effect string
+
+const (
+	Permit Effect = "permit"
+	Deny   Effect = "deny"
+	// NotApplicable means no rule matched
+	NotApplicable Effect = "not_applicable"
+	// Indeterminate means a condition could not be evaluated, e.g. because
+	// it compared a missing attribute. Callers must treat it as a denial.
+	Indeterminate Effect = "indeterminate"
+)
+
+// Algorithm combines the effects of rules within a policy, or of policies
+// within an engine
+type Algorithm string
+
+const (
+	// DenyOverrides denies if anything denies, is indeterminate if anything
+	// failed to evaluate, and otherwise permits if anything permits
+	DenyOverrides Algorithm = "deny-overrides"
+	// PermitOverrides permits if anything permits, is indeterminate if
+	// anything failed to evaluate, and otherwise denies if anything denies
+	PermitOverrides Algorithm = "permit-overrides"
+	// FirstApplicable takes the first effect that is not not_applicable
+	FirstApplicable Algorithm = "first-applicable"
+)
+
+// ParseAlgorithm validates an algorithm name
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch algorithm := Algorithm(name); algorithm {
+	case DenyOverrides, PermitOverrides, FirstApplicable:
+		return algorithm, nil
+	}
+	return "", fmt.Errorf("unknown combining algorithm %q, expected %s, %s or %s", name, DenyOverrides, PermitOverrides, FirstApplicable)
+}
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,99}$`)
+
+// Policy is a set of rules combined by Algorithm. A policy only applies to
+// requests matching its Target; an empty target matches every request.
+type Policy struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description,omitempty"`
+	Target      string    `json:"target,omitempty"`
+	Algorithm   Algorithm `json:"algorithm"`
+	Rules       []*Rule   `json:"rules"`
+
+	target   *Expression
+	compiled bool
+}
+
+// Rule has Effect when its Condition holds; an empty condition always holds
+type Rule struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Effect      Effect `json:"effect"`
+	Condition   string `json:"condition,omitempty"`
+
+	condition *Expression
+}
+
+// Parse parses and compiles a JSON policy
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("error parsing policy: %w", err)
+	}
+	if err := p.Compile(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Compile validates the policy and compiles its expressions. Policies must
+// be compiled before they are evaluated.
+func (p *Policy) Compile() error {
+	if !idPattern.MatchString(p.ID) {
+		return fmt.Errorf("invalid policy id %q", p.ID)
+	}
+	if _, err := ParseAlgorithm(string(p.Algorithm)); err != nil {
+		return fmt.Errorf("policy %s: %w", p.ID, err)
+	}
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("policy %s has no rules", p.ID)
+	}
+
+	p.target = nil
+	if p.Target != "" {
+		target, err := Compile(p.Target)
+		if err != nil {
+			return fmt.Errorf("policy %s target: %w", p.ID, err)
+		}
+		p.target = target
+	}
+
+	seen := make(map[string]bool, len(p.Rules))
+	for _, rule := range p.Rules {
+		if rule == nil || !idPattern.MatchString(rule.ID) {
+			return fmt.Errorf("policy %s has a rule with an invalid id", p.ID)
+		}
+		if seen[rule.ID] {
+			return fmt.Errorf("policy %s has duplicate rule %s", p.ID, rule.ID)
+		}
+		seen[rule.ID] = true
+
+		if rule.Effect != Permit && rule.Effect != Deny {
+			return fmt.Errorf("rule %s/%s: effect must be %s or %s", p.ID, rule.ID, Permit, Deny)
+		}
+
+		rule.condition = nil
+		if rule.Condition != "" {
+			condition, err := Compile(rule.Condition)
+			if err != nil {
+				return fmt.Errorf("rule %s/%s condition: %w", p.ID, rule.ID, err)
+			}
+			rule.condition = condition
+		}
+	}
+
+	p.compiled = true
+	return nil
+}
+
+// combiner folds effects with a combining algorithm
+type combiner struct {
+	algorithm Algorithm
+	// seen maps each effect to the first element that had it
+	seen map[Effect]int
+}
+
+func newCombiner(algorithm Algorithm) *combiner {
+	return &combiner{algorithm: algorithm, seen: make(map[Effect]int)}
+}
+
+// add records the effect of the i-th element
+func (c *combiner) add(i int, effect Effect) {
+	if _, ok := c.seen[effect]; !ok {
+		c.seen[effect] = i
+	}
+}
+
+// stop reports whether later elements cannot change the result
+func (c *combiner) stop() bool {
+	switch c.algorithm {
+	case DenyOverrides:
+		_, denied := c.seen[Deny]
+		return denied
+	case PermitOverrides:
+		_, permitted := c.seen[Permit]
+		return permitted
+	default:
+		for effect := range c.seen {
+			if effect != NotApplicable {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// result returns the combined effect and the index of the element that
+// decided it, or -1
+func (c *combiner) result() (Effect, int) {
+	var order []Effect
+	switch c.algorithm {
+	case DenyOverrides:
+		order = []Effect{Deny, Indeterminate, Permit}
+	case PermitOverrides:
+		order = []Effect{Permit, Indeterminate, Deny}
+	default:
+		first, effect := -1, NotApplicable
+		for e, i := range c.seen {
+			if e != NotApplicable && (first == -1 || i < first) {
+				first, effect = i, e
+			}
+		}
+		return effect, first
+	}
+
+	for _, effect := range order {
+		if i, ok := c.seen[effect]; ok {
+			return effect, i
+		}
+	}
+	return NotApplicable, -1
+}
@@ -0,0 +1,167 @@
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// filterMagic starts every filter file
+const filterMagic = "PWNDBLM1"
+
+// Filter is a Bloom filter of the hashes of breached passwords. It never
+// misses a password it was built with, and reports others as breached with
+// the false positive rate it was built for.
+//
+// The SHA-1 hashes are already uniformly distributed, so the bit positions
+// are derived from them directly by double hashing rather than by hashing
+// again.
+type Filter struct {
+	bits     []byte
+	m        uint64
+	k        uint32
+	minCount int
+	count    uint64
+}
+
+// NewFilter creates an empty filter for n passwords seen at least minCount
+// times, with the given false positive rate
+func NewFilter(n uint64, falsePositiveRate float64, minCount int) (*Filter, error) {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("false positive rate must be between 0 and 1")
+	}
+	if minCount < 1 {
+		return nil, fmt.Errorf("minimum count must be at least 1")
+	}
+
+	// m = -n ln p / (ln 2)^2 bits and k = m/n ln 2 positions per hash are
+	// the fewest bits for the rate
+	m := uint64(64)
+	k := uint32(1)
+	if n > 0 {
+		m = uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+		m = (m + 63) / 64 * 64
+		k = uint32(max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	}
+	return &Filter{bits: make([]byte, m/8), m: m, k: k, minCount: minCount}, nil
+}
+
+// MinCount is how often the passwords in the filter were at least seen
+func (f *Filter) MinCount() int {
+	return f.minCount
+}
+
+// Len is how many hashes were added to the filter
+func (f *Filter) Len() uint64 {
+	return f.count
+}
+
+// Size is the size of the filter in bytes
+func (f *Filter) Size() int {
+	return len(f.bits)
+}
+
+func (f *Filter) positions(hash [sha1.Size]byte, fn func(bit uint64) bool) bool {
+	h1 := binary.BigEndian.Uint64(hash[0:8])
+	h2 := binary.BigEndian.Uint64(hash[8:16]) | 1
+	for i := uint64(0); i < uint64(f.k); i++ {
+		if !fn((h1 + i*h2) % f.m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add adds the SHA-1 hash of a password
+func (f *Filter) Add(hash [sha1.Size]byte) {
+	f.positions(hash, func(bit uint64) bool {
+		f.bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+	f.count++
+}
+
+// Contains reports whether the SHA-1 hash was probably added
+func (f *Filter) Contains(hash [sha1.Size]byte) bool {
+	return f.positions(hash, func(bit uint64) bool {
+		return f.bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+func (f *Filter) Breached(password string) (bool, error) {
+	return f.Contains(sha1.Sum([]byte(password))), nil
+}
+
+// filterHeader follows filterMagic in filter files
+type filterHeader struct {
+	Bits     uint64
+	K        uint32
+	MinCount uint32
+	Count    uint64
+}
+
+// WriteTo writes the filter to w
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := filterHeader{Bits: f.m, K: f.k, MinCount: uint32(f.minCount), Count: f.count}
+	if _, err := bw.WriteString(filterMagic); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+	if _, err := bw.Write(f.bits); err != nil {
+		return 0, err
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(len(filterMagic) + binary.Size(header) + len(f.bits)), nil
+}
+
+// LoadFilter reads a filter written with WriteTo into memory
+func LoadFilter(path string) (*Filter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(filterMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != filterMagic {
+		return nil, fmt.Errorf("%s is not a breached password filter", path)
+	}
+	var header filterHeader
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("error reading filter header: %w", err)
+	}
+	if header.Bits == 0 || header.Bits%64 != 0 || header.K == 0 || header.MinCount == 0 {
+		return nil, fmt.Errorf("%s has an invalid filter header", path)
+	}
+
+	// Check the size before allocating what the header asks for
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(info.Size()) != uint64(len(filterMagic)+binary.Size(header))+header.Bits/8 {
+		return nil, fmt.Errorf("%s is truncated or has trailing data", path)
+	}
+
+	f := &Filter{
+		bits:     make([]byte, header.Bits/8),
+		m:        header.Bits,
+		k:        header.K,
+		minCount: int(header.MinCount),
+		count:    header.Count,
+	}
+	if _, err := io.ReadFull(reader, f.bits); err != nil {
+		return nil, fmt.Errorf("error reading filter: %w", err)
+	}
+	return f, nil
+}
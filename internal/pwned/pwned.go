@@ -0,0 +1,197 @@
+// Package pwned tells whether passwords appear in the Pwned Passwords corpus
+// of breached passwords, from a copy kept on disk rather than by calling the
+// API.
+//
+// The corpus is the SHA-1 range dataset fetched by the Pwned Passwords
+// downloader: a directory holding one file per five character hash prefix,
+// named like 21BD1.txt, whose lines are the remaining 35 hex digits of a
+// hash and how often it was seen, as in
+// "0018A45C4D1DEF81644B54AB7F969B88D65:10". Looking a password up reads one
+// of those files. For deployments that cannot keep the whole download, the
+// pwnedfilter command compacts it into a Bloom filter of a few bits per
+// password.
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Source tells whether a password is in the corpus
+type Source interface {
+	// Breached reports whether the password was seen in breaches at least
+	// as often as the source was opened with
+	Breached(password string) (bool, error)
+}
+
+// Open opens the corpus at path, a range dataset directory or a filter
+// written by Filter.WriteTo. Passwords seen fewer than minCount times are not
+// reported. A filter only holds the passwords seen as often as it was built
+// with, so it must have been built with minCount.
+func Open(path string, minCount int) (Source, error) {
+	if minCount < 1 {
+		return nil, fmt.Errorf("minimum count must be at least 1")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &RangeDir{dir: path, minCount: minCount}, nil
+	}
+
+	filter, err := LoadFilter(path)
+	if err != nil {
+		return nil, err
+	}
+	if filter.MinCount() != minCount {
+		return nil, fmt.Errorf("filter %s holds passwords seen at least %d times, not %d; rebuild it with -min-count %d", path, filter.MinCount(), minCount, minCount)
+	}
+	return filter, nil
+}
+
+// hashLen is the length of a hex SHA-1 hash and prefixLen that of the range
+// prefixes
+const (
+	hashLen   = 40
+	prefixLen = 5
+)
+
+// hashPassword returns the uppercase hex SHA-1 hash of the password, as the
+// corpus lists it
+func hashPassword(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// RangeDir looks passwords up in a range dataset directory
+type RangeDir struct {
+	dir      string
+	minCount int
+}
+
+func (d *RangeDir) Breached(password string) (bool, error) {
+	hash := hashPassword(password)
+	file, err := os.Open(filepath.Join(d.dir, hash[:prefixLen]+".txt"))
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	suffix := hash[prefixLen:]
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entrySuffix, count, err := parseLine(scanner.Text(), hashLen-prefixLen)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", file.Name(), err)
+		}
+		if entrySuffix == suffix {
+			return count >= d.minCount, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// parseLine splits a "HASH:COUNT" line whose hash has hexLen digits
+func parseLine(line string, hexLen int) (string, int, error) {
+	hash, countStr, ok := strings.Cut(strings.TrimSpace(line), ":")
+	if !ok || len(hash) != hexLen {
+		return "", 0, fmt.Errorf("invalid line %q", line)
+	}
+	if !isHex(hash) {
+		return "", 0, fmt.Errorf("invalid hash in line %q", line)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 0 {
+		return "", 0, fmt.Errorf("invalid count in line %q", line)
+	}
+	return strings.ToUpper(hash), count, nil
+}
+
+// isHex reports whether s holds only hex digits. Range prefixes and suffixes
+// have odd lengths, so they cannot be decoded on their own.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadCorpus calls fn with the hash and count of every entry of the corpus at
+// path: a range dataset directory, or a single file of full "HASH:COUNT"
+// lines as the downloader writes without its range option
+func ReadCorpus(path string, fn func(hash [sha1.Size]byte, count int) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return readCorpusFile(path, "", fn)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, entry := range entries {
+		prefix, ok := strings.CutSuffix(entry.Name(), ".txt")
+		if entry.IsDir() || !ok || len(prefix) != prefixLen {
+			continue
+		}
+		if !isHex(prefix) {
+			continue
+		}
+		found = true
+		if err := readCorpusFile(filepath.Join(path, entry.Name()), strings.ToUpper(prefix), fn); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return errors.New("no range files found in " + path)
+	}
+	return nil
+}
+
+// readCorpusFile reads the entries of one file, whose hashes all start with
+// prefix
+func readCorpusFile(path, prefix string, fn func(hash [sha1.Size]byte, count int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 1<<16)
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.TrimSpace(line) != "" {
+			suffix, count, parseErr := parseLine(line, hashLen-len(prefix))
+			if parseErr != nil {
+				return fmt.Errorf("%s: %w", path, parseErr)
+			}
+			var hash [sha1.Size]byte
+			hex.Decode(hash[:], []byte(prefix+suffix))
+			if err := fn(hash, count); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package pwned
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeRangeDir writes a range dataset holding the passwords with their
+// counts, with the CRLF line endings of the downloader
+func writeRangeDir(t *testing.T, counts map[string]int) string {
+	t.Helper()
+	dir := t.TempDir()
+	for password, count := range counts {
+		hash := hashPassword(password)
+		path := filepath.Join(dir, hash[:prefixLen]+".txt")
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatalf("Failed to write range file: %v", err)
+		}
+		file.WriteString(hash[prefixLen:] + ":" + strconv.Itoa(count) + "\r\n")
+		file.Close()
+	}
+	return dir
+}
+
+func TestRangeDir(t *testing.T) {
+	dir := writeRangeDir(t, map[string]int{"password": 52256179, "hunter2": 1, "padding": 0})
+
+	source, err := Open(dir, 2)
+	if err != nil {
+		t.Fatalf("Failed to open range dataset: %v", err)
+	}
+	if breached, err := source.Breached("password"); err != nil || !breached {
+		t.Errorf("Expected password to be breached, got %v, %v", breached, err)
+	}
+
+	// Seen less often than the minimum
+	if breached, err := source.Breached("hunter2"); err != nil || breached {
+		t.Errorf("Expected hunter2 to be below the minimum count, got %v, %v", breached, err)
+	}
+
+	// A missing range file means the download is incomplete
+	if _, err := source.Breached("Tulip-Gravel-42"); err == nil {
+		t.Error("Expected a missing range file to be an error")
+	}
+}
+
+func TestFilter_BuildAndOpen(t *testing.T) {
+	dir := writeRangeDir(t, map[string]int{"password": 52256179, "123456": 37359195, "hunter2": 1})
+
+	var hashes [][sha1.Size]byte
+	err := ReadCorpus(dir, func(hash [sha1.Size]byte, count int) error {
+		if count >= 2 {
+			hashes = append(hashes, hash)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read corpus: %v", err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("Expected 2 hashes seen twice, got %d", len(hashes))
+	}
+
+	filter, err := NewFilter(uint64(len(hashes)), 0.001, 2)
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+	for _, hash := range hashes {
+		filter.Add(hash)
+	}
+
+	path := filepath.Join(t.TempDir(), "pwned.bloom")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create filter file: %v", err)
+	}
+	if _, err := filter.WriteTo(file); err != nil {
+		t.Fatalf("Failed to write filter: %v", err)
+	}
+	file.Close()
+
+	// The filter only answers for the count it was built with
+	if _, err := Open(path, 1); err == nil {
+		t.Error("Expected a filter built for another count to be rejected")
+	}
+
+	source, err := Open(path, 2)
+	if err != nil {
+		t.Fatalf("Failed to open filter: %v", err)
+	}
+	for password, want := range map[string]bool{"password": true, "123456": true, "hunter2": false, "Tulip-Gravel-42": false} {
+		if breached, err := source.Breached(password); err != nil || breached != want {
+			t.Errorf("Expected %s breached to be %v, got %v, %v", password, want, breached, err)
+		}
+	}
+
+	// Truncated files are not loaded
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, data[:len(data)-1], 0o644)
+	if _, err := LoadFilter(path); err == nil {
+		t.Error("Expected a truncated filter to be rejected")
+	}
+}
+
+func TestFilter_FalsePositiveRate(t *testing.T) {
+	const n = 20000
+	filter, err := NewFilter(n, 0.01, 1)
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+
+	hashOf := func(i int) [sha1.Size]byte {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], uint64(i))
+		return sha1.Sum(buf[:])
+	}
+	for i := 0; i < n; i++ {
+		filter.Add(hashOf(i))
+	}
+
+	falsePositives := 0
+	for i := n; i < 2*n; i++ {
+		if filter.Contains(hashOf(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 0.02 {
+		t.Errorf("Expected a false positive rate near 1%%, got %.2f%%", rate*100)
+	}
+	for i := 0; i < n; i++ {
+		if !filter.Contains(hashOf(i)) {
+			t.Fatalf("Expected added hash %d to be found", i)
+		}
+	}
+}
+
+func TestReadCorpus_SingleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwnedpasswords.txt")
+	os.WriteFile(path, []byte(hashPassword("password")+":3\n"+hashPassword("hunter2")+":1"), 0o644)
+
+	counts := map[[sha1.Size]byte]int{}
+	if err := ReadCorpus(path, func(hash [sha1.Size]byte, count int) error {
+		counts[hash] = count
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to read corpus: %v", err)
+	}
+	if counts[sha1.Sum([]byte("password"))] != 3 || counts[sha1.Sum([]byte("hunter2"))] != 1 {
+		t.Errorf("Expected both entries with their counts, got %v", counts)
+	}
+
+	os.WriteFile(path, []byte("not a hash:1\n"), 0o644)
+	if err := ReadCorpus(path, func([sha1.Size]byte, int) error { return nil }); err == nil {
+		t.Error("Expected an invalid line to be rejected")
+	}
+}
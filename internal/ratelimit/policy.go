@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyBy selects what requests are counted by
+type KeyBy string
+
+const (
+	// KeyByIP counts requests per client address
+	KeyByIP KeyBy = "ip"
+	// KeyByUsername counts requests per username given in the request body
+	KeyByUsername KeyBy = "username"
+	// KeyByClient counts requests per client ID the caller identifies with
+	KeyByClient KeyBy = "client"
+)
+
+// Rule limits requests with the same key
+type Rule struct {
+	KeyBy KeyBy
+	Limit Limit
+}
+
+// Policy is the rules of a route. A request has to be allowed by each rule
+// that has a key for it.
+type Policy []Rule
+
+func (p Policy) String() string {
+	rules := make([]string, len(p))
+	for i, rule := range p {
+		rules[i] = string(rule.KeyBy) + ":" + rule.Limit.String()
+	}
+	return strings.Join(rules, ",")
+}
+
+// ParsePolicy parses a comma separated list of rules of the form
+// key:requests/window[:algorithm], such as
+// "ip:20/1m,username:5/10m:sliding-window". The algorithm defaults to
+// token-bucket. An empty string or "off" is an empty policy.
+func ParsePolicy(s string) (Policy, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" {
+		return nil, nil
+	}
+
+	var policy Policy
+	for _, item := range strings.Split(s, ",") {
+		rule, err := parseRule(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit rule %q: %w", item, err)
+		}
+		policy = append(policy, rule)
+	}
+	return policy, nil
+}
+
+func parseRule(s string) (Rule, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Rule{}, fmt.Errorf("expected key:requests/window[:algorithm]")
+	}
+
+	rule := Rule{KeyBy: KeyBy(parts[0])}
+	switch rule.KeyBy {
+	case KeyByIP, KeyByUsername, KeyByClient:
+	default:
+		return Rule{}, fmt.Errorf("unknown key %q", parts[0])
+	}
+
+	requests, window, ok := strings.Cut(parts[1], "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("expected requests/window")
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Rule{}, fmt.Errorf("invalid request count %q", requests)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Rule{}, fmt.Errorf("invalid window %q", window)
+	}
+	rule.Limit = Limit{Algorithm: TokenBucket, Requests: n, Window: d}
+
+	if len(parts) == 3 {
+		rule.Limit.Algorithm = Algorithm(parts[2])
+		switch rule.Limit.Algorithm {
+		case TokenBucket, SlidingWindow:
+		default:
+			return Rule{}, fmt.Errorf("unknown algorithm %q", parts[2])
+		}
+	}
+	return rule, nil
+}
@@ -0,0 +1,156 @@
+// Package ratelimit limits how often a key, such as a client address, may
+// make requests. Limits use either a token bucket, which allows short bursts
+// and then a steady rate, or a sliding window, which allows a number of
+// requests in any window of time. Their state lives in a Store, in memory for
+// a single node or shared between the nodes of a cluster.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Algorithm selects how requests are counted against a limit
+type Algorithm string
+
+const (
+	// TokenBucket holds up to Requests tokens, refilled at Requests per
+	// Window. Each request takes one.
+	TokenBucket Algorithm = "token-bucket"
+	// SlidingWindow allows Requests in any Window, estimated from the counts
+	// of the current and the previous fixed window
+	SlidingWindow Algorithm = "sliding-window"
+)
+
+// Limit allows Requests per Window
+type Limit struct {
+	Algorithm Algorithm
+	Requests  int
+	Window    time.Duration
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s:%s", l.Requests, l.Window, l.Algorithm)
+}
+
+// Result is the outcome of a request against a limit
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is how many more requests would be allowed right now
+	Remaining int
+	// Reset is how long until the full limit is available again
+	Reset time.Duration
+	// RetryAfter is how long until a denied request would be allowed
+	RetryAfter time.Duration
+}
+
+// Allow counts a request for key against the limit at the given time
+func Allow(ctx context.Context, store Store, key string, limit Limit, now time.Time) (Result, error) {
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return Result{}, fmt.Errorf("invalid limit %s", limit)
+	}
+
+	var result Result
+	var update func(*State)
+	ttl := limit.Window
+	switch limit.Algorithm {
+	case TokenBucket:
+		update = func(state *State) { result = takeToken(state, limit, now) }
+	case SlidingWindow:
+		update = func(state *State) { result = countInWindow(state, limit, now) }
+		ttl = 2 * limit.Window
+	default:
+		return Result{}, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
+	}
+
+	if err := store.Update(ctx, key, ttl, update); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// takeToken refills the bucket for the time passed since the last request
+// and takes a token if there is one. A new bucket starts full.
+func takeToken(state *State, limit Limit, now time.Time) Result {
+	capacity := float64(limit.Requests)
+	perToken := limit.Window / time.Duration(limit.Requests)
+
+	tokens := capacity
+	if !state.Time.IsZero() {
+		elapsed := now.Sub(state.Time)
+		tokens = min(capacity, state.Value+float64(elapsed)/float64(perToken))
+	}
+
+	result := Result{Limit: limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((capacity - tokens) * float64(perToken))
+
+	state.Value = tokens
+	state.Time = now
+	return result
+}
+
+// countInWindow counts the request in the current fixed window if the
+// estimated count over the last Window stays within the limit. The estimate
+// weighs the previous window's count by how much of it still overlaps.
+func countInWindow(state *State, limit Limit, now time.Time) Result {
+	start := now.Truncate(limit.Window)
+	if !state.Time.Equal(start) {
+		if state.Time.Equal(start.Add(-limit.Window)) {
+			state.Previous = state.Value
+		} else {
+			state.Previous = 0
+		}
+		state.Value = 0
+		state.Time = start
+	}
+
+	requests := float64(limit.Requests)
+	elapsed := now.Sub(start)
+	overlap := 1 - float64(elapsed)/float64(limit.Window)
+	estimate := state.Previous*overlap + state.Value
+
+	result := Result{Limit: limit}
+	if estimate+1 <= requests {
+		state.Value++
+		estimate++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = windowRetryAfter(state, limit, elapsed)
+	}
+	result.Remaining = max(0, int(math.Floor(requests-estimate)))
+
+	// The count drops to nothing once both windows have passed
+	result.Reset = 2*limit.Window - elapsed
+	if state.Value == 0 {
+		result.Reset = limit.Window - elapsed
+	}
+	return result
+}
+
+// windowRetryAfter returns how long until the estimate leaves room for
+// another request
+func windowRetryAfter(state *State, limit Limit, elapsed time.Duration) time.Duration {
+	room := float64(limit.Requests) - 1
+	window := float64(limit.Window)
+
+	// Within the current window the previous one's share shrinks
+	if state.Value <= room && state.Previous > 0 {
+		wait := window*(1-(room-state.Value)/state.Previous) - float64(elapsed)
+		return time.Duration(math.Ceil(max(wait, 0)))
+	}
+
+	// Otherwise the current window has to become the previous one and
+	// shrink in turn
+	wait := (window - float64(elapsed)) + window*(1-room/state.Value)
+	return time.Duration(math.Ceil(wait))
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAllow_TokenBucket(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Algorithm: TokenBucket, Requests: 3, Window: 3 * time.Second}
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	// A new bucket allows a burst of the full limit
+	for i := 2; i >= 0; i-- {
+		result, err := Allow(context.Background(), store, "key", limit, now)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("Expected request to be allowed with %d remaining, got %+v", i, result)
+		}
+	}
+
+	result, _ := Allow(context.Background(), store, "key", limit, now)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("Expected denial for 1s with a reset of 3s, got %+v", result)
+	}
+
+	// Tokens come back at the steady rate
+	result, _ = Allow(context.Background(), store, "key", limit, now.Add(time.Second))
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Expected a refilled token to be allowed, got %+v", result)
+	}
+
+	// Other keys have their own bucket
+	result, _ = Allow(context.Background(), store, "other", limit, now)
+	if !result.Allowed || result.Remaining != 2 {
+		t.Errorf("Expected another key to be allowed, got %+v", result)
+	}
+}
+
+func TestAllow_SlidingWindow(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Algorithm: SlidingWindow, Requests: 4, Window: time.Minute}
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		result, err := Allow(context.Background(), store, "key", limit, start.Add(30*time.Second))
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("Expected request %d to be allowed, got %+v", i+1, result)
+		}
+	}
+
+	// The window is full until the next one starts and the previous count
+	// has shrunk enough
+	result, _ := Allow(context.Background(), store, "key", limit, start.Add(45*time.Second))
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != 30*time.Second {
+		t.Errorf("Expected denial for 30s, got %+v", result)
+	}
+
+	// A quarter into the next window three quarters of the previous count
+	// still weigh: 3 of 4
+	result, _ = Allow(context.Background(), store, "key", limit, start.Add(75*time.Second))
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Expected one more request to be allowed, got %+v", result)
+	}
+	result, _ = Allow(context.Background(), store, "key", limit, start.Add(75*time.Second))
+	if result.Allowed {
+		t.Errorf("Expected the estimate to be at the limit, got %+v", result)
+	}
+
+	// Counts older than the previous window are forgotten
+	result, _ = Allow(context.Background(), store, "key", limit, start.Add(3*time.Minute))
+	if !result.Allowed || result.Remaining != 3 {
+		t.Errorf("Expected a fresh window, got %+v", result)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("ip:20/1m, username:5/10m:sliding-window")
+	if err != nil {
+		t.Fatalf("ParsePolicy failed: %v", err)
+	}
+	want := Policy{
+		{KeyBy: KeyByIP, Limit: Limit{Algorithm: TokenBucket, Requests: 20, Window: time.Minute}},
+		{KeyBy: KeyByUsername, Limit: Limit{Algorithm: SlidingWindow, Requests: 5, Window: 10 * time.Minute}},
+	}
+	if len(policy) != len(want) || policy[0] != want[0] || policy[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, policy)
+	}
+
+	if policy, err := ParsePolicy("off"); err != nil || policy != nil {
+		t.Errorf("Expected an empty policy, got %v, %v", policy, err)
+	}
+
+	for _, invalid := range []string{"ip", "host:1/1m", "ip:0/1m", "ip:1/0s", "ip:1/1m:leaky-bucket", "ip:1m/1"} {
+		if _, err := ParsePolicy(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	set := func(state *State) { state.Value = 1 }
+	if err := store.Update(context.Background(), "key", time.Minute, set); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	var seen State
+	store.Update(context.Background(), "key", time.Minute, func(state *State) { seen = *state })
+	if seen.Value != 0 {
+		t.Errorf("Expected expired state to be dropped, got %+v", seen)
+	}
+}
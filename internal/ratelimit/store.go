@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// State is what a limit keeps per key
+type State struct {
+	// Value is the tokens left in a bucket, or the requests counted in the
+	// current window
+	Value float64
+	// Previous is the requests counted in the previous window
+	Previous float64
+	// Time is when the bucket was last refilled, or when the current window
+	// started. It is zero for a new key.
+	Time time.Time
+}
+
+// Store holds the state of each key. Update must apply fn atomically: while
+// it runs no other update of the key, from this node or another one sharing
+// the store, may take place. State not updated for ttl may be dropped.
+type Store interface {
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State)) error
+}
+
+// sweepInterval is how often expired keys are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore keeps state in memory. Each node counts on its own, so behind
+// a load balancer clients get up to the limit from every node.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	state     State
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	fn(&entry.state)
+	entry.expiresAt = now.Add(ttl)
+	return nil
+}
@@ -0,0 +1,289 @@
+package rebac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultMaxDepth bounds how many relations a check may follow
+const DefaultMaxDepth = 25
+
+// ErrMaxDepth is returned when evaluating a relation follows more than the
+// maximum number of relations, usually because of a very deep group nesting
+var ErrMaxDepth = errors.New("relation evaluation exceeded the maximum depth")
+
+// Store persists relation tuples by revision. Every write creates a new
+// revision, and reads at a revision see exactly the tuples written at or
+// before it and not deleted by then.
+type Store interface {
+	// Revision returns the latest revision
+	Revision() (int64, error)
+	// Write applies touches and deletes atomically as a new revision and
+	// returns it. Touching a tuple that exists or deleting one that does not
+	// is not an error.
+	Write(touches, deletes []Tuple) (int64, error)
+	// Read returns the tuples of the object visible at revision, limited to
+	// relation unless it is empty
+	Read(object Object, relation string, revision int64) ([]Tuple, error)
+	// ObjectIDs returns the IDs of the namespace's objects that have tuples
+	// visible at revision
+	ObjectIDs(namespace string, revision int64) ([]string, error)
+}
+
+// Consistency chooses the revision a read is evaluated at. Without a zookie
+// reads use the latest revision. With one they are at least as fresh as the
+// zookie, or exactly at its revision if Exact is set.
+type Consistency struct {
+	Zookie Zookie
+	Exact  bool
+}
+
+// Engine evaluates relations over the tuple store according to a schema
+type Engine struct {
+	schema   *Schema
+	store    Store
+	maxDepth int
+}
+
+func NewEngine(schema *Schema, store Store) *Engine {
+	return &Engine{
+		schema:   schema,
+		store:    store,
+		maxDepth: DefaultMaxDepth,
+	}
+}
+
+// Schema returns the schema the engine evaluates
+func (e *Engine) Schema() *Schema {
+	return e.schema
+}
+
+// Write validates and stores the tuple changes, returning a zookie for the
+// revision that contains them
+func (e *Engine) Write(touches, deletes []Tuple) (Zookie, error) {
+	for _, tuples := range [][]Tuple{touches, deletes} {
+		for _, tuple := range tuples {
+			if err := e.schema.ValidateTuple(tuple); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	revision, err := e.store.Write(touches, deletes)
+	if err != nil {
+		return "", err
+	}
+	return NewZookie(revision), nil
+}
+
+// Read returns the stored tuples of the object, limited to relation unless
+// it is empty
+func (e *Engine) Read(object Object, relation string, consistency Consistency) ([]Tuple, Zookie, error) {
+	revision, err := e.revision(consistency)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tuples, err := e.store.Read(object, relation, revision)
+	if err != nil {
+		return nil, "", err
+	}
+	return tuples, NewZookie(revision), nil
+}
+
+// Check reports whether subject has relation to object
+func (e *Engine) Check(object Object, relation string, subject Subject, consistency Consistency) (bool, Zookie, error) {
+	if err := e.validateRelation(object.Namespace, relation); err != nil {
+		return false, "", err
+	}
+
+	revision, err := e.revision(consistency)
+	if err != nil {
+		return false, "", err
+	}
+
+	allowed, err := e.newChecker(subject, revision).check(object, relation, 0)
+	if err != nil {
+		return false, "", err
+	}
+	return allowed, NewZookie(revision), nil
+}
+
+// ListObjects returns the IDs of the namespace's objects subject has
+// relation to. Every object of the namespace is checked, which is fine for
+// the document counts this service is used with but does not scale to
+// millions of objects.
+func (e *Engine) ListObjects(namespace, relation string, subject Subject, consistency Consistency) ([]string, Zookie, error) {
+	if err := e.validateRelation(namespace, relation); err != nil {
+		return nil, "", err
+	}
+
+	revision, err := e.revision(consistency)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids, err := e.store.ObjectIDs(namespace, revision)
+	if err != nil {
+		return nil, "", err
+	}
+
+	objects := []string{}
+	for _, id := range ids {
+		allowed, err := e.newChecker(subject, revision).check(Object{Namespace: namespace, ID: id}, relation, 0)
+		if err != nil {
+			return nil, "", err
+		}
+		if allowed {
+			objects = append(objects, id)
+		}
+	}
+	sort.Strings(objects)
+	return objects, NewZookie(revision), nil
+}
+
+// revision picks the revision to evaluate at. This service has a single
+// store, so the latest revision is always at least as fresh as any zookie it
+// issued; a zookie beyond it was not issued here.
+func (e *Engine) revision(consistency Consistency) (int64, error) {
+	latest, err := e.store.Revision()
+	if err != nil {
+		return 0, err
+	}
+	if consistency.Zookie == "" {
+		return latest, nil
+	}
+
+	revision, err := consistency.Zookie.Revision()
+	if err != nil {
+		return 0, err
+	}
+	if revision > latest {
+		return 0, ErrInvalidZookie
+	}
+	if consistency.Exact {
+		return revision, nil
+	}
+	return latest, nil
+}
+
+func (e *Engine) validateRelation(namespace, relation string) error {
+	if _, ok := e.schema.Relation(namespace, relation); !ok {
+		return fmt.Errorf("unknown relation %s#%s", namespace, relation)
+	}
+	return nil
+}
+
+// checker evaluates one check. visiting holds the relations on the current
+// path so that cyclic tuples, such as two groups containing each other, end
+// the path instead of looping.
+type checker struct {
+	engine   *Engine
+	subject  Subject
+	revision int64
+	visiting map[string]bool
+}
+
+func (e *Engine) newChecker(subject Subject, revision int64) *checker {
+	return &checker{
+		engine:   e,
+		subject:  subject,
+		revision: revision,
+		visiting: make(map[string]bool),
+	}
+}
+
+func (c *checker) check(object Object, relation string, depth int) (bool, error) {
+	if c.subject.Object == object && c.subject.Relation == relation {
+		return true, nil
+	}
+	if depth > c.engine.maxDepth {
+		return false, ErrMaxDepth
+	}
+
+	// Tuples may outlive a schema change that removed what they refer to
+	rel, ok := c.engine.schema.Relation(object.Namespace, relation)
+	if !ok {
+		return false, nil
+	}
+
+	key := object.String() + "#" + relation
+	if c.visiting[key] {
+		return false, nil
+	}
+	c.visiting[key] = true
+	defer delete(c.visiting, key)
+
+	return c.eval(object, relation, rel.rewrite(), depth)
+}
+
+func (c *checker) eval(object Object, relation string, rw *Rewrite, depth int) (bool, error) {
+	switch {
+	case rw.This != nil:
+		tuples, err := c.engine.store.Read(object, relation, c.revision)
+		if err != nil {
+			return false, err
+		}
+		for _, tuple := range tuples {
+			if !tuple.Subject.IsUserset() {
+				if tuple.Subject == c.subject {
+					return true, nil
+				}
+				continue
+			}
+			allowed, err := c.check(tuple.Subject.Object, tuple.Subject.Relation, depth+1)
+			if err != nil || allowed {
+				return allowed, err
+			}
+		}
+		return false, nil
+
+	case rw.ComputedUserset != nil:
+		return c.check(object, rw.ComputedUserset.Relation, depth+1)
+
+	case rw.TupleToUserset != nil:
+		tuples, err := c.engine.store.Read(object, rw.TupleToUserset.Tupleset.Relation, c.revision)
+		if err != nil {
+			return false, err
+		}
+		for _, tuple := range tuples {
+			allowed, err := c.check(tuple.Subject.Object, rw.TupleToUserset.ComputedUserset.Relation, depth+1)
+			if err != nil || allowed {
+				return allowed, err
+			}
+		}
+		return false, nil
+
+	case rw.Union != nil:
+		for _, child := range rw.Union {
+			allowed, err := c.eval(object, relation, child, depth)
+			if err != nil || allowed {
+				return allowed, err
+			}
+		}
+		return false, nil
+
+	case rw.Intersection != nil:
+		for _, child := range rw.Intersection {
+			allowed, err := c.eval(object, relation, child, depth)
+			if err != nil || !allowed {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case rw.Exclusion != nil:
+		allowed, err := c.eval(object, relation, rw.Exclusion.Base, depth)
+		if err != nil || !allowed {
+			return false, err
+		}
+		excluded, err := c.eval(object, relation, rw.Exclusion.Subtract, depth)
+		if err != nil {
+			return false, err
+		}
+		return !excluded, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,312 @@
+package rebac
+
+import (
+	"errors"
+	"testing"
+)
+
+// memoryStore keeps every version of every tuple, like the database store
+type memoryStore struct {
+	revision int64
+	rows     []memoryRow
+}
+
+type memoryRow struct {
+	tuple   Tuple
+	created int64
+	deleted int64
+}
+
+func (r memoryRow) visible(revision int64) bool {
+	return r.created <= revision && (r.deleted == 0 || r.deleted > revision)
+}
+
+func (s *memoryStore) Revision() (int64, error) {
+	return s.revision, nil
+}
+
+func (s *memoryStore) Write(touches, deletes []Tuple) (int64, error) {
+	s.revision++
+	for _, tuple := range deletes {
+		for i := range s.rows {
+			if s.rows[i].tuple == tuple && s.rows[i].deleted == 0 {
+				s.rows[i].deleted = s.revision
+			}
+		}
+	}
+	for _, tuple := range touches {
+		exists := false
+		for _, row := range s.rows {
+			if row.tuple == tuple && row.deleted == 0 {
+				exists = true
+			}
+		}
+		if !exists {
+			s.rows = append(s.rows, memoryRow{tuple: tuple, created: s.revision})
+		}
+	}
+	return s.revision, nil
+}
+
+func (s *memoryStore) Read(object Object, relation string, revision int64) ([]Tuple, error) {
+	var tuples []Tuple
+	for _, row := range s.rows {
+		if row.visible(revision) && row.tuple.Object == object && (relation == "" || row.tuple.Relation == relation) {
+			tuples = append(tuples, row.tuple)
+		}
+	}
+	return tuples, nil
+}
+
+func (s *memoryStore) ObjectIDs(namespace string, revision int64) ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, row := range s.rows {
+		if row.visible(revision) && row.tuple.Object.Namespace == namespace && !seen[row.tuple.Object.ID] {
+			seen[row.tuple.Object.ID] = true
+			ids = append(ids, row.tuple.Object.ID)
+		}
+	}
+	return ids, nil
+}
+
+func mustTuples(t *testing.T, specs ...string) []Tuple {
+	t.Helper()
+	tuples := make([]Tuple, 0, len(specs))
+	for _, spec := range specs {
+		tuple, err := ParseTuple(spec)
+		if err != nil {
+			t.Fatalf("Failed to parse tuple: %v", err)
+		}
+		tuples = append(tuples, tuple)
+	}
+	return tuples
+}
+
+func mustSubject(t *testing.T, s string) Subject {
+	t.Helper()
+	subject, err := ParseSubject(s)
+	if err != nil {
+		t.Fatalf("Failed to parse subject: %v", err)
+	}
+	return subject
+}
+
+func TestEngine_CheckThroughGroupFolderAndDoc(t *testing.T) {
+	// Setup
+	engine := NewEngine(DefaultSchema(), &memoryStore{})
+	_, err := engine.Write(mustTuples(t,
+		"group:eng#member@user:alice",
+		"folder:specs#editor@group:eng#member",
+		"doc:design#parent@folder:specs",
+		"doc:design#owner@user:carol",
+	), nil)
+	if err != nil {
+		t.Fatalf("Failed to write tuples: %v", err)
+	}
+
+	doc := Object{Namespace: "doc", ID: "design"}
+	tests := []struct {
+		relation string
+		subject  string
+		expected bool
+	}{
+		{"viewer", "user:alice", true},
+		{"editor", "user:alice", true},
+		{"owner", "user:alice", false},
+		{"viewer", "user:bob", false},
+		{"viewer", "user:carol", true},
+		{"viewer", "group:eng#member", true},
+	}
+
+	for _, tt := range tests {
+		// Execute
+		allowed, zookie, err := engine.Check(doc, tt.relation, mustSubject(t, tt.subject), Consistency{})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if allowed != tt.expected {
+			t.Errorf("Check(doc:design#%s@%s) = %v, expected %v", tt.relation, tt.subject, allowed, tt.expected)
+		}
+		if zookie == "" {
+			t.Error("Expected a zookie")
+		}
+	}
+}
+
+func TestEngine_ExactSnapshot(t *testing.T) {
+	// Setup
+	engine := NewEngine(DefaultSchema(), &memoryStore{})
+	doc := Object{Namespace: "doc", ID: "design"}
+	alice := mustSubject(t, "user:alice")
+
+	granted, err := engine.Write(mustTuples(t, "doc:design#viewer@user:alice"), nil)
+	if err != nil {
+		t.Fatalf("Failed to write tuples: %v", err)
+	}
+	if _, err := engine.Write(nil, mustTuples(t, "doc:design#viewer@user:alice")); err != nil {
+		t.Fatalf("Failed to delete tuples: %v", err)
+	}
+
+	// Execute
+	atGrant, _, err := engine.Check(doc, "viewer", alice, Consistency{Zookie: granted, Exact: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	fresh, _, err := engine.Check(doc, "viewer", alice, Consistency{Zookie: granted})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assert
+	if !atGrant {
+		t.Error("Expected the snapshot at the grant to allow access")
+	}
+	if fresh {
+		t.Error("Expected a read at least as fresh as the grant to see the delete")
+	}
+
+	_, _, err = engine.Check(doc, "viewer", alice, Consistency{Zookie: NewZookie(100)})
+	if !errors.Is(err, ErrInvalidZookie) {
+		t.Errorf("Expected invalid zookie error for a future revision, got %v", err)
+	}
+}
+
+func TestEngine_IntersectionAndExclusion(t *testing.T) {
+	// Setup
+	schema, err := ParseSchema([]byte(`{"namespaces": [
+		{"name": "user"},
+		{"name": "report", "relations": [
+			{"name": "employee"},
+			{"name": "cleared"},
+			{"name": "blocked"},
+			{"name": "reader", "rewrite": {"exclusion": {
+				"base": {"intersection": [
+					{"computed_userset": {"relation": "employee"}},
+					{"computed_userset": {"relation": "cleared"}}
+				]},
+				"subtract": {"computed_userset": {"relation": "blocked"}}
+			}}}
+		]}
+	]}`))
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	engine := NewEngine(schema, &memoryStore{})
+	_, err = engine.Write(mustTuples(t,
+		"report:q3#employee@user:alice",
+		"report:q3#cleared@user:alice",
+		"report:q3#employee@user:bob",
+		"report:q3#employee@user:carol",
+		"report:q3#cleared@user:carol",
+		"report:q3#blocked@user:carol",
+	), nil)
+	if err != nil {
+		t.Fatalf("Failed to write tuples: %v", err)
+	}
+
+	// Execute
+	readers, _, err := engine.ListObjects("report", "reader", mustSubject(t, "user:alice"), Consistency{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assert
+	if len(readers) != 1 || readers[0] != "q3" {
+		t.Errorf("Expected alice to read q3, got %v", readers)
+	}
+	for _, user := range []string{"user:bob", "user:carol"} {
+		allowed, _, err := engine.Check(Object{Namespace: "report", ID: "q3"}, "reader", mustSubject(t, user), Consistency{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if allowed {
+			t.Errorf("Expected %s not to read q3", user)
+		}
+	}
+}
+
+func TestEngine_CyclicGroups(t *testing.T) {
+	// Setup
+	engine := NewEngine(DefaultSchema(), &memoryStore{})
+	_, err := engine.Write(mustTuples(t,
+		"group:a#member@group:b#member",
+		"group:b#member@group:a#member",
+		"group:b#member@user:alice",
+	), nil)
+	if err != nil {
+		t.Fatalf("Failed to write tuples: %v", err)
+	}
+
+	// Execute
+	alice, _, err := engine.Check(Object{Namespace: "group", ID: "a"}, "member", mustSubject(t, "user:alice"), Consistency{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bob, _, err := engine.Check(Object{Namespace: "group", ID: "a"}, "member", mustSubject(t, "user:bob"), Consistency{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assert
+	if !alice {
+		t.Error("Expected alice to be a member of a through b")
+	}
+	if bob {
+		t.Error("Expected bob not to be a member")
+	}
+}
+
+func TestEngine_Expand(t *testing.T) {
+	// Setup
+	engine := NewEngine(DefaultSchema(), &memoryStore{})
+	_, err := engine.Write(mustTuples(t,
+		"folder:specs#editor@group:eng#member",
+		"doc:design#parent@folder:specs",
+		"doc:design#viewer@user:bob",
+	), nil)
+	if err != nil {
+		t.Fatalf("Failed to write tuples: %v", err)
+	}
+
+	// Execute
+	tree, _, err := engine.Expand(Object{Namespace: "doc", ID: "design"}, "viewer", Consistency{})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tree.Operation != OpUnion || len(tree.Children) != 3 {
+		t.Fatalf("Expected a union of three rewrites, got %+v", tree)
+	}
+
+	direct := tree.Children[0]
+	if direct.Operation != OpLeaf || len(direct.Subjects) != 1 || direct.Subjects[0].String() != "user:bob" {
+		t.Errorf("Expected bob as the direct viewer, got %+v", direct)
+	}
+
+	parent := tree.Children[2]
+	if parent.Operation != OpTupleToUserset || len(parent.Children) != 1 {
+		t.Fatalf("Expected the parent folder expansion, got %+v", parent)
+	}
+	if folder := parent.Children[0]; folder.Object.String() != "folder:specs" || folder.Relation != "viewer" {
+		t.Errorf("Expected folder:specs#viewer, got %s#%s", folder.Object, folder.Relation)
+	}
+}
+
+func TestEngine_WriteValidatesSchema(t *testing.T) {
+	engine := NewEngine(DefaultSchema(), &memoryStore{})
+
+	for _, spec := range []string{
+		"doc:design#approver@user:alice",
+		"doc:design#viewer@team:eng",
+		"doc:design#viewer@group:eng#owner",
+	} {
+		if _, err := engine.Write(mustTuples(t, spec), nil); err == nil {
+			t.Errorf("Expected writing %s to fail", spec)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package rebac
+
+// Operations of userset tree nodes
+const (
+	// OpLeaf nodes list the subjects of a relation's own tuples
+	OpLeaf = "leaf"
+	// OpTupleToUserset nodes hold the expansions of the objects a relation's
+	// tuples point at
+	OpTupleToUserset = "tuple_to_userset"
+	OpUnion          = "union"
+	OpIntersection   = "intersection"
+	OpExclusion      = "exclusion"
+)
+
+// UsersetTree is the expansion of a relation. Leaves list subjects, which may
+// themselves be usersets the caller can expand in turn. Exclusion nodes have
+// the base as their first child and the subtracted set as their second.
+type UsersetTree struct {
+	Operation string
+	Object    Object
+	Relation  string
+	Subjects  []Subject
+	Children  []*UsersetTree
+}
+
+// Expand returns the userset tree of object's relation, showing how every
+// subject comes to have it
+func (e *Engine) Expand(object Object, relation string, consistency Consistency) (*UsersetTree, Zookie, error) {
+	if err := e.validateRelation(object.Namespace, relation); err != nil {
+		return nil, "", err
+	}
+
+	revision, err := e.revision(consistency)
+	if err != nil {
+		return nil, "", err
+	}
+
+	x := &expander{
+		engine:   e,
+		revision: revision,
+		visiting: make(map[string]bool),
+	}
+	tree, err := x.expand(object, relation, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	return tree, NewZookie(revision), nil
+}
+
+type expander struct {
+	engine   *Engine
+	revision int64
+	visiting map[string]bool
+}
+
+func (x *expander) expand(object Object, relation string, depth int) (*UsersetTree, error) {
+	if depth > x.engine.maxDepth {
+		return nil, ErrMaxDepth
+	}
+
+	empty := &UsersetTree{Operation: OpLeaf, Object: object, Relation: relation}
+
+	rel, ok := x.engine.schema.Relation(object.Namespace, relation)
+	if !ok {
+		return empty, nil
+	}
+
+	key := object.String() + "#" + relation
+	if x.visiting[key] {
+		return empty, nil
+	}
+	x.visiting[key] = true
+	defer delete(x.visiting, key)
+
+	return x.rewrite(object, relation, rel.rewrite(), depth)
+}
+
+func (x *expander) rewrite(object Object, relation string, rw *Rewrite, depth int) (*UsersetTree, error) {
+	node := &UsersetTree{Object: object, Relation: relation}
+
+	switch {
+	case rw.This != nil:
+		tuples, err := x.engine.store.Read(object, relation, x.revision)
+		if err != nil {
+			return nil, err
+		}
+		node.Operation = OpLeaf
+		for _, tuple := range tuples {
+			node.Subjects = append(node.Subjects, tuple.Subject)
+		}
+		return node, nil
+
+	case rw.ComputedUserset != nil:
+		return x.expand(object, rw.ComputedUserset.Relation, depth+1)
+
+	case rw.TupleToUserset != nil:
+		tuples, err := x.engine.store.Read(object, rw.TupleToUserset.Tupleset.Relation, x.revision)
+		if err != nil {
+			return nil, err
+		}
+		node.Operation = OpTupleToUserset
+		node.Relation = rw.TupleToUserset.Tupleset.Relation
+		for _, tuple := range tuples {
+			child, err := x.expand(tuple.Subject.Object, rw.TupleToUserset.ComputedUserset.Relation, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+		return node, nil
+
+	case rw.Union != nil:
+		node.Operation = OpUnion
+		return x.children(node, object, relation, rw.Union, depth)
+
+	case rw.Intersection != nil:
+		node.Operation = OpIntersection
+		return x.children(node, object, relation, rw.Intersection, depth)
+
+	case rw.Exclusion != nil:
+		node.Operation = OpExclusion
+		return x.children(node, object, relation, []*Rewrite{rw.Exclusion.Base, rw.Exclusion.Subtract}, depth)
+	}
+
+	node.Operation = OpLeaf
+	return node, nil
+}
+
+func (x *expander) children(node *UsersetTree, object Object, relation string, rewrites []*Rewrite, depth int) (*UsersetTree, error) {
+	for _, rw := range rewrites {
+		child, err := x.rewrite(object, relation, rw, depth)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+	return node, nil
+}
@@ -0,0 +1,237 @@
+package rebac
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Schema defines the namespaces, their relations and the userset rewrites
+// deriving relations from each other. It is written as JSON:
+//
+//	{"namespaces": [
+//	  {"name": "user"},
+//	  {"name": "doc", "relations": [
+//	    {"name": "parent"},
+//	    {"name": "editor"},
+//	    {"name": "viewer", "rewrite": {"union": [
+//	      {"this": {}},
+//	      {"computed_userset": {"relation": "editor"}},
+//	      {"tuple_to_userset": {"tupleset": {"relation": "parent"}, "computed_userset": {"relation": "viewer"}}}
+//	    ]}}
+//	  ]}
+//	]}
+type Schema struct {
+	Namespaces []Namespace `json:"namespaces"`
+
+	relations map[string]map[string]*Relation
+}
+
+// Namespace is a type of object
+type Namespace struct {
+	Name      string      `json:"name"`
+	Relations []*Relation `json:"relations"`
+}
+
+// Relation is a relation objects of a namespace can have. Without a rewrite
+// a relation holds exactly the subjects of its tuples.
+type Relation struct {
+	Name    string   `json:"name"`
+	Rewrite *Rewrite `json:"rewrite,omitempty"`
+}
+
+// Rewrite is a userset rewrite rule. Exactly one field is set.
+type Rewrite struct {
+	// This is the subjects of the relation's own tuples
+	This *This `json:"this,omitempty"`
+	// ComputedUserset is another relation of the same object
+	ComputedUserset *ComputedUserset `json:"computed_userset,omitempty"`
+	// TupleToUserset follows the tuples of one relation to other objects and
+	// takes a relation of those objects
+	TupleToUserset *TupleToUserset `json:"tuple_to_userset,omitempty"`
+	Union          []*Rewrite      `json:"union,omitempty"`
+	Intersection   []*Rewrite      `json:"intersection,omitempty"`
+	Exclusion      *Exclusion      `json:"exclusion,omitempty"`
+}
+
+type This struct{}
+
+type ComputedUserset struct {
+	Relation string `json:"relation"`
+}
+
+type TupleToUserset struct {
+	Tupleset        ComputedUserset `json:"tupleset"`
+	ComputedUserset ComputedUserset `json:"computed_userset"`
+}
+
+// Exclusion is the subjects of Base that are not in Subtract
+type Exclusion struct {
+	Base     *Rewrite `json:"base"`
+	Subtract *Rewrite `json:"subtract"`
+}
+
+// this is the rewrite of relations that do not declare one
+var this = &Rewrite{This: &This{}}
+
+//go:embed default_schema.json
+var defaultSchema []byte
+
+// DefaultSchema returns the built-in schema for users, groups, folders and
+// documents
+func DefaultSchema() *Schema {
+	schema, err := ParseSchema(defaultSchema)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in relation schema: %v", err))
+	}
+	return schema
+}
+
+// LoadSchema reads a schema from a JSON file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading relation schema: %w", err)
+	}
+	return ParseSchema(data)
+}
+
+// ParseSchema parses and validates a JSON schema
+func ParseSchema(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("error parsing relation schema: %w", err)
+	}
+	if err := schema.validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Relation returns the named relation of namespace
+func (s *Schema) Relation(namespace, relation string) (*Relation, bool) {
+	r, ok := s.relations[namespace][relation]
+	return r, ok
+}
+
+// HasNamespace reports whether the schema defines namespace
+func (s *Schema) HasNamespace(namespace string) bool {
+	_, ok := s.relations[namespace]
+	return ok
+}
+
+// ValidateTuple checks that a tuple only uses defined namespaces and relations
+func (s *Schema) ValidateTuple(t Tuple) error {
+	if _, ok := s.Relation(t.Object.Namespace, t.Relation); !ok {
+		return fmt.Errorf("unknown relation %s#%s", t.Object.Namespace, t.Relation)
+	}
+	if !s.HasNamespace(t.Subject.Namespace) {
+		return fmt.Errorf("unknown namespace %s", t.Subject.Namespace)
+	}
+	if t.Subject.IsUserset() {
+		if _, ok := s.Relation(t.Subject.Namespace, t.Subject.Relation); !ok {
+			return fmt.Errorf("unknown relation %s#%s", t.Subject.Namespace, t.Subject.Relation)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validate() error {
+	s.relations = make(map[string]map[string]*Relation, len(s.Namespaces))
+	for _, namespace := range s.Namespaces {
+		if !validName(namespace.Name) {
+			return fmt.Errorf("invalid namespace name %q", namespace.Name)
+		}
+		if _, ok := s.relations[namespace.Name]; ok {
+			return fmt.Errorf("duplicate namespace %q", namespace.Name)
+		}
+
+		relations := make(map[string]*Relation, len(namespace.Relations))
+		for _, relation := range namespace.Relations {
+			if !validName(relation.Name) {
+				return fmt.Errorf("invalid relation name %s#%q", namespace.Name, relation.Name)
+			}
+			if _, ok := relations[relation.Name]; ok {
+				return fmt.Errorf("duplicate relation %s#%s", namespace.Name, relation.Name)
+			}
+			relations[relation.Name] = relation
+		}
+		s.relations[namespace.Name] = relations
+	}
+
+	for _, namespace := range s.Namespaces {
+		for _, relation := range namespace.Relations {
+			if relation.Rewrite == nil {
+				continue
+			}
+			if err := s.validateRewrite(namespace.Name, relation.Name, relation.Rewrite); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateRewrite(namespace, relation string, rw *Rewrite) error {
+	where := namespace + "#" + relation
+
+	set := 0
+	for _, isSet := range []bool{rw.This != nil, rw.ComputedUserset != nil, rw.TupleToUserset != nil, rw.Union != nil, rw.Intersection != nil, rw.Exclusion != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("rewrite of %s must set exactly one of this, computed_userset, tuple_to_userset, union, intersection, exclusion", where)
+	}
+
+	switch {
+	case rw.ComputedUserset != nil:
+		if _, ok := s.Relation(namespace, rw.ComputedUserset.Relation); !ok {
+			return fmt.Errorf("rewrite of %s refers to unknown relation %s", where, rw.ComputedUserset.Relation)
+		}
+	case rw.TupleToUserset != nil:
+		// The computed relation belongs to whatever objects the tupleset
+		// points at, so only the tupleset can be checked here
+		if _, ok := s.Relation(namespace, rw.TupleToUserset.Tupleset.Relation); !ok {
+			return fmt.Errorf("rewrite of %s refers to unknown relation %s", where, rw.TupleToUserset.Tupleset.Relation)
+		}
+		if !validName(rw.TupleToUserset.ComputedUserset.Relation) {
+			return fmt.Errorf("rewrite of %s has an invalid computed relation", where)
+		}
+	case rw.Union != nil || rw.Intersection != nil:
+		children := rw.Union
+		if rw.Intersection != nil {
+			children = rw.Intersection
+		}
+		if len(children) == 0 {
+			return fmt.Errorf("rewrite of %s has an empty set operation", where)
+		}
+		for _, child := range children {
+			if err := s.validateRewrite(namespace, relation, child); err != nil {
+				return err
+			}
+		}
+	case rw.Exclusion != nil:
+		if rw.Exclusion.Base == nil || rw.Exclusion.Subtract == nil {
+			return fmt.Errorf("exclusion in %s needs base and subtract", where)
+		}
+		if err := s.validateRewrite(namespace, relation, rw.Exclusion.Base); err != nil {
+			return err
+		}
+		if err := s.validateRewrite(namespace, relation, rw.Exclusion.Subtract); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Relation) rewrite() *Rewrite {
+	if r.Rewrite == nil {
+		return this
+	}
+	return r.Rewrite
+}
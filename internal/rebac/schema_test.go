@@ -0,0 +1,49 @@
+package rebac
+
+import "testing"
+
+func TestParseTuple(t *testing.T) {
+	valid := []string{
+		"doc:readme#viewer@user:alice",
+		"doc:readme#viewer@group:eng#member",
+		"folder:0190a1b2-c3d4#parent@folder:root",
+	}
+	for _, s := range valid {
+		tuple, err := ParseTuple(s)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", s, err)
+			continue
+		}
+		if tuple.String() != s {
+			t.Errorf("Expected %q to round trip, got %q", s, tuple.String())
+		}
+	}
+
+	invalid := []string{"", "doc:readme#viewer", "doc#viewer@user:alice", "doc:readme@user:alice", "Doc:readme#viewer@user:alice", "doc:readme#viewer@user:", "doc:read me#viewer@user:alice"}
+	for _, s := range invalid {
+		if _, err := ParseTuple(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestParseSchema_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"duplicate namespace": `{"namespaces": [{"name": "doc"}, {"name": "doc"}]}`,
+		"unknown computed":    `{"namespaces": [{"name": "doc", "relations": [{"name": "viewer", "rewrite": {"computed_userset": {"relation": "editor"}}}]}]}`,
+		"unknown tupleset":    `{"namespaces": [{"name": "doc", "relations": [{"name": "viewer", "rewrite": {"tuple_to_userset": {"tupleset": {"relation": "parent"}, "computed_userset": {"relation": "viewer"}}}}]}]}`,
+		"two operations":      `{"namespaces": [{"name": "doc", "relations": [{"name": "viewer", "rewrite": {"this": {}, "union": [{"this": {}}]}}]}]}`,
+		"empty union":         `{"namespaces": [{"name": "doc", "relations": [{"name": "viewer", "rewrite": {"union": []}}]}]}`,
+		"partial exclusion":   `{"namespaces": [{"name": "doc", "relations": [{"name": "viewer", "rewrite": {"exclusion": {"base": {"this": {}}}}}]}]}`,
+		"invalid name":        `{"namespaces": [{"name": "Doc"}]}`,
+	}
+
+	for name, data := range tests {
+		if _, err := ParseSchema([]byte(data)); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+
+	// The built-in schema must always be valid
+	DefaultSchema()
+}
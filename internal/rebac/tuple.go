@@ -0,0 +1,124 @@
+// Package rebac implements relationship based access control in the style of
+// Google Zanzibar. Access is derived from relation tuples such as
+//
+//	doc:readme#viewer@group:eng#member
+//
+// ("members of group eng are viewers of doc readme") together with a schema
+// whose userset rewrites say how relations imply each other, e.g. that the
+// editors of a folder are viewers of every document in it.
+package rebac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UserNamespace is the namespace whose object IDs are user IDs
+const UserNamespace = "user"
+
+// Object is an object in a namespace, written "namespace:id"
+type Object struct {
+	Namespace string
+	ID        string
+}
+
+func (o Object) String() string {
+	return o.Namespace + ":" + o.ID
+}
+
+// Subject is who a tuple relates an object to: either an object such as
+// "user:alice", or the userset of an object's relation such as
+// "group:eng#member"
+type Subject struct {
+	Object
+	Relation string
+}
+
+func (s Subject) String() string {
+	if s.Relation == "" {
+		return s.Object.String()
+	}
+	return s.Object.String() + "#" + s.Relation
+}
+
+// IsUserset reports whether the subject stands for the userset of a relation
+func (s Subject) IsUserset() bool {
+	return s.Relation != ""
+}
+
+// Tuple relates Subject to Object through Relation
+type Tuple struct {
+	Object   Object
+	Relation string
+	Subject  Subject
+}
+
+func (t Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// ParseObject parses "namespace:id"
+func ParseObject(s string) (Object, error) {
+	namespace, id, ok := strings.Cut(s, ":")
+	if !ok || !validName(namespace) || !validID(id) {
+		return Object{}, fmt.Errorf("invalid object %q, expected namespace:id", s)
+	}
+	return Object{Namespace: namespace, ID: id}, nil
+}
+
+// ParseSubject parses "namespace:id" or "namespace:id#relation"
+func ParseSubject(s string) (Subject, error) {
+	object, relation, hasRelation := strings.Cut(s, "#")
+	o, err := ParseObject(object)
+	if err != nil {
+		return Subject{}, fmt.Errorf("invalid subject %q, expected namespace:id or namespace:id#relation", s)
+	}
+	if hasRelation && !validName(relation) {
+		return Subject{}, fmt.Errorf("invalid subject %q, expected namespace:id or namespace:id#relation", s)
+	}
+	return Subject{Object: o, Relation: relation}, nil
+}
+
+// ParseTuple parses "namespace:id#relation@subject"
+func ParseTuple(s string) (Tuple, error) {
+	objectRelation, subject, ok := strings.Cut(s, "@")
+	if !ok {
+		return Tuple{}, fmt.Errorf("invalid tuple %q, expected namespace:id#relation@subject", s)
+	}
+	object, relation, ok := strings.Cut(objectRelation, "#")
+	if !ok || !validName(relation) {
+		return Tuple{}, fmt.Errorf("invalid tuple %q, expected namespace:id#relation@subject", s)
+	}
+
+	o, err := ParseObject(object)
+	if err != nil {
+		return Tuple{}, err
+	}
+	sub, err := ParseSubject(subject)
+	if err != nil {
+		return Tuple{}, err
+	}
+	return Tuple{Object: o, Relation: relation, Subject: sub}, nil
+}
+
+// validName accepts namespace and relation names: lowercase letters, digits
+// and '_', starting with a letter
+func validName(s string) bool {
+	if s == "" || len(s) > 64 || s[0] < 'a' || s[0] > 'z' {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// validID accepts object IDs up to 255 bytes without whitespace, '#' or '@'
+func validID(s string) bool {
+	if s == "" || len(s) > 255 {
+		return false
+	}
+	return !strings.ContainsAny(s, "#@ \t\r\n")
+}
@@ -0,0 +1,44 @@
+package rebac
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// zookiePrefix versions the token format
+const zookiePrefix = "rv1:"
+
+// ErrInvalidZookie is returned for consistency tokens this service did not
+// issue
+var ErrInvalidZookie = errors.New("invalid zookie")
+
+// Zookie is an opaque consistency token naming a revision of the tuple store.
+// Writes return one; passing it to a later read guarantees the read sees that
+// write.
+type Zookie string
+
+// NewZookie encodes a revision
+func NewZookie(revision int64) Zookie {
+	return Zookie(base64.RawURLEncoding.EncodeToString([]byte(zookiePrefix + strconv.FormatInt(revision, 10))))
+}
+
+// Revision decodes the revision a zookie names
+func (z Zookie) Revision() (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(string(z))
+	if err != nil {
+		return 0, ErrInvalidZookie
+	}
+
+	value, ok := strings.CutPrefix(string(raw), zookiePrefix)
+	if !ok {
+		return 0, ErrInvalidZookie
+	}
+
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, ErrInvalidZookie
+	}
+	return revision, nil
+}
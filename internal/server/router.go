@@ -1,11 +1,12 @@
 package server
 
 import (
+	"authorization/internal/constants"
 	"authorization/internal/handler"
 	"authorization/internal/middleware"
 	"authorization/internal/pkg/response"
-	"authorization/internal/utils"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -16,13 +17,29 @@ import (
 func NewRouter(
 	authHandler *handler.AuthHandler,
 	userHandler *handler.UserHandler,
-	jwtManager *utils.JWTManager,
+	sessionHandler *handler.SessionHandler,
+	jwksHandler *handler.JWKSHandler,
+	roleHandler *handler.RoleHandler,
+	authzHandler *handler.AuthzHandler,
+	relationHandler *handler.RelationHandler,
+	policyHandler *handler.PolicyHandler,
+	orgHandler *handler.OrganizationHandler,
+	passwordHandler *handler.PasswordHandler,
+	emailVerificationHandler *handler.EmailVerificationHandler,
+	mfaHandler *handler.MFAHandler,
+	webauthnHandler *handler.WebAuthnHandler,
+	lockoutHandler *handler.LockoutHandler,
+	authMiddleware *middleware.AuthMiddleware,
+	rateLimiter *middleware.RateLimiter,
+	trustedProxies []netip.Prefix,
 ) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Built-in middleware
 	r.Use(chiMiddleware.RequestID)
-	r.Use(chiMiddleware.RealIP)
+	// Client addresses, which lockouts and rate limits are keyed by, are
+	// only taken from forwarding headers set by TRUSTED_PROXIES
+	r.Use(middleware.RealIP(trustedProxies))
 	r.Use(chiMiddleware.Recoverer)
 	r.Use(chiMiddleware.Timeout(60 * time.Second))
 
@@ -33,8 +50,8 @@ func NewRouter(
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", middleware.ClientIDHeader},
+		ExposedHeaders:   []string{"Link", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -48,22 +65,120 @@ func NewRouter(
 		})
 	})
 
+	// Public keys for verifying access tokens
+	r.Get("/.well-known/jwks.json", jwksHandler.JWKS)
+
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
-		// Auth routes (public)
+		// Auth routes (public), rate limited by the policies of
+		// RATE_LIMIT_<ROUTE>
 		r.Route("/auth", func(r chi.Router) {
-			r.Post("/register", authHandler.Register)
-			r.Post("/login", authHandler.Login)
-			r.Post("/refresh", authHandler.RefreshToken)
-			r.Post("/logout", authHandler.Logout)
+			r.With(rateLimiter.Limit(constants.RateLimitRegister)).Post("/register", authHandler.Register)
+			r.With(rateLimiter.Limit(constants.RateLimitLogin)).Post("/login", authHandler.Login)
+			r.With(rateLimiter.Limit(constants.RateLimitRefresh)).Post("/refresh", authHandler.RefreshToken)
+			r.With(rateLimiter.Limit(constants.RateLimitRefresh)).Post("/logout", authHandler.Logout)
+			r.With(rateLimiter.Limit(constants.RateLimitPassword)).Post("/password/forgot", passwordHandler.ForgotPassword)
+			r.With(rateLimiter.Limit(constants.RateLimitPassword)).Post("/password/reset", passwordHandler.ResetPassword)
+			r.With(rateLimiter.Limit(constants.RateLimitEmail)).Post("/email/verify", emailVerificationHandler.VerifyEmail)
+			r.With(rateLimiter.Limit(constants.RateLimitEmail)).Post("/email/resend", emailVerificationHandler.ResendVerification)
+			if mfaHandler != nil {
+				r.With(rateLimiter.Limit(constants.RateLimitMFA)).Post("/mfa/verify", authHandler.VerifyMFA)
+			}
+
+			// Passkeys. Logins are public, registering needs a signed in user
+			r.Route("/webauthn", func(r chi.Router) {
+				r.With(rateLimiter.Limit(constants.RateLimitWebAuthn)).Post("/login/begin", webauthnHandler.BeginLogin)
+				r.With(rateLimiter.Limit(constants.RateLimitLogin)).Post("/login/finish", webauthnHandler.FinishLogin)
+				r.With(authMiddleware.RequireAuth).Post("/register/begin", webauthnHandler.BeginRegistration)
+				r.With(authMiddleware.RequireAuth).Post("/register/finish", webauthnHandler.FinishRegistration)
+			})
 		})
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
-			authMiddleware := middleware.NewAuthMiddleware(jwtManager)
 			r.Use(authMiddleware.RequireAuth)
 
 			r.Get("/me", userHandler.Me)
+			r.Post("/me/password", authHandler.ChangePassword)
+
+			// Two-factor authentication, when MFA_ENCRYPTION_SECRET is set
+			if mfaHandler != nil {
+				r.Get("/me/mfa", mfaHandler.Status)
+				r.Post("/me/mfa/totp", mfaHandler.Enroll)
+				r.Post("/me/mfa/totp/confirm", mfaHandler.Confirm)
+				r.Post("/me/mfa/disable", mfaHandler.Disable)
+				r.Post("/me/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+			}
+
+			// Registered passkeys
+			r.Get("/me/webauthn/credentials", webauthnHandler.ListCredentials)
+			r.Delete("/me/webauthn/credentials/{id}", webauthnHandler.DeleteCredential)
+
+			// Session management
+			r.Get("/me/sessions", sessionHandler.List)
+			r.Delete("/me/sessions", sessionHandler.RevokeAll)
+			r.Delete("/me/sessions/{id}", sessionHandler.Revoke)
+
+			// Organizations. Members are managed by holders of members:read
+			// and members:write within the organization, or of orgs:read and
+			// orgs:write
+			r.Get("/me/orgs", orgHandler.ListMemberships)
+			r.Post("/orgs/switch", authHandler.SwitchOrganization)
+			r.Get("/orgs/{id}/members", orgHandler.ListMembers)
+			r.Put("/orgs/{id}/members/{userID}", orgHandler.SetMember)
+			r.Delete("/orgs/{id}/members/{userID}", orgHandler.RemoveMember)
+
+			// Permission checks for other services
+			r.Post("/authz/check", authzHandler.Check)
+
+			// Relationship based access. Users may check themselves; other
+			// subjects need relations:read
+			r.Post("/relations/check", relationHandler.Check)
+			r.Post("/relations/list-objects", relationHandler.ListObjects)
+			r.With(authMiddleware.RequirePermission(constants.PermRelationsRead)).Post("/relations/read", relationHandler.Read)
+			r.With(authMiddleware.RequirePermission(constants.PermRelationsRead)).Post("/relations/expand", relationHandler.Expand)
+			r.With(authMiddleware.RequirePermission(constants.PermRelationsWrite)).Post("/relations/write", relationHandler.Write)
+
+			// Attribute based policy decisions
+			r.With(authMiddleware.RequirePermission(constants.PermPoliciesEvaluate)).Post("/policy/evaluate", policyHandler.Evaluate)
+		})
+
+		// Admin routes, each guarded by the permission it needs so that
+		// narrower roles than admin can be granted access to some of them
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(authMiddleware.RequireAuth)
+
+			// Roles and permissions
+			r.With(authMiddleware.RequirePermission(constants.PermRolesRead)).Get("/roles", roleHandler.ListRoles)
+			r.With(authMiddleware.RequirePermission(constants.PermRolesWrite)).Post("/roles", roleHandler.CreateRole)
+			r.With(authMiddleware.RequirePermission(constants.PermRolesWrite)).Put("/roles/{name}/permissions", roleHandler.SetRolePermissions)
+			r.With(authMiddleware.RequirePermission(constants.PermRolesWrite)).Put("/roles/{name}/inherits", roleHandler.SetRoleInherits)
+			r.With(authMiddleware.RequirePermission(constants.PermRolesRead)).Get("/permissions", roleHandler.ListPermissions)
+			r.With(authMiddleware.RequirePermission(constants.PermRolesWrite)).Post("/permissions", roleHandler.CreatePermission)
+
+			// Role assignments
+			r.With(authMiddleware.RequirePermission(constants.PermRolesRead)).Get("/users/{id}/roles", roleHandler.GetUserRoles)
+			r.With(authMiddleware.RequirePermission(constants.PermRolesAssign)).Post("/users/{id}/roles", roleHandler.AssignRole)
+			r.With(authMiddleware.RequirePermission(constants.PermRolesAssign)).Delete("/users/{id}/roles/{role}", roleHandler.RevokeRole)
+
+			// Failed login lockouts
+			r.With(authMiddleware.RequirePermission(constants.PermUsersRead)).Get("/lockouts", lockoutHandler.ListLockouts)
+			r.With(authMiddleware.RequirePermission(constants.PermUsersWrite)).Delete("/lockouts/ips/{ip}", lockoutHandler.UnlockIP)
+			r.With(authMiddleware.RequirePermission(constants.PermUsersRead)).Get("/users/{id}/lockout", lockoutHandler.GetUserLockout)
+			r.With(authMiddleware.RequirePermission(constants.PermUsersWrite)).Delete("/users/{id}/lockout", lockoutHandler.UnlockUser)
+
+			// Disabled users cannot log in and lose their sessions
+			r.With(authMiddleware.RequirePermission(constants.PermUsersWrite)).Post("/users/{id}/disable", userHandler.Disable)
+			r.With(authMiddleware.RequirePermission(constants.PermUsersWrite)).Post("/users/{id}/enable", userHandler.Enable)
+
+			// Organizations
+			r.With(authMiddleware.RequirePermission(constants.PermOrgsRead)).Get("/orgs", orgHandler.ListOrganizations)
+			r.With(authMiddleware.RequirePermission(constants.PermOrgsWrite)).Post("/orgs", orgHandler.CreateOrganization)
+
+			// Stored policies
+			r.With(authMiddleware.RequirePermission(constants.PermPoliciesRead)).Get("/policies", policyHandler.ListPolicies)
+			r.With(authMiddleware.RequirePermission(constants.PermPoliciesWrite)).Put("/policies/{id}", policyHandler.PutPolicy)
+			r.With(authMiddleware.RequirePermission(constants.PermPoliciesWrite)).Delete("/policies/{id}", policyHandler.DeletePolicy)
 		})
 	})
 
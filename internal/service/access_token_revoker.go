@@ -0,0 +1,67 @@
+package service
+
+import (
+	"authorization/internal/denylist"
+	"authorization/internal/store"
+	"context"
+	"fmt"
+	"time"
+)
+
+// AccessTokenRevoker denies access tokens before they expire, one by one or
+// by the session they belong to. Entries are kept for the access token
+// lifetime, after which every token they match has expired anyway. A nil
+// revoker revokes nothing.
+type AccessTokenRevoker struct {
+	denylist       denylist.Store
+	tokenRepo      *store.TokenRepository
+	accessTokenExp time.Duration
+}
+
+func NewAccessTokenRevoker(denylist denylist.Store, tokenRepo *store.TokenRepository, accessTokenExp time.Duration) *AccessTokenRevoker {
+	return &AccessTokenRevoker{
+		denylist:       denylist,
+		tokenRepo:      tokenRepo,
+		accessTokenExp: accessTokenExp,
+	}
+}
+
+// RevokeToken denies the access token with the given ID. Tokens issued
+// before IDs were added have none and cannot be revoked on their own.
+func (r *AccessTokenRevoker) RevokeToken(tokenID string) error {
+	if r == nil || tokenID == "" {
+		return nil
+	}
+	if err := r.denylist.Add(context.Background(), denylist.TokenKey(tokenID), time.Now().Add(r.accessTokenExp)); err != nil {
+		return fmt.Errorf("error revoking access token: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessions denies every access token of the sessions
+func (r *AccessTokenRevoker) RevokeSessions(sessionIDs ...string) error {
+	if r == nil {
+		return nil
+	}
+	expiresAt := time.Now().Add(r.accessTokenExp)
+	for _, sessionID := range sessionIDs {
+		if err := r.denylist.Add(context.Background(), denylist.SessionKey(sessionID), expiresAt); err != nil {
+			return fmt.Errorf("error revoking access tokens: %w", err)
+		}
+	}
+	return nil
+}
+
+// RevokeUserSessions denies the access tokens of every session of the user
+// except exceptSessionID, which may be empty. It has to be called before the
+// sessions' refresh tokens are revoked, as it finds them by those.
+func (r *AccessTokenRevoker) RevokeUserSessions(userID, exceptSessionID string) error {
+	if r == nil {
+		return nil
+	}
+	sessionIDs, err := r.tokenRepo.UnrevokedFamilyIDs(userID, exceptSessionID)
+	if err != nil {
+		return fmt.Errorf("error getting sessions: %w", err)
+	}
+	return r.RevokeSessions(sessionIDs...)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/denylist"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAccessTokenRevoker(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	denylistRepo := store.NewAccessTokenDenylistRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	revoker := NewAccessTokenRevoker(denylistRepo, tokenRepo, 15*time.Minute)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithAccessTokenRevoker(revoker))
+	userService := NewUserService(userRepo, tokenRepo, nil, revoker)
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := registerResp.User.ID
+
+	login := func() (*dto.LoginResponse, *utils.Claims) {
+		t.Helper()
+		resp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		claims, err := jwtManager.ValidateAccessToken(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("Failed to validate access token: %v", err)
+		}
+		if claims.ID == "" {
+			t.Fatal("Expected the access token to carry a jti")
+		}
+		return resp, claims
+	}
+	denied := func(claims *utils.Claims) bool {
+		t.Helper()
+		denied, err := denylistRepo.Contains(context.Background(), denylist.TokenKey(claims.ID), denylist.SessionKey(claims.SessionID))
+		if err != nil {
+			t.Fatalf("Failed to check denylist: %v", err)
+		}
+		return denied
+	}
+
+	// Logging out denies the access tokens of the session only
+	loggedOut, loggedOutClaims := login()
+	_, otherClaims := login()
+	if err := authService.Logout(loggedOut.RefreshToken); err != nil {
+		t.Fatalf("Failed to logout: %v", err)
+	}
+	if !denied(loggedOutClaims) || denied(otherClaims) {
+		t.Error("Expected only the logged out session to be denied")
+	}
+	if err := revoker.RevokeSessions(loggedOutClaims.SessionID); err != nil {
+		t.Errorf("Expected revoking a session again to work, got %v", err)
+	}
+
+	// A password change denies the token it was made with
+	_, changeClaims := login()
+	changeResp, err := authService.ChangePassword(userID, changeClaims.SessionID, changeClaims.ID, "", &dto.ChangePasswordRequest{
+		CurrentPassword: "password123",
+		NewPassword:     "newpassword456",
+	})
+	if err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+	newClaims, _ := jwtManager.ValidateAccessToken(changeResp.AccessToken)
+	if !denied(changeClaims) || denied(newClaims) {
+		t.Error("Expected the old access token to be denied and the new one allowed")
+	}
+
+	// Disabling denies every session and refuses logins until enabled
+	if _, err := userService.DisableUser("admin-id", userID); err != nil {
+		t.Fatalf("Failed to disable user: %v", err)
+	}
+	if !denied(otherClaims) || !denied(newClaims) {
+		t.Error("Expected every session of the disabled user to be denied")
+	}
+	_, err = authService.Login(&dto.LoginRequest{Username: "testuser", Password: "newpassword456"})
+	if err == nil || err.Error() != constants.MsgAccountDisabled {
+		t.Errorf("Expected the disabled user to be refused, got %v", err)
+	}
+
+	if _, err := userService.EnableUser(userID); err != nil {
+		t.Fatalf("Failed to enable user: %v", err)
+	}
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "newpassword456"}); err != nil {
+		t.Errorf("Expected the enabled user to log in, got %v", err)
+	}
+
+	// Entries end when the tokens they match have expired
+	db.Model(&model.AccessTokenDenial{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Second))
+	if denied(otherClaims) {
+		t.Error("Expected expired entries to no longer deny")
+	}
+	if err := denylistRepo.DeleteExpired(); err != nil {
+		t.Fatalf("Failed to clear expired entries: %v", err)
+	}
+	var entries int64
+	db.Model(&model.AccessTokenDenial{}).Count(&entries)
+	if entries != 0 {
+		t.Errorf("Expected expired entries to be cleared, got %d", entries)
+	}
+}
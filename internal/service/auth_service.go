@@ -1,57 +1,203 @@
 package service
 
 import (
+	"authorization/internal/authz"
 	"authorization/internal/constants"
 	"authorization/internal/dto"
 	"authorization/internal/model"
+	"authorization/internal/notify"
+	"authorization/internal/pkg/logger"
 	"authorization/internal/store"
 	"authorization/internal/utils"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 type AuthService struct {
-	userRepo   *store.UserRepository
-	tokenRepo  *store.TokenRepository
-	jwtManager *utils.JWTManager
+	userRepo          *store.UserRepository
+	tokenRepo         *store.TokenRepository
+	jwtManager        *utils.JWTManager
+	roleRepo          *store.RoleRepository
+	evaluator         *authz.Evaluator
+	orgRepo           *store.OrganizationRepository
+	emailVerification *EmailVerificationService
+	requireVerified   bool
+	mfa               *MFAService
+	webauthn          *WebAuthnService
+	mfaChallenges     *store.MFAChallengeRepository
+	lockout           *LockoutService
+	registerNotifier  notify.Notifier
+	passwordPolicy    *PasswordPolicyService
+	revoker           *AccessTokenRevoker
+	refreshReuseGrace time.Duration
+
+	// rehashes tracks the password hash upgrades running in the background
+	rehashes sync.WaitGroup
+}
+
+// dummyPasswordHash is checked against for usernames without an account, so
+// that a login takes as long whether or not the account exists
+var dummyPasswordHash = sync.OnceValues(func() (string, error) {
+	return utils.HashPassword("dummy password")
+})
+
+// AuthOption configures optional AuthService behaviour
+type AuthOption func(*AuthService)
+
+// WithRefreshReuseGrace lets a just-rotated refresh token be presented again
+// for the given duration without being treated as reuse, so clients can retry
+// a refresh whose response was lost
+func WithRefreshReuseGrace(grace time.Duration) AuthOption {
+	return func(s *AuthService) {
+		s.refreshReuseGrace = grace
+	}
+}
+
+// WithRoles assigns constants.DefaultUserRole to new users and embeds the
+// effective roles and permissions of users in their access tokens
+func WithRoles(roleRepo *store.RoleRepository, evaluator *authz.Evaluator) AuthOption {
+	return func(s *AuthService) {
+		s.roleRepo = roleRepo
+		s.evaluator = evaluator
+	}
+}
+
+// WithOrganizations lets sessions switch into organizations the user is a
+// member of. Access tokens of such a session carry its org_id and the grants
+// of the user's roles in the organization on top of their global ones.
+func WithOrganizations(orgRepo *store.OrganizationRepository) AuthOption {
+	return func(s *AuthService) {
+		s.orgRepo = orgRepo
+	}
+}
+
+// WithEmailVerification sends new users a link to verify their email. If
+// required is set, users cannot log in until they have verified it.
+func WithEmailVerification(emailVerification *EmailVerificationService, required bool) AuthOption {
+	return func(s *AuthService) {
+		s.emailVerification = emailVerification
+		s.requireVerified = required
+	}
+}
+
+// WithMFA makes users with a second factor complete login with a code. Their
+// password only earns them a short lived challenge token, which VerifyMFA
+// exchanges for the session tokens.
+func WithMFA(mfa *MFAService) AuthOption {
+	return func(s *AuthService) {
+		s.mfa = mfa
+	}
+}
+
+// WithMFAChallenges limits each MFA challenge token to
+// mfaChallengeMaxAttempts attempts at a second factor and a single login.
+// Without it a challenge can be used until it expires, within the limits of
+// the factor itself.
+func WithMFAChallenges(mfaChallenges *store.MFAChallengeRepository) AuthOption {
+	return func(s *AuthService) {
+		s.mfaChallenges = mfaChallenges
+	}
 }
 
-func NewAuthService(userRepo *store.UserRepository, tokenRepo *store.TokenRepository, jwtManager *utils.JWTManager) *AuthService {
-	return &AuthService{
+// WithWebAuthn lets users log in with passkeys, either as the second factor
+// of a password login or without a password at all
+func WithWebAuthn(webauthn *WebAuthnService) AuthOption {
+	return func(s *AuthService) {
+		s.webauthn = webauthn
+	}
+}
+
+// WithLockout counts failed password logins and holds back further attempts
+// for the username or client address once they fail too often
+func WithLockout(lockout *LockoutService) AuthOption {
+	return func(s *AuthService) {
+		s.lockout = lockout
+	}
+}
+
+// WithEnumerationSafeRegistration makes Register answer every request the
+// same way, so that it cannot be used to find out which usernames and emails
+// have accounts. Requests that clash with an existing account create nothing;
+// the notifier tells the owner of the email instead.
+func WithEnumerationSafeRegistration(notifier notify.Notifier) AuthOption {
+	return func(s *AuthService) {
+		s.registerNotifier = notifier
+	}
+}
+
+// WithPasswordPolicy checks the passwords of new users and changed passwords
+// against the password policy
+func WithPasswordPolicy(passwordPolicy *PasswordPolicyService) AuthOption {
+	return func(s *AuthService) {
+		s.passwordPolicy = passwordPolicy
+	}
+}
+
+// WithAccessTokenRevoker denies the access tokens of sessions that log out
+// and the one a password is changed with, instead of leaving them valid
+// until they expire
+func WithAccessTokenRevoker(revoker *AccessTokenRevoker) AuthOption {
+	return func(s *AuthService) {
+		s.revoker = revoker
+	}
+}
+
+func NewAuthService(userRepo *store.UserRepository, tokenRepo *store.TokenRepository, jwtManager *utils.JWTManager, opts ...AuthOption) *AuthService {
+	s := &AuthService{
 		userRepo:   userRepo,
 		tokenRepo:  tokenRepo,
 		jwtManager: jwtManager,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *AuthService) Register(req *dto.RegisterRequest) (*dto.RegisterResponse, error) {
+	enumerationSafe := s.registerNotifier != nil
+
+	// The policy does not depend on existing accounts, so it is checked
+	// first even with enumeration safe registration
+	if err := s.passwordPolicy.Check(req.Password, req.Username, req.Email, nil); err != nil {
+		return nil, err
+	}
+
 	// Check if username already exists
-	exists, err := s.userRepo.ExistsByUsername(req.Username)
+	usernameTaken, err := s.userRepo.ExistsByUsername(req.Username)
 	if err != nil {
 		return nil, fmt.Errorf("error checking username: %w", err)
 	}
-	if exists {
+	if usernameTaken && !enumerationSafe {
 		return nil, fmt.Errorf(constants.MsgUserAlreadyExists)
 	}
 
 	// Check if email already exists
-	exists, err = s.userRepo.ExistsByEmail(req.Email)
+	emailTaken, err := s.userRepo.ExistsByEmail(req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("error checking email: %w", err)
 	}
-	if exists {
+	if emailTaken && !enumerationSafe {
 		return nil, fmt.Errorf("email already exists")
 	}
 
-	// Hash password
+	// Hash password. Clashing requests are hashed too, so that they take
+	// about as long as creating the account.
 	passwordHash, err := utils.HashPassword(req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("error hashing password: %w", err)
 	}
 
+	if usernameTaken || emailTaken {
+		s.notifyRegisterClash(req, emailTaken)
+		return &dto.RegisterResponse{Message: constants.MsgRegisterPending}, nil
+	}
+
 	// Create user
 	user := &model.User{
 		Username:     req.Username,
@@ -62,27 +208,101 @@ func (s *AuthService) Register(req *dto.RegisterRequest) (*dto.RegisterResponse,
 	// Generate UUID v7 for user ID
 	user.ID = utils.GenerateUUIDv7()
 
-	if err := s.userRepo.Create(user); err != nil {
+	// Look up the default role first so a missing role does not leave a user
+	// without any role behind
+	var defaultRole *model.Role
+	if s.roleRepo != nil {
+		defaultRole, err = s.roleRepo.GetByName(constants.DefaultUserRole)
+		if err != nil {
+			return nil, fmt.Errorf("error getting default role: %w", err)
+		}
+	}
+
+	if defaultRole != nil {
+		err = s.userRepo.CreateWithRole(user, defaultRole.ID)
+	} else {
+		err = s.userRepo.Create(user)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("error creating user: %w", err)
 	}
 
+	// The account exists either way; a lost link can be resent
+	if s.emailVerification != nil {
+		if err := s.emailVerification.SendVerification(user); err != nil {
+			logger.Error("Failed to send verification link", zap.Error(err), zap.String("user_id", user.ID))
+		}
+	}
+
+	if enumerationSafe {
+		return &dto.RegisterResponse{Message: constants.MsgRegisterPending}, nil
+	}
+
 	return &dto.RegisterResponse{
 		Message: constants.MsgRegisterSuccess,
-		User: dto.UserInfo{
-			ID:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
+		User: &dto.UserInfo{
+			ID:            user.ID,
+			Username:      user.Username,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerifiedAt != nil,
+			CreatedAt:     user.CreatedAt,
 		},
 	}, nil
 }
 
+// notifyRegisterClash tells the owner of the email why an enumeration safe
+// registration created no account: the email already has one, or the
+// username belongs to someone else. Failures are logged so the response
+// stays the same.
+func (s *AuthService) notifyRegisterClash(req *dto.RegisterRequest, emailTaken bool) {
+	msg := &notify.Message{
+		Kind:     notify.KindUsernameTaken,
+		Username: req.Username,
+		Email:    req.Email,
+	}
+	if emailTaken {
+		user, err := s.userRepo.GetByEmail(req.Email)
+		if err != nil {
+			logger.Error("Failed to get user for registration notice", zap.Error(err))
+			return
+		}
+		msg = &notify.Message{
+			Kind:     notify.KindAccountExists,
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+		}
+	}
+
+	logger.Debug("Registration clashed with an existing account", zap.String("kind", msg.Kind))
+	if err := s.registerNotifier.Notify(msg); err != nil {
+		logger.Error("Failed to send registration notice", zap.Error(err), zap.String("kind", msg.Kind))
+	}
+}
+
 func (s *AuthService) Login(req *dto.LoginRequest) (*dto.LoginResponse, error) {
+	// The attempt counts as failed until the password turns out right.
+	// Attempts that are held back still go through the password check, so
+	// that their timing does not tell a lockout from a wrong password.
+	var retryAfter time.Duration
+	if s.lockout != nil {
+		var err error
+		retryAfter, err = s.lockout.Reserve(req.Username, req.Client.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Get user by username
 	user, err := s.userRepo.GetByUsername(req.Username)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf(constants.MsgInvalidCredentials)
+			// Check the password anyway, so that the response takes as
+			// long as for a wrong password
+			if hash, err := dummyPasswordHash(); err == nil {
+				utils.VerifyPassword(req.Password, hash)
+			}
+			return nil, loginFailed(retryAfter)
 		}
 		return nil, fmt.Errorf("error getting user: %w", err)
 	}
@@ -93,12 +313,286 @@ func (s *AuthService) Login(req *dto.LoginRequest) (*dto.LoginResponse, error) {
 		return nil, fmt.Errorf("error verifying password: %w", err)
 	}
 
-	if !valid {
-		return nil, fmt.Errorf(constants.MsgInvalidCredentials)
+	if !valid || retryAfter > 0 {
+		return nil, loginFailed(retryAfter)
+	}
+
+	if s.lockout != nil {
+		if err := s.lockout.RecordSuccess(req.Username, req.Client.IPAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	s.upgradePasswordHash(user, req.Password)
+
+	// Checked after the password so that it reveals nothing to others
+	if s.requireVerified && user.EmailVerifiedAt == nil {
+		return nil, fmt.Errorf(constants.MsgEmailNotVerified)
+	}
+	if user.DisabledAt != nil {
+		return nil, fmt.Errorf(constants.MsgAccountDisabled)
+	}
+
+	deviceLabel := req.DeviceLabel
+	if deviceLabel == "" {
+		deviceLabel = utils.DescribeUserAgent(req.Client.UserAgent)
+	}
+
+	methods, err := s.secondFactors(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		challenge, expiresAt, err := s.jwtManager.GenerateMFAChallenge(user.ID, deviceLabel, mfaChallengeExpiration)
+		if err != nil {
+			return nil, fmt.Errorf("error generating MFA challenge: %w", err)
+		}
+		return &dto.LoginResponse{
+			MFAChallenge: &dto.MFAChallengeResponse{
+				MFARequired: true,
+				MFAToken:    challenge,
+				ExpiresAt:   expiresAt,
+				Methods:     methods,
+			},
+		}, nil
+	}
+
+	return s.startSession(user, deviceLabel, req.Client)
+}
+
+// upgradePasswordHash rehashes the password of the user with the current
+// parameters and pepper if their stored hash is older or weaker. The password
+// has just been checked, so this is the only time it is at hand. Hashing
+// takes as long as checking it, so the login does not wait for it. Failures
+// are logged and the old hash keeps working.
+func (s *AuthService) upgradePasswordHash(user *model.User, password string) {
+	if !utils.PasswordNeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	userID, oldHash := user.ID, user.PasswordHash
+	s.rehashes.Add(1)
+	go func() {
+		defer s.rehashes.Done()
+
+		passwordHash, err := utils.HashPassword(password)
+		if err != nil {
+			logger.Error("Failed to rehash password", zap.Error(err), zap.String("user_id", userID))
+			return
+		}
+
+		// A password changed in the meantime is not overwritten
+		updated, err := s.userRepo.UpdatePasswordHash(userID, oldHash, passwordHash)
+		if err != nil {
+			logger.Error("Failed to save rehashed password", zap.Error(err), zap.String("user_id", userID))
+			return
+		}
+		if updated {
+			logger.Info("Password rehashed with current parameters", zap.String("user_id", userID))
+		}
+	}()
+}
+
+// WaitForRehashes waits for the password hash upgrades started by logins to
+// finish, so that shutting down does not cut them off
+func (s *AuthService) WaitForRehashes() {
+	s.rehashes.Wait()
+}
+
+// loginFailed returns the error of a failed password login. The failure was
+// counted when the attempt was reserved; attempts made while held back were
+// not counted.
+func loginFailed(retryAfter time.Duration) error {
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return fmt.Errorf(constants.MsgInvalidCredentials)
+}
+
+// secondFactors returns the second factor methods the user has set up
+func (s *AuthService) secondFactors(userID string) ([]string, error) {
+	var methods []string
+	if s.mfa != nil {
+		enabled, err := s.mfa.Enabled(userID)
+		if err != nil {
+			return nil, fmt.Errorf("error checking MFA: %w", err)
+		}
+		if enabled {
+			methods = append(methods, "totp", "recovery_code")
+		}
+	}
+	if s.webauthn != nil {
+		hasPasskeys, err := s.webauthn.HasCredentials(userID)
+		if err != nil {
+			return nil, fmt.Errorf("error checking passkeys: %w", err)
+		}
+		if hasPasskeys {
+			methods = append(methods, "webauthn")
+		}
+	}
+	return methods, nil
+}
+
+// VerifyMFA completes a login that returned an MFA challenge
+func (s *AuthService) VerifyMFA(req *dto.MFAVerifyRequest) (*dto.LoginResponse, error) {
+	if s.mfa == nil {
+		return nil, fmt.Errorf(constants.MsgInvalidToken)
+	}
+
+	claims, err := s.jwtManager.ValidateMFAChallenge(req.MFAToken)
+	if err != nil {
+		return nil, fmt.Errorf(constants.MsgInvalidToken)
+	}
+
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgInvalidToken)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	if err := s.reserveChallengeAttempt(claims); err != nil {
+		return nil, err
+	}
+	if err := s.mfa.Verify(user.ID, req.Code, req.RecoveryCode); err != nil {
+		// The factor was removed since the challenge was issued
+		if err.Error() == constants.MsgMFANotEnabled {
+			return nil, fmt.Errorf(constants.MsgInvalidToken)
+		}
+		return nil, err
+	}
+	if err := s.consumeChallenge(claims); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, claims.DeviceLabel, req.Client)
+}
+
+// BeginWebAuthnLogin starts a passkey login. With an MFA token the passkey
+// completes the password login the token was issued for; without one the
+// login is passwordless.
+func (s *AuthService) BeginWebAuthnLogin(req *dto.WebAuthnLoginBeginRequest) (*dto.WebAuthnRequestOptions, error) {
+	if s.webauthn == nil {
+		return nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	userID := ""
+	if req.MFAToken != "" {
+		claims, err := s.jwtManager.ValidateMFAChallenge(req.MFAToken)
+		if err != nil {
+			return nil, fmt.Errorf(constants.MsgInvalidToken)
+		}
+		userID = claims.UserID
+	}
+
+	return s.webauthn.BeginLogin(userID)
+}
+
+// LoginWithWebAuthn completes a passkey login started by BeginWebAuthnLogin
+func (s *AuthService) LoginWithWebAuthn(req *dto.WebAuthnLoginRequest) (*dto.LoginResponse, error) {
+	if s.webauthn == nil {
+		return nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	var claims *utils.MFAChallengeClaims
+	userID := ""
+	deviceLabel := req.DeviceLabel
+	if req.MFAToken != "" {
+		var err error
+		claims, err = s.jwtManager.ValidateMFAChallenge(req.MFAToken)
+		if err != nil {
+			return nil, fmt.Errorf(constants.MsgInvalidToken)
+		}
+		if err := s.reserveChallengeAttempt(claims); err != nil {
+			return nil, err
+		}
+		userID = claims.UserID
+		deviceLabel = claims.DeviceLabel
+	}
+
+	userID, err := s.webauthn.FinishLogin(userID, &req.Credential)
+	if err != nil {
+		return nil, err
+	}
+	if claims != nil {
+		if err := s.consumeChallenge(claims); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	// Password logins checked this before handing out the MFA token
+	if req.MFAToken == "" && s.requireVerified && user.EmailVerifiedAt == nil {
+		return nil, fmt.Errorf(constants.MsgEmailNotVerified)
+	}
+
+	if deviceLabel == "" {
+		deviceLabel = utils.DescribeUserAgent(req.Client.UserAgent)
+	}
+	return s.startSession(user, deviceLabel, req.Client)
+}
+
+// reserveChallengeAttempt counts an attempt at completing the MFA challenge
+// before the second factor is checked. A challenge allows
+// mfaChallengeMaxAttempts attempts; after that, or once it completed a
+// login, the password has to be entered again.
+func (s *AuthService) reserveChallengeAttempt(claims *utils.MFAChallengeClaims) error {
+	if s.mfaChallenges == nil {
+		return nil
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return fmt.Errorf(constants.MsgInvalidToken)
+	}
+
+	reserved, err := s.mfaChallenges.ReserveAttempt(claims.ID, claims.UserID, mfaChallengeMaxAttempts, claims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("error recording MFA challenge attempt: %w", err)
+	}
+	if !reserved {
+		return fmt.Errorf(constants.MsgInvalidToken)
+	}
+	return nil
+}
+
+// consumeChallenge marks the MFA challenge as having completed a login, so
+// that it cannot complete another
+func (s *AuthService) consumeChallenge(claims *utils.MFAChallengeClaims) error {
+	if s.mfaChallenges == nil {
+		return nil
 	}
 
+	consumed, err := s.mfaChallenges.Consume(claims.ID)
+	if err != nil {
+		return fmt.Errorf("error consuming MFA challenge: %w", err)
+	}
+	if !consumed {
+		return fmt.Errorf(constants.MsgInvalidToken)
+	}
+	return nil
+}
+
+// startSession issues the tokens of a new session for an authenticated user
+func (s *AuthService) startSession(user *model.User, deviceLabel string, client dto.ClientInfo) (*dto.LoginResponse, error) {
+	// The user may have been disabled while completing a second factor, and
+	// passkey logins are not checked before
+	if user.DisabledAt != nil {
+		return nil, fmt.Errorf(constants.MsgAccountDisabled)
+	}
+
+	// The first refresh token of a login starts a new family, whose ID is
+	// also the session ID carried by access tokens
+	refreshTokenID := utils.GenerateUUIDv7()
+
 	// Generate access token
-	accessToken, expiresAt, err := s.jwtManager.GenerateAccessToken(user.ID, user.Username)
+	accessToken, expiresAt, err := s.generateAccessToken(user, refreshTokenID, "")
 	if err != nil {
 		return nil, fmt.Errorf("error generating access token: %w", err)
 	}
@@ -110,14 +604,20 @@ func (s *AuthService) Login(req *dto.LoginRequest) (*dto.LoginResponse, error) {
 	}
 
 	// Hash refresh token for storage
-	refreshTokenHash := utils.HashRefreshToken(refreshTokenStr)
+	refreshTokenHash := utils.HashToken(refreshTokenStr)
 
-	// Save refresh token
+	// Save refresh token as the root of a new token family
+	now := time.Now()
 	refreshToken := &model.RefreshToken{
-		ID:        utils.GenerateUUIDv7(),
-		UserID:    user.ID,
-		TokenHash: refreshTokenHash,
-		ExpiresAt: time.Now().Add(s.jwtManager.GetRefreshTokenExpiration()),
+		ID:               refreshTokenID,
+		UserID:           user.ID,
+		FamilyID:         refreshTokenID,
+		TokenHash:        refreshTokenHash,
+		ExpiresAt:        now.Add(s.jwtManager.GetRefreshTokenExpiration()),
+		SessionStartedAt: now,
+		UserAgent:        client.UserAgent,
+		IPAddress:        client.IPAddress,
+		DeviceLabel:      deviceLabel,
 	}
 
 	if err := s.tokenRepo.Create(refreshToken); err != nil {
@@ -129,63 +629,102 @@ func (s *AuthService) Login(req *dto.LoginRequest) (*dto.LoginResponse, error) {
 		RefreshToken: refreshTokenStr,
 		ExpiresAt:    expiresAt,
 		User: dto.UserInfo{
-			ID:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
+			ID:            user.ID,
+			Username:      user.Username,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerifiedAt != nil,
+			CreatedAt:     user.CreatedAt,
 		},
 	}, nil
 }
 
 func (s *AuthService) RefreshToken(req *dto.RefreshRequest) (*dto.LoginResponse, error) {
-	// Hash the provided refresh token
-	tokenHash := utils.HashRefreshToken(req.RefreshToken)
+	// Hash the provided refresh token under every key it may be stored under
+	tokenHashes := utils.TokenHashes(req.RefreshToken)
 
-	// Get refresh token from database
-	refreshToken, err := s.tokenRepo.GetByTokenHash(tokenHash)
+	// Generate new refresh token up front so the transaction stays short
+	newRefreshTokenStr, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("error generating refresh token: %w", err)
+	}
+
+	newRefreshToken := &model.RefreshToken{
+		ID:        utils.GenerateUUIDv7(),
+		TokenHash: utils.HashToken(newRefreshTokenStr),
+		ExpiresAt: time.Now().Add(s.jwtManager.GetRefreshTokenExpiration()),
+		UserAgent: req.Client.UserAgent,
+		IPAddress: req.Client.IPAddress,
+	}
+
+	// Rotate inside a single transaction. The presented token row is locked,
+	// so concurrent refreshes with the same token are serialised and at most
+	// one of them can mint a valid descendant.
+	var (
+		refreshToken *model.RefreshToken
+		outcome      rotationOutcome
+	)
+	err = s.tokenRepo.Transaction(func(repo *store.TokenRepository) error {
+		var err error
+		refreshToken, err = repo.GetByTokenHashForUpdate(tokenHashes)
+		if err != nil {
+			return err
+		}
+
+		// Rotated tokens are kept until they expire to recognise replays, so
+		// move the token to the active key before the old one is retired
+		if utils.TokenHashNeedsRekey(refreshToken.TokenHash) {
+			if _, err := repo.Rekey(refreshToken.ID, refreshToken.TokenHash, tokenHashes[0]); err != nil {
+				return err
+			}
+		}
+
+		outcome, err = s.rotate(repo, refreshToken, newRefreshToken)
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf(constants.MsgInvalidToken)
 		}
-		return nil, fmt.Errorf("error getting refresh token: %w", err)
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
 	}
 
-	// Check if token is expired
-	if time.Now().After(refreshToken.ExpiresAt) {
-		// Revoke expired token
-		s.tokenRepo.RevokeToken(tokenHash)
+	switch outcome {
+	case rotationExpired:
 		return nil, fmt.Errorf(constants.MsgTokenExpired)
+	case rotationReused:
+		logger.SecurityEvent(constants.EventRefreshTokenReuse,
+			zap.String("user_id", refreshToken.UserID),
+			zap.String("family_id", refreshToken.FamilyID),
+			zap.String("token_id", refreshToken.ID),
+		)
+		return nil, fmt.Errorf(constants.MsgTokenRevoked)
+	case rotationRevoked:
+		return nil, fmt.Errorf(constants.MsgInvalidToken)
+	case rotationConflict:
+		return nil, fmt.Errorf(constants.MsgTokenRevoked)
 	}
 
-	// Generate new access token
-	accessToken, expiresAt, err := s.jwtManager.GenerateAccessToken(refreshToken.User.ID, refreshToken.User.Username)
-	if err != nil {
-		return nil, fmt.Errorf("error generating access token: %w", err)
-	}
-
-	// Generate new refresh token
-	newRefreshTokenStr, err := utils.GenerateRefreshToken()
+	user, err := s.userRepo.GetByID(refreshToken.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("error generating refresh token: %w", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgInvalidToken)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
 	}
 
-	newRefreshTokenHash := utils.HashRefreshToken(newRefreshTokenStr)
-
-	// Revoke old refresh token
-	if err := s.tokenRepo.RevokeToken(tokenHash); err != nil {
-		return nil, fmt.Errorf("error revoking old refresh token: %w", err)
+	// Disabling a user revokes their sessions, but a refresh may have raced it
+	if user.DisabledAt != nil {
+		return nil, fmt.Errorf(constants.MsgTokenRevoked)
 	}
 
-	// Save new refresh token
-	newRefreshToken := &model.RefreshToken{
-		ID:        utils.GenerateUUIDv7(),
-		UserID:    refreshToken.User.ID,
-		TokenHash: newRefreshTokenHash,
-		ExpiresAt: time.Now().Add(s.jwtManager.GetRefreshTokenExpiration()),
+	// Generate new access token, picking up role changes made since the last one
+	var orgID string
+	if refreshToken.OrganizationID != nil {
+		orgID = *refreshToken.OrganizationID
 	}
-
-	if err := s.tokenRepo.Create(newRefreshToken); err != nil {
-		return nil, fmt.Errorf("error saving refresh token: %w", err)
+	accessToken, expiresAt, err := s.generateAccessToken(user, refreshToken.FamilyID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("error generating access token: %w", err)
 	}
 
 	return &dto.LoginResponse{
@@ -193,15 +732,329 @@ func (s *AuthService) RefreshToken(req *dto.RefreshRequest) (*dto.LoginResponse,
 		RefreshToken: newRefreshTokenStr,
 		ExpiresAt:    expiresAt,
 		User: dto.UserInfo{
-			ID:        refreshToken.User.ID,
-			Username:  refreshToken.User.Username,
-			Email:     refreshToken.User.Email,
-			CreatedAt: refreshToken.User.CreatedAt,
+			ID:            user.ID,
+			Username:      user.Username,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerifiedAt != nil,
+			CreatedAt:     user.CreatedAt,
 		},
 	}, nil
 }
 
+// SwitchOrganization makes the session work in the organization, or in none
+// when orgID is empty, and issues an access token for it. Organizations the
+// user is not a member of are reported as not found.
+func (s *AuthService) SwitchOrganization(userID, sessionID, orgID string) (*dto.SwitchOrganizationResponse, error) {
+	var activeOrg *string
+	if orgID != "" {
+		if s.orgRepo == nil {
+			return nil, fmt.Errorf(constants.MsgOrgNotFound)
+		}
+		member, err := s.orgRepo.IsMember(orgID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("error checking membership: %w", err)
+		}
+		if !member {
+			return nil, fmt.Errorf(constants.MsgOrgNotFound)
+		}
+		activeOrg = &orgID
+	}
+
+	// Later refreshes of the session keep the organization
+	updated, err := s.tokenRepo.SetFamilyOrganization(userID, sessionID, activeOrg)
+	if err != nil {
+		return nil, fmt.Errorf("error switching organization: %w", err)
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf(constants.MsgSessionNotFound)
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	subject, err := s.accessTokenSubject(user, sessionID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, expiresAt, err := s.jwtManager.GenerateAccessToken(subject)
+	if err != nil {
+		return nil, fmt.Errorf("error generating access token: %w", err)
+	}
+
+	return &dto.SwitchOrganizationResponse{
+		AccessToken:    accessToken,
+		ExpiresAt:      expiresAt,
+		OrgID:          subject.OrgID,
+		Roles:          nonNil(subject.Roles),
+		Permissions:    nonNil(subject.Permissions),
+		OrgRoles:       nonNil(subject.OrgRoles),
+		OrgPermissions: nonNil(subject.OrgPermissions),
+	}, nil
+}
+
+// ChangePassword replaces the user's password after checking the current one.
+// Access tokens issued before the change stop working, so a new one is issued
+// for the current session; other sessions have to refresh, or are signed out
+// if req asks for it.
+func (s *AuthService) ChangePassword(userID, sessionID, tokenID, orgID string, req *dto.ChangePasswordRequest) (*dto.ChangePasswordResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	// Wrong current passwords count towards the login lockout of the account,
+	// so a stolen session cannot be used to guess the password without limit
+	if s.lockout != nil {
+		retryAfter, err := s.lockout.Reserve(user.Username, req.Client.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+		if retryAfter > 0 {
+			return nil, &LoginLockedError{RetryAfter: retryAfter}
+		}
+	}
+
+	valid, err := utils.VerifyPassword(req.CurrentPassword, user.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying password: %w", err)
+	}
+	if !valid {
+		return nil, fmt.Errorf(constants.MsgWrongPassword)
+	}
+	if s.lockout != nil {
+		if err := s.lockout.RecordSuccess(user.Username, req.Client.IPAddress); err != nil {
+			return nil, err
+		}
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return nil, fmt.Errorf(constants.MsgSamePassword)
+	}
+	if err := s.passwordPolicy.Check(req.NewPassword, user.Username, user.Email, user); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	oldHash := user.PasswordHash
+	now := time.Now()
+	user.PasswordHash = passwordHash
+	user.PasswordChangedAt = &now
+	if err := s.userRepo.UpdatePassword(user.ID, passwordHash, now); err != nil {
+		return nil, fmt.Errorf("error updating password: %w", err)
+	}
+	if err := s.passwordPolicy.Remember(user.ID, oldHash); err != nil {
+		return nil, err
+	}
+
+	// The token the change was made with is replaced by the one returned
+	if err := s.revoker.RevokeToken(tokenID); err != nil {
+		return nil, err
+	}
+
+	if req.RevokeOtherSessions {
+		if err := s.revoker.RevokeUserSessions(userID, sessionID); err != nil {
+			return nil, err
+		}
+		if err := s.tokenRepo.RevokeAllUserTokensExcept(userID, sessionID); err != nil {
+			return nil, fmt.Errorf("error revoking sessions: %w", err)
+		}
+	}
+
+	logger.SecurityEvent(constants.EventPasswordChanged,
+		zap.String("user_id", userID),
+		zap.String("session_id", sessionID),
+		zap.Bool("revoked_other_sessions", req.RevokeOtherSessions),
+	)
+
+	accessToken, expiresAt, err := s.generateAccessToken(user, sessionID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("error generating access token: %w", err)
+	}
+
+	return &dto.ChangePasswordResponse{
+		Message:     constants.MsgPasswordChanged,
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// generateAccessToken issues an access token for the user's session carrying
+// the roles the user currently holds, including inherited ones, and their
+// grants
+func (s *AuthService) generateAccessToken(user *model.User, sessionID, orgID string) (string, time.Time, error) {
+	subject, err := s.accessTokenSubject(user, sessionID, orgID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return s.jwtManager.GenerateAccessToken(subject)
+}
+
+// accessTokenSubject describes the user's session. Within an organization
+// the user's roles there and their grants are kept apart from the global
+// ones, so that nothing checking global permissions mistakes them for
+// global grants; if the user is no longer a member the token carries no
+// organization.
+func (s *AuthService) accessTokenSubject(user *model.User, sessionID, orgID string) (utils.AccessTokenSubject, error) {
+	subject := utils.AccessTokenSubject{
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
+	}
+
+	var orgRoles []string
+	if orgID != "" && s.orgRepo != nil {
+		member, err := s.orgRepo.IsMember(orgID, user.ID)
+		if err != nil {
+			return subject, fmt.Errorf("error checking membership: %w", err)
+		}
+		if member {
+			subject.OrgID = orgID
+			if orgRoles, err = s.orgRepo.GetMemberRoleNames(orgID, user.ID); err != nil {
+				return subject, fmt.Errorf("error getting organization roles: %w", err)
+			}
+		}
+	}
+
+	if s.evaluator != nil {
+		grants, err := s.evaluator.Resolve(user.ID)
+		if err != nil {
+			return subject, fmt.Errorf("error resolving user permissions: %w", err)
+		}
+		subject.Roles, subject.Permissions = grants.Roles, grants.Permissions
+
+		if len(orgRoles) > 0 {
+			orgGrants, err := s.evaluator.ResolveRoles(orgRoles...)
+			if err != nil {
+				return subject, fmt.Errorf("error resolving organization permissions: %w", err)
+			}
+			subject.OrgRoles, subject.OrgPermissions = orgGrants.Roles, orgGrants.Permissions
+		}
+	}
+
+	return subject, nil
+}
+
+type rotationOutcome int
+
+const (
+	rotationRotated rotationOutcome = iota
+	rotationExpired
+	rotationReused
+	rotationRevoked
+	rotationConflict
+)
+
+// rotate exchanges the locked refreshToken for newRefreshToken. Expired and
+// reused tokens are revoked rather than failing the transaction, so those
+// revocations are committed.
+func (s *AuthService) rotate(repo *store.TokenRepository, refreshToken, newRefreshToken *model.RefreshToken) (rotationOutcome, error) {
+	newRefreshToken.UserID = refreshToken.UserID
+	newRefreshToken.FamilyID = refreshToken.FamilyID
+	newRefreshToken.ParentID = &refreshToken.ID
+	newRefreshToken.SessionStartedAt = refreshToken.SessionStartedAt
+	newRefreshToken.DeviceLabel = refreshToken.DeviceLabel
+	newRefreshToken.OrganizationID = refreshToken.OrganizationID
+	if newRefreshToken.UserAgent == "" {
+		newRefreshToken.UserAgent = refreshToken.UserAgent
+	}
+	if newRefreshToken.IPAddress == "" {
+		newRefreshToken.IPAddress = refreshToken.IPAddress
+	}
+
+	if refreshToken.Revoked {
+		// A client retrying a refresh whose response got lost presents the
+		// token it just rotated. Within the grace window the descendant it
+		// never received is replaced, so the family still has one valid token.
+		if s.withinReuseGrace(refreshToken) {
+			replaced, err := repo.RevokeActiveChildren(refreshToken.ID)
+			if err != nil {
+				return 0, fmt.Errorf("error revoking replaced refresh token: %w", err)
+			}
+			if replaced > 0 {
+				if err := repo.Create(newRefreshToken); err != nil {
+					return 0, fmt.Errorf("error saving refresh token: %w", err)
+				}
+				return rotationRotated, nil
+			}
+		}
+
+		// Tokens revoked without being rotated, on logout, on a password
+		// reset or on their first use after expiring, were never handed out
+		// again, so presenting them is no sign of theft
+		rotated, err := repo.HasChildren(refreshToken.ID)
+		if err != nil {
+			return 0, fmt.Errorf("error checking refresh token rotation: %w", err)
+		}
+		if !rotated {
+			if time.Now().After(refreshToken.ExpiresAt) {
+				return rotationExpired, nil
+			}
+			return rotationRevoked, nil
+		}
+
+		// Otherwise a rotated token being presented again means someone is
+		// replaying it. We cannot tell the legitimate client from an attacker,
+		// so the whole family is revoked.
+		if err := repo.RevokeFamily(refreshToken.FamilyID); err != nil {
+			return 0, fmt.Errorf("error revoking token family: %w", err)
+		}
+		return rotationReused, nil
+	}
+
+	// Check if token is expired
+	if time.Now().After(refreshToken.ExpiresAt) {
+		if _, err := repo.RevokeIfActive(refreshToken.ID); err != nil {
+			return 0, fmt.Errorf("error revoking expired refresh token: %w", err)
+		}
+		return rotationExpired, nil
+	}
+
+	// Revoke old refresh token, making sure no concurrent refresh beat us to it
+	revoked, err := repo.RevokeIfActive(refreshToken.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error revoking old refresh token: %w", err)
+	}
+	if !revoked {
+		return rotationConflict, nil
+	}
+
+	if err := repo.Create(newRefreshToken); err != nil {
+		return 0, fmt.Errorf("error saving refresh token: %w", err)
+	}
+
+	return rotationRotated, nil
+}
+
+func (s *AuthService) withinReuseGrace(token *model.RefreshToken) bool {
+	if s.refreshReuseGrace <= 0 || token.RevokedAt == nil {
+		return false
+	}
+	return time.Since(*token.RevokedAt) <= s.refreshReuseGrace
+}
+
+// Logout ends the session the refresh token belongs to by revoking its family
 func (s *AuthService) Logout(refreshToken string) error {
-	tokenHash := utils.HashRefreshToken(refreshToken)
-	return s.tokenRepo.RevokeToken(tokenHash)
+	token, err := s.tokenRepo.GetByTokenHashWithRevoked(utils.TokenHashes(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("error getting refresh token: %w", err)
+	}
+
+	if err := s.tokenRepo.RevokeFamily(token.FamilyID); err != nil {
+		return err
+	}
+	return s.revoker.RevokeSessions(token.FamilyID)
 }
@@ -1,10 +1,19 @@
 package service
 
 import (
+	"authorization/internal/constants"
 	"authorization/internal/dto"
 	"authorization/internal/model"
+	"authorization/internal/notify"
 	"authorization/internal/store"
 	"authorization/internal/utils"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,7 +28,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// Auto-migrate the schema
-	if err := db.AutoMigrate(&model.User{}, &model.RefreshToken{}); err != nil {
+	if err := db.AutoMigrate(&model.User{}, &model.RefreshToken{}, &model.SigningKey{}, &model.Permission{}, &model.Role{}, &model.UserRole{}, &model.RelationTuple{}, &model.RelationTupleRevision{}, &model.Policy{}, &model.Organization{}, &model.OrganizationMember{}, &model.OrganizationMemberRole{}, &model.PasswordResetToken{}, &model.EmailVerificationToken{}, &model.UserMFA{}, &model.MFARecoveryCode{}, &model.WebAuthnCredential{}, &model.WebAuthnChallenge{}, &model.LoginThrottle{}, &model.PasswordHistory{}, &model.AccessTokenDenial{}, &model.MFAChallenge{}); err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
@@ -118,6 +127,89 @@ func TestAuthService_Register_DuplicateUsername(t *testing.T) {
 	}
 }
 
+func TestAuthService_Register_EnumerationSafe(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+	verifyService := NewEmailVerificationService(userRepo, store.NewEmailVerificationRepository(db), notifier, 24*time.Hour, time.Minute, "https://app.example.com/verify")
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager,
+		WithEmailVerification(verifyService, true),
+		WithEnumerationSafeRegistration(notifier),
+	)
+
+	register := func(username, email string) *dto.RegisterResponse {
+		t.Helper()
+		resp, err := authService.Register(&dto.RegisterRequest{Username: username, Email: email, Password: "password123"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.Message != constants.MsgRegisterPending || resp.User != nil {
+			t.Errorf("Expected only %q, got %+v", constants.MsgRegisterPending, resp)
+		}
+		return resp
+	}
+
+	// Execute and assert: a new account gets a verification link
+	register("testuser", "test@example.com")
+	if len(notifier.messages) != 1 || notifier.messages[0].Kind != notify.KindEmailVerification {
+		t.Fatalf("Expected a verification link, got %+v", notifier.messages)
+	}
+	user, err := userRepo.GetByUsername("testuser")
+	if err != nil {
+		t.Fatalf("Expected the account to be created: %v", err)
+	}
+
+	// A taken username is reported to the email it was registered with
+	register("testuser", "other@example.com")
+	if msg := notifier.messages[1]; msg.Kind != notify.KindUsernameTaken || msg.Email != "other@example.com" || msg.UserID != "" {
+		t.Errorf("Expected a username taken notice to the new email, got %+v", msg)
+	}
+
+	// A taken email is reported to the account that has it
+	register("otheruser", "test@example.com")
+	if msg := notifier.messages[2]; msg.Kind != notify.KindAccountExists || msg.UserID != user.ID || msg.Email != "test@example.com" {
+		t.Errorf("Expected an account exists notice to the owner, got %+v", msg)
+	}
+
+	var count int64
+	db.Model(&model.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected clashing registrations to create nothing, got %d users", count)
+	}
+}
+
+func TestAuthService_Register_EnumerationSafeTiming(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+	verifyService := NewEmailVerificationService(userRepo, store.NewEmailVerificationRepository(db), notifier, 24*time.Hour, time.Minute, "https://app.example.com/verify")
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager,
+		WithEmailVerification(verifyService, true),
+		WithEnumerationSafeRegistration(notifier),
+	)
+	if _, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// Execute and assert: registering a new account takes as long as
+	// clashing with an existing one
+	n := 0
+	assertSimilarTiming(t, "new account", func() {
+		n++
+		authService.Register(&dto.RegisterRequest{Username: fmt.Sprintf("user%d", n), Email: fmt.Sprintf("user%d@example.com", n), Password: "password123"})
+	}, "existing account", func() {
+		authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "password123"})
+	})
+}
+
 func TestAuthService_Login_Success(t *testing.T) {
 	// Setup
 	db := setupTestDB(t)
@@ -217,6 +309,175 @@ func TestAuthService_Login_WrongPassword(t *testing.T) {
 	}
 }
 
+func TestAuthService_Login_UpgradesPasswordHash(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	t.Cleanup(func() { utils.SetPasswordParams(utils.DefaultPasswordParams) })
+
+	// Hash the password with weaker parameters than the current ones
+	if err := utils.SetPasswordParams(utils.PasswordParams{Memory: 1024, Time: 1, Threads: 1}); err != nil {
+		t.Fatalf("Failed to set password params: %v", err)
+	}
+	resp, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	utils.SetPasswordParams(utils.DefaultPasswordParams)
+
+	// Execute: a wrong password does not upgrade the hash, the right one does
+	authService.Login(&dto.LoginRequest{Username: "testuser", Password: "wrongpassword"})
+	authService.WaitForRehashes()
+	user, _ := userRepo.GetByID(resp.User.ID)
+	if !strings.Contains(user.PasswordHash, "m=1024,t=1,p=1") {
+		t.Fatalf("Expected a failed login to keep the hash, got %s", user.PasswordHash)
+	}
+
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	authService.WaitForRehashes()
+
+	// Assert
+	user, _ = userRepo.GetByID(resp.User.ID)
+	if utils.PasswordNeedsRehash(user.PasswordHash) || !strings.Contains(user.PasswordHash, "m=65536,t=1,p=4") {
+		t.Errorf("Expected the hash to use the current params, got %s", user.PasswordHash)
+	}
+	if user.PasswordChangedAt != nil {
+		t.Error("Expected a rehash not to count as a password change")
+	}
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"}); err != nil {
+		t.Errorf("Expected the upgraded hash to work, got %v", err)
+	}
+}
+
+func TestAuthService_Login_RotatesPepper(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	t.Cleanup(func() { utils.SetPasswordPeppers(nil) })
+
+	peppersFile := filepath.Join(t.TempDir(), "peppers")
+	content := "1:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32))) + "\n" +
+		"2:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32))) + "\n"
+	if err := os.WriteFile(peppersFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write pepper file: %v", err)
+	}
+	usePeppers := func(active int) {
+		peppers, err := utils.LoadPasswordPeppers(peppersFile, active)
+		if err != nil {
+			t.Fatalf("Failed to load peppers: %v", err)
+		}
+		utils.SetPasswordPeppers(peppers)
+	}
+
+	usePeppers(1)
+	resp, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// Execute: rotate to the second pepper and log in
+	usePeppers(2)
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"}); err != nil {
+		t.Fatalf("Expected the hash with the old pepper to work, got %v", err)
+	}
+	authService.WaitForRehashes()
+
+	// Assert
+	user, _ := userRepo.GetByID(resp.User.ID)
+	if !strings.Contains(user.PasswordHash, ",pepper=2$") {
+		t.Errorf("Expected the hash to move to the active pepper, got %s", user.PasswordHash)
+	}
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"}); err != nil {
+		t.Errorf("Expected the rehashed password to work, got %v", err)
+	}
+}
+
+func TestAuthService_RefreshToken_RotatesTokenHashKey(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	t.Cleanup(func() { utils.SetTokenHashKeys(nil) })
+
+	keysFile := filepath.Join(t.TempDir(), "token_hash_keys")
+	useKeys := func(versions ...int) {
+		content := ""
+		for _, version := range versions {
+			content += fmt.Sprintf("%d:%s\n", version, base64.StdEncoding.EncodeToString([]byte(strings.Repeat(fmt.Sprint(version), 32))))
+		}
+		if err := os.WriteFile(keysFile, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write key file: %v", err)
+		}
+		keys, err := utils.LoadTokenHashKeys(keysFile, 0)
+		if err != nil {
+			t.Fatalf("Failed to load token hash keys: %v", err)
+		}
+		keys.AcceptLegacy = true
+		utils.SetTokenHashKeys(keys)
+	}
+	storedHash := func(token string) string {
+		stored, err := tokenRepo.GetByTokenHashWithRevoked(utils.TokenHashes(token))
+		if err != nil {
+			t.Fatalf("Failed to find token: %v", err)
+		}
+		return stored.TokenHash
+	}
+
+	if _, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	// Execute: tokens from before keys were set still refresh, and the used
+	// one moves to the key
+	useKeys(1)
+	first, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: loginResp.RefreshToken})
+	if err != nil {
+		t.Fatalf("Expected the bare hashed token to refresh, got %v", err)
+	}
+	if hash := storedHash(loginResp.RefreshToken); !strings.HasPrefix(hash, "v1$") {
+		t.Errorf("Expected the used token to be rekeyed, got %s", hash)
+	}
+	if hash := storedHash(first.RefreshToken); !strings.HasPrefix(hash, "v1$") {
+		t.Errorf("Expected the new token to be keyed, got %s", hash)
+	}
+
+	useKeys(1, 2)
+	second, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("Expected the token under the old key to refresh, got %v", err)
+	}
+
+	// Assert: once the old key is dropped, rotated tokens rekeyed on use are
+	// still recognised as replays and the others are unknown
+	useKeys(2)
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: loginResp.RefreshToken}); err == nil || err.Error() != constants.MsgInvalidToken {
+		t.Errorf("Expected a token only under a dropped key to be unknown, got %v", err)
+	}
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: first.RefreshToken}); err == nil || err.Error() != constants.MsgTokenRevoked {
+		t.Errorf("Expected the replayed token to be recognised, got %v", err)
+	}
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: second.RefreshToken}); err == nil || err.Error() != constants.MsgInvalidToken {
+		t.Errorf("Expected the replay to revoke the session, got %v", err)
+	}
+}
+
 func TestAuthService_Login_NonexistentUser(t *testing.T) {
 	// Setup
 	db := setupTestDB(t)
@@ -245,7 +506,275 @@ func TestAuthService_Login_NonexistentUser(t *testing.T) {
 	}
 }
 
+func TestAuthService_Login_NonexistentUserTiming(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	if _, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// Execute and assert: an unknown username takes as long to reject as a
+	// wrong password
+	assertSimilarTiming(t, "wrong password", func() {
+		authService.Login(&dto.LoginRequest{Username: "testuser", Password: "wrongpassword"})
+	}, "unknown user", func() {
+		authService.Login(&dto.LoginRequest{Username: "nonexistent", Password: "wrongpassword"})
+	})
+}
+
+func TestAuthService_RefreshToken_RotatesWithinFamily(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+
+	_, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	// Execute
+	refreshResp, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: loginResp.RefreshToken})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if refreshResp.RefreshToken == loginResp.RefreshToken {
+		t.Error("Expected a new refresh token to be issued")
+	}
+
+	root, err := tokenRepo.GetByTokenHashWithRevoked(utils.TokenHashes(loginResp.RefreshToken))
+	if err != nil {
+		t.Fatalf("Failed to load original token: %v", err)
+	}
+
+	child, err := tokenRepo.GetByTokenHash(utils.TokenHashes(refreshResp.RefreshToken))
+	if err != nil {
+		t.Fatalf("Failed to load rotated token: %v", err)
+	}
+
+	if !root.Revoked || root.RevokedAt == nil {
+		t.Error("Expected original token to be revoked")
+	}
+
+	if root.FamilyID != root.ID {
+		t.Errorf("Expected login token to start its own family, got family %s", root.FamilyID)
+	}
+
+	if child.FamilyID != root.FamilyID {
+		t.Errorf("Expected rotated token in family %s, got %s", root.FamilyID, child.FamilyID)
+	}
+
+	if child.ParentID == nil || *child.ParentID != root.ID {
+		t.Errorf("Expected rotated token parent to be %s", root.ID)
+	}
+}
+
+func TestAuthService_RefreshToken_ReuseRevokesFamily(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+
+	_, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	// A second, unrelated session must survive the reuse
+	otherResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	refreshResp, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: loginResp.RefreshToken})
+	if err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+
+	// Execute: replay the already rotated token
+	_, err = authService.RefreshToken(&dto.RefreshRequest{RefreshToken: loginResp.RefreshToken})
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected error for reused refresh token, got nil")
+	}
+
+	if !contains(err.Error(), "revoked") {
+		t.Errorf("Expected revoked token error, got %v", err)
+	}
+
+	// The descendant issued by the legitimate rotation must be revoked too
+	_, err = authService.RefreshToken(&dto.RefreshRequest{RefreshToken: refreshResp.RefreshToken})
+	if err == nil {
+		t.Fatal("Expected descendant token to be revoked after reuse")
+	}
+
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: otherResp.RefreshToken}); err != nil {
+		t.Errorf("Expected other session to remain valid, got %v", err)
+	}
+}
+
+func TestAuthService_RefreshToken_RevokedIsNotReuse(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+
+	_, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	expiredResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	loggedOutResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	db.Model(&model.RefreshToken{}).
+		Where("token_hash = ?", utils.HashToken(expiredResp.RefreshToken)).
+		Update("expires_at", time.Now().Add(-time.Minute))
+	if err := authService.Logout(loggedOutResp.RefreshToken); err != nil {
+		t.Fatalf("Failed to logout: %v", err)
+	}
+
+	// Execute: an expired token, presented twice, and a logged out one
+	for i := 0; i < 2; i++ {
+		if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: expiredResp.RefreshToken}); err == nil || err.Error() != constants.MsgTokenExpired {
+			t.Errorf("Expected the expired token to be reported as expired, got %v", err)
+		}
+	}
+	_, err = authService.RefreshToken(&dto.RefreshRequest{RefreshToken: loggedOutResp.RefreshToken})
+
+	// Assert: neither is taken for a replay
+	if err == nil || err.Error() != constants.MsgInvalidToken {
+		t.Errorf("Expected the logged out token to be invalid, got %v", err)
+	}
+}
+
 // Helper functions
+// timingSamples is how often each case of a timing test is measured
+const timingSamples = 40
+
+// timingTolerance is how much slower one case may be than the other
+const timingTolerance = 1.25
+
+// timingCriticalZ is the one-sided critical value of the Mann-Whitney
+// statistic at a significance level of 0.001
+const timingCriticalZ = 3.09
+
+// assertSimilarTiming measures the two cases in turns and fails if either
+// is significantly faster than the other even after being slowed down by
+// timingTolerance, by a Mann-Whitney U test. Both are dominated by argon2, so
+// a case that skips the hash is several times faster, while noise from a busy
+// machine slows both cases alike and cannot fail the test on its own.
+func assertSimilarTiming(t *testing.T, nameA string, a func(), nameB string, b func()) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping timing test in short mode")
+	}
+
+	// Warm up, so that one-off work such as the dummy hash is not measured
+	a()
+	b()
+
+	durationsA := make([]float64, timingSamples)
+	durationsB := make([]float64, timingSamples)
+	for i := 0; i < timingSamples; i++ {
+		start := time.Now()
+		a()
+		durationsA[i] = float64(time.Since(start))
+
+		start = time.Now()
+		b()
+		durationsB[i] = float64(time.Since(start))
+	}
+
+	if z := mannWhitneyZ(scaleDurations(durationsA, timingTolerance), durationsB); z > timingCriticalZ {
+		t.Errorf("Expected %s (median %v) to take as long as %s (median %v), it is faster", nameA, medianDuration(durationsA), nameB, medianDuration(durationsB))
+	}
+	if z := mannWhitneyZ(scaleDurations(durationsB, timingTolerance), durationsA); z > timingCriticalZ {
+		t.Errorf("Expected %s (median %v) to take as long as %s (median %v), it is faster", nameB, medianDuration(durationsB), nameA, medianDuration(durationsA))
+	}
+}
+
+// mannWhitneyZ returns the normal approximation of the Mann-Whitney U
+// statistic, which is large when values from a tend to be smaller than
+// values from b
+func mannWhitneyZ(a, b []float64) float64 {
+	var u float64
+	for _, x := range a {
+		for _, y := range b {
+			switch {
+			case x < y:
+				u++
+			case x == y:
+				u += 0.5
+			}
+		}
+	}
+
+	n1, n2 := float64(len(a)), float64(len(b))
+	mean := n1 * n2 / 2
+	stddev := math.Sqrt(n1 * n2 * (n1 + n2 + 1) / 12)
+	return (u - mean) / stddev
+}
+
+func scaleDurations(durations []float64, factor float64) []float64 {
+	scaled := make([]float64, len(durations))
+	for i, d := range durations {
+		scaled[i] = d * factor
+	}
+	return scaled
+}
+
+func medianDuration(durations []float64) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return time.Duration(sorted[len(sorted)/2])
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 || s[0:len(substr)] == substr || s[len(s)-len(substr):] == substr || containsSubstring(s, substr))
 }
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"authorization/internal/authz"
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/store"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// roleStore feeds the permission evaluator from the role tables
+type roleStore struct {
+	roleRepo *store.RoleRepository
+}
+
+func (s roleStore) Roles() ([]authz.RoleDefinition, error) {
+	roles, err := s.roleRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing roles: %w", err)
+	}
+	return roleDefinitions(roles), nil
+}
+
+func (s roleStore) UserRoles(userID string) ([]string, error) {
+	names, err := s.roleRepo.GetUserRoleNames(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user roles: %w", err)
+	}
+	return names, nil
+}
+
+// NewAuthzEvaluator creates a permission evaluator backed by the role tables,
+// caching resolved permissions for cacheTTL
+func NewAuthzEvaluator(roleRepo *store.RoleRepository, cacheTTL time.Duration) *authz.Evaluator {
+	return authz.NewEvaluator(roleStore{roleRepo: roleRepo}, cacheTTL)
+}
+
+// AuthzService answers permission checks for other services
+type AuthzService struct {
+	evaluator *authz.Evaluator
+	userRepo  *store.UserRepository
+}
+
+func NewAuthzService(evaluator *authz.Evaluator, userRepo *store.UserRepository) *AuthzService {
+	return &AuthzService{
+		evaluator: evaluator,
+		userRepo:  userRepo,
+	}
+}
+
+// Check decides whether the user in req holds the permission. Users may
+// always check themselves; checking anyone else requires authz:check.
+func (s *AuthzService) Check(callerID string, req *dto.AuthzCheckRequest) (*dto.AuthzCheckResponse, error) {
+	userID := req.UserID
+	if userID == "" {
+		userID = callerID
+	}
+
+	if userID != callerID {
+		decision, err := s.evaluator.Check(callerID, constants.PermAuthzCheck)
+		if err != nil {
+			return nil, fmt.Errorf("error checking caller permissions: %w", err)
+		}
+		if !decision.Allowed {
+			return nil, fmt.Errorf(constants.MsgForbidden)
+		}
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	decision, err := s.evaluator.Check(userID, req.Permission)
+	if err != nil {
+		return nil, fmt.Errorf("error checking permission: %w", err)
+	}
+
+	return &dto.AuthzCheckResponse{
+		UserID:     userID,
+		Permission: req.Permission,
+		Allowed:    decision.Allowed,
+		Role:       decision.Role,
+		Grant:      decision.Grant,
+	}, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/notify"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// EmailVerificationService proves users own their email by sending them a
+// link. Tokens are random, stored hashed like refresh tokens, expire after
+// tokenExp and work once. Links are sent at most once per resendInterval to
+// the same user.
+type EmailVerificationService struct {
+	userRepo       *store.UserRepository
+	verifyRepo     *store.EmailVerificationRepository
+	notifier       notify.Notifier
+	tokenExp       time.Duration
+	resendInterval time.Duration
+	verifyURL      string
+}
+
+// NewEmailVerificationService creates the service. Verification links point
+// at verifyURL with the token in the "token" query parameter.
+func NewEmailVerificationService(userRepo *store.UserRepository, verifyRepo *store.EmailVerificationRepository, notifier notify.Notifier, tokenExp, resendInterval time.Duration, verifyURL string) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:       userRepo,
+		verifyRepo:     verifyRepo,
+		notifier:       notifier,
+		tokenExp:       tokenExp,
+		resendInterval: resendInterval,
+		verifyURL:      verifyURL,
+	}
+}
+
+// SendVerification sends a verification link to the user, retiring the
+// links sent before
+func (s *EmailVerificationService) SendVerification(user *model.User) error {
+	var (
+		tokenStr string
+		token    *model.EmailVerificationToken
+	)
+	err := s.verifyRepo.Transaction(func(repo *store.EmailVerificationRepository) error {
+		var err error
+		tokenStr, token, err = s.issueToken(repo, user.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return s.sendLink(user, tokenStr, token)
+}
+
+// ResendVerification sends a new link to the unverified account with the
+// given email. Unknown emails, verified accounts and requests within the
+// resend interval get the same response without a link being sent, so the
+// response reveals nothing about the account.
+func (s *EmailVerificationService) ResendVerification(req *dto.ResendVerificationRequest) (*dto.ResendVerificationResponse, error) {
+	resp := &dto.ResendVerificationResponse{Message: constants.MsgVerifyLinkSent}
+
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Debug("Verification resend requested for unknown email")
+			return resp, nil
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return resp, nil
+	}
+
+	// The user stays locked from the throttle check until the new token is
+	// stored, so concurrent requests cannot all pass the check
+	var (
+		throttled bool
+		tokenStr  string
+		token     *model.EmailVerificationToken
+	)
+	err = s.verifyRepo.Transaction(func(repo *store.EmailVerificationRepository) error {
+		if err := repo.LockUser(user.ID); err != nil {
+			return fmt.Errorf("error locking user: %w", err)
+		}
+
+		lastIssuedAt, err := repo.LastIssuedAt(user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting verification tokens: %w", err)
+		}
+		if lastIssuedAt != nil && time.Since(*lastIssuedAt) < s.resendInterval {
+			throttled = true
+			return nil
+		}
+
+		tokenStr, token, err = s.issueToken(repo, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if throttled {
+		logger.Debug("Verification resend throttled", zap.String("user_id", user.ID))
+		return resp, nil
+	}
+
+	// Delivery failures are logged so the response stays the same
+	if err := s.sendLink(user, tokenStr, token); err != nil {
+		logger.Error("Failed to resend verification link", zap.Error(err), zap.String("user_id", user.ID))
+	}
+	return resp, nil
+}
+
+// issueToken stores a new verification token for the user, retiring the
+// earlier ones, and returns it with the token string to send
+func (s *EmailVerificationService) issueToken(repo *store.EmailVerificationRepository, userID string) (string, *model.EmailVerificationToken, error) {
+	tokenStr, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("error generating verification token: %w", err)
+	}
+
+	token := &model.EmailVerificationToken{
+		ID:        utils.GenerateUUIDv7(),
+		UserID:    userID,
+		TokenHash: utils.HashToken(tokenStr),
+		ExpiresAt: time.Now().Add(s.tokenExp),
+	}
+	if err := repo.InvalidateUserTokens(userID); err != nil {
+		return "", nil, fmt.Errorf("error saving verification token: %w", err)
+	}
+	if err := repo.Create(token); err != nil {
+		return "", nil, fmt.Errorf("error saving verification token: %w", err)
+	}
+	return tokenStr, token, nil
+}
+
+// sendLink sends the user the link of a verification token
+func (s *EmailVerificationService) sendLink(user *model.User, tokenStr string, token *model.EmailVerificationToken) error {
+	link, err := tokenLink(s.verifyURL, tokenStr)
+	if err != nil {
+		return err
+	}
+
+	err = s.notifier.Notify(&notify.Message{
+		Kind:      notify.KindEmailVerification,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Link:      link,
+		ExpiresAt: token.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("error sending verification link: %w", err)
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of the token's user as verified
+func (s *EmailVerificationService) VerifyEmail(req *dto.VerifyEmailRequest) (*dto.VerifyEmailResponse, error) {
+	token, err := s.verifyRepo.Consume(utils.TokenHashes(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgInvalidVerifyToken)
+		}
+		return nil, fmt.Errorf("error using verification token: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgInvalidVerifyToken)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	verified, err := s.userRepo.SetEmailVerifiedAt(user.ID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error verifying email: %w", err)
+	}
+	if verified {
+		logger.SecurityEvent(constants.EventEmailVerified, zap.String("user_id", user.ID))
+	}
+
+	if err := s.verifyRepo.InvalidateUserTokens(user.ID); err != nil {
+		return nil, fmt.Errorf("error invalidating verification tokens: %w", err)
+	}
+
+	return &dto.VerifyEmailResponse{Message: constants.MsgEmailVerified}, nil
+}
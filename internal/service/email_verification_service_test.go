@@ -0,0 +1,209 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/notify"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestEmailVerificationService_RequiredForLogin(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	verifyRepo := store.NewEmailVerificationRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+
+	verifyService := NewEmailVerificationService(userRepo, verifyRepo, notifier, 24*time.Hour, time.Minute, "https://app.example.com/verify")
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithEmailVerification(verifyService, true))
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if registerResp.User.EmailVerified {
+		t.Error("Expected a new user to be unverified")
+	}
+
+	// Registration sends a link
+	if len(notifier.messages) != 1 || notifier.messages[0].Kind != notify.KindEmailVerification {
+		t.Fatalf("Expected one verification message, got %+v", notifier.messages)
+	}
+	token := notifier.lastToken(t)
+
+	// Unverified users cannot log in, even with the right password
+	_, err = authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err == nil || err.Error() != constants.MsgEmailNotVerified {
+		t.Fatalf("Expected %q, got %v", constants.MsgEmailNotVerified, err)
+	}
+	_, err = authService.Login(&dto.LoginRequest{Username: "testuser", Password: "wrong-password"})
+	if err == nil || err.Error() != constants.MsgInvalidCredentials {
+		t.Errorf("Expected %q for a wrong password, got %v", constants.MsgInvalidCredentials, err)
+	}
+
+	if _, err := verifyService.VerifyEmail(&dto.VerifyEmailRequest{Token: token}); err != nil {
+		t.Fatalf("Failed to verify email: %v", err)
+	}
+
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected a verified user to log in, got %v", err)
+	}
+	if !loginResp.User.EmailVerified {
+		t.Error("Expected the user to be verified")
+	}
+
+	// The token works once
+	_, err = verifyService.VerifyEmail(&dto.VerifyEmailRequest{Token: token})
+	if err == nil || err.Error() != constants.MsgInvalidVerifyToken {
+		t.Errorf("Expected %q when reusing the token, got %v", constants.MsgInvalidVerifyToken, err)
+	}
+}
+
+func TestEmailVerificationService_Resend(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	verifyRepo := store.NewEmailVerificationRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+
+	verifyService := NewEmailVerificationService(userRepo, verifyRepo, notifier, 24*time.Hour, time.Minute, "https://app.example.com/verify")
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithEmailVerification(verifyService, false))
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	first := notifier.lastToken(t)
+
+	// Verification is not required, so unverified users may log in
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"}); err != nil {
+		t.Errorf("Expected login without verification, got %v", err)
+	}
+
+	// Resending right away is throttled, unknown emails are ignored, and
+	// both look the same as a sent link
+	throttled, err := verifyService.ResendVerification(&dto.ResendVerificationRequest{Email: "test@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to resend: %v", err)
+	}
+	unknown, err := verifyService.ResendVerification(&dto.ResendVerificationRequest{Email: "nobody@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to resend: %v", err)
+	}
+	if *throttled != *unknown {
+		t.Errorf("Expected the same response, got %+v and %+v", throttled, unknown)
+	}
+	if len(notifier.messages) != 1 {
+		t.Fatalf("Expected no further messages, got %d", len(notifier.messages))
+	}
+
+	// Once the interval has passed a new link replaces the first one
+	if err := db.Model(&model.EmailVerificationToken{}).
+		Where("user_id = ?", registerResp.User.ID).
+		Update("created_at", time.Now().Add(-2*time.Minute)).Error; err != nil {
+		t.Fatalf("Failed to age token: %v", err)
+	}
+	if _, err := verifyService.ResendVerification(&dto.ResendVerificationRequest{Email: "test@example.com"}); err != nil {
+		t.Fatalf("Failed to resend: %v", err)
+	}
+	if len(notifier.messages) != 2 {
+		t.Fatalf("Expected a second message, got %d", len(notifier.messages))
+	}
+	second := notifier.lastToken(t)
+
+	_, err = verifyService.VerifyEmail(&dto.VerifyEmailRequest{Token: first})
+	if err == nil || err.Error() != constants.MsgInvalidVerifyToken {
+		t.Errorf("Expected a superseded token to be rejected, got %v", err)
+	}
+	if _, err := verifyService.VerifyEmail(&dto.VerifyEmailRequest{Token: second}); err != nil {
+		t.Fatalf("Failed to verify email: %v", err)
+	}
+
+	// Verified accounts are not sent links
+	if err := db.Model(&model.EmailVerificationToken{}).
+		Where("user_id = ?", registerResp.User.ID).
+		Update("created_at", time.Now().Add(-2*time.Minute)).Error; err != nil {
+		t.Fatalf("Failed to age token: %v", err)
+	}
+	if _, err := verifyService.ResendVerification(&dto.ResendVerificationRequest{Email: "test@example.com"}); err != nil {
+		t.Fatalf("Failed to resend: %v", err)
+	}
+	if len(notifier.messages) != 2 {
+		t.Errorf("Expected no message for a verified account, got %d", len(notifier.messages))
+	}
+}
+
+func TestEmailVerificationService_VerifyKeepsConcurrentChanges(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	verifyRepo := store.NewEmailVerificationRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+
+	verifyService := NewEmailVerificationService(userRepo, verifyRepo, notifier, 24*time.Hour, time.Minute, "https://app.example.com/verify")
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithEmailVerification(verifyService, true))
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := registerResp.User.ID
+
+	// The password is changed and the user disabled right after the
+	// verification read the user
+	changed := false
+	err = db.Callback().Query().After("gorm:query").Register("test:concurrent_change", func(tx *gorm.DB) {
+		if changed || tx.Statement.Table != "users" {
+			return
+		}
+		changed = true
+		db.Model(&model.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"password_hash": "changed", "disabled_at": time.Now()})
+	})
+	if err != nil {
+		t.Fatalf("Failed to register callback: %v", err)
+	}
+
+	// Execute
+	if _, err := verifyService.VerifyEmail(&dto.VerifyEmailRequest{Token: notifier.lastToken(t)}); err != nil {
+		t.Fatalf("Failed to verify email: %v", err)
+	}
+
+	// Assert
+	var user model.User
+	db.First(&user, "id = ?", userID)
+	if !changed {
+		t.Fatal("Expected the user to be changed during the verification")
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("Expected the email to be verified")
+	}
+	if user.PasswordHash != "changed" || user.DisabledAt == nil {
+		t.Error("Expected the verification to keep the concurrent changes")
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"authorization/internal/model"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// clockSkewLeeway keeps a rotated key verifiable a little longer than the
+// longest token it signed, to tolerate clock differences between services
+const clockSkewLeeway = time.Minute
+
+// reloadThrottle bounds how often unknown kids may trigger a reload
+const reloadThrottle = time.Minute
+
+// KeyService keeps the JWT signing keyring in the database so every replica
+// signs with the same key, and rotates it on a schedule
+type KeyService struct {
+	keyRepo            *store.SigningKeyRepository
+	secretBox          *utils.SecretBox
+	algorithm          string
+	rotationInterval   time.Duration
+	syncInterval       time.Duration
+	publishPeriod      time.Duration
+	verificationPeriod time.Duration
+	keyring            *utils.Keyring
+}
+
+// NewKeyService creates a key service generating algorithm keys. Keys are
+// rotated every rotationInterval (0 disables scheduled rotation). Replicas
+// reload the keyring every syncInterval, so a new key is published that long
+// plus the JWKS max-age before it starts signing, and every replica and
+// verifier knows it by then. Keys remain verifiable for maxTokenLifetime
+// after the last replica stopped signing with them.
+func NewKeyService(keyRepo *store.SigningKeyRepository, secretBox *utils.SecretBox, algorithm string, rotationInterval, syncInterval, maxTokenLifetime time.Duration) *KeyService {
+	return &KeyService{
+		keyRepo:            keyRepo,
+		secretBox:          secretBox,
+		algorithm:          algorithm,
+		rotationInterval:   rotationInterval,
+		syncInterval:       syncInterval,
+		publishPeriod:      syncInterval + utils.JWKSMaxAge,
+		verificationPeriod: syncInterval + maxTokenLifetime + clockSkewLeeway,
+	}
+}
+
+// Keyring returns the keyring kept in sync with the database. Sync must have
+// been called at least once.
+func (s *KeyService) Keyring() *utils.Keyring {
+	return s.keyring
+}
+
+// Sync loads the usable keys from the database into the keyring, creating
+// the first signing key if there is none yet
+func (s *KeyService) Sync() error {
+	return s.load(true)
+}
+
+// reload loads the keyring for a token with an unknown kid. It never creates
+// a key: tokens are no reason to write to the database.
+func (s *KeyService) reload() error {
+	return s.load(false)
+}
+
+func (s *KeyService) load(bootstrap bool) error {
+	keys, err := s.keyRepo.GetUsable()
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+
+	var (
+		active           *utils.SigningKey
+		verificationKeys []*utils.SigningKey
+	)
+	for _, record := range keys {
+		key, err := s.decode(record)
+		if err != nil {
+			return fmt.Errorf("error decoding signing key %s: %w", record.ID, err)
+		}
+		if record.Status == model.SigningKeyActive {
+			active = key
+		} else {
+			verificationKeys = append(verificationKeys, key)
+		}
+	}
+
+	if active == nil {
+		if !bootstrap {
+			return errors.New("no active signing key")
+		}
+		if _, err := s.rotate(true); err != nil {
+			return err
+		}
+		return s.load(false)
+	}
+
+	if s.keyring == nil {
+		s.keyring = utils.NewKeyring(active, verificationKeys...)
+		s.keyring.SetReloader(s.reload, reloadThrottle)
+	} else {
+		s.keyring.Replace(active, verificationKeys)
+	}
+
+	return nil
+}
+
+// Rotate publishes a new key, which replaces the active key once it has been
+// published for long enough, or returns the key already waiting to
+func (s *KeyService) Rotate() (*model.SigningKey, error) {
+	record, err := s.rotate(true)
+	if err != nil {
+		return nil, err
+	}
+	return record, s.Sync()
+}
+
+// RotateIfDue promotes the published key once it has been published for long
+// enough, and otherwise publishes a new one once the active key is older
+// than the rotation interval. It returns the key it promoted or published.
+func (s *KeyService) RotateIfDue() (*model.SigningKey, error) {
+	record, err := s.rotate(false)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	return record, s.Sync()
+}
+
+// SigningFrom returns when a published key replaces the active key
+func (s *KeyService) SigningFrom(record *model.SigningKey) time.Time {
+	return record.CreatedAt.Add(s.publishPeriod)
+}
+
+// RetireExpired retires keys whose tokens have all expired
+func (s *KeyService) RetireExpired() (int64, error) {
+	retired, err := s.keyRepo.RetireExpired(time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("error retiring signing keys: %w", err)
+	}
+	if retired > 0 && s.keyring != nil {
+		return retired, s.Sync()
+	}
+	return retired, nil
+}
+
+// ListKeys returns every key, including retired ones
+func (s *KeyService) ListKeys() ([]*model.SigningKey, error) {
+	return s.keyRepo.List()
+}
+
+// Run performs scheduled rotation, retirement and syncing until ctx is done
+func (s *KeyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if record, err := s.RotateIfDue(); err != nil {
+				logger.Error("Scheduled signing key rotation failed", zap.Error(err))
+			} else if record != nil && record.Status == model.SigningKeyActive {
+				logger.Info("Signing key rotated", zap.String("kid", record.ID))
+			} else if record != nil {
+				logger.Info("Signing key published", zap.String("kid", record.ID), zap.Time("signing_from", s.SigningFrom(record)))
+			}
+
+			if retired, err := s.RetireExpired(); err != nil {
+				logger.Error("Signing key retirement failed", zap.Error(err))
+			} else if retired > 0 {
+				logger.Info("Signing keys retired", zap.Int64("count", retired))
+			}
+
+			if err := s.Sync(); err != nil {
+				logger.Error("Signing key sync failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// rotate moves a rotation one step on. Once the published key has been
+// published for long enough it demotes the active key and promotes the
+// published one. Without a published key it publishes a new one, unless
+// force is not set and the active key is younger than the rotation interval.
+// The active row is locked, so replicas rotating at the same time take the
+// same step once. Without an active key there is no row to lock, so
+// replicas starting at the same time may all try to create the first one,
+// which signs at once as nothing was signed before: only one succeeds and
+// the others create nothing.
+func (s *KeyService) rotate(force bool) (*model.SigningKey, error) {
+	record, err := s.generate()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed *model.SigningKey
+	err = s.keyRepo.Transaction(func(repo *store.SigningKeyRepository) error {
+		current, err := repo.GetActiveForUpdate()
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			record.Status = model.SigningKeyActive
+			created, err := repo.CreateFirst(record)
+			if created {
+				changed = record
+			}
+			return err
+		case err != nil:
+			return err
+		}
+
+		published, err := repo.GetPublished()
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !force && (s.rotationInterval <= 0 || time.Since(current.CreatedAt) < s.rotationInterval) {
+				return nil
+			}
+			changed = record
+			return repo.Create(record)
+		case err != nil:
+			return err
+		case time.Now().Before(s.SigningFrom(published)):
+			if force {
+				changed = published
+			}
+			return nil
+		}
+
+		demoted, err := repo.Demote(current.ID, time.Now().Add(s.verificationPeriod))
+		if err != nil {
+			return err
+		}
+		if !demoted {
+			return fmt.Errorf("signing key %s was rotated concurrently", current.ID)
+		}
+		if _, err := repo.Promote(published.ID); err != nil {
+			return err
+		}
+		published.Status = model.SigningKeyActive
+		changed = published
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error rotating signing key: %w", err)
+	}
+	return changed, nil
+}
+
+// generate creates a new key, to be published for verification only
+func (s *KeyService) generate() (*model.SigningKey, error) {
+	key, err := utils.GenerateSigningKey("", s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	material, err := key.MarshalPrivate()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding signing key: %w", err)
+	}
+
+	sealed, err := s.secretBox.Seal(material)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting signing key: %w", err)
+	}
+
+	return &model.SigningKey{
+		ID:         key.ID,
+		Algorithm:  s.algorithm,
+		PrivateKey: sealed,
+		Status:     model.SigningKeyVerify,
+	}, nil
+}
+
+func (s *KeyService) decode(record *model.SigningKey) (*utils.SigningKey, error) {
+	material, err := s.secretBox.Open(record.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ParseSigningKey(record.ID, record.Algorithm, material)
+}
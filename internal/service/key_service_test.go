@@ -0,0 +1,307 @@
+package service
+
+import (
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func newTestKeyService(t *testing.T, keyRepo *store.SigningKeyRepository) *KeyService {
+	secretBox, err := utils.NewSecretBox([]byte(strings.Repeat("k", 32)), "jwt-signing-keys")
+	if err != nil {
+		t.Fatalf("Failed to create secret box: %v", err)
+	}
+
+	keyService := NewKeyService(keyRepo, secretBox, utils.AlgorithmEdDSA, 24*time.Hour, time.Minute, 15*time.Minute)
+	if err := keyService.Sync(); err != nil {
+		t.Fatalf("Failed to sync keyring: %v", err)
+	}
+	return keyService
+}
+
+// publishedLongAgo backdates a published key, as if every replica and
+// verifier had had time to load it
+func publishedLongAgo(db *gorm.DB, kid string) {
+	db.Model(&model.SigningKey{}).Where("id = ?", kid).Update("created_at", time.Now().Add(-time.Hour))
+}
+
+// rotateNow publishes a new key and promotes it straight away
+func rotateNow(t *testing.T, db *gorm.DB, keyService *KeyService) *model.SigningKey {
+	published, err := keyService.Rotate()
+	if err != nil {
+		t.Fatalf("Failed to publish a key: %v", err)
+	}
+	publishedLongAgo(db, published.ID)
+
+	rotated, err := keyService.Rotate()
+	if err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	return rotated
+}
+
+func TestKeyService_RotationKeepsOldTokensValid(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	keyRepo := store.NewSigningKeyRepository(db)
+	keyService := newTestKeyService(t, keyRepo)
+	jwtManager := utils.NewJWTManagerWithKeyring(keyService.Keyring(), 15*time.Minute, 7*24*time.Hour)
+
+	oldToken, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{UserID: "user-1", Username: "testuser", SessionID: "session-1"})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	oldKid := keyService.Keyring().Active().ID
+
+	// Execute: the new key is published first
+	published, err := keyService.Rotate()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if published.Status != model.SigningKeyVerify || keyService.Keyring().Active().ID != oldKid {
+		t.Fatalf("Expected the new key to be published without signing, got %s", published.Status)
+	}
+	if keys := jwtManager.JWKS().Keys; len(keys) != 2 {
+		t.Errorf("Expected the new key to be published, got %d keys", len(keys))
+	}
+	if again, err := keyService.Rotate(); err != nil || again.ID != published.ID {
+		t.Errorf("Expected the published key to be kept until it signs, got %v", err)
+	}
+
+	// It signs once published for long enough
+	publishedLongAgo(db, published.ID)
+	rotated, err := keyService.Rotate()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rotated.ID != published.ID || rotated.ID == oldKid || keyService.Keyring().Active().ID != rotated.ID {
+		t.Fatalf("Expected new active key, got %s (old %s)", keyService.Keyring().Active().ID, oldKid)
+	}
+
+	newToken, _, err := jwtManager.GenerateAccessToken(utils.AccessTokenSubject{UserID: "user-1", Username: "testuser", SessionID: "session-1"})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	if _, err := jwtManager.ValidateAccessToken(oldToken); err != nil {
+		t.Errorf("Expected token signed by the rotated key to stay valid, got %v", err)
+	}
+	if _, err := jwtManager.ValidateAccessToken(newToken); err != nil {
+		t.Errorf("Expected token signed by the new key to be valid, got %v", err)
+	}
+
+	if keys := jwtManager.JWKS().Keys; len(keys) != 2 {
+		t.Errorf("Expected both keys to be published, got %d", len(keys))
+	}
+
+	// Once the old key is past its retirement time it no longer verifies
+	db.Model(&model.SigningKey{}).Where("id = ?", oldKid).Update("retire_after", time.Now().Add(-time.Second))
+
+	retired, err := keyService.RetireExpired()
+	if err != nil {
+		t.Fatalf("Failed to retire keys: %v", err)
+	}
+	if retired != 1 {
+		t.Errorf("Expected 1 retired key, got %d", retired)
+	}
+
+	if _, err := jwtManager.ValidateAccessToken(oldToken); err == nil {
+		t.Error("Expected token signed by a retired key to be rejected")
+	}
+
+	var record model.SigningKey
+	db.First(&record, "id = ?", oldKid)
+	if record.Status != model.SigningKeyRetired || record.PrivateKey != "" {
+		t.Errorf("Expected retired key material to be erased, got status %s", record.Status)
+	}
+}
+
+func TestKeyService_ReplicasAgree(t *testing.T) {
+	// Setup: two replicas sharing one database
+	db := setupTestDB(t)
+	keyRepo := store.NewSigningKeyRepository(db)
+	replicaA := newTestKeyService(t, keyRepo)
+	replicaB := newTestKeyService(t, keyRepo)
+
+	if replicaA.Keyring().Active().ID != replicaB.Keyring().Active().ID {
+		t.Fatal("Expected replicas to share the first signing key")
+	}
+
+	jwtA := utils.NewJWTManagerWithKeyring(replicaA.Keyring(), 15*time.Minute, 7*24*time.Hour)
+	jwtB := utils.NewJWTManagerWithKeyring(replicaB.Keyring(), 15*time.Minute, 7*24*time.Hour)
+
+	// Execute: replica A rotates, replica B has not synced yet
+	rotateNow(t, db, replicaA)
+
+	token, _, err := jwtA.GenerateAccessToken(utils.AccessTokenSubject{UserID: "user-1", Username: "testuser", SessionID: "session-1"})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// Assert: the unknown kid makes replica B reload the keyring
+	if _, err := jwtB.ValidateAccessToken(token); err != nil {
+		t.Fatalf("Expected replica B to accept a token signed by the new key, got %v", err)
+	}
+
+	if replicaB.Keyring().Active().ID != replicaA.Keyring().Active().ID {
+		t.Error("Expected replica B to sign with the new key after reloading")
+	}
+}
+
+func TestKeyService_PublishedBeforeSigning(t *testing.T) {
+	// Setup: two replicas sharing one database
+	db := setupTestDB(t)
+	keyRepo := store.NewSigningKeyRepository(db)
+	replicaA := newTestKeyService(t, keyRepo)
+	replicaB := newTestKeyService(t, keyRepo)
+	jwtB := utils.NewJWTManagerWithKeyring(replicaB.Keyring(), 15*time.Minute, 7*24*time.Hour)
+
+	// Execute: replica A publishes a key, replica B syncs on schedule
+	published, err := replicaA.Rotate()
+	if err != nil {
+		t.Fatalf("Failed to publish a key: %v", err)
+	}
+	if err := replicaB.Sync(); err != nil {
+		t.Fatalf("Failed to sync keyring: %v", err)
+	}
+
+	// Assert: replica B publishes the key before anyone signs with it
+	found := false
+	for _, jwk := range jwtB.JWKS().Keys {
+		found = found || jwk.Kid == published.ID
+	}
+	if !found {
+		t.Error("Expected replica B to publish the new key")
+	}
+	if replicaA.Keyring().Active().ID == published.ID || replicaB.Keyring().Active().ID == published.ID {
+		t.Error("Expected the new key not to sign yet")
+	}
+
+	// Scheduled rotation does not promote it early
+	if rotated, err := replicaA.RotateIfDue(); err != nil || rotated != nil {
+		t.Errorf("Expected no rotation before the key was published for long enough, got %v", err)
+	}
+}
+
+func TestKeyService_ReloadsAreThrottled(t *testing.T) {
+	// Setup: replica B has just reloaded for a key rotated in by replica A
+	db := setupTestDB(t)
+	keyRepo := store.NewSigningKeyRepository(db)
+	replicaA := newTestKeyService(t, keyRepo)
+	replicaB := newTestKeyService(t, keyRepo)
+
+	jwtA := utils.NewJWTManagerWithKeyring(replicaA.Keyring(), 15*time.Minute, 7*24*time.Hour)
+	jwtB := utils.NewJWTManagerWithKeyring(replicaB.Keyring(), 15*time.Minute, 7*24*time.Hour)
+	subject := utils.AccessTokenSubject{UserID: "user-1", Username: "testuser", SessionID: "session-1"}
+
+	rotateNow(t, db, replicaA)
+	token, _, err := jwtA.GenerateAccessToken(subject)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := jwtB.ValidateAccessToken(token); err != nil {
+		t.Fatalf("Expected replica B to accept a token signed by the new key, got %v", err)
+	}
+
+	// Execute: another unknown kid right after
+	rotateNow(t, db, replicaA)
+	token, _, err = jwtA.GenerateAccessToken(subject)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// Assert: it waits for the next scheduled sync
+	if _, err := jwtB.ValidateAccessToken(token); err == nil {
+		t.Error("Expected no reload so soon after the last one")
+	}
+	if err := replicaB.Sync(); err != nil {
+		t.Fatalf("Failed to sync keyring: %v", err)
+	}
+	if _, err := jwtB.ValidateAccessToken(token); err != nil {
+		t.Errorf("Expected the token to be accepted after syncing, got %v", err)
+	}
+}
+
+func TestKeyService_FirstKeyCreatedOnce(t *testing.T) {
+	// Setup: replica A created the first key
+	db := setupTestDB(t)
+	keyRepo := store.NewSigningKeyRepository(db)
+	replicaA := newTestKeyService(t, keyRepo)
+
+	// Execute: replica B, which saw no key yet, tries to create it too
+	created, err := keyRepo.CreateFirst(&model.SigningKey{
+		ID:         "replica-b-key",
+		Algorithm:  utils.AlgorithmEdDSA,
+		PrivateKey: "sealed",
+		Status:     model.SigningKeyActive,
+	})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if created {
+		t.Error("Expected no second active key")
+	}
+
+	var active []model.SigningKey
+	db.Where("status = ?", model.SigningKeyActive).Find(&active)
+	if len(active) != 1 || active[0].ID != replicaA.Keyring().Active().ID {
+		t.Errorf("Expected only the key of replica A to be active, got %d keys", len(active))
+	}
+}
+
+func TestKeyService_RotateIfDue(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	keyRepo := store.NewSigningKeyRepository(db)
+	keyService := newTestKeyService(t, keyRepo)
+	activeKid := keyService.Keyring().Active().ID
+
+	// Execute: a fresh key is not rotated
+	rotated, err := keyService.RotateIfDue()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rotated != nil {
+		t.Fatal("Expected fresh key not to be rotated")
+	}
+
+	// A key older than the rotation interval gets a successor published
+	db.Model(&model.SigningKey{}).Where("id = ?", activeKid).Update("created_at", time.Now().Add(-25*time.Hour))
+
+	published, err := keyService.RotateIfDue()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if published == nil || published.Status != model.SigningKeyVerify || keyService.Keyring().Active().ID != activeKid {
+		t.Fatal("Expected a new key to be published for the expired one")
+	}
+
+	// and replaced once the new key has been published for long enough
+	publishedLongAgo(db, published.ID)
+	rotated, err = keyService.RotateIfDue()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rotated == nil || rotated.ID != published.ID || keyService.Keyring().Active().ID != published.ID {
+		t.Fatal("Expected expired key to be rotated")
+	}
+
+	var record model.SigningKey
+	db.First(&record, "id = ?", activeKid)
+	if record.Status != model.SigningKeyVerify || record.RetireAfter == nil {
+		t.Errorf("Expected previous key to be verification only with a retirement time, got %s", record.Status)
+	}
+}
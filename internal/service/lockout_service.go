@@ -0,0 +1,309 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/store"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Kinds of keys failed logins are counted against
+const (
+	lockoutScopeAccount = "account"
+	lockoutScopeIP      = "ip"
+)
+
+// LockoutLimits are the failed login thresholds for one kind of key
+type LockoutLimits struct {
+	// BackoffAfter failed attempts hold back each further attempt for a
+	// delay that doubles with every failure. Zero disables back-off.
+	BackoffAfter int
+	// LockAfter failed attempts lock the key for the lockout duration. Zero
+	// disables lockouts.
+	LockAfter int
+}
+
+// LockoutPolicy configures how failed logins are throttled
+type LockoutPolicy struct {
+	// Account limits apply per username, IP limits per client address
+	Account LockoutLimits
+	IP      LockoutLimits
+	// BackoffBase is the first back-off delay, BackoffMax the longest one
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Duration is how long a lockout lasts. Failed attempts are forgotten
+	// once none failed for as long.
+	Duration time.Duration
+}
+
+// LoginLockedError is returned for logins held back by earlier failed
+// attempts. Its message is constants.MsgLoginLocked.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return constants.MsgLoginLocked
+}
+
+// LockoutService counts failed password logins per username and per client
+// address and holds back further attempts, first with exponential back-off
+// and eventually with a temporary lockout. Usernames are counted whether or
+// not an account exists, so a lockout tells nothing about which do.
+type LockoutService struct {
+	throttleRepo *store.LoginThrottleRepository
+	userRepo     *store.UserRepository
+	policy       LockoutPolicy
+	now          func() time.Time
+}
+
+func NewLockoutService(throttleRepo *store.LoginThrottleRepository, userRepo *store.UserRepository, policy LockoutPolicy) *LockoutService {
+	return &LockoutService{
+		throttleRepo: throttleRepo,
+		userRepo:     userRepo,
+		policy:       policy,
+		now:          time.Now,
+	}
+}
+
+type lockoutKey struct {
+	scope   string
+	subject string
+}
+
+// keys returns the keys a login for the username from the address counts
+// against
+func (s *LockoutService) keys(username, ipAddress string) []lockoutKey {
+	keys := []lockoutKey{{lockoutScopeAccount, username}}
+	if ipAddress != "" {
+		keys = append(keys, lockoutKey{lockoutScopeIP, ipAddress})
+	}
+	return keys
+}
+
+func (s *LockoutService) limits(scope string) LockoutLimits {
+	if scope == lockoutScopeIP {
+		return s.policy.IP
+	}
+	return s.policy.Account
+}
+
+// Reserve counts a login for the username from the address as failed before
+// its password is checked, so that concurrent attempts cannot all pass a
+// check made before any of them failed. It returns how long logins are held
+// back instead, without counting the attempt, if any key is. Attempts that
+// turn out to succeed are given back with RecordSuccess.
+func (s *LockoutService) Reserve(username, ipAddress string) (time.Duration, error) {
+	now := s.now()
+	keys := s.keys(username, ipAddress)
+
+	var (
+		wait     time.Duration
+		locked   []lockoutKey
+		failures []int
+	)
+	err := s.throttleRepo.Transaction(func(repo *store.LoginThrottleRepository) error {
+		// Keys are always locked in the same order, account before address
+		throttles := make([]*model.LoginThrottle, len(keys))
+		for i, key := range keys {
+			throttle, err := repo.GetForUpdate(key.scope, key.subject, now)
+			if err != nil {
+				return err
+			}
+			if throttle.BlockedUntil != nil && throttle.BlockedUntil.After(now) {
+				wait = max(wait, throttle.BlockedUntil.Sub(now))
+			}
+			throttles[i] = throttle
+		}
+		if wait > 0 {
+			return nil
+		}
+
+		for i, throttle := range throttles {
+			limits := s.limits(keys[i].scope)
+			if !now.Before(throttle.LastFailedAt.Add(s.policy.Duration)) {
+				throttle.FailedAttempts = 0
+			}
+			throttle.FailedAttempts++
+			throttle.LastFailedAt = now
+
+			delay, lockout := s.delay(limits, throttle.FailedAttempts)
+			throttle.BlockedUntil = nil
+			if delay > 0 {
+				blockedUntil := now.Add(delay)
+				throttle.BlockedUntil = &blockedUntil
+			}
+			if lockout && throttle.FailedAttempts == limits.LockAfter {
+				locked = append(locked, keys[i])
+				failures = append(failures, throttle.FailedAttempts)
+			}
+
+			if err := repo.Save(throttle); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error recording failed login: %w", err)
+	}
+
+	for i, key := range locked {
+		logger.SecurityEvent(constants.EventLoginLocked,
+			zap.String("scope", key.scope),
+			zap.String("subject", key.subject),
+			zap.Int("failed_attempts", failures[i]),
+		)
+	}
+	return wait, nil
+}
+
+// RecordSuccess forgets the failed logins for the username and gives back
+// the attempt reserved against the address. Earlier failures from the
+// address still count, so one account an attacker controls does not reset
+// their budget for guessing others.
+func (s *LockoutService) RecordSuccess(username, ipAddress string) error {
+	if _, err := s.throttleRepo.Delete(lockoutScopeAccount, username); err != nil {
+		return fmt.Errorf("error clearing failed logins: %w", err)
+	}
+	if ipAddress == "" {
+		return nil
+	}
+
+	now := s.now()
+	err := s.throttleRepo.Transaction(func(repo *store.LoginThrottleRepository) error {
+		throttle, err := repo.GetForUpdate(lockoutScopeIP, ipAddress, now)
+		if err != nil {
+			return err
+		}
+		if throttle.FailedAttempts <= 1 {
+			_, err := repo.Delete(lockoutScopeIP, ipAddress)
+			return err
+		}
+
+		throttle.FailedAttempts--
+		delay, _ := s.delay(s.policy.IP, throttle.FailedAttempts)
+		throttle.BlockedUntil = nil
+		if delay > 0 {
+			blockedUntil := throttle.LastFailedAt.Add(delay)
+			throttle.BlockedUntil = &blockedUntil
+		}
+		return repo.Save(throttle)
+	})
+	if err != nil {
+		return fmt.Errorf("error clearing failed logins: %w", err)
+	}
+	return nil
+}
+
+// ClearStale removes the failed logins that are no longer counted because
+// none failed for a lockout duration
+func (s *LockoutService) ClearStale() error {
+	return s.throttleRepo.DeleteStale(s.now().Add(-s.policy.Duration))
+}
+
+// delay returns how long attempts are held back after the given number of
+// consecutive failures, and whether that is a lockout
+func (s *LockoutService) delay(limits LockoutLimits, failures int) (time.Duration, bool) {
+	if limits.LockAfter > 0 && failures >= limits.LockAfter {
+		return s.policy.Duration, true
+	}
+	if limits.BackoffAfter <= 0 || failures < limits.BackoffAfter {
+		return 0, false
+	}
+
+	delay := s.policy.BackoffBase
+	for i := limits.BackoffAfter; i < failures && delay < s.policy.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, s.policy.BackoffMax), false
+}
+
+// List returns the usernames and addresses whose logins are currently held
+// back
+func (s *LockoutService) List() (*dto.LockoutListResponse, error) {
+	throttles, err := s.throttleRepo.ListBlocked(s.now())
+	if err != nil {
+		return nil, fmt.Errorf("error listing lockouts: %w", err)
+	}
+
+	lockouts := make([]dto.LockoutInfo, 0, len(throttles))
+	for i := range throttles {
+		lockouts = append(lockouts, s.toLockoutInfo(&throttles[i]))
+	}
+	return &dto.LockoutListResponse{Lockouts: lockouts}, nil
+}
+
+// UserStatus returns the failed logins counted against the user's username
+func (s *LockoutService) UserStatus(userID string) (*dto.LockoutInfo, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	throttle, err := s.throttleRepo.Get(lockoutScopeAccount, user.Username)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error getting failed logins: %w", err)
+	}
+	if throttle == nil || !s.now().Before(throttle.LastFailedAt.Add(s.policy.Duration)) {
+		return &dto.LockoutInfo{Scope: lockoutScopeAccount, Subject: user.Username}, nil
+	}
+
+	info := s.toLockoutInfo(throttle)
+	return &info, nil
+}
+
+// UnlockUser forgets the failed logins counted against the user's username
+func (s *LockoutService) UnlockUser(userID string) (*dto.ClearLockoutResponse, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.clear(lockoutScopeAccount, user.Username)
+}
+
+// UnlockIP forgets the failed logins counted against the client address
+func (s *LockoutService) UnlockIP(ipAddress string) (*dto.ClearLockoutResponse, error) {
+	return s.clear(lockoutScopeIP, ipAddress)
+}
+
+func (s *LockoutService) clear(scope, subject string) (*dto.ClearLockoutResponse, error) {
+	if _, err := s.throttleRepo.Delete(scope, subject); err != nil {
+		return nil, fmt.Errorf("error clearing failed logins: %w", err)
+	}
+	return &dto.ClearLockoutResponse{Message: constants.MsgLockoutCleared}, nil
+}
+
+func (s *LockoutService) getUser(userID string) (*model.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	return user, nil
+}
+
+func (s *LockoutService) toLockoutInfo(throttle *model.LoginThrottle) dto.LockoutInfo {
+	lastFailedAt := throttle.LastFailedAt
+	info := dto.LockoutInfo{
+		Scope:          throttle.Scope,
+		Subject:        throttle.Subject,
+		FailedAttempts: throttle.FailedAttempts,
+		LastFailedAt:   &lastFailedAt,
+	}
+	if throttle.BlockedUntil != nil && throttle.BlockedUntil.After(s.now()) {
+		info.BlockedUntil = throttle.BlockedUntil
+		limits := s.limits(throttle.Scope)
+		info.Locked = limits.LockAfter > 0 && throttle.FailedAttempts >= limits.LockAfter
+	}
+	return info
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+func setupLockoutTest(t *testing.T) (*AuthService, *LockoutService, *time.Time, string) {
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	lockoutService := NewLockoutService(store.NewLoginThrottleRepository(db), userRepo, LockoutPolicy{
+		Account:     LockoutLimits{BackoffAfter: 2, LockAfter: 4},
+		IP:          LockoutLimits{BackoffAfter: 0, LockAfter: 6},
+		BackoffBase: time.Second,
+		BackoffMax:  time.Minute,
+		Duration:    15 * time.Minute,
+	})
+	now := time.Now()
+	lockoutService.now = func() time.Time { return now }
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithLockout(lockoutService))
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	return authService, lockoutService, &now, registerResp.User.ID
+}
+
+// login tries the password for testuser from the given address
+func login(authService *AuthService, password, ip string) error {
+	_, err := authService.Login(&dto.LoginRequest{
+		Username: "testuser",
+		Password: password,
+		Client:   dto.ClientInfo{IPAddress: ip},
+	})
+	return err
+}
+
+// retryAfter returns how long a locked login has to wait, failing the test
+// if the login was not held back
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var locked *LoginLockedError
+	if !errors.As(err, &locked) || err.Error() != constants.MsgLoginLocked {
+		t.Fatalf("Expected the login to be held back, got %v", err)
+	}
+	return locked.RetryAfter
+}
+
+func TestLockoutService_BackoffAndLockout(t *testing.T) {
+	authService, lockoutService, now, userID := setupLockoutTest(t)
+
+	// The first failure costs nothing
+	if err := login(authService, "wrong", "10.0.0.1"); err == nil || err.Error() != constants.MsgInvalidCredentials {
+		t.Fatalf("Expected %q, got %v", constants.MsgInvalidCredentials, err)
+	}
+	if err := login(authService, "password123", "10.0.0.1"); err != nil {
+		t.Fatalf("Expected login to succeed, got %v", err)
+	}
+
+	// A success starts the count over, so two more failures start the back-off
+	for i := 0; i < 2; i++ {
+		if err := login(authService, "wrong", "10.0.0.1"); err == nil || err.Error() != constants.MsgInvalidCredentials {
+			t.Fatalf("Expected %q, got %v", constants.MsgInvalidCredentials, err)
+		}
+	}
+
+	// Even the right password is held back until the delay has passed
+	if wait := retryAfter(t, login(authService, "password123", "10.0.0.1")); wait != time.Second {
+		t.Errorf("Expected a delay of 1s, got %v", wait)
+	}
+
+	// Each further failure doubles the delay
+	*now = now.Add(time.Second)
+	if err := login(authService, "wrong", "10.0.0.1"); err == nil || err.Error() != constants.MsgInvalidCredentials {
+		t.Fatalf("Expected %q, got %v", constants.MsgInvalidCredentials, err)
+	}
+	if wait := retryAfter(t, login(authService, "wrong", "10.0.0.1")); wait != 2*time.Second {
+		t.Errorf("Expected a delay of 2s, got %v", wait)
+	}
+
+	// The fourth failure in a row locks the account, from every address
+	*now = now.Add(2 * time.Second)
+	if err := login(authService, "wrong", "10.0.0.1"); err == nil || err.Error() != constants.MsgInvalidCredentials {
+		t.Fatalf("Expected %q, got %v", constants.MsgInvalidCredentials, err)
+	}
+	if wait := retryAfter(t, login(authService, "password123", "10.0.0.2")); wait != 15*time.Minute {
+		t.Errorf("Expected a lockout of 15m, got %v", wait)
+	}
+
+	status, err := lockoutService.UserStatus(userID)
+	if err != nil {
+		t.Fatalf("Failed to get lockout status: %v", err)
+	}
+	if !status.Locked || status.FailedAttempts != 4 || status.BlockedUntil == nil {
+		t.Errorf("Expected the account to be locked after 4 failures, got %+v", status)
+	}
+	list, err := lockoutService.List()
+	if err != nil || len(list.Lockouts) != 1 || list.Lockouts[0].Subject != "testuser" {
+		t.Errorf("Expected the account to be listed, got %+v, %v", list, err)
+	}
+
+	// An administrator can lift the lockout
+	if _, err := lockoutService.UnlockUser(userID); err != nil {
+		t.Fatalf("Failed to unlock user: %v", err)
+	}
+	if err := login(authService, "password123", "10.0.0.2"); err != nil {
+		t.Errorf("Expected login to succeed after unlocking, got %v", err)
+	}
+
+	// Lockouts end on their own and failures are forgotten after a while
+	for i := 0; i < 4; i++ {
+		login(authService, "wrong", "10.0.0.3")
+		*now = now.Add(time.Minute)
+	}
+	retryAfter(t, login(authService, "password123", "10.0.0.3"))
+	*now = now.Add(15 * time.Minute)
+	if err := login(authService, "wrong", "10.0.0.3"); err == nil || err.Error() != constants.MsgInvalidCredentials {
+		t.Fatalf("Expected %q once the lockout ended, got %v", constants.MsgInvalidCredentials, err)
+	}
+	if err := login(authService, "password123", "10.0.0.3"); err != nil {
+		t.Errorf("Expected the count to have started over, got %v", err)
+	}
+}
+
+func TestLockoutService_PerAddress(t *testing.T) {
+	authService, lockoutService, _, _ := setupLockoutTest(t)
+
+	// Guessing across usernames, existing or not, is counted per address
+	for i := 0; i < 6; i++ {
+		_, err := authService.Login(&dto.LoginRequest{
+			Username: "guess" + string(rune('a'+i)),
+			Password: "wrong",
+			Client:   dto.ClientInfo{IPAddress: "10.0.0.1"},
+		})
+		if err == nil || err.Error() != constants.MsgInvalidCredentials {
+			t.Fatalf("Expected %q, got %v", constants.MsgInvalidCredentials, err)
+		}
+	}
+	retryAfter(t, login(authService, "password123", "10.0.0.1"))
+
+	// Other addresses are not affected
+	if err := login(authService, "password123", "10.0.0.2"); err != nil {
+		t.Errorf("Expected login from another address to succeed, got %v", err)
+	}
+
+	if _, err := lockoutService.UnlockIP("10.0.0.1"); err != nil {
+		t.Fatalf("Failed to unlock address: %v", err)
+	}
+	if err := login(authService, "password123", "10.0.0.1"); err != nil {
+		t.Errorf("Expected login to succeed after unlocking, got %v", err)
+	}
+
+	// Usernames without an account are locked like any other
+	for i := 0; i < 4; i++ {
+		authService.Login(&dto.LoginRequest{Username: "nobody", Password: "wrong"})
+	}
+	_, err := authService.Login(&dto.LoginRequest{Username: "nobody", Password: "wrong"})
+	retryAfter(t, err)
+}
+
+func TestLockoutService_ReservesAttempts(t *testing.T) {
+	authService, lockoutService, now, _ := setupLockoutTest(t)
+
+	// Attempts count as soon as they start, so ones still checking their
+	// passwords cannot all get past the limit together
+	for i := 0; i < 2; i++ {
+		wait, err := lockoutService.Reserve("testuser", "10.0.0.1")
+		if err != nil || wait != 0 {
+			t.Fatalf("Expected attempt %d to be let through, got %v, %v", i+1, wait, err)
+		}
+	}
+	if wait, err := lockoutService.Reserve("testuser", "10.0.0.1"); err != nil || wait != time.Second {
+		t.Fatalf("Expected the third attempt to be held back for 1s, got %v, %v", wait, err)
+	}
+
+	// A successful login gives its reservation back to the address
+	*now = now.Add(time.Second)
+	if err := login(authService, "password123", "10.0.0.1"); err != nil {
+		t.Fatalf("Expected login to succeed, got %v", err)
+	}
+	throttle, err := lockoutService.throttleRepo.Get(lockoutScopeIP, "10.0.0.1")
+	if err != nil || throttle.FailedAttempts != 2 {
+		t.Errorf("Expected the 2 reserved failures to remain for the address, got %+v, %v", throttle, err)
+	}
+
+	// Stale failures are cleared in the background rather than by logins
+	*now = now.Add(16 * time.Minute)
+	if err := lockoutService.ClearStale(); err != nil {
+		t.Fatalf("Failed to clear stale failures: %v", err)
+	}
+	if _, err := lockoutService.throttleRepo.Get(lockoutScopeIP, "10.0.0.1"); err == nil {
+		t.Error("Expected the stale failures of the address to be cleared")
+	}
+}
+
+func TestLockoutService_ChangePassword(t *testing.T) {
+	authService, _, now, userID := setupLockoutTest(t)
+	changePassword := func(current string) error {
+		_, err := authService.ChangePassword(userID, "", "", "", &dto.ChangePasswordRequest{
+			CurrentPassword: current,
+			NewPassword:     "Orbit-Canyon-77",
+			Client:          dto.ClientInfo{IPAddress: "10.0.0.1"},
+		})
+		return err
+	}
+
+	// Wrong current passwords count like failed logins of the account
+	for i := 0; i < 2; i++ {
+		if err := changePassword("wrong"); err == nil || err.Error() != constants.MsgWrongPassword {
+			t.Fatalf("Expected %q, got %v", constants.MsgWrongPassword, err)
+		}
+	}
+	if wait := retryAfter(t, changePassword("password123")); wait != time.Second {
+		t.Errorf("Expected a delay of 1s, got %v", wait)
+	}
+	retryAfter(t, login(authService, "password123", "10.0.0.2"))
+
+	// Once the delay has passed the right password works and clears them
+	*now = now.Add(time.Second)
+	if err := changePassword("password123"); err != nil {
+		t.Fatalf("Expected the password change to succeed, got %v", err)
+	}
+	if err := login(authService, "Orbit-Canyon-77", "10.0.0.2"); err != nil {
+		t.Errorf("Expected login to succeed after the change, got %v", err)
+	}
+}
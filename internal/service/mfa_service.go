@@ -0,0 +1,336 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// mfaChallengeExpiration is how long a user has to enter a code after
+	// passing the password check
+	mfaChallengeExpiration = 5 * time.Minute
+
+	// mfaChallengeMaxAttempts is how many second factor attempts one
+	// challenge allows before the password has to be entered again
+	mfaChallengeMaxAttempts = 3
+
+	// mfaSkew is how many time steps a TOTP code may be off by, to allow for
+	// clock drift and slow typing
+	mfaSkew = 1
+
+	// mfaMaxAttempts consecutive wrong codes lock the factor for mfaLockout
+	mfaMaxAttempts = 5
+	mfaLockout     = 15 * time.Minute
+
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAService manages TOTP second factors and their recovery codes. Secrets
+// are stored encrypted with secretBox, recovery codes hashed like refresh
+// tokens.
+type MFAService struct {
+	mfaRepo   *store.MFARepository
+	userRepo  *store.UserRepository
+	secretBox *utils.SecretBox
+	issuer    string
+}
+
+// NewMFAService creates the service. issuer is the name authenticator apps
+// show next to the account.
+func NewMFAService(mfaRepo *store.MFARepository, userRepo *store.UserRepository, secretBox *utils.SecretBox, issuer string) *MFAService {
+	return &MFAService{
+		mfaRepo:   mfaRepo,
+		userRepo:  userRepo,
+		secretBox: secretBox,
+		issuer:    issuer,
+	}
+}
+
+// Enabled reports whether the user has to present a second factor at login
+func (s *MFAService) Enabled(userID string) (bool, error) {
+	return s.mfaRepo.IsEnabled(userID)
+}
+
+func (s *MFAService) Status(userID string) (*dto.MFAStatusResponse, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &dto.MFAStatusResponse{}, nil
+		}
+		return nil, fmt.Errorf("error getting MFA: %w", err)
+	}
+	if mfa.EnabledAt == nil {
+		return &dto.MFAStatusResponse{Pending: true}, nil
+	}
+
+	remaining, err := s.mfaRepo.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error counting recovery codes: %w", err)
+	}
+
+	return &dto.MFAStatusResponse{
+		Enabled:                true,
+		EnabledAt:              mfa.EnabledAt,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+// Enroll starts setting up TOTP with a new secret. The factor is not used
+// until Confirm proves the user's app generates matching codes; enrolling
+// again replaces an unconfirmed secret.
+func (s *MFAService) Enroll(userID string) (*dto.MFAEnrollResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("error generating TOTP secret: %w", err)
+	}
+
+	sealed, err := s.secretBox.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting TOTP secret: %w", err)
+	}
+
+	saved, err := s.mfaRepo.SavePending(userID, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("error saving TOTP secret: %w", err)
+	}
+	if !saved {
+		return nil, fmt.Errorf(constants.MsgMFAAlreadyEnabled)
+	}
+
+	return &dto.MFAEnrollResponse{
+		Secret:          utils.EncodeTOTPSecret(secret),
+		ProvisioningURI: utils.TOTPProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm enables the pending factor with a code from the user's app and
+// returns the first set of recovery codes
+func (s *MFAService) Confirm(userID, code string) (*dto.MFARecoveryCodesResponse, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgMFANoEnrollment)
+		}
+		return nil, fmt.Errorf("error getting MFA: %w", err)
+	}
+	if mfa.EnabledAt != nil {
+		return nil, fmt.Errorf(constants.MsgMFAAlreadyEnabled)
+	}
+
+	secret, err := s.secretBox.Open(mfa.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting TOTP secret: %w", err)
+	}
+
+	counter, ok := utils.VerifyTOTP(secret, code, time.Now(), mfaSkew)
+	if !ok {
+		return nil, fmt.Errorf(constants.MsgInvalidMFACode)
+	}
+
+	codes, records, err := s.newRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.mfaRepo.Transaction(func(repo *store.MFARepository) error {
+		enabled, err := repo.Enable(userID, counter)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return fmt.Errorf(constants.MsgMFAAlreadyEnabled)
+		}
+		return repo.ReplaceRecoveryCodes(userID, records)
+	})
+	if err != nil {
+		if err.Error() == constants.MsgMFAAlreadyEnabled {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error enabling MFA: %w", err)
+	}
+
+	logger.SecurityEvent(constants.EventMFAEnabled, zap.String("user_id", userID))
+
+	return &dto.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable removes the user's second factor after checking a current code
+func (s *MFAService) Disable(userID string, req *dto.MFACodeRequest) (*dto.MFADisableResponse, error) {
+	if err := s.Verify(userID, req.Code, req.RecoveryCode); err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.Delete(userID); err != nil {
+		return nil, fmt.Errorf("error disabling MFA: %w", err)
+	}
+
+	logger.SecurityEvent(constants.EventMFADisabled, zap.String("user_id", userID))
+
+	return &dto.MFADisableResponse{Message: constants.MsgMFADisabled}, nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a
+// current code
+func (s *MFAService) RegenerateRecoveryCodes(userID string, req *dto.MFACodeRequest) (*dto.MFARecoveryCodesResponse, error) {
+	if err := s.Verify(userID, req.Code, req.RecoveryCode); err != nil {
+		return nil, err
+	}
+
+	codes, records, err := s.newRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, records); err != nil {
+		return nil, fmt.Errorf("error saving recovery codes: %w", err)
+	}
+
+	return &dto.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Verify checks a TOTP code, or a recovery code if one is given, against the
+// user's enabled factor. Each TOTP code and recovery code is accepted once.
+// After mfaMaxAttempts wrong codes in a row the factor is locked and every
+// code is rejected until the lockout ends. Attempts are counted before the
+// code is checked, so concurrent guesses cannot get around the limit.
+func (s *MFAService) Verify(userID, code, recoveryCode string) error {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(constants.MsgMFANotEnabled)
+		}
+		return fmt.Errorf("error getting MFA: %w", err)
+	}
+	if mfa.EnabledAt == nil {
+		return fmt.Errorf(constants.MsgMFANotEnabled)
+	}
+
+	unlocked, attempts, err := s.mfaRepo.ReserveAttempt(userID, mfaMaxAttempts, time.Now().Add(mfaLockout))
+	if err != nil {
+		return fmt.Errorf("error recording MFA attempt: %w", err)
+	}
+	if !unlocked {
+		return fmt.Errorf(constants.MsgMFALocked)
+	}
+
+	var accepted bool
+	if recoveryCode != "" {
+		accepted, err = s.useRecoveryCode(userID, recoveryCode)
+	} else {
+		accepted, err = s.acceptCode(mfa, code)
+	}
+	if err != nil {
+		return err
+	}
+	if accepted {
+		return nil
+	}
+
+	logger.SecurityEvent(constants.EventMFAFailed, zap.String("user_id", userID), zap.Int("failed_attempts", attempts))
+	if attempts >= mfaMaxAttempts {
+		logger.SecurityEvent(constants.EventMFALocked, zap.String("user_id", userID))
+	}
+
+	return fmt.Errorf(constants.MsgInvalidMFACode)
+}
+
+// acceptCode checks a TOTP code and records its time step as used
+func (s *MFAService) acceptCode(mfa *model.UserMFA, code string) (bool, error) {
+	secret, err := s.secretBox.Open(mfa.Secret)
+	if err != nil {
+		return false, fmt.Errorf("error decrypting TOTP secret: %w", err)
+	}
+
+	counter, ok := utils.VerifyTOTP(secret, code, time.Now(), mfaSkew)
+	if !ok {
+		return false, nil
+	}
+
+	// A replayed code counts as a wrong one
+	accepted, err := s.mfaRepo.AcceptCounter(mfa.UserID, counter)
+	if err != nil {
+		return false, fmt.Errorf("error accepting TOTP code: %w", err)
+	}
+	return accepted, nil
+}
+
+func (s *MFAService) useRecoveryCode(userID, code string) (bool, error) {
+	used, err := s.mfaRepo.UseRecoveryCode(userID, utils.HashSecret(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, fmt.Errorf("error using recovery code: %w", err)
+	}
+	if !used {
+		return false, nil
+	}
+
+	if err := s.mfaRepo.ResetFailures(userID); err != nil {
+		return false, fmt.Errorf("error resetting MFA failures: %w", err)
+	}
+	logger.SecurityEvent(constants.EventRecoveryCodeUsed, zap.String("user_id", userID))
+	return true, nil
+}
+
+// newRecoveryCodes generates a set of recovery codes, returning them for the
+// user together with the records to store
+func (s *MFAService) newRecoveryCodes(userID string) ([]string, []model.MFARecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]model.MFARecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("error generating recovery code: %w", err)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+
+		codes = append(codes, formatRecoveryCode(code))
+		records = append(records, model.MFARecoveryCode{
+			ID:       utils.GenerateUUIDv7(),
+			UserID:   userID,
+			CodeHash: utils.HashSecret(code),
+		})
+	}
+	return codes, records, nil
+}
+
+// formatRecoveryCode splits a code into dash separated groups of four
+// characters to make it easier to copy
+func formatRecoveryCode(code string) string {
+	var b strings.Builder
+	for i, r := range code {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// normalizeRecoveryCode undoes formatRecoveryCode and whatever case or
+// spacing the user typed the code in
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
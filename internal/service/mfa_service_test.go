@@ -0,0 +1,234 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func setupMFATest(t *testing.T) (*AuthService, *MFAService, string) {
+	return setupMFATestWithDB(t, setupTestDB(t))
+}
+
+func setupMFATestWithDB(t *testing.T, db *gorm.DB) (*AuthService, *MFAService, string) {
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	secretBox, err := utils.NewSecretBox([]byte(strings.Repeat("m", 32)), "mfa-secrets")
+	if err != nil {
+		t.Fatalf("Failed to create secret box: %v", err)
+	}
+	mfaService := NewMFAService(store.NewMFARepository(db), userRepo, secretBox, "Test")
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithMFA(mfaService), WithMFAChallenges(store.NewMFAChallengeRepository(db)))
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	return authService, mfaService, registerResp.User.ID
+}
+
+// newMFAChallenge logs the test user in with their password and returns the
+// MFA challenge token
+func newMFAChallenge(t *testing.T, authService *AuthService) string {
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil || loginResp.MFAChallenge == nil {
+		t.Fatalf("Expected an MFA challenge, got %+v, %v", loginResp, err)
+	}
+	return loginResp.MFAChallenge.MFAToken
+}
+
+// enableTOTP enrolls and confirms TOTP for the user, returning the secret
+// and the recovery codes
+func enableTOTP(t *testing.T, mfaService *MFAService, userID string) ([]byte, []string) {
+	enrollResp, err := mfaService.Enroll(userID)
+	if err != nil {
+		t.Fatalf("Failed to enroll: %v", err)
+	}
+	if !strings.HasPrefix(enrollResp.ProvisioningURI, "otpauth://totp/") {
+		t.Errorf("Unexpected provisioning URI %q", enrollResp.ProvisioningURI)
+	}
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollResp.Secret)
+	if err != nil {
+		t.Fatalf("Failed to decode secret: %v", err)
+	}
+
+	confirmResp, err := mfaService.Confirm(userID, utils.TOTPCode(secret, utils.TOTPCounter(time.Now())))
+	if err != nil {
+		t.Fatalf("Failed to confirm: %v", err)
+	}
+	return secret, confirmResp.RecoveryCodes
+}
+
+func TestMFAService_LoginWithSecondFactor(t *testing.T) {
+	authService, mfaService, userID := setupMFATest(t)
+
+	// A wrong code does not enable the factor
+	if _, err := mfaService.Enroll(userID); err != nil {
+		t.Fatalf("Failed to enroll: %v", err)
+	}
+	if _, err := mfaService.Confirm(userID, "000000"); err == nil {
+		t.Error("Expected a wrong confirmation code to fail")
+	}
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil || loginResp.MFAChallenge != nil || loginResp.AccessToken == "" {
+		t.Fatalf("Expected an unconfirmed factor to be ignored at login, got %+v, %v", loginResp, err)
+	}
+
+	secret, recoveryCodes := enableTOTP(t, mfaService, userID)
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(recoveryCodes))
+	}
+
+	// The password alone only earns a challenge
+	loginResp, err = authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	if loginResp.MFAChallenge == nil || loginResp.AccessToken != "" || loginResp.RefreshToken != "" {
+		t.Fatalf("Expected an MFA challenge instead of tokens, got %+v", loginResp)
+	}
+	challenge := loginResp.MFAChallenge.MFAToken
+
+	// The challenge is not an access token
+	if _, err := authService.jwtManager.ValidateAccessToken(challenge); err == nil {
+		t.Error("Expected the challenge to be rejected as an access token")
+	}
+
+	// The code used to confirm cannot be replayed
+	_, err = authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: challenge, Code: utils.TOTPCode(secret, utils.TOTPCounter(time.Now()))})
+	if err == nil || err.Error() != constants.MsgInvalidMFACode {
+		t.Fatalf("Expected %q for a replayed code, got %v", constants.MsgInvalidMFACode, err)
+	}
+
+	code := utils.TOTPCode(secret, utils.TOTPCounter(time.Now())+1)
+	verifyResp, err := authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: challenge, Code: code})
+	if err != nil {
+		t.Fatalf("Failed to verify code: %v", err)
+	}
+	if verifyResp.AccessToken == "" || verifyResp.RefreshToken == "" || verifyResp.User.ID != userID {
+		t.Errorf("Expected tokens for the user, got %+v", verifyResp)
+	}
+
+	// A challenge completes a single login
+	_, err = authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: recoveryCodes[0]})
+	if err == nil || err.Error() != constants.MsgInvalidToken {
+		t.Errorf("Expected %q when reusing a challenge, got %v", constants.MsgInvalidToken, err)
+	}
+
+	_, err = authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: newMFAChallenge(t, authService), Code: code})
+	if err == nil || err.Error() != constants.MsgInvalidMFACode {
+		t.Errorf("Expected %q when reusing a code, got %v", constants.MsgInvalidMFACode, err)
+	}
+
+	// Recovery codes work once, in any case and with or without dashes
+	recoveryCode := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+	if _, err := authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: newMFAChallenge(t, authService), RecoveryCode: recoveryCode}); err != nil {
+		t.Fatalf("Failed to verify recovery code: %v", err)
+	}
+	_, err = authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: newMFAChallenge(t, authService), RecoveryCode: recoveryCodes[0]})
+	if err == nil || err.Error() != constants.MsgInvalidMFACode {
+		t.Errorf("Expected %q when reusing a recovery code, got %v", constants.MsgInvalidMFACode, err)
+	}
+
+	status, err := mfaService.Status(userID)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Errorf("Expected MFA enabled with %d recovery codes left, got %+v", recoveryCodeCount-1, status)
+	}
+
+	// Once disabled, the password is enough again
+	challenge = newMFAChallenge(t, authService)
+	if _, err := mfaService.Disable(userID, &dto.MFACodeRequest{RecoveryCode: recoveryCodes[1]}); err != nil {
+		t.Fatalf("Failed to disable MFA: %v", err)
+	}
+	loginResp, err = authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil || loginResp.MFAChallenge != nil {
+		t.Errorf("Expected a plain login after disabling MFA, got %+v, %v", loginResp, err)
+	}
+	_, err = authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: recoveryCodes[2]})
+	if err == nil || err.Error() != constants.MsgInvalidToken {
+		t.Errorf("Expected %q for a challenge of a removed factor, got %v", constants.MsgInvalidToken, err)
+	}
+}
+
+func TestMFAService_ChallengeAttemptsAreLimited(t *testing.T) {
+	authService, mfaService, userID := setupMFATest(t)
+	_, recoveryCodes := enableTOTP(t, mfaService, userID)
+	challenge := newMFAChallenge(t, authService)
+
+	for i := 0; i < mfaChallengeMaxAttempts; i++ {
+		_, err := authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: challenge, Code: "000000"})
+		if err == nil || err.Error() != constants.MsgInvalidMFACode {
+			t.Fatalf("Expected %q for attempt %d, got %v", constants.MsgInvalidMFACode, i+1, err)
+		}
+	}
+
+	// The password has to be entered again, even for a valid code
+	_, err := authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: challenge, RecoveryCode: recoveryCodes[0]})
+	if err == nil || err.Error() != constants.MsgInvalidToken {
+		t.Errorf("Expected %q after %d attempts, got %v", constants.MsgInvalidToken, mfaChallengeMaxAttempts, err)
+	}
+	if _, err := authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: newMFAChallenge(t, authService), RecoveryCode: recoveryCodes[0]}); err != nil {
+		t.Errorf("Expected a new challenge to work, got %v", err)
+	}
+}
+
+func TestMFAService_LocksAfterFailedAttempts(t *testing.T) {
+	_, mfaService, userID := setupMFATest(t)
+	_, recoveryCodes := enableTOTP(t, mfaService, userID)
+
+	for i := 0; i < mfaMaxAttempts; i++ {
+		err := mfaService.Verify(userID, "000000", "")
+		if err == nil || err.Error() != constants.MsgInvalidMFACode {
+			t.Fatalf("Expected %q for attempt %d, got %v", constants.MsgInvalidMFACode, i+1, err)
+		}
+	}
+
+	// Even a valid code is refused while locked
+	err := mfaService.Verify(userID, "", recoveryCodes[0])
+	if err == nil || err.Error() != constants.MsgMFALocked {
+		t.Errorf("Expected %q, got %v", constants.MsgMFALocked, err)
+	}
+}
+
+func TestMFAService_ExpiredLockStartsCountOver(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	_, mfaService, userID := setupMFATestWithDB(t, db)
+	enableTOTP(t, mfaService, userID)
+
+	for i := 0; i < mfaMaxAttempts; i++ {
+		mfaService.Verify(userID, "000000", "")
+	}
+	if err := db.Model(&model.UserMFA{}).Where("user_id = ?", userID).Update("locked_until", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatalf("Failed to expire lock: %v", err)
+	}
+
+	// Execute
+	for i := 0; i < mfaMaxAttempts-1; i++ {
+		err := mfaService.Verify(userID, "000000", "")
+
+		// Assert
+		if err == nil || err.Error() != constants.MsgInvalidMFACode {
+			t.Fatalf("Expected %q for attempt %d after the lock expired, got %v", constants.MsgInvalidMFACode, i+1, err)
+		}
+	}
+}
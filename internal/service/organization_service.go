@@ -0,0 +1,310 @@
+package service
+
+import (
+	"authorization/internal/authz"
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// OrganizationService manages organizations and their members. Members hold
+// organization scoped roles, whose grants only count within that
+// organization: a member may manage another organization's members only if
+// they hold the permission there, or globally through orgs:read/orgs:write.
+type OrganizationService struct {
+	orgRepo   *store.OrganizationRepository
+	roleRepo  *store.RoleRepository
+	userRepo  *store.UserRepository
+	evaluator *authz.Evaluator
+}
+
+func NewOrganizationService(orgRepo *store.OrganizationRepository, roleRepo *store.RoleRepository, userRepo *store.UserRepository, evaluator *authz.Evaluator) *OrganizationService {
+	return &OrganizationService{
+		orgRepo:   orgRepo,
+		roleRepo:  roleRepo,
+		userRepo:  userRepo,
+		evaluator: evaluator,
+	}
+}
+
+// CreateOrganization creates an organization. If req names an owner they
+// become its first member with the org_admin role.
+func (s *OrganizationService) CreateOrganization(req *dto.CreateOrganizationRequest) (*dto.OrganizationInfo, error) {
+	exists, err := s.orgRepo.ExistsBySlug(req.Slug)
+	if err != nil {
+		return nil, fmt.Errorf("error checking organization: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf(constants.MsgOrgAlreadyExists)
+	}
+
+	var ownerRole *model.Role
+	if req.OwnerID != "" {
+		if _, err := s.getUser(req.OwnerID); err != nil {
+			return nil, err
+		}
+		ownerRole, err = s.roleRepo.GetByName(constants.RoleOrgAdmin)
+		if err != nil {
+			return nil, fmt.Errorf("error getting organization admin role: %w", err)
+		}
+	}
+
+	org := &model.Organization{
+		ID:   utils.GenerateUUIDv7(),
+		Slug: req.Slug,
+		Name: req.Name,
+	}
+	err = s.orgRepo.Transaction(func(repo *store.OrganizationRepository) error {
+		if err := repo.Create(org); err != nil {
+			return fmt.Errorf("error creating organization: %w", err)
+		}
+		if ownerRole == nil {
+			return nil
+		}
+		if err := repo.AddMember(org.ID, req.OwnerID); err != nil {
+			return fmt.Errorf("error adding organization owner: %w", err)
+		}
+		if err := repo.SetMemberRoles(org.ID, req.OwnerID, []string{ownerRole.ID}); err != nil {
+			return fmt.Errorf("error assigning organization owner role: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := organizationInfo(org)
+	return &info, nil
+}
+
+func (s *OrganizationService) ListOrganizations() (*dto.OrganizationListResponse, error) {
+	orgs, err := s.orgRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing organizations: %w", err)
+	}
+
+	resp := &dto.OrganizationListResponse{Organizations: make([]dto.OrganizationInfo, 0, len(orgs))}
+	for _, org := range orgs {
+		resp.Organizations = append(resp.Organizations, organizationInfo(org))
+	}
+	return resp, nil
+}
+
+// ListMemberships returns the organizations the user belongs to and their
+// roles there, flagging the one activeOrgID names
+func (s *OrganizationService) ListMemberships(userID, activeOrgID string) (*dto.MembershipListResponse, error) {
+	orgs, err := s.orgRepo.ListByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing organizations: %w", err)
+	}
+
+	resp := &dto.MembershipListResponse{Organizations: make([]dto.MembershipInfo, 0, len(orgs))}
+	for _, org := range orgs {
+		roles, err := s.orgRepo.GetMemberRoleNames(org.ID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting organization roles: %w", err)
+		}
+		resp.Organizations = append(resp.Organizations, dto.MembershipInfo{
+			OrganizationInfo: organizationInfo(org),
+			Roles:            nonNil(roles),
+			Active:           org.ID == activeOrgID,
+		})
+	}
+	return resp, nil
+}
+
+// ListMembers returns the members of the organization
+func (s *OrganizationService) ListMembers(callerID, orgID string) (*dto.MemberListResponse, error) {
+	if err := s.authorize(callerID, orgID, constants.PermOrgsRead, constants.PermMembersRead); err != nil {
+		return nil, err
+	}
+
+	members, err := s.orgRepo.ListMembers(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing members: %w", err)
+	}
+	roles, err := s.orgRepo.ListMemberRoleNames(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing member roles: %w", err)
+	}
+
+	resp := &dto.MemberListResponse{OrganizationID: orgID, Members: make([]dto.MemberInfo, 0, len(members))}
+	for _, member := range members {
+		resp.Members = append(resp.Members, memberInfo(member, roles[member.UserID]))
+	}
+	return resp, nil
+}
+
+// SetMember adds the user to the organization, or replaces their roles
+// there if they already are a member. Only organization roles can be given.
+func (s *OrganizationService) SetMember(callerID, orgID, userID string, req *dto.SetMemberRequest) (*dto.MemberInfo, error) {
+	if err := s.authorize(callerID, orgID, constants.PermOrgsWrite, constants.PermMembersWrite); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getUser(userID); err != nil {
+		return nil, err
+	}
+
+	roleIDs, err := s.resolveOrganizationRoles(req.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.orgRepo.Transaction(func(repo *store.OrganizationRepository) error {
+		if err := repo.AddMember(orgID, userID); err != nil {
+			return fmt.Errorf("error adding member: %w", err)
+		}
+		if err := repo.SetMemberRoles(orgID, userID, roleIDs); err != nil {
+			return fmt.Errorf("error setting member roles: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := s.orgRepo.GetMember(orgID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting member: %w", err)
+	}
+	roles, err := s.orgRepo.GetMemberRoleNames(orgID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting member roles: %w", err)
+	}
+	info := memberInfo(member, roles)
+	return &info, nil
+}
+
+// RemoveMember removes the user and their roles from the organization.
+// Sessions working in the organization lose it on their next refresh.
+func (s *OrganizationService) RemoveMember(callerID, orgID, userID string) error {
+	if err := s.authorize(callerID, orgID, constants.PermOrgsWrite, constants.PermMembersWrite); err != nil {
+		return err
+	}
+
+	var removed int64
+	err := s.orgRepo.Transaction(func(repo *store.OrganizationRepository) error {
+		var err error
+		removed, err = repo.RemoveMember(orgID, userID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error removing member: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf(constants.MsgNotMember)
+	}
+	return nil
+}
+
+// authorize lets the caller act on the organization if they hold
+// globalPermission, or hold orgPermission through their roles in the
+// organization. Callers who are neither are told the organization does not
+// exist, so that tenants cannot probe for each other.
+func (s *OrganizationService) authorize(callerID, orgID, globalPermission, orgPermission string) error {
+	if _, err := s.orgRepo.GetByID(orgID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(constants.MsgOrgNotFound)
+		}
+		return fmt.Errorf("error getting organization: %w", err)
+	}
+
+	decision, err := s.evaluator.Check(callerID, globalPermission)
+	if err != nil {
+		return fmt.Errorf("error checking caller permissions: %w", err)
+	}
+	if decision.Allowed {
+		return nil
+	}
+
+	member, err := s.orgRepo.IsMember(orgID, callerID)
+	if err != nil {
+		return fmt.Errorf("error checking membership: %w", err)
+	}
+	if !member {
+		return fmt.Errorf(constants.MsgOrgNotFound)
+	}
+
+	roles, err := s.orgRepo.GetMemberRoleNames(orgID, callerID)
+	if err != nil {
+		return fmt.Errorf("error getting organization roles: %w", err)
+	}
+	grants, err := s.evaluator.ResolveRoles(roles...)
+	if err != nil {
+		return fmt.Errorf("error resolving organization permissions: %w", err)
+	}
+	if !grants.Check(orgPermission).Allowed {
+		return fmt.Errorf(constants.MsgForbidden)
+	}
+	return nil
+}
+
+// resolveOrganizationRoles returns the IDs of the named roles, failing if
+// any is unknown or not an organization role
+func (s *OrganizationService) resolveOrganizationRoles(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	roles, err := s.roleRepo.GetByNames(names)
+	if err != nil {
+		return nil, fmt.Errorf("error getting roles: %w", err)
+	}
+
+	byName := make(map[string]model.Role, len(roles))
+	for _, role := range roles {
+		byName[role.Name] = role
+	}
+
+	ids := make([]string, 0, len(roles))
+	for _, name := range names {
+		role, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf(constants.MsgRoleNotFound)
+		}
+		if role.Scope != constants.RoleScopeOrganization {
+			return nil, fmt.Errorf(constants.MsgRoleWrongScope)
+		}
+	}
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+	return ids, nil
+}
+
+func (s *OrganizationService) getUser(userID string) (*model.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	return user, nil
+}
+
+func organizationInfo(org *model.Organization) dto.OrganizationInfo {
+	return dto.OrganizationInfo{
+		ID:        org.ID,
+		Slug:      org.Slug,
+		Name:      org.Name,
+		CreatedAt: org.CreatedAt,
+	}
+}
+
+func memberInfo(member *model.OrganizationMember, roles []string) dto.MemberInfo {
+	return dto.MemberInfo{
+		UserID:   member.UserID,
+		Username: member.User.Username,
+		Email:    member.User.Email,
+		Roles:    nonNil(roles),
+		JoinedAt: member.CreatedAt,
+	}
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"testing"
+	"time"
+)
+
+// seedOrgRoles adds the organization permissions and roles created by the
+// migrations on top of seedRoles
+func seedOrgRoles(t *testing.T, roleRepo *store.RoleRepository) {
+	permissions := make(map[string]model.Permission)
+	for _, name := range []string{constants.PermOrgsRead, constants.PermOrgsWrite, constants.PermMembersRead, constants.PermMembersWrite} {
+		permission := model.Permission{ID: utils.GenerateUUIDv7(), Name: name}
+		if err := roleRepo.CreatePermission(&permission); err != nil {
+			t.Fatalf("Failed to create permission: %v", err)
+		}
+		permissions[name] = permission
+	}
+
+	admin, err := roleRepo.GetByName(constants.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Failed to get admin role: %v", err)
+	}
+	adminPermissions := append(admin.Permissions, permissions[constants.PermOrgsRead], permissions[constants.PermOrgsWrite])
+	if err := roleRepo.SetPermissions(admin, adminPermissions); err != nil {
+		t.Fatalf("Failed to grant admin permissions: %v", err)
+	}
+
+	roles := []*model.Role{
+		{ID: utils.GenerateUUIDv7(), Name: constants.RoleOrgAdmin, Scope: constants.RoleScopeOrganization,
+			Permissions: []model.Permission{permissions[constants.PermMembersRead], permissions[constants.PermMembersWrite]}},
+		{ID: utils.GenerateUUIDv7(), Name: constants.RoleOrgMember, Scope: constants.RoleScopeOrganization,
+			Permissions: []model.Permission{permissions[constants.PermMembersRead]}},
+	}
+	for _, role := range roles {
+		if err := roleRepo.Create(role); err != nil {
+			t.Fatalf("Failed to create role: %v", err)
+		}
+	}
+}
+
+type orgFixture struct {
+	authService *AuthService
+	orgService  *OrganizationService
+	roleService *RoleService
+	jwtManager  *utils.JWTManager
+	users       map[string]string
+	orgs        map[string]string
+}
+
+// setupOrgs creates the acme and globex tenants. alice administers acme,
+// where carol is a plain member; bob administers globex; root is a global
+// admin belonging to neither.
+func setupOrgs(t *testing.T) *orgFixture {
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	orgRepo := store.NewOrganizationRepository(db)
+	seedRoles(t, roleRepo)
+	seedOrgRoles(t, roleRepo)
+
+	evaluator := NewAuthzEvaluator(roleRepo, 0)
+	f := &orgFixture{
+		jwtManager:  utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour),
+		orgService:  NewOrganizationService(orgRepo, roleRepo, userRepo, evaluator),
+		roleService: NewRoleService(roleRepo, userRepo, evaluator),
+		users:       make(map[string]string),
+		orgs:        make(map[string]string),
+	}
+	f.authService = NewAuthService(userRepo, store.NewTokenRepository(db), f.jwtManager, WithRoles(roleRepo, evaluator), WithOrganizations(orgRepo))
+
+	for _, username := range []string{"alice", "bob", "carol", "root"} {
+		resp, err := f.authService.Register(&dto.RegisterRequest{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: "password123",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		f.users[username] = resp.User.ID
+	}
+	if _, err := f.roleService.AssignRole(OperatorActor, f.users["root"], constants.RoleAdmin); err != nil {
+		t.Fatalf("Failed to assign admin role: %v", err)
+	}
+
+	for slug, owner := range map[string]string{"acme": "alice", "globex": "bob"} {
+		org, err := f.orgService.CreateOrganization(&dto.CreateOrganizationRequest{Slug: slug, Name: slug, OwnerID: f.users[owner]})
+		if err != nil {
+			t.Fatalf("Failed to create organization: %v", err)
+		}
+		f.orgs[slug] = org.ID
+	}
+	if _, err := f.orgService.SetMember(f.users["alice"], f.orgs["acme"], f.users["carol"], &dto.SetMemberRequest{Roles: []string{constants.RoleOrgMember}}); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+
+	return f
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func memberIDs(resp *dto.MemberListResponse) map[string]bool {
+	ids := make(map[string]bool)
+	for _, member := range resp.Members {
+		ids[member.UserID] = true
+	}
+	return ids
+}
+
+func TestOrganizationService_TenantIsolation(t *testing.T) {
+	// Setup
+	f := setupOrgs(t)
+	alice, bob, carol := f.users["alice"], f.users["bob"], f.users["carol"]
+	acme, globex := f.orgs["acme"], f.orgs["globex"]
+
+	// Execute: alice sees her own tenant only
+	members, err := f.orgService.ListMembers(alice, acme)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected alice to list acme members, got %v", err)
+	}
+	ids := memberIDs(members)
+	if len(ids) != 2 || !ids[alice] || !ids[carol] || ids[bob] {
+		t.Errorf("Expected alice and carol in acme, got %+v", members.Members)
+	}
+
+	// Another tenant's memberships can be neither read nor changed, and
+	// whether the organization exists is not revealed
+	if _, err := f.orgService.ListMembers(alice, globex); err == nil || err.Error() != constants.MsgOrgNotFound {
+		t.Errorf("Expected error '%s' listing globex members, got %v", constants.MsgOrgNotFound, err)
+	}
+	if _, err := f.orgService.SetMember(alice, globex, alice, &dto.SetMemberRequest{Roles: []string{constants.RoleOrgAdmin}}); err == nil || err.Error() != constants.MsgOrgNotFound {
+		t.Errorf("Expected error '%s' joining globex, got %v", constants.MsgOrgNotFound, err)
+	}
+	if err := f.orgService.RemoveMember(alice, globex, bob); err == nil || err.Error() != constants.MsgOrgNotFound {
+		t.Errorf("Expected error '%s' removing a globex member, got %v", constants.MsgOrgNotFound, err)
+	}
+	if _, err := f.orgService.ListMembers(bob, acme); err == nil || err.Error() != constants.MsgOrgNotFound {
+		t.Errorf("Expected error '%s' listing acme members as bob, got %v", constants.MsgOrgNotFound, err)
+	}
+
+	members, err = f.orgService.ListMembers(bob, globex)
+	if err != nil {
+		t.Fatalf("Expected bob to list globex members, got %v", err)
+	}
+	if ids := memberIDs(members); len(ids) != 1 || !ids[bob] {
+		t.Errorf("Expected only bob in globex, got %+v", members.Members)
+	}
+
+	memberships, err := f.orgService.ListMemberships(alice, "")
+	if err != nil {
+		t.Fatalf("Failed to list memberships: %v", err)
+	}
+	if len(memberships.Organizations) != 1 || memberships.Organizations[0].ID != acme {
+		t.Errorf("Expected alice to belong to acme only, got %+v", memberships.Organizations)
+	}
+}
+
+func TestOrganizationService_MemberPermissions(t *testing.T) {
+	// Setup
+	f := setupOrgs(t)
+	alice, bob, carol, root := f.users["alice"], f.users["bob"], f.users["carol"], f.users["root"]
+	acme, globex := f.orgs["acme"], f.orgs["globex"]
+
+	// Plain members may read but not change the membership
+	if _, err := f.orgService.ListMembers(carol, acme); err != nil {
+		t.Errorf("Expected carol to list acme members, got %v", err)
+	}
+	if err := f.orgService.RemoveMember(carol, acme, alice); err == nil || err.Error() != constants.MsgForbidden {
+		t.Errorf("Expected error '%s', got %v", constants.MsgForbidden, err)
+	}
+
+	// Only organization roles can be given to members, and organization
+	// roles cannot be assigned globally
+	if _, err := f.orgService.SetMember(alice, acme, carol, &dto.SetMemberRequest{Roles: []string{constants.RoleAdmin}}); err == nil || err.Error() != constants.MsgRoleWrongScope {
+		t.Errorf("Expected error '%s', got %v", constants.MsgRoleWrongScope, err)
+	}
+	if _, err := f.roleService.AssignRole(OperatorActor, carol, constants.RoleOrgAdmin); err == nil || err.Error() != constants.MsgRoleWrongScope {
+		t.Errorf("Expected error '%s', got %v", constants.MsgRoleWrongScope, err)
+	}
+
+	// Global admins manage every organization
+	if _, err := f.orgService.ListMembers(root, globex); err != nil {
+		t.Errorf("Expected a global admin to list globex members, got %v", err)
+	}
+
+	// Execute
+	member, err := f.orgService.SetMember(alice, acme, bob, &dto.SetMemberRequest{Roles: []string{constants.RoleOrgMember}})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected alice to add bob to acme, got %v", err)
+	}
+	if len(member.Roles) != 1 || member.Roles[0] != constants.RoleOrgMember || member.Username != "bob" {
+		t.Errorf("Expected bob as an org_member, got %+v", member)
+	}
+
+	// bob's roles in acme give him nothing in globex and vice versa
+	if err := f.orgService.RemoveMember(bob, acme, carol); err == nil || err.Error() != constants.MsgForbidden {
+		t.Errorf("Expected error '%s', got %v", constants.MsgForbidden, err)
+	}
+
+	if err := f.orgService.RemoveMember(alice, acme, bob); err != nil {
+		t.Fatalf("Failed to remove member: %v", err)
+	}
+	if err := f.orgService.RemoveMember(alice, acme, bob); err == nil || err.Error() != constants.MsgNotMember {
+		t.Errorf("Expected error '%s', got %v", constants.MsgNotMember, err)
+	}
+}
+
+func TestAuthService_SwitchOrganization(t *testing.T) {
+	// Setup
+	f := setupOrgs(t)
+	alice, acme, globex := f.users["alice"], f.orgs["acme"], f.orgs["globex"]
+
+	login, err := f.authService.Login(&dto.LoginRequest{Username: "alice", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	claims, err := f.jwtManager.ValidateAccessToken(login.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+	if claims.OrgID != "" || hasString(claims.Permissions, constants.PermMembersWrite) || len(claims.OrgPermissions) != 0 {
+		t.Errorf("Expected a login token without an organization, got %+v", claims)
+	}
+
+	// Execute
+	switched, err := f.authService.SwitchOrganization(alice, claims.SessionID, acme)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Failed to switch organization: %v", err)
+	}
+	claims, err = f.jwtManager.ValidateAccessToken(switched.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+	if claims.OrgID != acme || !hasString(claims.OrgRoles, constants.RoleOrgAdmin) || !hasString(claims.OrgPermissions, constants.PermMembersWrite) {
+		t.Errorf("Expected an acme token with org_admin grants, got %+v", claims)
+	}
+	// Organization grants are never global ones
+	if hasString(claims.Roles, constants.RoleOrgAdmin) || hasString(claims.Permissions, constants.PermMembersWrite) {
+		t.Errorf("Expected org_admin grants to be kept out of the global claims, got %+v", claims)
+	}
+
+	if _, err := f.authService.SwitchOrganization(alice, claims.SessionID, globex); err == nil || err.Error() != constants.MsgOrgNotFound {
+		t.Errorf("Expected error '%s' switching to globex, got %v", constants.MsgOrgNotFound, err)
+	}
+
+	// Refreshing keeps the organization while alice is a member
+	refreshed, err := f.authService.RefreshToken(&dto.RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("Failed to refresh token: %v", err)
+	}
+	if claims, _ = f.jwtManager.ValidateAccessToken(refreshed.AccessToken); claims.OrgID != acme {
+		t.Errorf("Expected the refreshed token to keep org_id %s, got %q", acme, claims.OrgID)
+	}
+
+	if err := f.orgService.RemoveMember(f.users["root"], acme, alice); err != nil {
+		t.Fatalf("Failed to remove member: %v", err)
+	}
+	refreshed, err = f.authService.RefreshToken(&dto.RefreshRequest{RefreshToken: refreshed.RefreshToken})
+	if err != nil {
+		t.Fatalf("Failed to refresh token: %v", err)
+	}
+	if claims, _ = f.jwtManager.ValidateAccessToken(refreshed.AccessToken); claims.OrgID != "" || hasString(claims.OrgRoles, constants.RoleOrgAdmin) {
+		t.Errorf("Expected no organization after removal, got %+v", claims)
+	}
+
+	// Leaving the organization context works for any session
+	left, err := f.authService.SwitchOrganization(alice, claims.SessionID, "")
+	if err != nil || left.OrgID != "" {
+		t.Errorf("Expected to leave the organization, got %+v, %v", left, err)
+	}
+	if _, err := f.authService.SwitchOrganization(alice, "unknown-session", ""); err == nil || err.Error() != constants.MsgSessionNotFound {
+		t.Errorf("Expected error '%s', got %v", constants.MsgSessionNotFound, err)
+	}
+}
+
+func TestRoleService_OrganizationRolesStayInOrganization(t *testing.T) {
+	// Setup
+	f := setupOrgs(t)
+	if _, err := f.roleService.CreatePermission(&dto.CreatePermissionRequest{Name: "users:*"}); err != nil {
+		t.Fatalf("Failed to create permission: %v", err)
+	}
+
+	// Organization roles can neither inherit global roles nor grant global
+	// permissions, which org admins could otherwise hand to themselves
+	for _, req := range []*dto.CreateRoleRequest{
+		{Name: "org-escalation", Scope: constants.RoleScopeOrganization, Inherits: []string{constants.RoleAdmin}},
+		{Name: "org-escalation", Scope: constants.RoleScopeOrganization, Permissions: []string{constants.PermOrgsWrite}},
+		{Name: "org-escalation", Scope: constants.RoleScopeOrganization, Permissions: []string{"users:*"}},
+	} {
+		if _, err := f.roleService.CreateRole(OperatorActor, req); err == nil || err.Error() != constants.MsgOrgRoleTooBroad {
+			t.Errorf("Expected error '%s' for %+v, got %v", constants.MsgOrgRoleTooBroad, req, err)
+		}
+	}
+	if _, err := f.roleService.SetRoleInherits(OperatorActor, constants.RoleOrgMember, &dto.SetRoleInheritsRequest{Inherits: []string{constants.RoleAdmin}}); err == nil || err.Error() != constants.MsgOrgRoleTooBroad {
+		t.Errorf("Expected error '%s' inheriting admin, got %v", constants.MsgOrgRoleTooBroad, err)
+	}
+	if _, err := f.roleService.SetRolePermissions(OperatorActor, constants.RoleOrgMember, &dto.SetRolePermissionsRequest{Permissions: []string{constants.PermRolesAssign}}); err == nil || err.Error() != constants.MsgOrgRoleTooBroad {
+		t.Errorf("Expected error '%s' granting roles:assign, got %v", constants.MsgOrgRoleTooBroad, err)
+	}
+
+	// Organization permissions and roles are fine
+	role, err := f.roleService.CreateRole(OperatorActor, &dto.CreateRoleRequest{
+		Name:        "org-auditor",
+		Scope:       constants.RoleScopeOrganization,
+		Permissions: []string{constants.PermMembersRead},
+		Inherits:    []string{constants.RoleOrgMember},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create organization role: %v", err)
+	}
+	if role.Scope != constants.RoleScopeOrganization {
+		t.Errorf("Expected an organization role, got %+v", role)
+	}
+
+	// Global roles are not restricted
+	if _, err := f.roleService.CreateRole(OperatorActor, &dto.CreateRoleRequest{Name: "support", Permissions: []string{"users:*"}, Inherits: []string{constants.RoleUser}}); err != nil {
+		t.Errorf("Failed to create global role: %v", err)
+	}
+}
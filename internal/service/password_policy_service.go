@@ -0,0 +1,125 @@
+package service
+
+import (
+	"authorization/internal/model"
+	"authorization/internal/passwordpolicy"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/pwned"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// PasswordPolicyService checks new passwords against the password policy,
+// including whether the user had them before and whether they appear in
+// known breaches. A nil service allows any password.
+type PasswordPolicyService struct {
+	policy      passwordpolicy.Policy
+	historyRepo *store.PasswordHistoryRepository
+	breaches    pwned.Source
+}
+
+// NewPasswordPolicyService creates the service. historyRepo may be nil if the
+// policy keeps no history, and breaches nil to not check for breached
+// passwords.
+func NewPasswordPolicyService(policy passwordpolicy.Policy, historyRepo *store.PasswordHistoryRepository, breaches pwned.Source) *PasswordPolicyService {
+	return &PasswordPolicyService{
+		policy:      policy,
+		historyRepo: historyRepo,
+		breaches:    breaches,
+	}
+}
+
+// Check returns a *passwordpolicy.Error listing every rule the password
+// breaks, or nil if it may be chosen. user is nil for new accounts, which
+// have no earlier passwords.
+func (s *PasswordPolicyService) Check(password, username, email string, user *model.User) error {
+	if s == nil {
+		return nil
+	}
+
+	failures := s.policy.Check(password, username, email)
+
+	if s.policy.History > 0 && user != nil {
+		reused, err := s.reused(password, user)
+		if err != nil {
+			return err
+		}
+		if reused {
+			failures = append(failures, passwordpolicy.Failure{
+				Rule:    passwordpolicy.RuleReused,
+				Message: fmt.Sprintf("Password must differ from your last %d passwords", s.policy.History),
+			})
+		}
+	}
+
+	if s.breaches != nil {
+		// An unreadable corpus should not stop users from choosing
+		// passwords, the other rules still apply
+		breached, err := s.breaches.Breached(password)
+		if err != nil {
+			logger.Error("Failed to check password against breach corpus", zap.Error(err))
+		} else if breached {
+			failures = append(failures, passwordpolicy.Failure{
+				Rule:    passwordpolicy.RuleBreached,
+				Message: "Password appears in known data breaches",
+			})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &passwordpolicy.Error{Failures: failures}
+	}
+	return nil
+}
+
+// reused reports whether the password is the user's current one or among the
+// earlier ones the policy remembers
+func (s *PasswordPolicyService) reused(password string, user *model.User) (bool, error) {
+	hashes := []string{user.PasswordHash}
+	if s.policy.History > 1 && s.historyRepo != nil {
+		entries, err := s.historyRepo.Recent(user.ID, s.policy.History-1)
+		if err != nil {
+			return false, fmt.Errorf("error getting password history: %w", err)
+		}
+		for _, entry := range entries {
+			hashes = append(hashes, entry.PasswordHash)
+		}
+	}
+
+	for _, hash := range hashes {
+		// Hashes in formats no longer supported cannot match
+		if utils.CheckPasswordHash(hash) != nil {
+			continue
+		}
+		valid, err := utils.VerifyPassword(password, hash)
+		if err != nil {
+			return false, fmt.Errorf("error verifying password: %w", err)
+		}
+		if valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Remember adds the password hash the user just replaced to their history
+func (s *PasswordPolicyService) Remember(userID, oldHash string) error {
+	// The current password is checked on its own, so a history of one needs
+	// no earlier ones
+	if s == nil || s.policy.History <= 1 || s.historyRepo == nil {
+		return nil
+	}
+
+	entry := &model.PasswordHistory{
+		ID:           utils.GenerateUUIDv7(),
+		UserID:       userID,
+		PasswordHash: oldHash,
+	}
+	if err := s.historyRepo.Add(entry, s.policy.History-1); err != nil {
+		return fmt.Errorf("error saving password history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/passwordpolicy"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+// policyRules returns the rules err reports broken, nil if it is no policy
+// error
+func policyRules(err error) []string {
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+	var rules []string
+	for _, failure := range policyErr.Failures {
+		rules = append(rules, failure.Rule)
+	}
+	return rules
+}
+
+func TestAuthService_PasswordPolicy(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	historyRepo := store.NewPasswordHistoryRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	policy := passwordpolicy.Default()
+	policy.History = 3
+	passwordPolicy := NewPasswordPolicyService(policy, historyRepo, nil)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithPasswordPolicy(passwordPolicy))
+
+	// Registration is checked before anything is created
+	_, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "testuser1"})
+	if rules := policyRules(err); !hasString(rules, passwordpolicy.RulePersonalInfo) {
+		t.Fatalf("Expected the username in the password to be rejected, got %v", err)
+	}
+	if exists, _ := userRepo.ExistsByUsername("testuser"); exists {
+		t.Fatal("Expected no user to be created for a rejected password")
+	}
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "securepassword123"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := registerResp.User.ID
+
+	change := func(current, next string) error {
+		_, err := authService.ChangePassword(userID, "", "", "", &dto.ChangePasswordRequest{CurrentPassword: current, NewPassword: next})
+		return err
+	}
+
+	if err := change("securepassword123", "short"); !hasString(policyRules(err), passwordpolicy.RuleMinLength) {
+		t.Errorf("Expected a short password to be rejected, got %v", err)
+	}
+
+	// Each change remembers the password it replaces
+	passwords := []string{"securepassword123", "Tulip-Gravel-42", "Orbit-Canyon-77", "Maple-Quartz-19"}
+	for i := 1; i < len(passwords); i++ {
+		if err := change(passwords[i-1], passwords[i]); err != nil {
+			t.Fatalf("Failed to change password to %s: %v", passwords[i], err)
+		}
+	}
+
+	// The last three passwords, the current one included, cannot be chosen
+	for _, reused := range passwords[1:3] {
+		if err := change("Maple-Quartz-19", reused); !hasString(policyRules(err), passwordpolicy.RuleReused) {
+			t.Errorf("Expected %s to be rejected as reused, got %v", reused, err)
+		}
+	}
+	if err := change("Maple-Quartz-19", passwords[0]); err != nil {
+		t.Errorf("Expected a password older than the history to be allowed, got %v", err)
+	}
+
+	var entries int64
+	db.Model(&model.PasswordHistory{}).Where("user_id = ?", userID).Count(&entries)
+	if entries != 2 {
+		t.Errorf("Expected the history to keep 2 earlier passwords, got %d", entries)
+	}
+}
+
+func TestPasswordService_ResetChecksPolicy(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	resetRepo := store.NewPasswordResetRepository(db)
+	historyRepo := store.NewPasswordHistoryRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+
+	passwordPolicy := NewPasswordPolicyService(passwordpolicy.Default(), historyRepo, nil)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithPasswordPolicy(passwordPolicy))
+	passwordService := NewPasswordService(userRepo, tokenRepo, resetRepo, passwordPolicy, nil, notifier, 30*time.Minute, 0, "https://app.example.com/reset")
+
+	if _, err := authService.Register(&dto.RegisterRequest{Username: "testuser", Email: "test@example.com", Password: "securepassword123"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := passwordService.ForgotPassword(&dto.ForgotPasswordRequest{Email: "test@example.com"}); err != nil {
+		t.Fatalf("Failed to request reset: %v", err)
+	}
+	passwordService.WaitForResetLinks()
+	token := notifier.lastToken(t)
+
+	// Rejected passwords leave the token usable
+	for password, rule := range map[string]string{"password": passwordpolicy.RuleStrength, "securepassword123": passwordpolicy.RuleReused} {
+		_, err := passwordService.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: password})
+		if !hasString(policyRules(err), rule) {
+			t.Errorf("Expected %s to break %s, got %v", password, rule, err)
+		}
+	}
+
+	if _, err := passwordService.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: "Tulip-Gravel-42"}); err != nil {
+		t.Fatalf("Expected the token to still work, got %v", err)
+	}
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "Tulip-Gravel-42"}); err != nil {
+		t.Errorf("Expected the new password to work, got %v", err)
+	}
+}
+
+// breachList reports the passwords it holds as breached
+type breachList map[string]bool
+
+func (l breachList) Breached(password string) (bool, error) {
+	return l[password], nil
+}
+
+func TestPasswordPolicyService_Breached(t *testing.T) {
+	passwordPolicy := NewPasswordPolicyService(passwordpolicy.Policy{}, nil, breachList{"Tulip-Gravel-42": true})
+
+	if err := passwordPolicy.Check("Tulip-Gravel-42", "testuser", "test@example.com", nil); !hasString(policyRules(err), passwordpolicy.RuleBreached) {
+		t.Errorf("Expected a breached password to be rejected, got %v", err)
+	}
+	if err := passwordPolicy.Check("Orbit-Canyon-77", "testuser", "test@example.com", nil); err != nil {
+		t.Errorf("Expected other passwords to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/notify"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// PasswordService lets users who forgot their password reset it through a
+// link sent by the notifier. Reset tokens are random, stored hashed like
+// refresh tokens, expire after tokenExp and work once. Links are sent at most
+// once per resendInterval to each user, so that the reset form cannot be used
+// to flood an inbox or keep retiring a link the user is about to use.
+type PasswordService struct {
+	userRepo       *store.UserRepository
+	tokenRepo      *store.TokenRepository
+	resetRepo      *store.PasswordResetRepository
+	passwordPolicy *PasswordPolicyService
+	revoker        *AccessTokenRevoker
+	notifier       notify.Notifier
+	tokenExp       time.Duration
+	resendInterval time.Duration
+	resetURL       string
+
+	// resetLinks tracks the reset links being sent in the background
+	resetLinks sync.WaitGroup
+}
+
+// NewPasswordService creates the service. Reset links point at resetURL with
+// the token in the "token" query parameter. New passwords are checked against
+// passwordPolicy; if it is nil any password is allowed. A reset revokes the
+// user's access tokens with revoker, which may be nil to leave them valid
+// until they expire.
+func NewPasswordService(userRepo *store.UserRepository, tokenRepo *store.TokenRepository, resetRepo *store.PasswordResetRepository, passwordPolicy *PasswordPolicyService, revoker *AccessTokenRevoker, notifier notify.Notifier, tokenExp, resendInterval time.Duration, resetURL string) *PasswordService {
+	return &PasswordService{
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		resetRepo:      resetRepo,
+		passwordPolicy: passwordPolicy,
+		revoker:        revoker,
+		notifier:       notifier,
+		tokenExp:       tokenExp,
+		resendInterval: resendInterval,
+		resetURL:       resetURL,
+	}
+}
+
+// ForgotPassword sends a reset link to the account with the given email.
+// Unknown emails are not an error, so the response cannot be used to find
+// out which emails have accounts; only internal failures are returned. The
+// link is issued and sent in the background, so that known emails are not
+// answered any slower than unknown ones, and neither are throttled requests.
+func (s *PasswordService) ForgotPassword(req *dto.ForgotPasswordRequest) (*dto.ForgotPasswordResponse, error) {
+	resp := &dto.ForgotPasswordResponse{Message: constants.MsgResetLinkSent}
+
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Debug("Password reset requested for unknown email")
+			return resp, nil
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	s.resetLinks.Add(1)
+	go func() {
+		defer s.resetLinks.Done()
+
+		if err := s.sendResetLink(user); err != nil {
+			logger.Error("Failed to send password reset link", zap.Error(err), zap.String("user_id", user.ID))
+		}
+	}()
+
+	return resp, nil
+}
+
+// WaitForResetLinks waits for the reset links ForgotPassword started
+// sending, so that shutting down does not cut them off
+func (s *PasswordService) WaitForResetLinks() {
+	s.resetLinks.Wait()
+}
+
+// sendResetLink issues a reset token for the user and sends them the link,
+// unless they were sent one less than resendInterval ago
+func (s *PasswordService) sendResetLink(user *model.User) error {
+	tokenStr, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return fmt.Errorf("error generating reset token: %w", err)
+	}
+
+	token := &model.PasswordResetToken{
+		ID:        utils.GenerateUUIDv7(),
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(tokenStr),
+		ExpiresAt: time.Now().Add(s.tokenExp),
+	}
+
+	// Issuing a token retires the user's earlier ones. The user stays locked
+	// from the throttle check until the new token is stored, so concurrent
+	// requests cannot all pass the check.
+	var throttled bool
+	err = s.resetRepo.Transaction(func(repo *store.PasswordResetRepository) error {
+		if err := repo.LockUser(user.ID); err != nil {
+			return fmt.Errorf("error locking user: %w", err)
+		}
+
+		lastIssuedAt, err := repo.LastIssuedAt(user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting reset tokens: %w", err)
+		}
+		if lastIssuedAt != nil && time.Since(*lastIssuedAt) < s.resendInterval {
+			throttled = true
+			return nil
+		}
+
+		if err := repo.InvalidateUserTokens(user.ID); err != nil {
+			return err
+		}
+		return repo.Create(token)
+	})
+	if err != nil {
+		return fmt.Errorf("error saving reset token: %w", err)
+	}
+	if throttled {
+		logger.Debug("Password reset throttled", zap.String("user_id", user.ID))
+		return nil
+	}
+
+	link, err := tokenLink(s.resetURL, tokenStr)
+	if err != nil {
+		return err
+	}
+
+	logger.SecurityEvent(constants.EventResetRequested, zap.String("user_id", user.ID))
+
+	return s.notifier.Notify(&notify.Message{
+		Kind:      notify.KindPasswordReset,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Link:      link,
+		ExpiresAt: token.ExpiresAt,
+	})
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out
+// of every session
+func (s *PasswordService) ResetPassword(req *dto.ResetPasswordRequest) (*dto.ResetPasswordResponse, error) {
+	tokenHashes := utils.TokenHashes(req.Token)
+
+	// The new password is checked against the policy, which needs the user,
+	// before the token is used up, so that a rejected password can be
+	// corrected with the same link
+	token, err := s.resetRepo.GetValid(tokenHashes)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgInvalidResetToken)
+		}
+		return nil, fmt.Errorf("error getting reset token: %w", err)
+	}
+
+	// A link may be retried after a rejected password, so keep it working
+	// once the key it was hashed with is retired
+	if utils.TokenHashNeedsRekey(token.TokenHash) {
+		if _, err := s.resetRepo.Rekey(token.ID, token.TokenHash, tokenHashes[0]); err != nil {
+			return nil, fmt.Errorf("error rekeying reset token: %w", err)
+		}
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgInvalidResetToken)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	if err := s.passwordPolicy.Check(req.NewPassword, user.Username, user.Email, user); err != nil {
+		return nil, err
+	}
+
+	// Hash the new password before using up the token
+	passwordHash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	if _, err := s.resetRepo.Consume(tokenHashes); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgInvalidResetToken)
+		}
+		return nil, fmt.Errorf("error using reset token: %w", err)
+	}
+
+	oldHash := user.PasswordHash
+	if err := s.userRepo.UpdatePassword(user.ID, passwordHash, time.Now()); err != nil {
+		return nil, fmt.Errorf("error updating password: %w", err)
+	}
+	if err := s.passwordPolicy.Remember(user.ID, oldHash); err != nil {
+		return nil, err
+	}
+
+	// Whoever knew the old password may hold a session
+	if err := s.revoker.RevokeUserSessions(user.ID, ""); err != nil {
+		return nil, err
+	}
+	if err := s.tokenRepo.RevokeAllUserTokens(user.ID); err != nil {
+		return nil, fmt.Errorf("error revoking sessions: %w", err)
+	}
+	if err := s.resetRepo.InvalidateUserTokens(user.ID); err != nil {
+		return nil, fmt.Errorf("error invalidating reset tokens: %w", err)
+	}
+
+	logger.SecurityEvent(constants.EventPasswordReset, zap.String("user_id", user.ID))
+
+	return &dto.ResetPasswordResponse{Message: constants.MsgPasswordReset}, nil
+}
+
+// tokenLink adds the token to baseURL as its "token" query parameter
+func tokenLink(baseURL, token string) (string, error) {
+	link, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid link URL %q: %w", baseURL, err)
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/notify"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// recordingNotifier keeps the messages it is asked to deliver
+type recordingNotifier struct {
+	messages []*notify.Message
+}
+
+func (n *recordingNotifier) Notify(msg *notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// lastToken returns the token of the last link sent
+func (n *recordingNotifier) lastToken(t *testing.T) string {
+	t.Helper()
+	if len(n.messages) == 0 {
+		t.Fatal("Expected a message to be sent")
+	}
+	link, err := url.Parse(n.messages[len(n.messages)-1].Link)
+	if err != nil {
+		t.Fatalf("Invalid link: %v", err)
+	}
+	return link.Query().Get("token")
+}
+
+func TestPasswordService_ForgotAndReset(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	resetRepo := store.NewPasswordResetRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	passwordService := NewPasswordService(userRepo, tokenRepo, resetRepo, nil, nil, notifier, 30*time.Minute, 0, "https://app.example.com/reset?lang=en")
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	login, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	// Unknown emails get the same response and nothing is sent
+	unknown, err := passwordService.ForgotPassword(&dto.ForgotPasswordRequest{Email: "nobody@example.com"})
+	if err != nil {
+		t.Fatalf("Expected no error for an unknown email, got %v", err)
+	}
+	passwordService.WaitForResetLinks()
+	if len(notifier.messages) != 0 {
+		t.Fatalf("Expected no message for an unknown email, got %d", len(notifier.messages))
+	}
+
+	known, err := passwordService.ForgotPassword(&dto.ForgotPasswordRequest{Email: "test@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to request reset: %v", err)
+	}
+	passwordService.WaitForResetLinks()
+	if *known != *unknown {
+		t.Errorf("Expected the same response for known and unknown emails, got %+v and %+v", known, unknown)
+	}
+	if len(notifier.messages) != 1 || notifier.messages[0].UserID != registerResp.User.ID {
+		t.Fatalf("Expected one message for the user, got %+v", notifier.messages)
+	}
+	if link, _ := url.Parse(notifier.messages[0].Link); link.Query().Get("lang") != "en" {
+		t.Errorf("Expected the reset URL query to be kept, got %s", notifier.messages[0].Link)
+	}
+	token := notifier.lastToken(t)
+
+	// Only the hash is stored
+	var stored model.PasswordResetToken
+	if err := db.First(&stored).Error; err != nil {
+		t.Fatalf("Reset token was not stored: %v", err)
+	}
+	if stored.TokenHash == token || stored.TokenHash != utils.HashToken(token) {
+		t.Error("Expected the reset token to be stored hashed")
+	}
+
+	if _, err := passwordService.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: "newpassword456"}); err != nil {
+		t.Fatalf("Failed to reset password: %v", err)
+	}
+
+	// The new password works, the old one and the old sessions do not
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"}); err == nil {
+		t.Error("Expected the old password to be rejected")
+	}
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "newpassword456"}); err != nil {
+		t.Errorf("Expected the new password to work, got %v", err)
+	}
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: login.RefreshToken}); err == nil {
+		t.Error("Expected sessions from before the reset to be revoked")
+	}
+	if changedAt, err := userRepo.PasswordChangedAt(registerResp.User.ID); err != nil || changedAt == nil {
+		t.Errorf("Expected password_changed_at to be set, got %v, %v", changedAt, err)
+	}
+
+	// The token works once
+	_, err = passwordService.ResetPassword(&dto.ResetPasswordRequest{Token: token, NewPassword: "another789"})
+	if err == nil || err.Error() != constants.MsgInvalidResetToken {
+		t.Errorf("Expected %q when reusing the token, got %v", constants.MsgInvalidResetToken, err)
+	}
+}
+
+func TestPasswordService_RejectsStaleTokens(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	resetRepo := store.NewPasswordResetRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	notifier := &recordingNotifier{}
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	passwordService := NewPasswordService(userRepo, tokenRepo, resetRepo, nil, nil, notifier, 30*time.Minute, time.Minute, "https://app.example.com/reset")
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if _, err := passwordService.ForgotPassword(&dto.ForgotPasswordRequest{Email: "test@example.com"}); err != nil {
+		t.Fatalf("Failed to request reset: %v", err)
+	}
+	passwordService.WaitForResetLinks()
+	first := notifier.lastToken(t)
+
+	// Asking again right away sends nothing and keeps the link working
+	if _, err := passwordService.ForgotPassword(&dto.ForgotPasswordRequest{Email: "test@example.com"}); err != nil {
+		t.Fatalf("Failed to request reset: %v", err)
+	}
+	passwordService.WaitForResetLinks()
+	if len(notifier.messages) != 1 {
+		t.Fatalf("Expected no further messages, got %d", len(notifier.messages))
+	}
+	if _, err := resetRepo.GetValid(utils.TokenHashes(first)); err != nil {
+		t.Errorf("Expected a throttled request to keep the link, got %v", err)
+	}
+
+	// Once the interval has passed a newer link replaces the earlier one
+	if err := db.Model(&model.PasswordResetToken{}).
+		Where("user_id = ?", registerResp.User.ID).
+		Update("created_at", time.Now().Add(-2*time.Minute)).Error; err != nil {
+		t.Fatalf("Failed to age token: %v", err)
+	}
+	if _, err := passwordService.ForgotPassword(&dto.ForgotPasswordRequest{Email: "test@example.com"}); err != nil {
+		t.Fatalf("Failed to request reset: %v", err)
+	}
+	passwordService.WaitForResetLinks()
+	second := notifier.lastToken(t)
+
+	_, err = passwordService.ResetPassword(&dto.ResetPasswordRequest{Token: first, NewPassword: "newpassword456"})
+	if err == nil || err.Error() != constants.MsgInvalidResetToken {
+		t.Errorf("Expected a superseded token to be rejected, got %v", err)
+	}
+
+	// Expired tokens are rejected
+	if err := db.Model(&model.PasswordResetToken{}).
+		Where("token_hash = ?", utils.HashToken(second)).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("Failed to expire token: %v", err)
+	}
+	_, err = passwordService.ResetPassword(&dto.ResetPasswordRequest{Token: second, NewPassword: "newpassword456"})
+	if err == nil || err.Error() != constants.MsgInvalidResetToken {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+
+	// Unknown tokens are rejected
+	_, err = passwordService.ResetPassword(&dto.ResetPasswordRequest{Token: "not-a-token", NewPassword: "newpassword456"})
+	if err == nil || err.Error() != constants.MsgInvalidResetToken {
+		t.Errorf("Expected an unknown token to be rejected, got %v", err)
+	}
+
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"}); err != nil {
+		t.Errorf("Expected the password to be unchanged, got %v", err)
+	}
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := registerResp.User.ID
+
+	laptop, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	phone, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	claims, err := jwtManager.ValidateAccessToken(laptop.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+
+	// The current password has to be right
+	_, err = authService.ChangePassword(userID, claims.SessionID, claims.ID, "", &dto.ChangePasswordRequest{
+		CurrentPassword: "wrong-password",
+		NewPassword:     "newpassword456",
+	})
+	if err == nil || err.Error() != constants.MsgWrongPassword {
+		t.Errorf("Expected %q, got %v", constants.MsgWrongPassword, err)
+	}
+	_, err = authService.ChangePassword(userID, claims.SessionID, claims.ID, "", &dto.ChangePasswordRequest{
+		CurrentPassword: "password123",
+		NewPassword:     "password123",
+	})
+	if err == nil || err.Error() != constants.MsgSamePassword {
+		t.Errorf("Expected %q, got %v", constants.MsgSamePassword, err)
+	}
+
+	resp, err := authService.ChangePassword(userID, claims.SessionID, claims.ID, "", &dto.ChangePasswordRequest{
+		CurrentPassword:     "password123",
+		NewPassword:         "newpassword456",
+		RevokeOtherSessions: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+
+	newClaims, err := jwtManager.ValidateAccessToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected a valid access token, got %v", err)
+	}
+	if newClaims.SessionID != claims.SessionID {
+		t.Errorf("Expected the new access token to keep session %s, got %s", claims.SessionID, newClaims.SessionID)
+	}
+
+	// The change is recorded and the new token is not older than it
+	changedAt, err := userRepo.PasswordChangedAt(userID)
+	if err != nil || changedAt == nil {
+		t.Fatalf("Expected password_changed_at to be set, got %v, %v", changedAt, err)
+	}
+	if newClaims.IssuedAt.Time.Before(changedAt.Truncate(time.Second)) {
+		t.Errorf("Expected the new access token to be issued after the change")
+	}
+
+	// The current session survives, the other one does not
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: laptop.RefreshToken}); err != nil {
+		t.Errorf("Expected the current session to survive, got %v", err)
+	}
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: phone.RefreshToken}); err == nil {
+		t.Error("Expected the other session to be revoked")
+	}
+
+	if _, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "newpassword456"}); err != nil {
+		t.Errorf("Expected the new password to work, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/policy"
+	"authorization/internal/store"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PolicyService evaluates attribute based access policies. Policies come
+// from files, which are fixed for the life of the process, and from the
+// database, which is reloaded after every change made here and periodically
+// to pick up changes made by other replicas.
+type PolicyService struct {
+	engine       *policy.Engine
+	policyRepo   *store.PolicyRepository
+	filePolicies []*policy.Policy
+
+	// reloadMu serialises reloads so an older snapshot cannot replace a
+	// newer one
+	reloadMu sync.Mutex
+}
+
+// NewPolicyService creates the service and loads the stored policies.
+// filePolicies are evaluated before stored ones.
+func NewPolicyService(policyRepo *store.PolicyRepository, algorithm policy.Algorithm, filePolicies []*policy.Policy) (*PolicyService, error) {
+	engine, err := policy.NewEngine(algorithm, filePolicies...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PolicyService{
+		engine:       engine,
+		policyRepo:   policyRepo,
+		filePolicies: filePolicies,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Engine returns the policy engine for in-process evaluation
+func (s *PolicyService) Engine() *policy.Engine {
+	return s.engine
+}
+
+// Reload replaces the engine's policies with the file policies and the
+// enabled stored policies. If a stored policy no longer compiles the
+// current policies are kept, since dropping a deny policy would widen
+// access.
+func (s *PolicyService) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	records, err := s.policyRepo.ListEnabled()
+	if err != nil {
+		return fmt.Errorf("error listing policies: %w", err)
+	}
+
+	policies := append([]*policy.Policy(nil), s.filePolicies...)
+	for _, record := range records {
+		p, err := policy.Parse([]byte(record.Document))
+		if err != nil {
+			return fmt.Errorf("error loading policy %s: %w", record.ID, err)
+		}
+		policies = append(policies, p)
+	}
+
+	if err := s.engine.Replace(policies); err != nil {
+		return fmt.Errorf("error loading policies: %w", err)
+	}
+	return nil
+}
+
+// Run reloads stored policies every interval until ctx is done
+func (s *PolicyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				logger.Error("Policy reload failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Evaluate decides the request and explains the decision
+func (s *PolicyService) Evaluate(req *dto.PolicyEvaluateRequest) *dto.PolicyDecisionResponse {
+	decision := s.engine.Evaluate(&policy.Request{
+		Subject:     req.Subject,
+		Resource:    req.Resource,
+		Action:      req.Action,
+		Environment: req.Environment,
+	})
+
+	resp := &dto.PolicyDecisionResponse{
+		Decision: string(decision.Effect),
+		Allowed:  decision.Allowed(),
+		Policy:   decision.Policy,
+		Rule:     decision.Rule,
+		Reason:   decision.Reason,
+		Results:  make([]dto.PolicyRuleResult, 0, len(decision.Results)),
+	}
+	for _, result := range decision.Results {
+		resp.Results = append(resp.Results, dto.PolicyRuleResult{
+			Policy: result.Policy,
+			Rule:   result.Rule,
+			Effect: string(result.Effect),
+			Error:  result.Error,
+		})
+	}
+	return resp
+}
+
+// ListPolicies returns the file and stored policies in evaluation order,
+// including disabled stored policies
+func (s *PolicyService) ListPolicies() (*dto.PolicyListResponse, error) {
+	records, err := s.policyRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing policies: %w", err)
+	}
+
+	resp := &dto.PolicyListResponse{
+		Algorithm: string(s.engine.Algorithm()),
+		Policies:  make([]dto.PolicyInfo, 0, len(s.filePolicies)+len(records)),
+	}
+	for _, p := range s.filePolicies {
+		document, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding policy %s: %w", p.ID, err)
+		}
+		resp.Policies = append(resp.Policies, dto.PolicyInfo{
+			ID:      p.ID,
+			Source:  dto.PolicySourceFile,
+			Enabled: true,
+			Policy:  document,
+		})
+	}
+	for _, record := range records {
+		resp.Policies = append(resp.Policies, policyInfo(record))
+	}
+	return resp, nil
+}
+
+// PutPolicy creates or replaces the stored policy with the given id
+func (s *PolicyService) PutPolicy(id string, req *dto.PutPolicyRequest) (*dto.PolicyInfo, error) {
+	if s.isFilePolicy(id) {
+		return nil, fmt.Errorf(constants.MsgPolicyFromFile)
+	}
+
+	p, err := policy.Parse(req.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", constants.MsgInvalidPolicy, err)
+	}
+	if p.ID != id {
+		return nil, fmt.Errorf("%s: policy id %q does not match %q", constants.MsgInvalidPolicy, p.ID, id)
+	}
+
+	// Store the normalised document rather than the request bytes
+	document, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding policy: %w", err)
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	record := &model.Policy{
+		ID:       id,
+		Document: string(document),
+		Priority: req.Priority,
+		Enabled:  enabled,
+	}
+	if err := s.policyRepo.Save(record); err != nil {
+		return nil, fmt.Errorf("error saving policy: %w", err)
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.policyRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting policy: %w", err)
+	}
+	info := policyInfo(saved)
+	return &info, nil
+}
+
+// DeletePolicy removes a stored policy
+func (s *PolicyService) DeletePolicy(id string) error {
+	if s.isFilePolicy(id) {
+		return fmt.Errorf(constants.MsgPolicyFromFile)
+	}
+
+	deleted, err := s.policyRepo.Delete(id)
+	if err != nil {
+		return fmt.Errorf("error deleting policy: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf(constants.MsgPolicyNotFound)
+	}
+
+	return s.Reload()
+}
+
+func (s *PolicyService) isFilePolicy(id string) bool {
+	for _, p := range s.filePolicies {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func policyInfo(record *model.Policy) dto.PolicyInfo {
+	updatedAt := record.UpdatedAt
+	return dto.PolicyInfo{
+		ID:        record.ID,
+		Source:    dto.PolicySourceDatabase,
+		Priority:  record.Priority,
+		Enabled:   record.Enabled,
+		Policy:    json.RawMessage(record.Document),
+		UpdatedAt: &updatedAt,
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/policy"
+	"authorization/internal/store"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const ownerPolicy = `{
+  "id": "documents",
+  "target": "resource.type == \"document\"",
+  "algorithm": "deny-overrides",
+  "rules": [
+    {"id": "owner", "effect": "permit", "condition": "resource.owner == subject.id"},
+    {"id": "suspended", "effect": "deny", "condition": "subject.status == \"suspended\""}
+  ]
+}`
+
+func TestPolicyService_PutEvaluateDelete(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	policyService, err := NewPolicyService(store.NewPolicyRepository(db), policy.DenyOverrides, nil)
+	if err != nil {
+		t.Fatalf("Failed to create policy service: %v", err)
+	}
+
+	req := &dto.PolicyEvaluateRequest{
+		Subject:  map[string]any{"id": "user-1"},
+		Resource: map[string]any{"type": "document", "owner": "user-1"},
+		Action:   "read",
+	}
+
+	// Execute
+	saved, err := policyService.PutPolicy("documents", &dto.PutPolicyRequest{Policy: json.RawMessage(ownerPolicy)})
+	if err != nil {
+		t.Fatalf("Failed to save policy: %v", err)
+	}
+
+	// Assert
+	if !saved.Enabled || saved.Source != dto.PolicySourceDatabase {
+		t.Errorf("Expected an enabled database policy, got %+v", saved)
+	}
+
+	resp := policyService.Evaluate(req)
+	if !resp.Allowed || resp.Rule != "owner" {
+		t.Errorf("Expected the owner rule to permit, got %+v", resp)
+	}
+
+	req.Subject["status"] = "suspended"
+	resp = policyService.Evaluate(req)
+	if resp.Allowed || resp.Decision != "deny" || resp.Rule != "suspended" {
+		t.Errorf("Expected the suspended rule to deny, got %+v", resp)
+	}
+
+	// Disabled policies are kept but not evaluated
+	disabled := false
+	if _, err := policyService.PutPolicy("documents", &dto.PutPolicyRequest{Policy: json.RawMessage(ownerPolicy), Enabled: &disabled}); err != nil {
+		t.Fatalf("Failed to disable policy: %v", err)
+	}
+	if resp = policyService.Evaluate(req); resp.Decision != "not_applicable" {
+		t.Errorf("Expected no policy to apply, got %+v", resp)
+	}
+
+	list, err := policyService.ListPolicies()
+	if err != nil {
+		t.Fatalf("Failed to list policies: %v", err)
+	}
+	if len(list.Policies) != 1 || list.Policies[0].Enabled {
+		t.Errorf("Expected one disabled policy, got %+v", list.Policies)
+	}
+
+	if err := policyService.DeletePolicy("documents"); err != nil {
+		t.Fatalf("Failed to delete policy: %v", err)
+	}
+	if err := policyService.DeletePolicy("documents"); err == nil || err.Error() != constants.MsgPolicyNotFound {
+		t.Errorf("Expected error '%s', got %v", constants.MsgPolicyNotFound, err)
+	}
+}
+
+func TestPolicyService_PutInvalidPolicy(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	policyService, err := NewPolicyService(store.NewPolicyRepository(db), policy.DenyOverrides, nil)
+	if err != nil {
+		t.Fatalf("Failed to create policy service: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		document string
+	}{
+		{"invalid condition", "broken", `{"id": "broken", "algorithm": "deny-overrides", "rules": [{"id": "r", "effect": "permit", "condition": "subject.id =="}]}`},
+		{"id mismatch", "other", ownerPolicy},
+		{"not json", "broken", `"policy"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			_, err := policyService.PutPolicy(tt.id, &dto.PutPolicyRequest{Policy: json.RawMessage(tt.document)})
+
+			// Assert
+			if err == nil || !strings.HasPrefix(err.Error(), constants.MsgInvalidPolicy) {
+				t.Errorf("Expected error '%s', got %v", constants.MsgInvalidPolicy, err)
+			}
+		})
+	}
+}
+
+func TestPolicyService_FilePoliciesAreReadOnly(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	filePolicy, err := policy.Parse([]byte(ownerPolicy))
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	policyService, err := NewPolicyService(store.NewPolicyRepository(db), policy.DenyOverrides, []*policy.Policy{filePolicy})
+	if err != nil {
+		t.Fatalf("Failed to create policy service: %v", err)
+	}
+
+	// Execute
+	_, putErr := policyService.PutPolicy("documents", &dto.PutPolicyRequest{Policy: json.RawMessage(ownerPolicy)})
+	deleteErr := policyService.DeletePolicy("documents")
+
+	// Assert
+	for _, err := range []error{putErr, deleteErr} {
+		if err == nil || err.Error() != constants.MsgPolicyFromFile {
+			t.Errorf("Expected error '%s', got %v", constants.MsgPolicyFromFile, err)
+		}
+	}
+
+	list, err := policyService.ListPolicies()
+	if err != nil {
+		t.Fatalf("Failed to list policies: %v", err)
+	}
+	if len(list.Policies) != 1 || list.Policies[0].Source != dto.PolicySourceFile {
+		t.Errorf("Expected the file policy to be listed, got %+v", list.Policies)
+	}
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"authorization/internal/authz"
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/rebac"
+	"authorization/internal/store"
+	"errors"
+	"fmt"
+)
+
+// maxRelationUpdates bounds the tuples changed by a single write
+const maxRelationUpdates = 100
+
+// tupleStore stores the relation engine's tuples in the relation tables
+type tupleStore struct {
+	repo *store.RelationTupleRepository
+}
+
+func (s tupleStore) Revision() (int64, error) {
+	revision, err := s.repo.CurrentRevision()
+	if err != nil {
+		return 0, fmt.Errorf("error getting relation revision: %w", err)
+	}
+	return revision, nil
+}
+
+func (s tupleStore) Write(touches, deletes []rebac.Tuple) (int64, error) {
+	var revision int64
+	err := s.repo.Transaction(func(repo *store.RelationTupleRepository) error {
+		var err error
+		revision, err = repo.NextRevision()
+		if err != nil {
+			return fmt.Errorf("error advancing relation revision: %w", err)
+		}
+
+		for _, tuple := range deletes {
+			if err := repo.MarkDeleted(tupleModel(tuple, revision), revision); err != nil {
+				return fmt.Errorf("error deleting relation tuple: %w", err)
+			}
+		}
+		for _, tuple := range touches {
+			if err := repo.Insert(tupleModel(tuple, revision)); err != nil {
+				return fmt.Errorf("error writing relation tuple: %w", err)
+			}
+		}
+		return nil
+	})
+	return revision, err
+}
+
+func (s tupleStore) Read(object rebac.Object, relation string, revision int64) ([]rebac.Tuple, error) {
+	rows, err := s.repo.ListByObject(object.Namespace, object.ID, relation, revision)
+	if err != nil {
+		return nil, fmt.Errorf("error reading relation tuples: %w", err)
+	}
+
+	tuples := make([]rebac.Tuple, 0, len(rows))
+	for _, row := range rows {
+		tuples = append(tuples, rebac.Tuple{
+			Object:   rebac.Object{Namespace: row.Namespace, ID: row.ObjectID},
+			Relation: row.Relation,
+			Subject: rebac.Subject{
+				Object:   rebac.Object{Namespace: row.SubjectNamespace, ID: row.SubjectObjectID},
+				Relation: row.SubjectRelation,
+			},
+		})
+	}
+	return tuples, nil
+}
+
+func (s tupleStore) ObjectIDs(namespace string, revision int64) ([]string, error) {
+	ids, err := s.repo.ListObjectIDs(namespace, revision)
+	if err != nil {
+		return nil, fmt.Errorf("error listing relation objects: %w", err)
+	}
+	return ids, nil
+}
+
+func tupleModel(tuple rebac.Tuple, revision int64) *model.RelationTuple {
+	return &model.RelationTuple{
+		Namespace:        tuple.Object.Namespace,
+		ObjectID:         tuple.Object.ID,
+		Relation:         tuple.Relation,
+		SubjectNamespace: tuple.Subject.Namespace,
+		SubjectObjectID:  tuple.Subject.ID,
+		SubjectRelation:  tuple.Subject.Relation,
+		CreatedRevision:  revision,
+	}
+}
+
+// NewRelationEngine creates a relation engine evaluating schema over the
+// relation tables
+func NewRelationEngine(repo *store.RelationTupleRepository, schema *rebac.Schema) *rebac.Engine {
+	return rebac.NewEngine(schema, tupleStore{repo: repo})
+}
+
+// RelationService manages relation tuples and answers relationship based
+// access questions such as "can user:alice view doc:readme"
+type RelationService struct {
+	engine    *rebac.Engine
+	evaluator *authz.Evaluator
+}
+
+func NewRelationService(engine *rebac.Engine, evaluator *authz.Evaluator) *RelationService {
+	return &RelationService{
+		engine:    engine,
+		evaluator: evaluator,
+	}
+}
+
+// Write applies the updates atomically and returns the zookie of the
+// revision they were written at
+func (s *RelationService) Write(req *dto.WriteRelationsRequest) (*dto.WriteRelationsResponse, error) {
+	if len(req.Updates) == 0 || len(req.Updates) > maxRelationUpdates {
+		return nil, fmt.Errorf("%s: between 1 and %d updates are required", constants.MsgInvalidRelation, maxRelationUpdates)
+	}
+
+	var touches, deletes []rebac.Tuple
+	for _, update := range req.Updates {
+		tuple, err := s.parseTuple(update.Tuple)
+		if err != nil {
+			return nil, err
+		}
+
+		switch update.Operation {
+		case dto.RelationTouch:
+			touches = append(touches, tuple)
+		case dto.RelationDelete:
+			deletes = append(deletes, tuple)
+		default:
+			return nil, fmt.Errorf("%s: operation must be %s or %s", constants.MsgInvalidRelation, dto.RelationTouch, dto.RelationDelete)
+		}
+	}
+
+	zookie, err := s.engine.Write(touches, deletes)
+	if err != nil {
+		return nil, fmt.Errorf("error writing relations: %w", err)
+	}
+
+	return &dto.WriteRelationsResponse{Zookie: string(zookie)}, nil
+}
+
+// Read lists the stored tuples of an object
+func (s *RelationService) Read(req *dto.ReadRelationsRequest) (*dto.ReadRelationsResponse, error) {
+	object, err := s.parseObject(req.Object)
+	if err != nil {
+		return nil, err
+	}
+	if req.Relation != "" {
+		if err := s.validateRelation(object.Namespace, req.Relation); err != nil {
+			return nil, err
+		}
+	}
+
+	consistency, err := relationConsistency(req.RelationConsistency)
+	if err != nil {
+		return nil, err
+	}
+
+	tuples, zookie, err := s.engine.Read(object, req.Relation, consistency)
+	if err != nil {
+		return nil, engineError("error reading relations", err)
+	}
+
+	resp := &dto.ReadRelationsResponse{Tuples: make([]dto.RelationTuple, 0, len(tuples)), Zookie: string(zookie)}
+	for _, tuple := range tuples {
+		resp.Tuples = append(resp.Tuples, dto.RelationTuple{
+			Object:   tuple.Object.String(),
+			Relation: tuple.Relation,
+			Subject:  tuple.Subject.String(),
+		})
+	}
+	return resp, nil
+}
+
+// Check decides whether the subject has the relation to the object. Users
+// may always check themselves; checking any other subject requires
+// relations:read.
+func (s *RelationService) Check(callerID string, req *dto.CheckRelationRequest) (*dto.CheckRelationResponse, error) {
+	object, err := s.parseObject(req.Object)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateRelation(object.Namespace, req.Relation); err != nil {
+		return nil, err
+	}
+	subject, err := s.parseSubject(req.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeSubject(callerID, subject); err != nil {
+		return nil, err
+	}
+
+	consistency, err := relationConsistency(req.RelationConsistency)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, zookie, err := s.engine.Check(object, req.Relation, subject, consistency)
+	if err != nil {
+		return nil, engineError("error checking relation", err)
+	}
+
+	return &dto.CheckRelationResponse{
+		Object:   object.String(),
+		Relation: req.Relation,
+		Subject:  subject.String(),
+		Allowed:  allowed,
+		Zookie:   string(zookie),
+	}, nil
+}
+
+// Expand returns the userset tree of an object's relation
+func (s *RelationService) Expand(req *dto.ExpandRelationRequest) (*dto.ExpandRelationResponse, error) {
+	object, err := s.parseObject(req.Object)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateRelation(object.Namespace, req.Relation); err != nil {
+		return nil, err
+	}
+
+	consistency, err := relationConsistency(req.RelationConsistency)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, zookie, err := s.engine.Expand(object, req.Relation, consistency)
+	if err != nil {
+		return nil, engineError("error expanding relation", err)
+	}
+
+	return &dto.ExpandRelationResponse{Tree: usersetTree(tree), Zookie: string(zookie)}, nil
+}
+
+// ListObjects returns the objects of a namespace the subject has the
+// relation to, with the same access rule as Check
+func (s *RelationService) ListObjects(callerID string, req *dto.ListObjectsRequest) (*dto.ListObjectsResponse, error) {
+	if err := s.validateRelation(req.Namespace, req.Relation); err != nil {
+		return nil, err
+	}
+	subject, err := s.parseSubject(req.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeSubject(callerID, subject); err != nil {
+		return nil, err
+	}
+
+	consistency, err := relationConsistency(req.RelationConsistency)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, zookie, err := s.engine.ListObjects(req.Namespace, req.Relation, subject, consistency)
+	if err != nil {
+		return nil, engineError("error listing objects", err)
+	}
+
+	return &dto.ListObjectsResponse{
+		Namespace: req.Namespace,
+		Relation:  req.Relation,
+		Subject:   subject.String(),
+		Objects:   objects,
+		Zookie:    string(zookie),
+	}, nil
+}
+
+// authorizeSubject lets callers ask about themselves and requires
+// relations:read for asking about anyone or anything else
+func (s *RelationService) authorizeSubject(callerID string, subject rebac.Subject) error {
+	if subject.Namespace == rebac.UserNamespace && subject.ID == callerID && !subject.IsUserset() {
+		return nil
+	}
+
+	decision, err := s.evaluator.Check(callerID, constants.PermRelationsRead)
+	if err != nil {
+		return fmt.Errorf("error checking caller permissions: %w", err)
+	}
+	if !decision.Allowed {
+		return fmt.Errorf(constants.MsgForbidden)
+	}
+	return nil
+}
+
+func (s *RelationService) parseTuple(t dto.RelationTuple) (rebac.Tuple, error) {
+	object, err := s.parseObject(t.Object)
+	if err != nil {
+		return rebac.Tuple{}, err
+	}
+	subject, err := rebac.ParseSubject(t.Subject)
+	if err != nil {
+		return rebac.Tuple{}, fmt.Errorf("%s: %v", constants.MsgInvalidRelation, err)
+	}
+
+	tuple := rebac.Tuple{Object: object, Relation: t.Relation, Subject: subject}
+	if err := s.engine.Schema().ValidateTuple(tuple); err != nil {
+		return rebac.Tuple{}, fmt.Errorf("%s: %v", constants.MsgInvalidRelation, err)
+	}
+	return tuple, nil
+}
+
+func (s *RelationService) parseObject(value string) (rebac.Object, error) {
+	object, err := rebac.ParseObject(value)
+	if err != nil {
+		return rebac.Object{}, fmt.Errorf("%s: %v", constants.MsgInvalidRelation, err)
+	}
+	if !s.engine.Schema().HasNamespace(object.Namespace) {
+		return rebac.Object{}, fmt.Errorf("%s: unknown namespace %s", constants.MsgInvalidRelation, object.Namespace)
+	}
+	return object, nil
+}
+
+func (s *RelationService) parseSubject(value string) (rebac.Subject, error) {
+	subject, err := rebac.ParseSubject(value)
+	if err != nil {
+		return rebac.Subject{}, fmt.Errorf("%s: %v", constants.MsgInvalidRelation, err)
+	}
+	return subject, nil
+}
+
+func (s *RelationService) validateRelation(namespace, relation string) error {
+	if _, ok := s.engine.Schema().Relation(namespace, relation); !ok {
+		return fmt.Errorf("%s: unknown relation %s#%s", constants.MsgInvalidRelation, namespace, relation)
+	}
+	return nil
+}
+
+// relationConsistency maps the requested consistency to the engine's
+func relationConsistency(c dto.RelationConsistency) (rebac.Consistency, error) {
+	switch c.Consistency {
+	case "", dto.ConsistencyAtLeastAsFresh:
+		return rebac.Consistency{Zookie: rebac.Zookie(c.Zookie)}, nil
+	case dto.ConsistencyMinimizeLatency:
+		return rebac.Consistency{}, nil
+	case dto.ConsistencyAtExactSnapshot:
+		if c.Zookie == "" {
+			return rebac.Consistency{}, fmt.Errorf("%s: %s requires a zookie", constants.MsgInvalidRelation, dto.ConsistencyAtExactSnapshot)
+		}
+		return rebac.Consistency{Zookie: rebac.Zookie(c.Zookie), Exact: true}, nil
+	default:
+		return rebac.Consistency{}, fmt.Errorf("%s: unknown consistency %s", constants.MsgInvalidRelation, c.Consistency)
+	}
+}
+
+func engineError(message string, err error) error {
+	if errors.Is(err, rebac.ErrInvalidZookie) {
+		return fmt.Errorf(constants.MsgInvalidZookie)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+func usersetTree(node *rebac.UsersetTree) *dto.UsersetTree {
+	tree := &dto.UsersetTree{
+		Operation: node.Operation,
+		Object:    node.Object.String(),
+		Relation:  node.Relation,
+	}
+	for _, subject := range node.Subjects {
+		tree.Subjects = append(tree.Subjects, subject.String())
+	}
+	for _, child := range node.Children {
+		tree.Children = append(tree.Children, usersetTree(child))
+	}
+	return tree
+}
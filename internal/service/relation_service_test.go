@@ -0,0 +1,140 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/rebac"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestRelationService_GroupFolderDocument(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	seedRoles(t, roleRepo)
+
+	evaluator := NewAuthzEvaluator(roleRepo, time.Minute)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithRoles(roleRepo, evaluator))
+	engine := NewRelationEngine(store.NewRelationTupleRepository(db), rebac.DefaultSchema())
+	relationService := NewRelationService(engine, evaluator)
+
+	var userIDs []string
+	for _, username := range []string{"alice", "bob"} {
+		resp, err := authService.Register(&dto.RegisterRequest{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: "password123",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		userIDs = append(userIDs, resp.User.ID)
+	}
+	alice, bob := userIDs[0], userIDs[1]
+
+	touch := func(object, relation, subject string) dto.RelationUpdate {
+		return dto.RelationUpdate{Operation: dto.RelationTouch, Tuple: dto.RelationTuple{Object: object, Relation: relation, Subject: subject}}
+	}
+
+	// Execute
+	written, err := relationService.Write(&dto.WriteRelationsRequest{Updates: []dto.RelationUpdate{
+		touch("group:eng", "member", "user:"+alice),
+		touch("folder:specs", "editor", "group:eng#member"),
+		touch("doc:design", "parent", "folder:specs"),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to write relations: %v", err)
+	}
+
+	check := func(callerID, subject string, c dto.RelationConsistency) (*dto.CheckRelationResponse, error) {
+		return relationService.Check(callerID, &dto.CheckRelationRequest{
+			Object:              "doc:design",
+			Relation:            "viewer",
+			Subject:             subject,
+			RelationConsistency: c,
+		})
+	}
+
+	// Assert
+	resp, err := check(alice, "user:"+alice, dto.RelationConsistency{Zookie: written.Zookie})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.Allowed {
+		t.Error("Expected alice to view the doc through her group and the folder")
+	}
+
+	resp, err = check(bob, "user:"+bob, dto.RelationConsistency{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Allowed {
+		t.Error("Expected bob not to view the doc")
+	}
+
+	// Checking someone else needs relations:read
+	_, err = check(bob, "user:"+alice, dto.RelationConsistency{})
+	if err == nil || err.Error() != constants.MsgForbidden {
+		t.Errorf("Expected forbidden error, got %v", err)
+	}
+
+	listed, err := relationService.ListObjects(alice, &dto.ListObjectsRequest{Namespace: "doc", Relation: "viewer", Subject: "user:" + alice})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(listed.Objects) != 1 || listed.Objects[0] != "design" {
+		t.Errorf("Expected alice to list doc design, got %v", listed.Objects)
+	}
+
+	// Removing alice from the group takes effect, while the old snapshot
+	// still shows her access
+	removed, err := relationService.Write(&dto.WriteRelationsRequest{Updates: []dto.RelationUpdate{
+		{Operation: dto.RelationDelete, Tuple: dto.RelationTuple{Object: "group:eng", Relation: "member", Subject: "user:" + alice}},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to write relations: %v", err)
+	}
+
+	resp, err = check(alice, "user:"+alice, dto.RelationConsistency{Zookie: removed.Zookie})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Allowed {
+		t.Error("Expected alice to lose access after leaving the group")
+	}
+
+	resp, err = check(alice, "user:"+alice, dto.RelationConsistency{Zookie: written.Zookie, Consistency: dto.ConsistencyAtExactSnapshot})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.Allowed {
+		t.Error("Expected the snapshot before the removal to allow access")
+	}
+
+	// Touching a live tuple again is a no-op
+	if _, err := relationService.Write(&dto.WriteRelationsRequest{Updates: []dto.RelationUpdate{
+		touch("doc:design", "parent", "folder:specs"),
+	}}); err != nil {
+		t.Fatalf("Expected touching an existing tuple to succeed, got %v", err)
+	}
+	read, err := relationService.Read(&dto.ReadRelationsRequest{Object: "doc:design"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(read.Tuples) != 1 {
+		t.Errorf("Expected a single tuple on the doc, got %v", read.Tuples)
+	}
+
+	_, err = relationService.Write(&dto.WriteRelationsRequest{Updates: []dto.RelationUpdate{
+		touch("doc:design", "approver", "user:"+bob),
+	}})
+	if err == nil || !contains(err.Error(), constants.MsgInvalidRelation) {
+		t.Errorf("Expected invalid relation error, got %v", err)
+	}
+}
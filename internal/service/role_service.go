@@ -0,0 +1,517 @@
+package service
+
+import (
+	"authorization/internal/authz"
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// OperatorActor is the actor of changes made by operators with access to the
+// database, such as through the roles command, who may grant anything
+const OperatorActor = "operator"
+
+// RoleService manages roles, the permissions they grant, their inheritance
+// and which users hold them. Changes reach a user's access tokens on their
+// next refresh.
+type RoleService struct {
+	roleRepo  *store.RoleRepository
+	userRepo  *store.UserRepository
+	evaluator *authz.Evaluator
+}
+
+func NewRoleService(roleRepo *store.RoleRepository, userRepo *store.UserRepository, evaluator *authz.Evaluator) *RoleService {
+	return &RoleService{
+		roleRepo:  roleRepo,
+		userRepo:  userRepo,
+		evaluator: evaluator,
+	}
+}
+
+func (s *RoleService) ListRoles() (*dto.RoleListResponse, error) {
+	roles, err := s.roleRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing roles: %w", err)
+	}
+
+	resp := &dto.RoleListResponse{Roles: make([]dto.RoleInfo, 0, len(roles))}
+	for _, role := range roles {
+		resp.Roles = append(resp.Roles, roleInfo(role))
+	}
+	return resp, nil
+}
+
+// CreateRole creates a role. The actor must hold everything it grants.
+func (s *RoleService) CreateRole(actorID string, req *dto.CreateRoleRequest) (*dto.RoleInfo, error) {
+	if _, err := s.roleRepo.GetByName(req.Name); err == nil {
+		return nil, fmt.Errorf(constants.MsgRoleAlreadyExists)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error checking role: %w", err)
+	}
+
+	permissions, err := s.resolvePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new role cannot close a cycle since nothing inherits from it yet
+	inherits, err := s.resolveRoles(s.roleRepo, req.Inherits)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := req.Scope
+	if scope == "" {
+		scope = constants.RoleScopeGlobal
+	}
+	if err := checkRoleScope(scope, permissions, inherits); err != nil {
+		return nil, err
+	}
+	if err := s.checkGrantable(actorID, req.Permissions, req.Inherits); err != nil {
+		return nil, err
+	}
+
+	role := &model.Role{
+		ID:          utils.GenerateUUIDv7(),
+		Name:        req.Name,
+		Description: req.Description,
+		Scope:       scope,
+		Permissions: permissions,
+		Inherits:    inherits,
+	}
+
+	if err := s.roleRepo.Create(role); err != nil {
+		return nil, fmt.Errorf("error creating role: %w", err)
+	}
+
+	info := roleInfo(role)
+	return &info, nil
+}
+
+// SetRolePermissions replaces the permissions granted by the named role. The
+// actor must hold the permissions added.
+func (s *RoleService) SetRolePermissions(actorID, name string, req *dto.SetRolePermissionsRequest) (*dto.RoleInfo, error) {
+	role, err := s.getRole(name)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.resolvePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRoleScope(role.Scope, permissions, nil); err != nil {
+		return nil, err
+	}
+
+	var current []string
+	for _, permission := range role.Permissions {
+		current = append(current, permission.Name)
+	}
+	if err := s.checkGrantable(actorID, added(current, req.Permissions), nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.SetPermissions(role, permissions); err != nil {
+		return nil, fmt.Errorf("error setting role permissions: %w", err)
+	}
+	role.Permissions = permissions
+	s.evaluator.InvalidateAll()
+
+	info := roleInfo(role)
+	return &info, nil
+}
+
+// SetRoleInherits replaces the roles the named role inherits from, refusing
+// changes that would make a role inherit from itself. The actor must hold
+// everything the roles added grant.
+func (s *RoleService) SetRoleInherits(actorID, name string, req *dto.SetRoleInheritsRequest) (*dto.RoleInfo, error) {
+	var role *model.Role
+	err := s.roleRepo.Transaction(func(repo *store.RoleRepository) error {
+		roles, err := repo.ListForUpdate()
+		if err != nil {
+			return fmt.Errorf("error listing roles: %w", err)
+		}
+
+		for _, r := range roles {
+			if r.Name == name {
+				role = r
+			}
+		}
+		if role == nil {
+			return fmt.Errorf(constants.MsgRoleNotFound)
+		}
+
+		inherits, err := s.resolveRoles(repo, req.Inherits)
+		if err != nil {
+			return err
+		}
+		if err := checkRoleScope(role.Scope, nil, inherits); err != nil {
+			return err
+		}
+
+		var current []string
+		for _, inherited := range role.Inherits {
+			current = append(current, inherited.Name)
+		}
+		if err := s.checkGrantable(actorID, nil, added(current, req.Inherits)); err != nil {
+			return err
+		}
+
+		role.Inherits = inherits
+		if _, err := authz.NewHierarchy(roleDefinitions(roles)); err != nil {
+			var cycle *authz.CycleError
+			if errors.As(err, &cycle) {
+				return fmt.Errorf("%s: %s", constants.MsgInheritanceCycle, strings.Join(cycle.Path, " -> "))
+			}
+			return err
+		}
+
+		if err := repo.SetInherits(role, inherits); err != nil {
+			return fmt.Errorf("error setting role inheritance: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.evaluator.InvalidateAll()
+
+	info := roleInfo(role)
+	return &info, nil
+}
+
+func (s *RoleService) ListPermissions() (*dto.PermissionListResponse, error) {
+	permissions, err := s.roleRepo.ListPermissions()
+	if err != nil {
+		return nil, fmt.Errorf("error listing permissions: %w", err)
+	}
+
+	resp := &dto.PermissionListResponse{Permissions: make([]dto.PermissionInfo, 0, len(permissions))}
+	for _, permission := range permissions {
+		resp.Permissions = append(resp.Permissions, permissionInfo(permission))
+	}
+	return resp, nil
+}
+
+// CreatePermission registers a permission or wildcard grant that roles can
+// then be given
+func (s *RoleService) CreatePermission(req *dto.CreatePermissionRequest) (*dto.PermissionInfo, error) {
+	existing, err := s.roleRepo.GetPermissionsByName([]string{req.Name})
+	if err != nil {
+		return nil, fmt.Errorf("error checking permission: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf(constants.MsgPermissionExists)
+	}
+
+	permission := &model.Permission{
+		ID:          utils.GenerateUUIDv7(),
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := s.roleRepo.CreatePermission(permission); err != nil {
+		return nil, fmt.Errorf("error creating permission: %w", err)
+	}
+
+	info := permissionInfo(permission)
+	return &info, nil
+}
+
+func (s *RoleService) GetUserRoles(userID string) (*dto.UserRolesResponse, error) {
+	if _, err := s.getUser(userID); err != nil {
+		return nil, err
+	}
+	return s.userRoles(userID)
+}
+
+// AssignRole gives the user the named role. Organization roles are only
+// assigned to members within an organization. The actor must hold
+// everything the role grants.
+func (s *RoleService) AssignRole(actorID, userID, roleName string) (*dto.UserRolesResponse, error) {
+	if _, err := s.getUser(userID); err != nil {
+		return nil, err
+	}
+
+	role, err := s.getRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role.Scope == constants.RoleScopeOrganization {
+		return nil, fmt.Errorf(constants.MsgRoleWrongScope)
+	}
+	if err := s.checkGrantable(actorID, nil, []string{role.Name}); err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.AssignRole(userID, role.ID); err != nil {
+		return nil, fmt.Errorf("error assigning role: %w", err)
+	}
+	s.evaluator.InvalidateUser(userID)
+
+	return s.userRoles(userID)
+}
+
+// RevokeRole takes the named role away from the user. The admin role cannot
+// be taken from the last user holding it, which would lock everyone out of
+// the admin endpoints.
+func (s *RoleService) RevokeRole(userID, roleName string) error {
+	if _, err := s.getUser(userID); err != nil {
+		return err
+	}
+
+	role, err := s.getRole(roleName)
+	if err != nil {
+		return err
+	}
+
+	var removed int64
+	err = s.roleRepo.Transaction(func(repo *store.RoleRepository) error {
+		// Otherwise two admins revoking each other could both see the other
+		// one left and remove the last two admins
+		if role.Name == constants.RoleAdmin {
+			if err := repo.LockRoleAssignments(role.ID); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		removed, err = repo.RemoveRole(userID, role.ID)
+		if err != nil || removed == 0 || role.Name != constants.RoleAdmin {
+			return err
+		}
+
+		remaining, err := repo.CountUsersWithRole(role.ID)
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return errors.New(constants.MsgLastAdmin)
+		}
+		return nil
+	})
+	if err != nil {
+		if err.Error() == constants.MsgLastAdmin {
+			return err
+		}
+		return fmt.Errorf("error revoking role: %w", err)
+	}
+
+	if removed == 0 {
+		return fmt.Errorf(constants.MsgRoleNotFound)
+	}
+	s.evaluator.InvalidateUser(userID)
+	return nil
+}
+
+// checkGrantable refuses to grant the permissions, or what the roles grant,
+// unless the actor holds it, so that roles:write and roles:assign cannot be
+// used to gain more access than the actor has
+func (s *RoleService) checkGrantable(actorID string, permissions, roles []string) error {
+	if actorID == OperatorActor || len(permissions)+len(roles) == 0 {
+		return nil
+	}
+
+	actor, err := s.evaluator.Resolve(actorID)
+	if err != nil {
+		return fmt.Errorf("error resolving grants: %w", err)
+	}
+	granted, err := s.evaluator.ResolveRoles(roles...)
+	if err != nil {
+		return fmt.Errorf("error resolving grants: %w", err)
+	}
+
+	for _, grant := range append(append([]string(nil), permissions...), granted.Permissions...) {
+		if !actor.Check(grant).Allowed {
+			return fmt.Errorf("%s: %s", constants.MsgGrantNotHeld, grant)
+		}
+	}
+	return nil
+}
+
+func (s *RoleService) getUser(userID string) (*model.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	return user, nil
+}
+
+func (s *RoleService) getRole(name string) (*model.Role, error) {
+	role, err := s.roleRepo.GetByName(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgRoleNotFound)
+		}
+		return nil, fmt.Errorf("error getting role: %w", err)
+	}
+	return role, nil
+}
+
+func (s *RoleService) userRoles(userID string) (*dto.UserRolesResponse, error) {
+	grants, err := s.evaluator.Resolve(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving user roles: %w", err)
+	}
+
+	return &dto.UserRolesResponse{
+		UserID:         userID,
+		Roles:          nonNil(grants.AssignedRoles),
+		EffectiveRoles: nonNil(grants.Roles),
+		Permissions:    nonNil(grants.Permissions),
+	}, nil
+}
+
+// resolvePermissions loads the named permissions, failing if any is unknown
+func (s *RoleService) resolvePermissions(names []string) ([]model.Permission, error) {
+	if len(names) == 0 {
+		return []model.Permission{}, nil
+	}
+
+	permissions, err := s.roleRepo.GetPermissionsByName(names)
+	if err != nil {
+		return nil, fmt.Errorf("error getting permissions: %w", err)
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%s: %s", constants.MsgUnknownPermission, name)
+		}
+	}
+
+	return permissions, nil
+}
+
+// resolveRoles loads the named roles, failing if any is unknown
+func (s *RoleService) resolveRoles(repo *store.RoleRepository, names []string) ([]model.Role, error) {
+	if len(names) == 0 {
+		return []model.Role{}, nil
+	}
+
+	roles, err := repo.GetByNames(names)
+	if err != nil {
+		return nil, fmt.Errorf("error getting roles: %w", err)
+	}
+
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf(constants.MsgRoleNotFound)
+		}
+	}
+
+	return roles, nil
+}
+
+// checkRoleScope keeps organization roles to organization permissions. Their
+// grants end up in the org_permissions claim, but an organization role that
+// inherits a global role or grants a global permission would still let an
+// organization admin hand out more than the organization is theirs to give.
+func checkRoleScope(scope string, permissions []model.Permission, inherits []model.Role) error {
+	if scope != constants.RoleScopeOrganization {
+		return nil
+	}
+
+	for _, inherited := range inherits {
+		if inherited.Scope != constants.RoleScopeOrganization {
+			return fmt.Errorf(constants.MsgOrgRoleTooBroad)
+		}
+	}
+	for _, permission := range permissions {
+		grant := authz.NewPermissionSet(permission.Name)
+		for _, global := range constants.GlobalPermissions {
+			if grant.Allows(global) {
+				return fmt.Errorf(constants.MsgOrgRoleTooBroad)
+			}
+		}
+	}
+	return nil
+}
+
+func roleDefinitions(roles []*model.Role) []authz.RoleDefinition {
+	definitions := make([]authz.RoleDefinition, 0, len(roles))
+	for _, role := range roles {
+		definition := authz.RoleDefinition{Name: role.Name}
+		for _, inherited := range role.Inherits {
+			definition.Inherits = append(definition.Inherits, inherited.Name)
+		}
+		for _, permission := range role.Permissions {
+			definition.Permissions = append(definition.Permissions, permission.Name)
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions
+}
+
+func roleInfo(role *model.Role) dto.RoleInfo {
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	sort.Strings(permissions)
+
+	inherits := make([]string, 0, len(role.Inherits))
+	for _, inherited := range role.Inherits {
+		inherits = append(inherits, inherited.Name)
+	}
+	sort.Strings(inherits)
+
+	return dto.RoleInfo{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Scope:       role.Scope,
+		Permissions: permissions,
+		Inherits:    inherits,
+		CreatedAt:   role.CreatedAt,
+	}
+}
+
+func permissionInfo(permission *model.Permission) dto.PermissionInfo {
+	return dto.PermissionInfo{
+		ID:          permission.ID,
+		Name:        permission.Name,
+		Description: permission.Description,
+	}
+}
+
+// added returns the names in next that are not in current
+func added(current, next []string) []string {
+	have := make(map[string]bool, len(current))
+	for _, name := range current {
+		have[name] = true
+	}
+
+	var names []string
+	for _, name := range next {
+		if !have[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"testing"
+	"time"
+)
+
+// seedRoles creates the built-in roles and permissions the RBAC migrations seed
+func seedRoles(t *testing.T, roleRepo *store.RoleRepository) {
+	var permissions []model.Permission
+	for _, name := range []string{constants.PermUsersRead, constants.PermUsersWrite, constants.PermRolesRead, constants.PermRolesWrite, constants.PermRolesAssign, constants.PermAuthzCheck} {
+		permission := model.Permission{ID: utils.GenerateUUIDv7(), Name: name}
+		if err := roleRepo.CreatePermission(&permission); err != nil {
+			t.Fatalf("Failed to create permission: %v", err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	roles := []*model.Role{
+		{ID: utils.GenerateUUIDv7(), Name: constants.RoleUser},
+		{ID: utils.GenerateUUIDv7(), Name: constants.RoleAdmin, Permissions: permissions},
+	}
+	for _, role := range roles {
+		if err := roleRepo.Create(role); err != nil {
+			t.Fatalf("Failed to create role: %v", err)
+		}
+	}
+}
+
+func TestRoleService_RolesReachAccessTokens(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	seedRoles(t, roleRepo)
+
+	evaluator := NewAuthzEvaluator(roleRepo, time.Minute)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithRoles(roleRepo, evaluator))
+	roleService := NewRoleService(roleRepo, userRepo, evaluator)
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := registerResp.User.ID
+
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	claims, err := jwtManager.ValidateAccessToken(loginResp.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != constants.RoleUser {
+		t.Errorf("Expected new user to hold the default role, got %v", claims.Roles)
+	}
+	if len(claims.Permissions) != 0 {
+		t.Errorf("Expected no permissions, got %v", claims.Permissions)
+	}
+
+	// Execute
+	_, err = roleService.AssignRole(OperatorActor, userID, constants.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Failed to assign role: %v", err)
+	}
+
+	refreshResp, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: loginResp.RefreshToken})
+	if err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+
+	// Assert
+	claims, err = jwtManager.ValidateAccessToken(refreshResp.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+
+	if len(claims.Roles) != 2 {
+		t.Errorf("Expected 2 roles after refresh, got %v", claims.Roles)
+	}
+
+	if len(claims.Permissions) != 6 {
+		t.Errorf("Expected the admin permissions after refresh, got %v", claims.Permissions)
+	}
+}
+
+func TestRoleService_RevokeRole_LastAdmin(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	seedRoles(t, roleRepo)
+
+	evaluator := NewAuthzEvaluator(roleRepo, time.Minute)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithRoles(roleRepo, evaluator))
+	roleService := NewRoleService(roleRepo, userRepo, evaluator)
+
+	var userIDs []string
+	for _, username := range []string{"alice", "bob"} {
+		resp, err := authService.Register(&dto.RegisterRequest{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: "password123",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		if _, err := roleService.AssignRole(OperatorActor, resp.User.ID, constants.RoleAdmin); err != nil {
+			t.Fatalf("Failed to assign role: %v", err)
+		}
+		userIDs = append(userIDs, resp.User.ID)
+	}
+
+	if err := roleService.RevokeRole(userIDs[0], constants.RoleAdmin); err != nil {
+		t.Fatalf("Expected revoking one of two admins to succeed, got %v", err)
+	}
+
+	// Execute
+	err := roleService.RevokeRole(userIDs[1], constants.RoleAdmin)
+
+	// Assert
+	if err == nil || err.Error() != constants.MsgLastAdmin {
+		t.Fatalf("Expected last admin error, got %v", err)
+	}
+
+	roles, err := roleService.GetUserRoles(userIDs[1])
+	if err != nil {
+		t.Fatalf("Failed to get user roles: %v", err)
+	}
+	if len(roles.Roles) != 2 {
+		t.Errorf("Expected the last admin to keep the role, got %v", roles.Roles)
+	}
+}
+
+func TestUserService_DisableUser_LastActiveAdmin(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	seedRoles(t, roleRepo)
+
+	evaluator := NewAuthzEvaluator(roleRepo, time.Minute)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithRoles(roleRepo, evaluator))
+	roleService := NewRoleService(roleRepo, userRepo, evaluator)
+	userService := NewUserService(userRepo, tokenRepo, evaluator, nil)
+
+	var userIDs []string
+	for _, username := range []string{"alice", "bob", "carol"} {
+		resp, err := authService.Register(&dto.RegisterRequest{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: "password123",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		userIDs = append(userIDs, resp.User.ID)
+	}
+	alice, bob, carol := userIDs[0], userIDs[1], userIDs[2]
+	for _, userID := range []string{alice, bob} {
+		if _, err := roleService.AssignRole(OperatorActor, userID, constants.RoleAdmin); err != nil {
+			t.Fatalf("Failed to assign role: %v", err)
+		}
+	}
+
+	// Execute and assert: nobody disables themselves
+	if _, err := userService.DisableUser(alice, alice); err == nil || err.Error() != constants.MsgCannotDisableSelf {
+		t.Errorf("Expected error '%s', got %v", constants.MsgCannotDisableSelf, err)
+	}
+
+	// One of two admins can be disabled, the other one is left
+	if _, err := userService.DisableUser(alice, bob); err != nil {
+		t.Fatalf("Expected disabling one of two admins to succeed, got %v", err)
+	}
+	if _, err := userService.DisableUser(carol, alice); err == nil || err.Error() != constants.MsgLastActiveAdmin {
+		t.Errorf("Expected error '%s', got %v", constants.MsgLastActiveAdmin, err)
+	}
+	if user, err := userRepo.GetByID(alice); err != nil || user.DisabledAt != nil {
+		t.Errorf("Expected the last active admin to stay enabled, got %+v, %v", user, err)
+	}
+
+	// Users without the role are not held back
+	if _, err := userService.DisableUser(alice, carol); err != nil {
+		t.Errorf("Expected disabling a user to succeed, got %v", err)
+	}
+
+	// Disabled admins do not count as admins left
+	if err := roleService.RevokeRole(alice, constants.RoleAdmin); err == nil || err.Error() != constants.MsgLastAdmin {
+		t.Errorf("Expected error '%s', got %v", constants.MsgLastAdmin, err)
+	}
+}
+
+func TestRoleService_InheritanceAndCheck(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	seedRoles(t, roleRepo)
+
+	evaluator := NewAuthzEvaluator(roleRepo, time.Minute)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithRoles(roleRepo, evaluator))
+	roleService := NewRoleService(roleRepo, userRepo, evaluator)
+	authzService := NewAuthzService(evaluator, userRepo)
+
+	for _, name := range []string{"billing:invoices:read", "billing:*"} {
+		if _, err := roleService.CreatePermission(&dto.CreatePermissionRequest{Name: name}); err != nil {
+			t.Fatalf("Failed to create permission: %v", err)
+		}
+	}
+	for _, req := range []dto.CreateRoleRequest{
+		{Name: "viewer", Permissions: []string{"billing:invoices:read"}},
+		{Name: "editor", Inherits: []string{"viewer"}},
+		{Name: "billing-admin", Inherits: []string{"editor"}, Permissions: []string{"billing:*"}},
+	} {
+		if _, err := roleService.CreateRole(OperatorActor, &req); err != nil {
+			t.Fatalf("Failed to create role %s: %v", req.Name, err)
+		}
+	}
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := registerResp.User.ID
+
+	if _, err := roleService.AssignRole(OperatorActor, userID, "editor"); err != nil {
+		t.Fatalf("Failed to assign role: %v", err)
+	}
+
+	// Execute
+	readResp, err := authzService.Check(userID, &dto.AuthzCheckRequest{Permission: "billing:invoices:read"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	voidResp, err := authzService.Check(userID, &dto.AuthzCheckRequest{Permission: "billing:invoices:void"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assert
+	if !readResp.Allowed || readResp.Role != "viewer" {
+		t.Errorf("Expected read to be allowed through the inherited viewer role, got %+v", readResp)
+	}
+	if voidResp.Allowed {
+		t.Errorf("Expected void to be denied for an editor, got %+v", voidResp)
+	}
+
+	// Promoting the user is visible immediately
+	if _, err := roleService.AssignRole(OperatorActor, userID, "billing-admin"); err != nil {
+		t.Fatalf("Failed to assign role: %v", err)
+	}
+	voidResp, err = authzService.Check(userID, &dto.AuthzCheckRequest{Permission: "billing:invoices:void"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !voidResp.Allowed || voidResp.Grant != "billing:*" {
+		t.Errorf("Expected void to be allowed by the wildcard grant, got %+v", voidResp)
+	}
+
+	// Making viewer inherit from billing-admin would close a cycle
+	_, err = roleService.SetRoleInherits(OperatorActor, "viewer", &dto.SetRoleInheritsRequest{Inherits: []string{"billing-admin"}})
+	if err == nil || !contains(err.Error(), constants.MsgInheritanceCycle) {
+		t.Errorf("Expected inheritance cycle error, got %v", err)
+	}
+
+	// Other users may only be checked with authz:check
+	otherResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "other",
+		Email:    "other@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	_, err = authzService.Check(otherResp.User.ID, &dto.AuthzCheckRequest{UserID: userID, Permission: "billing:invoices:read"})
+	if err == nil || err.Error() != constants.MsgForbidden {
+		t.Errorf("Expected forbidden error, got %v", err)
+	}
+}
+
+func TestRoleService_CannotGrantWhatActorLacks(t *testing.T) {
+	// Setup: a role manager who may write and assign roles, but is no admin
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	seedRoles(t, roleRepo)
+
+	evaluator := NewAuthzEvaluator(roleRepo, time.Minute)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithRoles(roleRepo, evaluator))
+	roleService := NewRoleService(roleRepo, userRepo, evaluator)
+
+	if _, err := roleService.CreatePermission(&dto.CreatePermissionRequest{Name: "*"}); err != nil {
+		t.Fatalf("Failed to create permission: %v", err)
+	}
+	for _, req := range []dto.CreateRoleRequest{
+		{Name: "role-manager", Permissions: []string{constants.PermRolesRead, constants.PermRolesWrite, constants.PermRolesAssign}},
+		{Name: "reader", Permissions: []string{constants.PermRolesRead}},
+	} {
+		if _, err := roleService.CreateRole(OperatorActor, &req); err != nil {
+			t.Fatalf("Failed to create role %s: %v", req.Name, err)
+		}
+	}
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "manager",
+		Email:    "manager@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	managerID := registerResp.User.ID
+	if _, err := roleService.AssignRole(OperatorActor, managerID, "role-manager"); err != nil {
+		t.Fatalf("Failed to assign role: %v", err)
+	}
+
+	// Execute & Assert: nothing beyond the manager's own grants
+	refused := func(action string, err error) {
+		t.Helper()
+		if err == nil || !contains(err.Error(), constants.MsgGrantNotHeld) {
+			t.Errorf("Expected %s to be refused, got %v", action, err)
+		}
+	}
+	_, err = roleService.AssignRole(managerID, managerID, constants.RoleAdmin)
+	refused("assigning admin", err)
+	_, err = roleService.SetRolePermissions(managerID, "role-manager", &dto.SetRolePermissionsRequest{
+		Permissions: []string{constants.PermRolesRead, constants.PermRolesWrite, constants.PermRolesAssign, "*"},
+	})
+	refused("adding * to their role", err)
+	_, err = roleService.SetRoleInherits(managerID, "role-manager", &dto.SetRoleInheritsRequest{Inherits: []string{constants.RoleAdmin}})
+	refused("inheriting admin", err)
+	_, err = roleService.CreateRole(managerID, &dto.CreateRoleRequest{Name: "superuser", Permissions: []string{constants.PermUsersWrite}})
+	refused("creating a broader role", err)
+
+	// What they hold they may pass on
+	if _, err := roleService.AssignRole(managerID, managerID, "reader"); err != nil {
+		t.Errorf("Expected a role within the manager's grants to be assignable, got %v", err)
+	}
+	if _, err := roleService.SetRolePermissions(managerID, "reader", &dto.SetRolePermissionsRequest{Permissions: []string{constants.PermRolesRead, constants.PermRolesAssign}}); err != nil {
+		t.Errorf("Expected a permission the manager holds to be grantable, got %v", err)
+	}
+}
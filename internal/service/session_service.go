@@ -0,0 +1,83 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/store"
+	"fmt"
+)
+
+// SessionService exposes a user's refresh token families as sessions
+type SessionService struct {
+	tokenRepo *store.TokenRepository
+	revoker   *AccessTokenRevoker
+}
+
+// NewSessionService creates the service. Revoked sessions lose their access
+// tokens through revoker; with a nil revoker those stay valid until they
+// expire.
+func NewSessionService(tokenRepo *store.TokenRepository, revoker *AccessTokenRevoker) *SessionService {
+	return &SessionService{
+		tokenRepo: tokenRepo,
+		revoker:   revoker,
+	}
+}
+
+// ListSessions returns the active sessions of a user, flagging the one the
+// request was made from
+func (s *SessionService) ListSessions(userID, currentSessionID string) (*dto.SessionListResponse, error) {
+	tokens, err := s.tokenRepo.GetActiveByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sessions: %w", err)
+	}
+
+	sessions := make([]dto.SessionInfo, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, dto.SessionInfo{
+			ID:          token.FamilyID,
+			DeviceLabel: token.DeviceLabel,
+			UserAgent:   token.UserAgent,
+			IPAddress:   token.IPAddress,
+			CreatedAt:   token.SessionStartedAt,
+			LastUsedAt:  token.CreatedAt,
+			ExpiresAt:   token.ExpiresAt,
+			Current:     token.FamilyID == currentSessionID,
+		})
+	}
+
+	return &dto.SessionListResponse{Sessions: sessions}, nil
+}
+
+// RevokeSession signs a single session of the user out
+func (s *SessionService) RevokeSession(userID, sessionID string) error {
+	revoked, err := s.tokenRepo.RevokeUserFamily(userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	if revoked == 0 {
+		return fmt.Errorf(constants.MsgSessionNotFound)
+	}
+	return s.revoker.RevokeSessions(sessionID)
+}
+
+// RevokeOtherSessions signs the user out everywhere except the current session
+func (s *SessionService) RevokeOtherSessions(userID, currentSessionID string) error {
+	if err := s.revoker.RevokeUserSessions(userID, currentSessionID); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.RevokeAllUserTokensExcept(userID, currentSessionID); err != nil {
+		return fmt.Errorf("error revoking sessions: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllSessions signs the user out everywhere, including the current session
+func (s *SessionService) RevokeAllSessions(userID string) error {
+	if err := s.revoker.RevokeUserSessions(userID, ""); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.RevokeAllUserTokens(userID); err != nil {
+		return fmt.Errorf("error revoking sessions: %w", err)
+	}
+	return nil
+}
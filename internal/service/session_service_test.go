@@ -0,0 +1,182 @@
+package service
+
+import (
+	"authorization/internal/dto"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestSessionService_ListAndRevoke(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	sessionService := NewSessionService(tokenRepo, nil)
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	userID := registerResp.User.ID
+
+	laptop, err := authService.Login(&dto.LoginRequest{
+		Username: "testuser",
+		Password: "password123",
+		Client: dto.ClientInfo{
+			UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+			IPAddress: "203.0.113.10",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	phone, err := authService.Login(&dto.LoginRequest{
+		Username:    "testuser",
+		Password:    "password123",
+		DeviceLabel: "My phone",
+		Client:      dto.ClientInfo{IPAddress: "198.51.100.7"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	// Refreshing keeps the session but records where it was last used from
+	_, err = authService.RefreshToken(&dto.RefreshRequest{
+		RefreshToken: phone.RefreshToken,
+		Client:       dto.ClientInfo{IPAddress: "198.51.100.8"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+
+	laptopClaims, err := jwtManager.ValidateAccessToken(laptop.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+	phoneClaims, err := jwtManager.ValidateAccessToken(phone.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+
+	// Execute
+	list, err := sessionService.ListSessions(userID, laptopClaims.SessionID)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(list.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(list.Sessions))
+	}
+
+	for _, session := range list.Sessions {
+		switch session.ID {
+		case laptopClaims.SessionID:
+			if !session.Current {
+				t.Error("Expected laptop session to be current")
+			}
+			if session.DeviceLabel != "Chrome on macOS" {
+				t.Errorf("Expected device label derived from user agent, got %q", session.DeviceLabel)
+			}
+		case phoneClaims.SessionID:
+			if session.Current {
+				t.Error("Expected phone session not to be current")
+			}
+			if session.DeviceLabel != "My phone" {
+				t.Errorf("Expected device label to survive rotation, got %q", session.DeviceLabel)
+			}
+			if session.IPAddress != "198.51.100.8" {
+				t.Errorf("Expected last used IP 198.51.100.8, got %s", session.IPAddress)
+			}
+			if session.CreatedAt.After(session.LastUsedAt) {
+				t.Error("Expected session creation to precede last use")
+			}
+		default:
+			t.Errorf("Unexpected session %s", session.ID)
+		}
+	}
+
+	// Revoking everything but the current session leaves only the laptop
+	if err := sessionService.RevokeOtherSessions(userID, laptopClaims.SessionID); err != nil {
+		t.Fatalf("Failed to revoke other sessions: %v", err)
+	}
+
+	list, err = sessionService.ListSessions(userID, laptopClaims.SessionID)
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(list.Sessions) != 1 || list.Sessions[0].ID != laptopClaims.SessionID {
+		t.Errorf("Expected only the current session to remain, got %+v", list.Sessions)
+	}
+
+	if err := sessionService.RevokeSession(userID, laptopClaims.SessionID); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: laptop.RefreshToken}); err == nil {
+		t.Error("Expected refresh to fail for a revoked session")
+	}
+}
+
+func TestSessionService_RevokeSession_OtherUser(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	sessionService := NewSessionService(tokenRepo, nil)
+
+	for _, username := range []string{"alice", "mallory"} {
+		_, err := authService.Register(&dto.RegisterRequest{
+			Username: username,
+			Email:    username + "@example.com",
+			Password: "password123",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	alice, err := authService.Login(&dto.LoginRequest{Username: "alice", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	aliceClaims, err := jwtManager.ValidateAccessToken(alice.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate access token: %v", err)
+	}
+
+	mallory, err := userRepo.GetByUsername("mallory")
+	if err != nil {
+		t.Fatalf("Failed to load user: %v", err)
+	}
+
+	// Execute
+	err = sessionService.RevokeSession(mallory.ID, aliceClaims.SessionID)
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected error when revoking another user's session, got nil")
+	}
+
+	if !contains(err.Error(), "not found") {
+		t.Errorf("Expected session not found error, got %v", err)
+	}
+
+	if _, err := authService.RefreshToken(&dto.RefreshRequest{RefreshToken: alice.RefreshToken}); err != nil {
+		t.Errorf("Expected alice's session to remain valid, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserImportService creates accounts from the exports of systems being
+// consolidated into this one. Password hashes are kept as they are, so users
+// keep their passwords; their first login moves the hash to argon2id.
+type UserImportService struct {
+	userRepo *store.UserRepository
+	roleRepo *store.RoleRepository
+}
+
+// NewUserImportService creates the service. Imported users get
+// constants.DefaultUserRole unless roleRepo is nil.
+func NewUserImportService(userRepo *store.UserRepository, roleRepo *store.RoleRepository) *UserImportService {
+	return &UserImportService{
+		userRepo: userRepo,
+		roleRepo: roleRepo,
+	}
+}
+
+// Import creates an account for each record. Records that are incomplete,
+// have a password hash in an unknown format or clash with an existing account
+// or an earlier record are skipped and reported. With dryRun the records are
+// checked but nothing is created. Only internal failures stop an import.
+func (s *UserImportService) Import(records []dto.ImportUserRecord, dryRun bool) (*dto.ImportUsersResult, error) {
+	var defaultRole *model.Role
+	if s.roleRepo != nil {
+		var err error
+		defaultRole, err = s.roleRepo.GetByName(constants.DefaultUserRole)
+		if err != nil {
+			return nil, fmt.Errorf("error getting default role: %w", err)
+		}
+	}
+
+	result := &dto.ImportUsersResult{Skipped: []dto.ImportUserSkip{}}
+	usernames := make(map[string]bool)
+	emails := make(map[string]bool)
+	for i, record := range records {
+		reason, err := s.checkRecord(&record, usernames, emails)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			result.Skipped = append(result.Skipped, dto.ImportUserSkip{Record: i + 1, Username: record.Username, Reason: reason})
+			continue
+		}
+		usernames[record.Username] = true
+		emails[record.Email] = true
+
+		if !dryRun {
+			if err := s.createUser(&record, defaultRole); err != nil {
+				return nil, fmt.Errorf("error importing record %d: %w", i+1, err)
+			}
+		}
+		result.Created++
+	}
+	return result, nil
+}
+
+// checkRecord returns why the record cannot be imported, or "" if it can
+func (s *UserImportService) checkRecord(record *dto.ImportUserRecord, usernames, emails map[string]bool) (string, error) {
+	switch {
+	case record.Username == "" || record.Email == "" || record.PasswordHash == "":
+		return "username, email and password_hash are required", nil
+	case len(record.Username) > 255:
+		return "username is too long", nil
+	case usernames[record.Username]:
+		return "username appears earlier in the import", nil
+	case emails[record.Email]:
+		return "email appears earlier in the import", nil
+	}
+
+	if err := utils.CheckPasswordHash(record.PasswordHash); err != nil {
+		return fmt.Sprintf("password hash is not supported: %v", err), nil
+	}
+
+	exists, err := s.userRepo.ExistsByUsername(record.Username)
+	if err != nil {
+		return "", fmt.Errorf("error checking username: %w", err)
+	}
+	if exists {
+		return "username already exists", nil
+	}
+
+	exists, err = s.userRepo.ExistsByEmail(record.Email)
+	if err != nil {
+		return "", fmt.Errorf("error checking email: %w", err)
+	}
+	if exists {
+		return "email already exists", nil
+	}
+	return "", nil
+}
+
+func (s *UserImportService) createUser(record *dto.ImportUserRecord, defaultRole *model.Role) error {
+	user := &model.User{
+		Username:     record.Username,
+		Email:        record.Email,
+		PasswordHash: record.PasswordHash,
+	}
+	user.ID = utils.GenerateUUIDv7()
+	if record.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	// Created with the role at once, so that a failed record can be imported
+	// again rather than leaving a user without a role behind
+	var err error
+	if defaultRole != nil {
+		err = s.userRepo.CreateWithRole(user, defaultRole.ID)
+	} else {
+		err = s.userRepo.Create(user)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating user: %w", err)
+	}
+	return nil
+}
+
+// ReadImportRecords reads the records of an export in the given format:
+// "json" for an array of records or one record per line, "csv" for a header
+// row naming the username, email, password_hash and optional email_verified
+// columns followed by a row per record
+func ReadImportRecords(r io.Reader, format string) ([]dto.ImportUserRecord, error) {
+	switch format {
+	case "json":
+		return readJSONImport(r)
+	case "csv":
+		return readCSVImport(r)
+	}
+	return nil, fmt.Errorf("unknown import format %q", format)
+}
+
+func readJSONImport(r io.Reader) ([]dto.ImportUserRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dto.ImportUserRecord
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("invalid JSON import: %w", err)
+		}
+		return records, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var record dto.ImportUserRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("invalid JSON import record %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readCSVImport(r io.Reader) ([]dto.ImportUserRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV import header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range []string{"username", "email", "password_hash"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV import has no %s column", name)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var records []dto.ImportUserRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV import: %w", err)
+		}
+
+		record := dto.ImportUserRecord{
+			Username:     field(row, "username"),
+			Email:        field(row, "email"),
+			PasswordHash: field(row, "password_hash"),
+		}
+		if verified := field(row, "email_verified"); verified != "" {
+			record.EmailVerified, err = strconv.ParseBool(verified)
+			if err != nil {
+				return nil, fmt.Errorf("invalid email_verified of CSV import record %d: %s", len(records)+1, verified)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserImportService_ImportAndUpgrade(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	roleRepo := store.NewRoleRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+	seedRoles(t, roleRepo)
+
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager)
+	importService := NewUserImportService(userRepo, roleRepo)
+
+	if _, err := authService.Register(&dto.RegisterRequest{Username: "existing", Email: "existing@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("alicepassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to generate bcrypt hash: %v", err)
+	}
+	export := "Username,Email,Password_Hash,Email_Verified\n" +
+		"alice,alice@example.com," + string(bcryptHash) + ",true\n" +
+		"bob,bob@example.com,$pbkdf2-sha256$1000$....................AQ$av6WDQbvUrgDdoXe0aCTfZM//8p.XtAATx1KGtneIFI,\n" +
+		"existing,other@example.com," + string(bcryptHash) + ",false\n" +
+		"carol,alice@example.com," + string(bcryptHash) + ",false\n" +
+		"dave,dave@example.com,$md5$abc,false\n" +
+		"erin,,$2b$10$abc,false\n"
+
+	records, err := ReadImportRecords(strings.NewReader(export), "csv")
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+
+	// A dry run checks the records without creating anything
+	result, err := importService.Import(records, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if exists, _ := userRepo.ExistsByUsername("alice"); exists || result.Created != 2 {
+		t.Fatalf("Expected a dry run to create nothing and count 2, got %+v", result)
+	}
+
+	// Execute
+	result, err = importService.Import(records, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	// Assert
+	if result.Created != 2 || len(result.Skipped) != 4 {
+		t.Fatalf("Expected 2 records created and 4 skipped, got %+v", result)
+	}
+	for i, want := range []string{"username already exists", "email appears earlier", "not supported", "required"} {
+		if skip := result.Skipped[i]; skip.Record != i+3 || !strings.Contains(skip.Reason, want) {
+			t.Errorf("Expected record %d to be skipped with %q, got %+v", i+3, want, skip)
+		}
+	}
+
+	alice, err := userRepo.GetByUsername("alice")
+	if err != nil {
+		t.Fatalf("Expected alice to be imported: %v", err)
+	}
+	if alice.PasswordHash != string(bcryptHash) || alice.EmailVerifiedAt == nil {
+		t.Errorf("Expected the hash and verification to be kept, got %+v", alice)
+	}
+	if roles, _ := roleRepo.GetUserRoleNames(alice.ID); len(roles) != 1 || roles[0] != constants.DefaultUserRole {
+		t.Errorf("Expected the default role, got %v", roles)
+	}
+
+	// Imported users log in with their old passwords, which moves them to
+	// argon2id
+	for username, password := range map[string]string{"alice": "alicepassword", "bob": "password"} {
+		if _, err := authService.Login(&dto.LoginRequest{Username: username, Password: password}); err != nil {
+			t.Fatalf("Expected %s to log in with the imported hash, got %v", username, err)
+		}
+		authService.WaitForRehashes()
+		user, _ := userRepo.GetByUsername(username)
+		if !strings.HasPrefix(user.PasswordHash, "$argon2id$") {
+			t.Errorf("Expected the hash of %s to be upgraded, got %s", username, user.PasswordHash)
+		}
+		if _, err := authService.Login(&dto.LoginRequest{Username: username, Password: password}); err != nil {
+			t.Errorf("Expected %s to log in with the upgraded hash, got %v", username, err)
+		}
+	}
+}
+
+func TestReadImportRecords_JSON(t *testing.T) {
+	want := dto.ImportUserRecord{Username: "alice", Email: "alice@example.com", PasswordHash: "$2b$10$abc", EmailVerified: true}
+
+	for name, export := range map[string]string{
+		"array": `[{"username": "alice", "email": "alice@example.com", "password_hash": "$2b$10$abc", "email_verified": true}]`,
+		"lines": `{"username": "alice", "email": "alice@example.com", "password_hash": "$2b$10$abc", "email_verified": true}` + "\n",
+	} {
+		records, err := ReadImportRecords(strings.NewReader(export), "json")
+		if err != nil {
+			t.Fatalf("%s: failed to read export: %v", name, err)
+		}
+		if len(records) != 1 || records[0] != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, records)
+		}
+	}
+
+	if _, err := ReadImportRecords(strings.NewReader("username,email\nalice,alice@example.com\n"), "csv"); err == nil {
+		t.Error("Expected a CSV export without password_hash to be rejected")
+	}
+	if _, err := ReadImportRecords(strings.NewReader(""), "xml"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -1,22 +1,33 @@
 package service
 
 import (
+	"authorization/internal/authz"
 	"authorization/internal/constants"
 	"authorization/internal/dto"
 	"authorization/internal/store"
 	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type UserService struct {
-	userRepo *store.UserRepository
+	userRepo  *store.UserRepository
+	tokenRepo *store.TokenRepository
+	evaluator *authz.Evaluator
+	revoker   *AccessTokenRevoker
 }
 
-func NewUserService(userRepo *store.UserRepository) *UserService {
+// NewUserService creates the service. Disabling a user revokes their access
+// tokens through revoker, which may be nil to leave them valid until they
+// expire.
+func NewUserService(userRepo *store.UserRepository, tokenRepo *store.TokenRepository, evaluator *authz.Evaluator, revoker *AccessTokenRevoker) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		evaluator: evaluator,
+		revoker:   revoker,
 	}
 }
 
@@ -29,10 +40,60 @@ func (s *UserService) GetUserByID(userID string) (*dto.UserInfo, error) {
 		return nil, fmt.Errorf("error getting user: %w", err)
 	}
 
+	grants, err := s.evaluator.Resolve(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving user permissions: %w", err)
+	}
+
 	return &dto.UserInfo{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Roles:         grants.Roles,
+		Permissions:   grants.Permissions,
+		CreatedAt:     user.CreatedAt,
 	}, nil
 }
+
+// DisableUser stops the user from logging in and signs them out everywhere.
+// Administrators cannot disable themselves, nor the last active
+// administrator, so that someone is always left to enable users again.
+func (s *UserService) DisableUser(actorID, userID string) (*dto.UserStatusResponse, error) {
+	if actorID == userID {
+		return nil, fmt.Errorf(constants.MsgCannotDisableSelf)
+	}
+
+	found, lastAdmin, err := s.userRepo.DisableKeepingRole(userID, time.Now(), constants.RoleAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("error disabling user: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf(constants.MsgUserNotFound)
+	}
+	if lastAdmin {
+		return nil, fmt.Errorf(constants.MsgLastActiveAdmin)
+	}
+
+	if err := s.revoker.RevokeUserSessions(userID, ""); err != nil {
+		return nil, err
+	}
+	if err := s.tokenRepo.RevokeAllUserTokens(userID); err != nil {
+		return nil, fmt.Errorf("error revoking sessions: %w", err)
+	}
+
+	return &dto.UserStatusResponse{Message: constants.MsgUserDisabled}, nil
+}
+
+// EnableUser lets a disabled user log in again
+func (s *UserService) EnableUser(userID string) (*dto.UserStatusResponse, error) {
+	found, err := s.userRepo.SetDisabledAt(userID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error enabling user: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf(constants.MsgUserNotFound)
+	}
+
+	return &dto.UserStatusResponse{Message: constants.MsgUserEnabled}, nil
+}
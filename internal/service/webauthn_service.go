@@ -0,0 +1,370 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/model"
+	"authorization/internal/pkg/logger"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"authorization/internal/webauthn"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// webauthnTimeout is how long a ceremony challenge stays valid
+	webauthnTimeout = 5 * time.Minute
+
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+// WebAuthnService registers passkeys and security keys and verifies logins
+// with them. Every ceremony uses a fresh challenge that is stored hashed
+// and works once, like reset tokens.
+type WebAuthnService struct {
+	webauthnRepo *store.WebAuthnRepository
+	userRepo     *store.UserRepository
+	rp           *webauthn.RelyingParty
+}
+
+func NewWebAuthnService(webauthnRepo *store.WebAuthnRepository, userRepo *store.UserRepository, rp *webauthn.RelyingParty) *WebAuthnService {
+	return &WebAuthnService{
+		webauthnRepo: webauthnRepo,
+		userRepo:     userRepo,
+		rp:           rp,
+	}
+}
+
+// HasCredentials reports whether the user has registered a credential
+func (s *WebAuthnService) HasCredentials(userID string) (bool, error) {
+	return s.webauthnRepo.HasCredentials(userID)
+}
+
+// BeginRegistration starts registering a new credential for the user
+func (s *WebAuthnService) BeginRegistration(userID string) (*dto.WebAuthnCreationOptions, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(constants.MsgUserNotFound)
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	// Authenticators refuse to register a second credential for the account
+	exclude, err := s.descriptors(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.newChallenge(&userID, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]dto.WebAuthnCredentialParam, 0, len(webauthn.SupportedAlgorithms))
+	for _, alg := range webauthn.SupportedAlgorithms {
+		params = append(params, dto.WebAuthnCredentialParam{Type: "public-key", Alg: alg})
+	}
+
+	return &dto.WebAuthnCreationOptions{
+		Challenge: challenge,
+		RP:        dto.WebAuthnRelyingParty{ID: s.rp.ID, Name: s.rp.Name},
+		User: dto.WebAuthnUserEntity{
+			ID:          webauthn.EncodeBase64URL([]byte(user.ID)),
+			Name:        user.Username,
+			DisplayName: user.Username,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            webauthnTimeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: dto.WebAuthnAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration verifies the browser's response to BeginRegistration
+// and stores the new credential
+func (s *WebAuthnService) FinishRegistration(userID string, req *dto.WebAuthnRegisterRequest) (*dto.WebAuthnCredentialInfo, error) {
+	clientDataJSON, err := webauthn.DecodeBase64URL(req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+	attestationObject, err := webauthn.DecodeBase64URL(req.Credential.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	record, challenge, err := s.consumeChallenge(clientDataJSON, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID == nil || *record.UserID != userID {
+		return nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	cred, err := s.rp.VerifyRegistration(challenge, &webauthn.RegistrationResponse{
+		ClientDataJSON:    clientDataJSON,
+		AttestationObject: attestationObject,
+	}, false)
+	if err != nil {
+		logger.Debug("Passkey registration rejected", zap.Error(err), zap.String("user_id", userID))
+		return nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	credentialID := webauthn.EncodeBase64URL(cred.ID)
+	if _, err := s.webauthnRepo.GetCredential(credentialID); err == nil {
+		return nil, fmt.Errorf(constants.MsgPasskeyExists)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error getting passkey: %w", err)
+	}
+
+	credential := &model.WebAuthnCredential{
+		ID:                utils.GenerateUUIDv7(),
+		UserID:            userID,
+		CredentialID:      credentialID,
+		PublicKey:         cred.PublicKey,
+		SignCount:         int64(cred.SignCount),
+		AAGUID:            formatAAGUID(cred.AAGUID),
+		AttestationFormat: cred.AttestationFormat,
+		Transports:        strings.Join(req.Credential.Response.Transports, ","),
+		BackupEligible:    cred.BackupEligible,
+		Name:              req.Name,
+	}
+	if err := s.webauthnRepo.CreateCredential(credential); err != nil {
+		return nil, fmt.Errorf("error saving passkey: %w", err)
+	}
+
+	logger.SecurityEvent(constants.EventPasskeyAdded, zap.String("user_id", userID), zap.String("passkey_id", credential.ID), zap.String("aaguid", credential.AAGUID))
+
+	info := toCredentialInfo(credential)
+	return &info, nil
+}
+
+// BeginLogin starts a login ceremony. For a second factor userID is the user
+// who passed the password check and only their credentials are offered;
+// with an empty userID the login is passwordless and the browser offers
+// every passkey it holds for the relying party.
+func (s *WebAuthnService) BeginLogin(userID string) (*dto.WebAuthnRequestOptions, error) {
+	options := &dto.WebAuthnRequestOptions{
+		RPID:             s.rp.ID,
+		Timeout:          webauthnTimeout.Milliseconds(),
+		AllowCredentials: []dto.WebAuthnCredentialDescriptor{},
+		UserVerification: "required",
+	}
+
+	var owner *string
+	if userID != "" {
+		allow, err := s.descriptors(userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(allow) == 0 {
+			return nil, fmt.Errorf(constants.MsgPasskeyNotFound)
+		}
+		owner = &userID
+		options.AllowCredentials = allow
+		options.UserVerification = "preferred"
+	}
+
+	challenge, err := s.newChallenge(owner, ceremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+	options.Challenge = challenge
+	return options, nil
+}
+
+// FinishLogin verifies the browser's response to BeginLogin and returns the
+// ID of the user it authenticates. userID must be the one the ceremony was
+// started with. Passwordless logins require the authenticator to have
+// verified the user, which makes the passkey a second factor on its own.
+func (s *WebAuthnService) FinishLogin(userID string, req *dto.WebAuthnCredential) (string, error) {
+	clientDataJSON, err := webauthn.DecodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+	authenticatorData, err := webauthn.DecodeBase64URL(req.Response.AuthenticatorData)
+	if err != nil {
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+	signature, err := webauthn.DecodeBase64URL(req.Response.Signature)
+	if err != nil {
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+	rawID, err := webauthn.DecodeBase64URL(req.RawID)
+	if err != nil {
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	record, challenge, err := s.consumeChallenge(clientDataJSON, ceremonyLogin)
+	if err != nil {
+		return "", err
+	}
+	owner := ""
+	if record.UserID != nil {
+		owner = *record.UserID
+	}
+	if owner != userID {
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	credential, err := s.webauthnRepo.GetCredential(webauthn.EncodeBase64URL(rawID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+		}
+		return "", fmt.Errorf("error getting passkey: %w", err)
+	}
+	if userID != "" && credential.UserID != userID {
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+	if req.Response.UserHandle != "" {
+		userHandle, err := webauthn.DecodeBase64URL(req.Response.UserHandle)
+		if err != nil || string(userHandle) != credential.UserID {
+			return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+		}
+	}
+
+	signCount, err := s.rp.VerifyAssertion(challenge, credential.PublicKey, uint32(credential.SignCount), &webauthn.AssertionResponse{
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authenticatorData,
+		Signature:         signature,
+	}, userID == "")
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegressed) {
+			logger.SecurityEvent(constants.EventPasskeyCloned, zap.String("user_id", credential.UserID), zap.String("passkey_id", credential.ID))
+		} else {
+			logger.Debug("Passkey login rejected", zap.Error(err), zap.String("user_id", credential.UserID))
+		}
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	recorded, err := s.webauthnRepo.RecordUse(credential.ID, credential.SignCount, int64(signCount))
+	if err != nil {
+		return "", fmt.Errorf("error updating passkey: %w", err)
+	}
+	if !recorded {
+		return "", fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	return credential.UserID, nil
+}
+
+func (s *WebAuthnService) ListCredentials(userID string) (*dto.WebAuthnCredentialListResponse, error) {
+	credentials, err := s.webauthnRepo.ListCredentials(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing passkeys: %w", err)
+	}
+
+	infos := make([]dto.WebAuthnCredentialInfo, 0, len(credentials))
+	for i := range credentials {
+		infos = append(infos, toCredentialInfo(&credentials[i]))
+	}
+	return &dto.WebAuthnCredentialListResponse{Credentials: infos}, nil
+}
+
+func (s *WebAuthnService) DeleteCredential(userID, id string) (*dto.DeleteWebAuthnCredentialResponse, error) {
+	deleted, err := s.webauthnRepo.DeleteCredential(userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting passkey: %w", err)
+	}
+	if !deleted {
+		return nil, fmt.Errorf(constants.MsgPasskeyNotFound)
+	}
+
+	logger.SecurityEvent(constants.EventPasskeyRemoved, zap.String("user_id", userID), zap.String("passkey_id", id))
+
+	return &dto.DeleteWebAuthnCredentialResponse{Message: constants.MsgPasskeyRemoved}, nil
+}
+
+// descriptors lists the user's credentials for allow and exclude lists
+func (s *WebAuthnService) descriptors(userID string) ([]dto.WebAuthnCredentialDescriptor, error) {
+	credentials, err := s.webauthnRepo.ListCredentials(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing passkeys: %w", err)
+	}
+
+	descriptors := make([]dto.WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := dto.WebAuthnCredentialDescriptor{Type: "public-key", ID: credential.CredentialID}
+		if credential.Transports != "" {
+			descriptor.Transports = strings.Split(credential.Transports, ",")
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors, nil
+}
+
+// newChallenge stores a challenge for a ceremony and returns it encoded
+func (s *WebAuthnService) newChallenge(userID *string, ceremony string) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", fmt.Errorf("error generating challenge: %w", err)
+	}
+	encoded := webauthn.EncodeBase64URL(challenge)
+
+	err = s.webauthnRepo.CreateChallenge(&model.WebAuthnChallenge{
+		ID:            utils.GenerateUUIDv7(),
+		UserID:        userID,
+		Ceremony:      ceremony,
+		ChallengeHash: utils.HashSecret(encoded),
+		ExpiresAt:     time.Now().Add(webauthnTimeout),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error saving challenge: %w", err)
+	}
+	return encoded, nil
+}
+
+// consumeChallenge uses up the challenge the client data was signed for
+func (s *WebAuthnService) consumeChallenge(clientDataJSON []byte, ceremony string) (*model.WebAuthnChallenge, []byte, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+	challenge, err := webauthn.DecodeBase64URL(clientData.Challenge)
+	if err != nil {
+		return nil, nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+	}
+
+	record, err := s.webauthnRepo.ConsumeChallenge(utils.HashSecret(webauthn.EncodeBase64URL(challenge)), ceremony)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf(constants.MsgWebAuthnFailed)
+		}
+		return nil, nil, fmt.Errorf("error using challenge: %w", err)
+	}
+	return record, challenge, nil
+}
+
+func toCredentialInfo(credential *model.WebAuthnCredential) dto.WebAuthnCredentialInfo {
+	return dto.WebAuthnCredentialInfo{
+		ID:                credential.ID,
+		Name:              credential.Name,
+		AAGUID:            credential.AAGUID,
+		AttestationFormat: credential.AttestationFormat,
+		BackupEligible:    credential.BackupEligible,
+		CreatedAt:         credential.CreatedAt,
+		LastUsedAt:        credential.LastUsedAt,
+	}
+}
+
+// formatAAGUID formats an authenticator model ID like a UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
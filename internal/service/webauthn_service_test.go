@@ -0,0 +1,205 @@
+package service
+
+import (
+	"authorization/internal/constants"
+	"authorization/internal/dto"
+	"authorization/internal/store"
+	"authorization/internal/utils"
+	"authorization/internal/webauthn"
+	"authorization/internal/webauthn/webauthntest"
+	"testing"
+	"time"
+)
+
+func setupWebAuthnTest(t *testing.T) (*AuthService, *WebAuthnService, string) {
+	db := setupTestDB(t)
+	userRepo := store.NewUserRepository(db)
+	tokenRepo := store.NewTokenRepository(db)
+	jwtManager := utils.NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	rp, err := webauthn.NewRelyingParty("example.com", "Example", []string{"https://example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create relying party: %v", err)
+	}
+	webauthnService := NewWebAuthnService(store.NewWebAuthnRepository(db), userRepo, rp)
+	authService := NewAuthService(userRepo, tokenRepo, jwtManager, WithWebAuthn(webauthnService))
+
+	registerResp, err := authService.Register(&dto.RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	return authService, webauthnService, registerResp.User.ID
+}
+
+// registerPasskey runs a registration ceremony with the authenticator and
+// returns the credential ID it created
+func registerPasskey(t *testing.T, webauthnService *WebAuthnService, authenticator *webauthntest.Authenticator, userID string) []byte {
+	options, err := webauthnService.BeginRegistration(userID)
+	if err != nil {
+		t.Fatalf("Failed to begin registration: %v", err)
+	}
+	challenge, _ := webauthn.DecodeBase64URL(options.Challenge)
+	userHandle, _ := webauthn.DecodeBase64URL(options.User.ID)
+
+	credentialID, clientDataJSON, attestationObject, err := authenticator.Create(challenge, userHandle, webauthntest.FormatPacked)
+	if err != nil {
+		t.Fatalf("Failed to create credential: %v", err)
+	}
+
+	_, err = webauthnService.FinishRegistration(userID, &dto.WebAuthnRegisterRequest{
+		Name: "Test key",
+		Credential: dto.WebAuthnCredential{
+			ID:    webauthn.EncodeBase64URL(credentialID),
+			RawID: webauthn.EncodeBase64URL(credentialID),
+			Type:  "public-key",
+			Response: dto.WebAuthnAuthenticatorResponse{
+				ClientDataJSON:    webauthn.EncodeBase64URL(clientDataJSON),
+				AttestationObject: webauthn.EncodeBase64URL(attestationObject),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to finish registration: %v", err)
+	}
+	return credentialID
+}
+
+// passkeyLogin runs a login ceremony with the authenticator
+func passkeyLogin(t *testing.T, authService *AuthService, authenticator *webauthntest.Authenticator, credentialID []byte, mfaToken string) (*dto.LoginResponse, error) {
+	options, err := authService.BeginWebAuthnLogin(&dto.WebAuthnLoginBeginRequest{MFAToken: mfaToken})
+	if err != nil {
+		t.Fatalf("Failed to begin login: %v", err)
+	}
+	challenge, _ := webauthn.DecodeBase64URL(options.Challenge)
+
+	clientDataJSON, authenticatorData, signature, userHandle, err := authenticator.Get(credentialID, challenge)
+	if err != nil {
+		t.Fatalf("Failed to get assertion: %v", err)
+	}
+
+	return authService.LoginWithWebAuthn(&dto.WebAuthnLoginRequest{
+		MFAToken: mfaToken,
+		Credential: dto.WebAuthnCredential{
+			ID:    webauthn.EncodeBase64URL(credentialID),
+			RawID: webauthn.EncodeBase64URL(credentialID),
+			Type:  "public-key",
+			Response: dto.WebAuthnAuthenticatorResponse{
+				ClientDataJSON:    webauthn.EncodeBase64URL(clientDataJSON),
+				AuthenticatorData: webauthn.EncodeBase64URL(authenticatorData),
+				Signature:         webauthn.EncodeBase64URL(signature),
+				UserHandle:        webauthn.EncodeBase64URL(userHandle),
+			},
+		},
+	})
+}
+
+func TestWebAuthnService_PasswordlessLogin(t *testing.T) {
+	authService, webauthnService, userID := setupWebAuthnTest(t)
+	authenticator := webauthntest.New("example.com", "https://example.com")
+	credentialID := registerPasskey(t, webauthnService, authenticator, userID)
+
+	loginResp, err := passkeyLogin(t, authService, authenticator, credentialID, "")
+	if err != nil {
+		t.Fatalf("Failed to log in with passkey: %v", err)
+	}
+	if loginResp.AccessToken == "" || loginResp.RefreshToken == "" || loginResp.User.ID != userID {
+		t.Errorf("Expected tokens for the user, got %+v", loginResp)
+	}
+
+	// Passwordless logins need the user verified by the authenticator
+	authenticator.UserVerified = false
+	_, err = passkeyLogin(t, authService, authenticator, credentialID, "")
+	if err == nil || err.Error() != constants.MsgWebAuthnFailed {
+		t.Errorf("Expected %q without user verification, got %v", constants.MsgWebAuthnFailed, err)
+	}
+	authenticator.UserVerified = true
+
+	// A counter going backwards looks like a cloned authenticator
+	authenticator.SetSignCount(credentialID, 0)
+	_, err = passkeyLogin(t, authService, authenticator, credentialID, "")
+	if err == nil || err.Error() != constants.MsgWebAuthnFailed {
+		t.Errorf("Expected %q for a regressed sign count, got %v", constants.MsgWebAuthnFailed, err)
+	}
+
+	// Credentials that were never registered are unknown
+	other := webauthntest.New("example.com", "https://example.com")
+	otherID, _, _, err := other.Create([]byte("unused"), []byte(userID), webauthntest.FormatNone)
+	if err != nil {
+		t.Fatalf("Failed to create credential: %v", err)
+	}
+	_, err = passkeyLogin(t, authService, other, otherID, "")
+	if err == nil || err.Error() != constants.MsgWebAuthnFailed {
+		t.Errorf("Expected %q for an unregistered credential, got %v", constants.MsgWebAuthnFailed, err)
+	}
+}
+
+func TestWebAuthnService_SecondFactor(t *testing.T) {
+	authService, webauthnService, userID := setupWebAuthnTest(t)
+	authenticator := webauthntest.New("example.com", "https://example.com")
+	credentialID := registerPasskey(t, webauthnService, authenticator, userID)
+
+	loginResp, err := authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+	if loginResp.MFAChallenge == nil || !hasString(loginResp.MFAChallenge.Methods, "webauthn") {
+		t.Fatalf("Expected a webauthn challenge, got %+v", loginResp)
+	}
+	mfaToken := loginResp.MFAChallenge.MFAToken
+
+	// The second factor needs only user presence
+	authenticator.UserVerified = false
+	verifyResp, err := passkeyLogin(t, authService, authenticator, credentialID, mfaToken)
+	if err != nil {
+		t.Fatalf("Failed to complete login with passkey: %v", err)
+	}
+	if verifyResp.AccessToken == "" || verifyResp.User.ID != userID {
+		t.Errorf("Expected tokens for the user, got %+v", verifyResp)
+	}
+
+	// The challenge of a ceremony works once
+	options, err := authService.BeginWebAuthnLogin(&dto.WebAuthnLoginBeginRequest{MFAToken: mfaToken})
+	if err != nil {
+		t.Fatalf("Failed to begin login: %v", err)
+	}
+	if len(options.AllowCredentials) != 1 || options.AllowCredentials[0].ID != webauthn.EncodeBase64URL(credentialID) {
+		t.Errorf("Expected the user's passkey to be allowed, got %+v", options.AllowCredentials)
+	}
+	challenge, _ := webauthn.DecodeBase64URL(options.Challenge)
+	clientDataJSON, authenticatorData, signature, _, _ := authenticator.Get(credentialID, challenge)
+	req := &dto.WebAuthnLoginRequest{
+		MFAToken: mfaToken,
+		Credential: dto.WebAuthnCredential{
+			RawID: webauthn.EncodeBase64URL(credentialID),
+			Response: dto.WebAuthnAuthenticatorResponse{
+				ClientDataJSON:    webauthn.EncodeBase64URL(clientDataJSON),
+				AuthenticatorData: webauthn.EncodeBase64URL(authenticatorData),
+				Signature:         webauthn.EncodeBase64URL(signature),
+			},
+		},
+	}
+	if _, err := authService.LoginWithWebAuthn(req); err != nil {
+		t.Fatalf("Failed to complete login: %v", err)
+	}
+	if _, err := authService.LoginWithWebAuthn(req); err == nil || err.Error() != constants.MsgWebAuthnFailed {
+		t.Errorf("Expected %q when replaying a response, got %v", constants.MsgWebAuthnFailed, err)
+	}
+
+	// Removing the passkey removes the second factor
+	credentials, err := webauthnService.ListCredentials(userID)
+	if err != nil || len(credentials.Credentials) != 1 {
+		t.Fatalf("Expected one passkey, got %+v, %v", credentials, err)
+	}
+	if _, err := webauthnService.DeleteCredential(userID, credentials.Credentials[0].ID); err != nil {
+		t.Fatalf("Failed to delete passkey: %v", err)
+	}
+	loginResp, err = authService.Login(&dto.LoginRequest{Username: "testuser", Password: "password123"})
+	if err != nil || loginResp.MFAChallenge != nil {
+		t.Errorf("Expected a plain login after removing the passkey, got %+v, %v", loginResp, err)
+	}
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccessTokenDenylistRepository keeps denied access tokens and sessions in
+// the database, so that a revocation on one node applies on all of them. It
+// implements denylist.Store.
+type AccessTokenDenylistRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenDenylistRepository(db *gorm.DB) *AccessTokenDenylistRepository {
+	return &AccessTokenDenylistRepository{db: db}
+}
+
+// Add denies the key until expiresAt, keeping a later expiry it already has
+func (r *AccessTokenDenylistRepository) Add(ctx context.Context, key string, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"expires_at": gorm.Expr("CASE WHEN excluded.expires_at > access_token_denylist.expires_at THEN excluded.expires_at ELSE access_token_denylist.expires_at END"),
+		}),
+	}).Create(&model.AccessTokenDenial{Key: key, ExpiresAt: expiresAt}).Error
+}
+
+// Contains reports whether any of the keys is denied and not yet expired
+func (r *AccessTokenDenylistRepository) Contains(ctx context.Context, keys ...string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.AccessTokenDenial{}).
+		Where("key IN ? AND expires_at > ?", keys, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpired removes the entries whose tokens have expired
+func (r *AccessTokenDenylistRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&model.AccessTokenDenial{}).Error
+}
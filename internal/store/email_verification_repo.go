@@ -0,0 +1,22 @@
+package store
+
+import (
+	"authorization/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type EmailVerificationRepository struct {
+	SingleUseTokenRepository[model.EmailVerificationToken]
+}
+
+func NewEmailVerificationRepository(db *gorm.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{SingleUseTokenRepository[model.EmailVerificationToken]{db: db}}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *EmailVerificationRepository) Transaction(fn func(repo *EmailVerificationRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewEmailVerificationRepository(tx))
+	})
+}
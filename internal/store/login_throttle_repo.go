@@ -0,0 +1,80 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginThrottleRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleRepository(db *gorm.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *LoginThrottleRepository) Transaction(fn func(repo *LoginThrottleRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&LoginThrottleRepository{db: tx})
+	})
+}
+
+// Get returns the throttle of the key, or gorm.ErrRecordNotFound if no
+// attempt for it failed
+func (r *LoginThrottleRepository) Get(scope, subject string) (*model.LoginThrottle, error) {
+	var throttle model.LoginThrottle
+	if err := r.db.Where("scope = ? AND subject = ?", scope, subject).First(&throttle).Error; err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// GetForUpdate returns the throttle of the key, creating an empty one if
+// needed, and locks the row until the surrounding transaction ends
+func (r *LoginThrottleRepository) GetForUpdate(scope, subject string, now time.Time) (*model.LoginThrottle, error) {
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.LoginThrottle{
+		Scope:        scope,
+		Subject:      subject,
+		LastFailedAt: now,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var throttle model.LoginThrottle
+	err = r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND subject = ?", scope, subject).
+		First(&throttle).Error
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *LoginThrottleRepository) Save(throttle *model.LoginThrottle) error {
+	return r.db.Save(throttle).Error
+}
+
+// ListBlocked returns the throttles holding back attempts at the given time,
+// most recent failure first
+func (r *LoginThrottleRepository) ListBlocked(now time.Time) ([]model.LoginThrottle, error) {
+	var throttles []model.LoginThrottle
+	err := r.db.Where("blocked_until > ?", now).Order("last_failed_at DESC").Find(&throttles).Error
+	return throttles, err
+}
+
+// Delete forgets the failed attempts of the key and reports whether there
+// were any
+func (r *LoginThrottleRepository) Delete(scope, subject string) (bool, error) {
+	result := r.db.Where("scope = ? AND subject = ?", scope, subject).Delete(&model.LoginThrottle{})
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteStale removes throttles whose last failure is before the given time
+func (r *LoginThrottleRepository) DeleteStale(before time.Time) error {
+	return r.db.Where("last_failed_at < ?", before).Delete(&model.LoginThrottle{}).Error
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MFAChallengeRepository tracks the use of MFA challenge tokens by their jti
+type MFAChallengeRepository struct {
+	db *gorm.DB
+}
+
+func NewMFAChallengeRepository(db *gorm.DB) *MFAChallengeRepository {
+	return &MFAChallengeRepository{db: db}
+}
+
+// ReserveAttempt counts an attempt at completing the challenge before it is
+// checked and reports whether it may be made: the challenge has not
+// completed a login yet and fewer than maxAttempts were made with it.
+// Counting in a single statement keeps concurrent attempts within the limit.
+func (r *MFAChallengeRepository) ReserveAttempt(id, userID string, maxAttempts int, expiresAt time.Time) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"attempts": gorm.Expr("mfa_challenges.attempts + 1"),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			gorm.Expr("mfa_challenges.attempts < ? AND mfa_challenges.consumed_at IS NULL", maxAttempts),
+		}},
+	}).Create(&model.MFAChallenge{ID: id, UserID: userID, Attempts: 1, ExpiresAt: expiresAt})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Consume marks the challenge as having completed a login. It reports false
+// if another attempt consumed it first.
+func (r *MFAChallengeRepository) Consume(id string) (bool, error) {
+	result := r.db.Model(&model.MFAChallenge{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteExpired removes the challenges that have expired
+func (r *MFAChallengeRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&model.MFAChallenge{}).Error
+}
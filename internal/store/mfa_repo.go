@@ -0,0 +1,138 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MFARepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *MFARepository) Transaction(fn func(repo *MFARepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&MFARepository{db: tx})
+	})
+}
+
+func (r *MFARepository) GetByUserID(userID string) (*model.UserMFA, error) {
+	var mfa model.UserMFA
+	if err := r.db.Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// IsEnabled reports whether the user has a confirmed second factor
+func (r *MFARepository) IsEnabled(userID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SavePending replaces the user's unconfirmed factor with a new secret. A
+// confirmed factor is left alone; the returned bool reports whether the
+// secret was saved.
+func (r *MFARepository) SavePending(userID, secret string) (bool, error) {
+	result := r.db.Where("user_id = ? AND enabled_at IS NULL", userID).Delete(&model.UserMFA{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	result = r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.UserMFA{
+		UserID: userID,
+		Secret: secret,
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Enable confirms the user's pending factor, recording counter as the time
+// step of the confirming code
+func (r *MFARepository) Enable(userID string, counter int64) (bool, error) {
+	result := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND enabled_at IS NULL", userID).
+		Updates(map[string]interface{}{"enabled_at": time.Now(), "last_counter": counter, "failed_attempts": 0, "locked_until": nil})
+	return result.RowsAffected == 1, result.Error
+}
+
+// AcceptCounter records a successful code for the given time step, resetting
+// the failed attempts. It fails if a code for this or a later step was
+// already accepted, so each code works once even under concurrent use.
+func (r *MFARepository) AcceptCounter(userID string, counter int64) (bool, error) {
+	result := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND last_counter < ?", userID, counter).
+		Updates(map[string]interface{}{"last_counter": counter, "failed_attempts": 0, "locked_until": nil})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ResetFailures clears the failed attempts after a successful recovery code
+func (r *MFARepository) ResetFailures(userID string) error {
+	return r.db.Model(&model.UserMFA{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error
+}
+
+// ReserveAttempt counts an attempt at a code before it is checked, locking
+// the factor until lockUntil once maxAttempts consecutive attempts were
+// made. A successful code resets the count with AcceptCounter or
+// ResetFailures, and an expired lock starts it over. Counting in a single
+// statement beforehand keeps concurrent attempts from all getting past the
+// lock. It reports whether the factor was unlocked, and the attempts counted
+// including this one.
+func (r *MFARepository) ReserveAttempt(userID string, maxAttempts int, lockUntil time.Time) (bool, int, error) {
+	attempts := "CASE WHEN locked_until IS NULL THEN failed_attempts + 1 ELSE 1 END"
+
+	var mfa model.UserMFA
+	result := r.db.Model(&mfa).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_attempts"}}}).
+		Where("user_id = ? AND (locked_until IS NULL OR locked_until <= ?)", userID, time.Now()).
+		Updates(map[string]interface{}{
+			"failed_attempts": gorm.Expr(attempts),
+			"locked_until":    gorm.Expr("CASE WHEN "+attempts+" >= ? THEN ? ELSE NULL END", maxAttempts, lockUntil),
+		})
+	return result.RowsAffected == 1, mfa.FailedAttempts, result.Error
+}
+
+// Delete removes the user's factor and recovery codes
+func (r *MFARepository) Delete(userID string) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("user_id = ?", userID).Delete(&model.UserMFA{}).Error
+}
+
+// ReplaceRecoveryCodes replaces the user's recovery codes
+func (r *MFARepository) ReplaceRecoveryCodes(userID string, codes []model.MFARecoveryCode) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return r.db.Create(&codes).Error
+}
+
+// UseRecoveryCode marks the user's unused code with the given hash as used
+// and reports whether there was one
+func (r *MFARepository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	result := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes the user has left
+func (r *MFARepository) CountUnusedRecoveryCodes(userID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
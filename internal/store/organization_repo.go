@@ -0,0 +1,169 @@
+package store
+
+import (
+	"authorization/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrganizationRepository stores organizations and their memberships. Every
+// membership query is scoped to a single organization.
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *OrganizationRepository) Transaction(fn func(repo *OrganizationRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&OrganizationRepository{db: tx})
+	})
+}
+
+func (r *OrganizationRepository) Create(org *model.Organization) error {
+	return r.db.Create(org).Error
+}
+
+func (r *OrganizationRepository) GetByID(id string) (*model.Organization, error) {
+	var org model.Organization
+	if err := r.db.Where("id = ?", id).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *OrganizationRepository) ExistsBySlug(slug string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Organization{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *OrganizationRepository) List() ([]*model.Organization, error) {
+	var orgs []*model.Organization
+	err := r.db.Order("slug").Find(&orgs).Error
+	return orgs, err
+}
+
+// ListByUser returns the organizations the user is a member of
+func (r *OrganizationRepository) ListByUser(userID string) ([]*model.Organization, error) {
+	var orgs []*model.Organization
+	err := r.db.Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userID).
+		Order("organizations.slug").
+		Find(&orgs).Error
+	return orgs, err
+}
+
+// IsMember reports whether the user is a member of the organization
+func (r *OrganizationRepository) IsMember(orgID, userID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListMembers returns the members of the organization with their user,
+// leaving out deleted users
+func (r *OrganizationRepository) ListMembers(orgID string) ([]*model.OrganizationMember, error) {
+	var members []*model.OrganizationMember
+	err := r.db.Preload("User").
+		Joins("JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ? AND users.is_deleted = false", orgID).
+		Order("users.username").
+		Find(&members).Error
+	return members, err
+}
+
+// GetMember returns the membership of the user in the organization
+func (r *OrganizationRepository) GetMember(orgID, userID string) (*model.OrganizationMember, error) {
+	var member model.OrganizationMember
+	err := r.db.Preload("User").
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetMemberRoleNames returns the names of the roles the user holds in the
+// organization
+func (r *OrganizationRepository) GetMemberRoleNames(orgID, userID string) ([]string, error) {
+	var names []string
+	err := r.db.Model(&model.Role{}).
+		Joins("JOIN organization_member_roles ON organization_member_roles.role_id = roles.id").
+		Where("organization_member_roles.organization_id = ? AND organization_member_roles.user_id = ?", orgID, userID).
+		Order("roles.name").
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+// ListMemberRoleNames returns the names of the roles every member holds in
+// the organization, keyed by user ID
+func (r *OrganizationRepository) ListMemberRoleNames(orgID string) (map[string][]string, error) {
+	var rows []struct {
+		UserID string
+		Name   string
+	}
+	err := r.db.Model(&model.OrganizationMemberRole{}).
+		Select("organization_member_roles.user_id, roles.name").
+		Joins("JOIN roles ON roles.id = organization_member_roles.role_id").
+		Where("organization_member_roles.organization_id = ?", orgID).
+		Order("roles.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string][]string)
+	for _, row := range rows {
+		names[row.UserID] = append(names[row.UserID], row.Name)
+	}
+	return names, nil
+}
+
+// AddMember makes the user a member of the organization. Adding an existing
+// member is not an error.
+func (r *OrganizationRepository) AddMember(orgID, userID string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.OrganizationMember{
+		OrganizationID: orgID,
+		UserID:         userID,
+	}).Error
+}
+
+// SetMemberRoles replaces the roles the member holds in the organization
+func (r *OrganizationRepository) SetMemberRoles(orgID, userID string, roleIDs []string) error {
+	err := r.db.Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Delete(&model.OrganizationMemberRole{}).Error
+	if err != nil || len(roleIDs) == 0 {
+		return err
+	}
+
+	assignments := make([]model.OrganizationMemberRole, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		assignments = append(assignments, model.OrganizationMemberRole{
+			OrganizationID: orgID,
+			UserID:         userID,
+			RoleID:         roleID,
+		})
+	}
+	return r.db.Create(&assignments).Error
+}
+
+// RemoveMember removes the user and their roles from the organization and
+// returns how many memberships were removed
+func (r *OrganizationRepository) RemoveMember(orgID, userID string) (int64, error) {
+	err := r.db.Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Delete(&model.OrganizationMemberRole{}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	result := r.db.Where("organization_id = ? AND user_id = ?", orgID, userID).Delete(&model.OrganizationMember{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"authorization/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{db: db}
+}
+
+// Recent returns the latest limit entries of the user, newest first
+func (r *PasswordHistoryRepository) Recent(userID string, limit int) ([]model.PasswordHistory, error) {
+	var entries []model.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
+
+// Add records an earlier password of the user and deletes all but the keep
+// latest entries
+func (r *PasswordHistoryRepository) Add(entry *model.PasswordHistory, keep int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		latest := tx.Model(&model.PasswordHistory{}).
+			Select("id").
+			Where("user_id = ?", entry.UserID).
+			Order("created_at DESC, id DESC").
+			Limit(keep)
+		return tx.Where("user_id = ? AND id NOT IN (?)", entry.UserID, latest).
+			Delete(&model.PasswordHistory{}).Error
+	})
+}
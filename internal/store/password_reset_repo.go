@@ -0,0 +1,22 @@
+package store
+
+import (
+	"authorization/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository struct {
+	SingleUseTokenRepository[model.PasswordResetToken]
+}
+
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{SingleUseTokenRepository[model.PasswordResetToken]{db: db}}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *PasswordResetRepository) Transaction(fn func(repo *PasswordResetRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewPasswordResetRepository(tx))
+	})
+}
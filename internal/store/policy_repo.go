@@ -0,0 +1,52 @@
+package store
+
+import (
+	"authorization/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewPolicyRepository(db *gorm.DB) *PolicyRepository {
+	return &PolicyRepository{db: db}
+}
+
+// List returns every policy in evaluation order
+func (r *PolicyRepository) List() ([]*model.Policy, error) {
+	var policies []*model.Policy
+	err := r.db.Order("priority, id").Find(&policies).Error
+	return policies, err
+}
+
+// ListEnabled returns the enabled policies in evaluation order
+func (r *PolicyRepository) ListEnabled() ([]*model.Policy, error) {
+	var policies []*model.Policy
+	err := r.db.Where("enabled = ?", true).Order("priority, id").Find(&policies).Error
+	return policies, err
+}
+
+func (r *PolicyRepository) GetByID(id string) (*model.Policy, error) {
+	var p model.Policy
+	if err := r.db.Where("id = ?", id).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Save creates the policy or replaces the stored one with the same ID
+func (r *PolicyRepository) Save(p *model.Policy) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"document", "priority", "enabled", "updated_at"}),
+	}).Create(p).Error
+}
+
+// Delete removes the policy and returns how many rows were deleted
+func (r *PolicyRepository) Delete(id string) (int64, error) {
+	result := r.db.Where("id = ?", id).Delete(&model.Policy{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"authorization/internal/ratelimit"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitRepository keeps rate limit state in the database, so that all
+// nodes count against the same limits. It implements ratelimit.Store.
+type RateLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewRateLimitRepository(db *gorm.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// Update applies fn to the state of the key inside a transaction holding the
+// key's row lock. Expired state is handed to fn as a new key.
+func (r *RateLimitRepository) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *ratelimit.State)) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RateLimit{
+			Key:       key,
+			ExpiresAt: now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		var row model.RateLimit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		var state ratelimit.State
+		if now.Before(row.ExpiresAt) {
+			state = ratelimit.State{Value: row.Value, Previous: row.Previous, Time: row.WindowTime}
+		}
+		fn(&state)
+
+		row.Value = state.Value
+		row.Previous = state.Previous
+		row.WindowTime = state.Time
+		row.ExpiresAt = now.Add(ttl)
+		return tx.Save(&row).Error
+	})
+}
+
+// DeleteExpired removes the state of keys that have expired
+func (r *RateLimitRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&model.RateLimit{}).Error
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"authorization/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *RoleRepository) Transaction(fn func(repo *RoleRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&RoleRepository{db: tx})
+	})
+}
+
+func (r *RoleRepository) Create(role *model.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *RoleRepository) GetByName(name string) (*model.Role, error) {
+	var role model.Role
+	err := r.db.Preload("Permissions").Preload("Inherits").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *RoleRepository) List() ([]*model.Role, error) {
+	var roles []*model.Role
+	err := r.db.Preload("Permissions").Preload("Inherits").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// ListForUpdate returns every role and locks them until the surrounding
+// transaction ends, which serialises changes to the role hierarchy
+func (r *RoleRepository) ListForUpdate() ([]*model.Role, error) {
+	var roles []*model.Role
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Permissions").
+		Preload("Inherits").
+		Order("name").
+		Find(&roles).Error
+	return roles, err
+}
+
+// GetByNames returns the roles with the given names. Names that do not
+// exist are left out.
+func (r *RoleRepository) GetByNames(names []string) ([]model.Role, error) {
+	var roles []model.Role
+	err := r.db.Where("name IN ?", names).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// SetPermissions replaces the permissions granted by role
+func (r *RoleRepository) SetPermissions(role *model.Role, permissions []model.Permission) error {
+	return r.db.Model(role).Association("Permissions").Replace(permissions)
+}
+
+// SetInherits replaces the roles role inherits from
+func (r *RoleRepository) SetInherits(role *model.Role, inherits []model.Role) error {
+	return r.db.Model(role).Association("Inherits").Replace(inherits)
+}
+
+func (r *RoleRepository) CreatePermission(permission *model.Permission) error {
+	return r.db.Create(permission).Error
+}
+
+// GetPermissionsByName returns the permissions with the given names. Names
+// that do not exist are left out.
+func (r *RoleRepository) GetPermissionsByName(names []string) ([]model.Permission, error) {
+	var permissions []model.Permission
+	err := r.db.Where("name IN ?", names).Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *RoleRepository) ListPermissions() ([]*model.Permission, error) {
+	var permissions []*model.Permission
+	err := r.db.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// GetUserRoleNames returns the names of the roles assigned to the user
+func (r *RoleRepository) GetUserRoleNames(userID string) ([]string, error) {
+	var names []string
+	err := r.db.Model(&model.Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+// AssignRole gives the user a role. Assigning a role the user already has is
+// not an error.
+func (r *RoleRepository) AssignRole(userID, roleID string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.UserRole{
+		UserID: userID,
+		RoleID: roleID,
+	}).Error
+}
+
+// LockRoleAssignments locks the assignments of the role until the surrounding
+// transaction ends, so that concurrent revocations of the role wait for each
+// other before counting who is left
+func (r *RoleRepository) LockRoleAssignments(roleID string) error {
+	var assignments []model.UserRole
+	return r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role_id = ?", roleID).
+		Find(&assignments).Error
+}
+
+// RemoveRole takes a role away from the user and returns how many
+// assignments were removed
+func (r *RoleRepository) RemoveRole(userID, roleID string) (int64, error) {
+	result := r.db.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&model.UserRole{})
+	return result.RowsAffected, result.Error
+}
+
+// CountUsersWithRole counts the users holding the role, ignoring deleted and
+// disabled users
+func (r *RoleRepository) CountUsersWithRole(roleID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.UserRole{}).
+		Joins("JOIN users ON users.id = user_roles.user_id").
+		Where("user_roles.role_id = ? AND users.is_deleted = false AND users.disabled_at IS NULL", roleID).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SigningKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *SigningKeyRepository) Transaction(fn func(repo *SigningKeyRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&SigningKeyRepository{db: tx})
+	})
+}
+
+func (r *SigningKeyRepository) Create(key *model.SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+// CreateFirst stores the first active key unless another one was stored
+// meanwhile, and reports whether it did
+func (r *SigningKeyRepository) CreateFirst(key *model.SigningKey) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	return result.RowsAffected == 1, result.Error
+}
+
+// GetActiveForUpdate returns the active key and locks it until the
+// surrounding transaction ends
+func (r *SigningKeyRepository) GetActiveForUpdate() (*model.SigningKey, error) {
+	var key model.SigningKey
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("status = ?", model.SigningKeyActive).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetPublished returns the newest key published ahead of signing, or
+// gorm.ErrRecordNotFound
+func (r *SigningKeyRepository) GetPublished() (*model.SigningKey, error) {
+	var key model.SigningKey
+	err := r.db.Where("status = ? AND retire_after IS NULL", model.SigningKeyVerify).
+		Order("created_at DESC").
+		First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetUsable returns the active key and every key still accepted for verification
+func (r *SigningKeyRepository) GetUsable() ([]*model.SigningKey, error) {
+	var keys []*model.SigningKey
+	err := r.db.Where("status IN ?", []string{model.SigningKeyActive, model.SigningKeyVerify}).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *SigningKeyRepository) List() ([]*model.SigningKey, error) {
+	var keys []*model.SigningKey
+	err := r.db.Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Demote stops the key from signing if it is still the active one and
+// reports whether this call was the one that demoted it
+func (r *SigningKeyRepository) Demote(id string, retireAfter time.Time) (bool, error) {
+	result := r.db.Model(&model.SigningKey{}).
+		Where("id = ? AND status = ?", id, model.SigningKeyActive).
+		Updates(map[string]interface{}{
+			"status":       model.SigningKeyVerify,
+			"rotated_at":   time.Now(),
+			"retire_after": retireAfter,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Promote makes a published key the active one. The active key must have
+// been demoted first.
+func (r *SigningKeyRepository) Promote(id string) (bool, error) {
+	result := r.db.Model(&model.SigningKey{}).
+		Where("id = ? AND status = ? AND retire_after IS NULL", id, model.SigningKeyVerify).
+		Update("status", model.SigningKeyActive)
+	return result.RowsAffected == 1, result.Error
+}
+
+// RetireExpired retires verification keys past their retirement time and
+// erases their key material
+func (r *SigningKeyRepository) RetireExpired(now time.Time) (int64, error) {
+	result := r.db.Model(&model.SigningKey{}).
+		Where("status = ? AND retire_after < ?", model.SigningKeyVerify, now).
+		Updates(map[string]interface{}{
+			"status":      model.SigningKeyRetired,
+			"private_key": "",
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SingleUseToken is a model of tokens that are sent to a user, stored by
+// their hash and used once: password reset and email verification tokens
+type SingleUseToken interface {
+	model.PasswordResetToken | model.EmailVerificationToken
+}
+
+// SingleUseTokenRepository holds the queries shared by the tables of single
+// use tokens. Their repositories embed it.
+type SingleUseTokenRepository[T SingleUseToken] struct {
+	db *gorm.DB
+}
+
+func (r *SingleUseTokenRepository[T]) Create(token *T) error {
+	return r.db.Create(token).Error
+}
+
+// LockUser locks the row of the user until the surrounding transaction ends,
+// which serialises issuing tokens to them
+func (r *SingleUseTokenRepository[T]) LockUser(userID string) error {
+	var user model.User
+	return r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ?", userID).
+		First(&user).Error
+}
+
+// LastIssuedAt returns when the user was last sent a token, nil if never
+func (r *SingleUseTokenRepository[T]) LastIssuedAt(userID string) (*time.Time, error) {
+	var createdAt []time.Time
+	err := r.db.Model(new(T)).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(1).
+		Pluck("created_at", &createdAt).Error
+	if err != nil || len(createdAt) == 0 {
+		return nil, err
+	}
+	return &createdAt[0], nil
+}
+
+// InvalidateUserTokens marks every unused token of the user as used, so that
+// only the most recently issued link works
+func (r *SingleUseTokenRepository[T]) InvalidateUserTokens(userID string) error {
+	return r.db.Model(new(T)).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// GetValid returns the token stored under any of the hashes if it is unused
+// and unexpired, without using it up, or gorm.ErrRecordNotFound
+func (r *SingleUseTokenRepository[T]) GetValid(tokenHashes []string) (*T, error) {
+	var token T
+	err := r.db.Where("token_hash IN ? AND used_at IS NULL AND expires_at > ?", tokenHashes, time.Now()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rekey replaces the hash a token is stored under, unless another request
+// changed it first, and reports whether it did
+func (r *SingleUseTokenRepository[T]) Rekey(id, oldHash, newHash string) (bool, error) {
+	result := r.db.Model(new(T)).
+		Where("id = ? AND token_hash = ?", id, oldHash).
+		Update("token_hash", newHash)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Consume marks the token stored under any of the hashes as used if it is
+// unused and unexpired and returns it. Concurrent calls with the same token
+// succeed at most once; the others, like unknown tokens, get
+// gorm.ErrRecordNotFound.
+func (r *SingleUseTokenRepository[T]) Consume(tokenHashes []string) (*T, error) {
+	now := time.Now()
+	result := r.db.Model(new(T)).
+		Where("token_hash IN ? AND used_at IS NULL AND expires_at > ?", tokenHashes, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var token T
+	if err := r.db.Where("token_hash IN ?", tokenHashes).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
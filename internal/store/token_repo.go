@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TokenRepository struct {
@@ -15,40 +16,165 @@ func NewTokenRepository(db *gorm.DB) *TokenRepository {
 	return &TokenRepository{db: db}
 }
 
+// Transaction runs fn with a repository bound to a single database transaction
+func (r *TokenRepository) Transaction(fn func(repo *TokenRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&TokenRepository{db: tx})
+	})
+}
+
 func (r *TokenRepository) Create(token *model.RefreshToken) error {
 	return r.db.Create(token).Error
 }
 
-func (r *TokenRepository) GetByTokenHash(tokenHash string) (*model.RefreshToken, error) {
+// GetByTokenHash looks up an unrevoked token by any of the hashes it may be
+// stored under, see utils.TokenHashes
+func (r *TokenRepository) GetByTokenHash(tokenHashes []string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.Preload("User").Where("token_hash IN ? AND revoked = false", tokenHashes).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetByTokenHashWithRevoked looks up a token regardless of its revoked state,
+// so that replays of rotated tokens can be detected
+func (r *TokenRepository) GetByTokenHashWithRevoked(tokenHashes []string) (*model.RefreshToken, error) {
 	var token model.RefreshToken
-	err := r.db.Preload("User").Where("token_hash = ? AND revoked = false", tokenHash).First(&token).Error
+	err := r.db.Preload("User").Where("token_hash IN ?", tokenHashes).First(&token).Error
 	if err != nil {
 		return nil, err
 	}
 	return &token, nil
 }
 
+// GetByTokenHashForUpdate looks up a token regardless of its revoked state and
+// locks the row until the surrounding transaction ends
+func (r *TokenRepository) GetByTokenHashForUpdate(tokenHashes []string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash IN ?", tokenHashes).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rekey replaces the hash a token is stored under, unless another request
+// changed it first, and reports whether it did
+func (r *TokenRepository) Rekey(id, oldHash, newHash string) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND token_hash = ?", id, oldHash).
+		Update("token_hash", newHash)
+	return result.RowsAffected == 1, result.Error
+}
+
 func (r *TokenRepository) GetByUserID(userID string) ([]*model.RefreshToken, error) {
 	var tokens []*model.RefreshToken
 	err := r.db.Where("user_id = ? AND revoked = false", userID).Find(&tokens).Error
 	return tokens, err
 }
 
-func (r *TokenRepository) RevokeToken(tokenHash string) error {
-	return r.db.Model(&model.RefreshToken{}).Where("token_hash = ?", tokenHash).Update("revoked", true).Error
+// GetActiveByUserID returns the unexpired, unrevoked tokens of a user. Each
+// family has at most one such token, so this is the list of active sessions.
+func (r *TokenRepository) GetActiveByUserID(userID string) ([]*model.RefreshToken, error) {
+	var tokens []*model.RefreshToken
+	err := r.db.Where("user_id = ? AND revoked = false AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// UnrevokedFamilyIDs returns the sessions of a user that have not been
+// revoked, except exceptFamilyID. Their access tokens may still be valid.
+func (r *TokenRepository) UnrevokedFamilyIDs(userID, exceptFamilyID string) ([]string, error) {
+	var familyIDs []string
+	err := r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked = false", userID, exceptFamilyID).
+		Distinct().
+		Pluck("family_id", &familyIDs).Error
+	return familyIDs, err
+}
+
+func (r *TokenRepository) RevokeToken(tokenHashes []string) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("token_hash IN ? AND revoked = false", tokenHashes).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": time.Now()}).Error
+}
+
+// RevokeIfActive revokes the token only if nobody else revoked it first and
+// reports whether this call was the one that did
+func (r *TokenRepository) RevokeIfActive(id string) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND revoked = false", id).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": time.Now()})
+	return result.RowsAffected == 1, result.Error
+}
+
+// HasChildren reports whether a token was exchanged for another one
+func (r *TokenRepository) HasChildren(parentID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.RefreshToken{}).Where("parent_id = ?", parentID).Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// RevokeActiveChildren revokes the still active tokens minted from parentID
+// and returns how many were revoked
+func (r *TokenRepository) RevokeActiveChildren(parentID string) (int64, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("parent_id = ? AND revoked = false", parentID).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeFamily revokes every token that descends from the same login
+func (r *TokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked = false", familyID).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": time.Now()}).Error
+}
+
+// RevokeUserFamily revokes a family only if it belongs to userID and returns
+// how many tokens were revoked
+func (r *TokenRepository) RevokeUserFamily(userID, familyID string) (int64, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked = false", userID, familyID).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// SetFamilyOrganization records the organization an active session of
+// userID is working in, nil for none, and returns how many tokens were
+// updated
+func (r *TokenRepository) SetFamilyOrganization(userID, familyID string, orgID *string) (int64, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked = false AND expires_at > ?", userID, familyID, time.Now()).
+		Update("organization_id", orgID)
+	return result.RowsAffected, result.Error
 }
 
 func (r *TokenRepository) RevokeAllUserTokens(userID string) error {
-	return r.db.Model(&model.RefreshToken{}).Where("user_id = ?", userID).Update("revoked", true).Error
+	return r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked = false", userID).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": time.Now()}).Error
+}
+
+// RevokeAllUserTokensExcept revokes every token of a user outside the given family
+func (r *TokenRepository) RevokeAllUserTokensExcept(userID, familyID string) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked = false", userID, familyID).
+		Updates(map[string]interface{}{"revoked": true, "revoked_at": time.Now()}).Error
 }
 
+// CleanExpiredTokens deletes expired tokens. Revoked tokens are kept until they
+// expire so that a replay can still be recognised as reuse of a rotated token.
 func (r *TokenRepository) CleanExpiredTokens() error {
-	return r.db.Where("expires_at < ? OR revoked = true", time.Now()).Delete(&model.RefreshToken{}).Error
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&model.RefreshToken{}).Error
 }
 
-func (r *TokenRepository) IsTokenValid(tokenHash string) (bool, error) {
+func (r *TokenRepository) IsTokenValid(tokenHashes []string) (bool, error) {
 	var token model.RefreshToken
-	err := r.db.Where("token_hash = ? AND revoked = false AND expires_at > ?", tokenHash, time.Now()).First(&token).Error
+	err := r.db.Where("token_hash IN ? AND revoked = false AND expires_at > ?", tokenHashes, time.Now()).First(&token).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return false, nil
@@ -2,8 +2,12 @@ package store
 
 import (
 	"authorization/internal/model"
+	"errors"
+	"slices"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository struct {
@@ -18,6 +22,17 @@ func (r *UserRepository) Create(user *model.User) error {
 	return r.db.Create(user).Error
 }
 
+// CreateWithRole creates the user and assigns them the role in a single
+// transaction, so that a failed assignment leaves no user without a role
+func (r *UserRepository) CreateWithRole(user *model.User, roleID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.UserRole{UserID: user.ID, RoleID: roleID}).Error
+	})
+}
+
 func (r *UserRepository) GetByID(id string) (*model.User, error) {
 	var user model.User
 	err := r.db.Where("id = ? AND is_deleted = false", id).First(&user).Error
@@ -59,6 +74,17 @@ func (r *UserRepository) SoftDelete(id string) error {
 	return r.db.Save(&user).Error
 }
 
+// PasswordChangedAt returns when the user last changed their password, nil
+// if they never did
+func (r *UserRepository) PasswordChangedAt(id string) (*time.Time, error) {
+	var user model.User
+	err := r.db.Select("id", "password_changed_at").Where("id = ? AND is_deleted = false", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return user.PasswordChangedAt, nil
+}
+
 func (r *UserRepository) ExistsByUsername(username string) (bool, error) {
 	var count int64
 	err := r.db.Model(&model.User{}).Where("username = ? AND is_deleted = false", username).Count(&count).Error
@@ -70,3 +96,90 @@ func (r *UserRepository) ExistsByEmail(email string) (bool, error) {
 	err := r.db.Model(&model.User{}).Where("email = ? AND is_deleted = false", email).Count(&count).Error
 	return count > 0, err
 }
+
+// UpdatePasswordHash replaces the password hash of the user with one of the
+// same password, so unlike a password change it leaves PasswordChangedAt
+// alone. The hash is only replaced while it is still oldHash, and the result
+// reports whether it was.
+func (r *UserRepository) UpdatePasswordHash(id, oldHash, newHash string) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND password_hash = ?", id, oldHash).
+		Update("password_hash", newHash)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdatePassword sets a new password hash for the user, changed at
+// changedAt. Only these columns are written, so changes other requests made
+// to the user meanwhile are kept.
+func (r *UserRepository) UpdatePassword(id, hash string, changedAt time.Time) error {
+	return r.db.Model(&model.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"password_hash": hash, "password_changed_at": changedAt}).Error
+}
+
+// SetEmailVerifiedAt marks the email of the user as verified at verifiedAt
+// unless it already was, and reports whether it did
+func (r *UserRepository) SetEmailVerifiedAt(id string, verifiedAt time.Time) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", verifiedAt)
+	return result.RowsAffected == 1, result.Error
+}
+
+// SetDisabledAt disables the user as of disabledAt, or enables them with nil,
+// and reports whether the user exists
+func (r *UserRepository) SetDisabledAt(id string, disabledAt *time.Time) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND is_deleted = false", id).
+		Update("disabled_at", disabledAt)
+	return result.RowsAffected == 1, result.Error
+}
+
+// errLastRoleHolder rolls back disabling the last active holder of a role
+var errLastRoleHolder = errors.New("last active holder of the role")
+
+// DisableKeepingRole disables the user as of disabledAt unless they are the
+// last active user holding the named role. It reports whether the user
+// exists and whether they were kept enabled for being the last holder. The
+// assignments of the role stay locked until the check is done, so that
+// concurrent calls cannot disable the last two holders together.
+func (r *UserRepository) DisableKeepingRole(id string, disabledAt time.Time, roleName string) (bool, bool, error) {
+	var found bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var holders []string
+		err := tx.Model(&model.UserRole{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("role_id IN (?)", tx.Model(&model.Role{}).Select("id").Where("name = ?", roleName)).
+			Pluck("user_id", &holders).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND is_deleted = false", id).
+			Update("disabled_at", disabledAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		found = result.RowsAffected == 1
+		if !found || !slices.Contains(holders, id) {
+			return nil
+		}
+
+		var remaining int64
+		err = tx.Model(&model.User{}).
+			Where("id IN ? AND is_deleted = false AND disabled_at IS NULL", holders).
+			Count(&remaining).Error
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return errLastRoleHolder
+		}
+		return nil
+	})
+	if errors.Is(err, errLastRoleHolder) {
+		return true, true, nil
+	}
+	return found, false, err
+}
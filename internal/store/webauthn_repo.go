@@ -0,0 +1,90 @@
+package store
+
+import (
+	"authorization/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebAuthnRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnRepository(db *gorm.DB) *WebAuthnRepository {
+	return &WebAuthnRepository{db: db}
+}
+
+// CreateChallenge stores a ceremony challenge, clearing out expired ones
+func (r *WebAuthnRepository) CreateChallenge(challenge *model.WebAuthnChallenge) error {
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&model.WebAuthnChallenge{}).Error; err != nil {
+		return err
+	}
+	return r.db.Create(challenge).Error
+}
+
+// ConsumeChallenge marks the unused, unexpired challenge of the ceremony with
+// the given hash as used and returns it. Concurrent calls succeed at most
+// once; the others, like unknown challenges, get gorm.ErrRecordNotFound.
+func (r *WebAuthnRepository) ConsumeChallenge(challengeHash, ceremony string) (*model.WebAuthnChallenge, error) {
+	now := time.Now()
+	result := r.db.Model(&model.WebAuthnChallenge{}).
+		Where("challenge_hash = ? AND ceremony = ? AND used_at IS NULL AND expires_at > ?", challengeHash, ceremony, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var challenge model.WebAuthnChallenge
+	if err := r.db.Where("challenge_hash = ?", challengeHash).First(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *WebAuthnRepository) CreateCredential(credential *model.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+// GetCredential returns the credential with the given authenticator chosen ID
+func (r *WebAuthnRepository) GetCredential(credentialID string) (*model.WebAuthnCredential, error) {
+	var credential model.WebAuthnCredential
+	if err := r.db.Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// ListCredentials returns the user's credentials, oldest first
+func (r *WebAuthnRepository) ListCredentials(userID string) ([]model.WebAuthnCredential, error) {
+	var credentials []model.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&credentials).Error
+	return credentials, err
+}
+
+// HasCredentials reports whether the user has registered a credential
+func (r *WebAuthnRepository) HasCredentials(userID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error
+	return count > 0, err
+}
+
+// RecordUse stores the sign count of a successful assertion. It fails if the
+// stored count is no longer oldCount, so of two concurrent assertions with
+// the same count only one is accepted.
+func (r *WebAuthnRepository) RecordUse(id string, oldCount, newCount int64) (bool, error) {
+	result := r.db.Model(&model.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, oldCount).
+		Updates(map[string]interface{}{"sign_count": newCount, "last_used_at": time.Now()})
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteCredential removes one of the user's credentials and reports whether
+// it existed
+func (r *WebAuthnRepository) DeleteCredential(userID, id string) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.WebAuthnCredential{})
+	return result.RowsAffected == 1, result.Error
+}
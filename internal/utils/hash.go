@@ -2,12 +2,18 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
 
+// Parameters of the hashes stored before the PHC string format, which had no
+// room to record them
 const (
 	argonTime     = 1
 	argonMemory   = 64 * 1024
@@ -16,7 +22,66 @@ const (
 	saltLen       = 16
 )
 
-// HashPassword hashes a password using argon2id
+// Upper bounds of the argon2id parameters, so that a stored or imported hash
+// cannot make checking a password exhaust memory or tie up the CPU
+const (
+	maxArgonMemory = 1024 * 1024
+	maxArgonTime   = 10
+)
+
+// PasswordParams are the argon2id parameters passwords are hashed with.
+// Memory is in KiB.
+type PasswordParams struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+// DefaultPasswordParams are used until SetPasswordParams is called
+var DefaultPasswordParams = PasswordParams{Memory: argonMemory, Time: argonTime, Threads: argonThreads}
+
+// passwordParams are the parameters new hashes are made with
+var passwordParams = DefaultPasswordParams
+
+// Validate checks that argon2id accepts the parameters and that they are
+// within the bounds of what a login may cost
+func (p PasswordParams) Validate() error {
+	if p.Time < 1 || p.Time > maxArgonTime {
+		return fmt.Errorf("argon2 time must be between 1 and %d", maxArgonTime)
+	}
+	if p.Threads < 1 {
+		return fmt.Errorf("argon2 threads must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Threads) {
+		return fmt.Errorf("argon2 memory must be at least 8 KiB per thread")
+	}
+	if p.Memory > maxArgonMemory {
+		return fmt.Errorf("argon2 memory must be at most %d KiB", maxArgonMemory)
+	}
+	return nil
+}
+
+// weakerThan reports whether hashes made with p are cheaper to crack than
+// ones made with other
+func (p PasswordParams) weakerThan(other PasswordParams) bool {
+	return p.Memory < other.Memory || p.Time < other.Time
+}
+
+// SetPasswordParams sets the parameters new password hashes are made with.
+// It is meant to be called once at startup, before any password is hashed.
+func SetPasswordParams(params PasswordParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	passwordParams = params
+	return nil
+}
+
+// HashPassword hashes a password using argon2id. The hash is a PHC string,
+// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>, so it records the parameters
+// it was made with and stays verifiable after they change. With peppers set,
+// the password is peppered with the active one and its version is recorded
+// as another parameter, as in m=65536,t=1,p=4,pepper=2.
 func HashPassword(password string) (string, error) {
 	salt := make([]byte, saltLen)
 	_, err := rand.Read(salt)
@@ -24,39 +89,245 @@ func HashPassword(password string) (string, error) {
 		return "", err
 	}
 
-	hash := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	pepperVersion := activePepperVersion()
+	input, err := pepperPassword(password, pepperVersion)
+	if err != nil {
+		return "", err
+	}
 
-	// Combine salt and hash
-	combined := append(salt, hash...)
-	return base64.StdEncoding.EncodeToString(combined), nil
+	params := passwordParams
+	hash := argon2.IDKey(input, salt, params.Time, params.Memory, params.Threads, argonKeyLen)
+
+	encodedParams := fmt.Sprintf("m=%d,t=%d,p=%d", params.Memory, params.Time, params.Threads)
+	if pepperVersion > 0 {
+		encodedParams += fmt.Sprintf(",pepper=%d", pepperVersion)
+	}
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s",
+		argon2.Version, encodedParams,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
 }
 
-// VerifyPassword verifies a password against its hash
+// PasswordVerifier checks passwords against the hashes of one format
+type PasswordVerifier interface {
+	// Check returns an error if the hash is not well formed, without the
+	// cost of hashing a password
+	Check(encodedHash string) error
+	// Verify reports whether the password matches the hash
+	Verify(password, encodedHash string) (bool, error)
+}
+
+// passwordVerifiers are keyed by the identifier between the first two "$" of
+// a hash, as in PHC strings and the crypt formats before them. Hashes without
+// one are the argon2id hashes stored before the PHC format.
+var passwordVerifiers = map[string]PasswordVerifier{
+	"argon2id":      argon2idVerifier{},
+	"2a":            bcryptVerifier{},
+	"2b":            bcryptVerifier{},
+	"2y":            bcryptVerifier{},
+	"scrypt":        scryptVerifier{},
+	"pbkdf2-sha256": pbkdf2Verifier{hash: sha256.New},
+}
+
+// RegisterPasswordVerifier makes VerifyPassword accept hashes with the given
+// identifier, such as those of a system users are imported from. It replaces
+// any verifier of the identifier and is meant to be called at startup.
+func RegisterPasswordVerifier(id string, verifier PasswordVerifier) {
+	passwordVerifiers[id] = verifier
+}
+
+// passwordHashID returns the identifier of the format of a hash, "" for the
+// argon2id hashes stored before the PHC format
+func passwordHashID(encodedHash string) string {
+	if !strings.HasPrefix(encodedHash, "$") {
+		return ""
+	}
+	id, _, _ := strings.Cut(encodedHash[1:], "$")
+	return id
+}
+
+func passwordVerifier(encodedHash string) (PasswordVerifier, error) {
+	id := passwordHashID(encodedHash)
+	if id == "" {
+		return argon2idVerifier{}, nil
+	}
+	verifier, ok := passwordVerifiers[id]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", id)
+	}
+	return verifier, nil
+}
+
+// VerifyPassword verifies a password against its hash in any registered
+// format
 func VerifyPassword(password, encodedHash string) (bool, error) {
-	combined, err := base64.StdEncoding.DecodeString(encodedHash)
+	verifier, err := passwordVerifier(encodedHash)
 	if err != nil {
 		return false, err
 	}
+	return verifier.Verify(password, encodedHash)
+}
 
-	if len(combined) != saltLen+argonKeyLen {
-		return false, fmt.Errorf("invalid hash format")
+// CheckPasswordHash returns an error if VerifyPassword cannot read the hash,
+// without verifying a password against it
+func CheckPasswordHash(encodedHash string) error {
+	verifier, err := passwordVerifier(encodedHash)
+	if err != nil {
+		return err
 	}
+	return verifier.Check(encodedHash)
+}
 
-	salt := combined[:saltLen]
-	hash := combined[saltLen:]
+// PasswordNeedsRehash reports whether a hash should be replaced once the
+// password is known, because it is not an argon2id PHC string, was made
+// with weaker parameters than the current ones or is not peppered with the
+// active pepper. Hashes that cannot be read are left alone; VerifyPassword
+// fails them.
+func PasswordNeedsRehash(encodedHash string) bool {
+	if CheckPasswordHash(encodedHash) != nil {
+		return false
+	}
+	if passwordHashID(encodedHash) != "argon2id" {
+		return true
+	}
 
-	comparisonHash := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	decoded, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return false
+	}
+	return decoded.params.weakerThan(passwordParams) || len(decoded.hash) < argonKeyLen || decoded.pepper != activePepperVersion()
+}
+
+// argon2idVerifier verifies the hashes made by HashPassword
+type argon2idVerifier struct{}
+
+func (argon2idVerifier) Check(encodedHash string) error {
+	decoded, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return err
+	}
+	_, err = pepperPassword("", decoded.pepper)
+	return err
+}
+
+func (argon2idVerifier) Verify(password, encodedHash string) (bool, error) {
+	decoded, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+	input, err := pepperPassword(password, decoded.pepper)
+	if err != nil {
+		return false, err
+	}
+
+	comparisonHash := argon2.IDKey(input, decoded.salt, decoded.params.Time, decoded.params.Memory, decoded.params.Threads, uint32(len(decoded.hash)))
 
 	// Constant time comparison
-	if len(hash) != len(comparisonHash) {
-		return false, nil
+	return subtle.ConstantTimeCompare(decoded.hash, comparisonHash) == 1, nil
+}
+
+// decodedArgon2idHash is an argon2id hash taken apart
+type decodedArgon2idHash struct {
+	params PasswordParams
+	// pepper is the version of the pepper, 0 if the hash is not peppered
+	pepper int
+	salt   []byte
+	hash   []byte
+}
+
+func decodeArgon2idHash(encodedHash string) (*decodedArgon2idHash, error) {
+	if !strings.HasPrefix(encodedHash, "$") {
+		return decodeLegacyPasswordHash(encodedHash)
+	}
+
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, fmt.Errorf("invalid hash format")
+	}
+	if parts[1] != "argon2id" {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", parts[1])
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	params, pepper, err := decodeArgon2idParams(parts[3])
+	if err != nil {
+		return nil, err
 	}
 
-	for i := range hash {
-		if hash[i] != comparisonHash[i] {
-			return false, nil
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("invalid hash format")
+	}
+
+	return &decodedArgon2idHash{params: params, pepper: pepper, salt: salt, hash: hash}, nil
+}
+
+// decodeArgon2idParams reads the m, t and p parameters of an argon2id PHC
+// string and the version of its pepper, if it has one
+func decodeArgon2idParams(encoded string) (PasswordParams, int, error) {
+	var params PasswordParams
+	pepper := 0
+	seen := make(map[string]bool)
+	for _, param := range strings.Split(encoded, ",") {
+		name, valueStr, _ := strings.Cut(param, "=")
+		value, err := strconv.ParseUint(valueStr, 10, 32)
+		if err != nil || seen[name] {
+			return params, 0, fmt.Errorf("invalid argon2 parameters %q", encoded)
+		}
+		seen[name] = true
+
+		switch name {
+		case "m":
+			params.Memory = uint32(value)
+		case "t":
+			params.Time = uint32(value)
+		case "p":
+			if value > 255 {
+				return params, 0, fmt.Errorf("invalid argon2 parameters %q", encoded)
+			}
+			params.Threads = uint8(value)
+		case "pepper":
+			if value == 0 {
+				return params, 0, fmt.Errorf("invalid argon2 parameters %q", encoded)
+			}
+			pepper = int(value)
+		default:
+			return params, 0, fmt.Errorf("unsupported argon2 parameter %q", name)
 		}
 	}
+	if !seen["m"] || !seen["t"] || !seen["p"] {
+		return params, 0, fmt.Errorf("invalid argon2 parameters %q", encoded)
+	}
+	if err := params.Validate(); err != nil {
+		return params, 0, err
+	}
+	return params, pepper, nil
+}
+
+// decodeLegacyPasswordHash reads the base64 salt and hash stored before the
+// PHC string format, which were always made with the same parameters
+func decodeLegacyPasswordHash(encodedHash string) (*decodedArgon2idHash, error) {
+	combined, err := base64.StdEncoding.DecodeString(encodedHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(combined) != saltLen+argonKeyLen {
+		return nil, fmt.Errorf("invalid hash format")
+	}
 
-	return true, nil
+	return &decodedArgon2idHash{
+		params: PasswordParams{Memory: argonMemory, Time: argonTime, Threads: argonThreads},
+		salt:   combined[:saltLen],
+		hash:   combined[saltLen:],
+	}, nil
 }
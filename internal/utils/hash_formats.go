@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Password hashes of the systems users are imported from. They verify like
+// any other hash, and PasswordNeedsRehash reports them so that logins move
+// them to argon2id.
+
+// Upper bounds of the cost parameters of imported hashes, so that one cannot
+// make checking a password exhaust memory or tie up the CPU
+const (
+	maxBcryptCost       = 16
+	maxScryptWork       = 1 << 24 // N·r·p
+	maxPBKDF2Iterations = 10_000_000
+)
+
+// bcryptVerifier verifies bcrypt hashes, $2b$<cost>$<salt and hash>
+type bcryptVerifier struct{}
+
+func (bcryptVerifier) Check(encodedHash string) error {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	if err != nil {
+		return err
+	}
+	if cost > maxBcryptCost {
+		return fmt.Errorf("bcrypt cost %d is above %d", cost, maxBcryptCost)
+	}
+	return nil
+}
+
+func (v bcryptVerifier) Verify(password, encodedHash string) (bool, error) {
+	if err := v.Check(encodedHash); err != nil {
+		return false, err
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// scryptVerifier verifies scrypt hashes in the PHC format of passlib,
+// $scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>
+type scryptVerifier struct{}
+
+type decodedScryptHash struct {
+	logN, r, p int
+	salt, hash []byte
+}
+
+func (scryptVerifier) decode(encodedHash string) (*decodedScryptHash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "scrypt" {
+		return nil, fmt.Errorf("invalid scrypt hash format")
+	}
+
+	var decoded decodedScryptHash
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &decoded.logN, &decoded.r, &decoded.p); err != nil {
+		return nil, fmt.Errorf("invalid scrypt parameters %q", parts[2])
+	}
+	if decoded.logN < 1 || decoded.logN > 30 || decoded.r < 1 || decoded.p < 1 || decoded.r*decoded.p >= 1<<30 {
+		return nil, fmt.Errorf("invalid scrypt parameters %q", parts[2])
+	}
+	if decoded.r*decoded.p > maxScryptWork>>decoded.logN {
+		return nil, fmt.Errorf("scrypt parameters %q are too costly", parts[2])
+	}
+
+	var err error
+	if decoded.salt, err = decodeHashBase64(parts[3]); err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	if decoded.hash, err = decodeHashBase64(parts[4]); err != nil || len(decoded.hash) == 0 {
+		return nil, fmt.Errorf("invalid scrypt hash format")
+	}
+	return &decoded, nil
+}
+
+func (v scryptVerifier) Check(encodedHash string) error {
+	_, err := v.decode(encodedHash)
+	return err
+}
+
+func (v scryptVerifier) Verify(password, encodedHash string) (bool, error) {
+	decoded, err := v.decode(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	comparisonHash, err := scrypt.Key([]byte(password), decoded.salt, 1<<decoded.logN, decoded.r, decoded.p, len(decoded.hash))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(decoded.hash, comparisonHash) == 1, nil
+}
+
+// pbkdf2Verifier verifies PBKDF2 hashes in the format of passlib,
+// $pbkdf2-sha256$<iterations>$<salt>$<hash>
+type pbkdf2Verifier struct {
+	hash func() hash.Hash
+}
+
+type decodedPBKDF2Hash struct {
+	iterations int
+	salt, hash []byte
+}
+
+func (pbkdf2Verifier) decode(encodedHash string) (*decodedPBKDF2Hash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 || parts[0] != "" {
+		return nil, fmt.Errorf("invalid pbkdf2 hash format")
+	}
+
+	var (
+		decoded decodedPBKDF2Hash
+		err     error
+	)
+	if decoded.iterations, err = strconv.Atoi(parts[2]); err != nil || decoded.iterations < 1 {
+		return nil, fmt.Errorf("invalid pbkdf2 iterations %q", parts[2])
+	}
+	if decoded.iterations > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("pbkdf2 iterations %d are above %d", decoded.iterations, maxPBKDF2Iterations)
+	}
+	if decoded.salt, err = decodeHashBase64(parts[3]); err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	if decoded.hash, err = decodeHashBase64(parts[4]); err != nil || len(decoded.hash) == 0 {
+		return nil, fmt.Errorf("invalid pbkdf2 hash format")
+	}
+	return &decoded, nil
+}
+
+func (v pbkdf2Verifier) Check(encodedHash string) error {
+	_, err := v.decode(encodedHash)
+	return err
+}
+
+func (v pbkdf2Verifier) Verify(password, encodedHash string) (bool, error) {
+	decoded, err := v.decode(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	comparisonHash := pbkdf2.Key([]byte(password), decoded.salt, decoded.iterations, len(decoded.hash), v.hash)
+	return subtle.ConstantTimeCompare(decoded.hash, comparisonHash) == 1, nil
+}
+
+// decodeHashBase64 decodes the base64 of salts and hashes, which passlib
+// writes without padding and with "." in place of "+"
+func decodeHashBase64(s string) ([]byte, error) {
+	s = strings.ReplaceAll(strings.TrimRight(s, "="), ".", "+")
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usePasswordParams switches the parameters new hashes are made with for the
+// rest of the test
+func usePasswordParams(t *testing.T, params PasswordParams) {
+	t.Helper()
+	previous := passwordParams
+	if err := SetPasswordParams(params); err != nil {
+		t.Fatalf("Failed to set password params: %v", err)
+	}
+	t.Cleanup(func() { passwordParams = previous })
+}
+
+func TestHashPassword_PHCFormat(t *testing.T) {
+	usePasswordParams(t, PasswordParams{Memory: 1024, Time: 2, Threads: 1})
+
+	hash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$") {
+		t.Errorf("Expected a PHC string with the parameters, got %s", hash)
+	}
+
+	if valid, err := VerifyPassword("password123", hash); err != nil || !valid {
+		t.Errorf("Expected the password to verify, got %v, %v", valid, err)
+	}
+	if valid, err := VerifyPassword("wrongpassword", hash); err != nil || valid {
+		t.Errorf("Expected a wrong password to fail, got %v, %v", valid, err)
+	}
+
+	// Hashes keep verifying with their own parameters after they change
+	usePasswordParams(t, PasswordParams{Memory: 2048, Time: 1, Threads: 2})
+	if valid, err := VerifyPassword("password123", hash); err != nil || !valid {
+		t.Errorf("Expected the password to verify with new params, got %v, %v", valid, err)
+	}
+
+	for _, invalid := range []string{"$argon2i$v=19$m=1024,t=2,p=1$c2FsdA$aGFzaA", "$argon2id$v=16$m=1024,t=2,p=1$c2FsdA$aGFzaA", "$argon2id$v=19$m=1024$c2FsdA$aGFzaA", "$argon2id$v=19$m=1024,t=2,p=1$c2FsdA$"} {
+		if _, err := VerifyPassword("password123", invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestVerifyPassword_LegacyFormat(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("password123"), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	legacy := base64.StdEncoding.EncodeToString(append(salt, key...))
+
+	if valid, err := VerifyPassword("password123", legacy); err != nil || !valid {
+		t.Errorf("Expected the legacy hash to verify, got %v, %v", valid, err)
+	}
+	if valid, err := VerifyPassword("wrongpassword", legacy); err != nil || valid {
+		t.Errorf("Expected a wrong password to fail, got %v, %v", valid, err)
+	}
+	if !PasswordNeedsRehash(legacy) {
+		t.Error("Expected a legacy hash to need rehashing")
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	usePasswordParams(t, PasswordParams{Memory: 2048, Time: 2, Threads: 2})
+	current, _ := HashPassword("password123")
+
+	usePasswordParams(t, PasswordParams{Memory: 1024, Time: 2, Threads: 2})
+	lessMemory, _ := HashPassword("password123")
+
+	usePasswordParams(t, PasswordParams{Memory: 2048, Time: 1, Threads: 1})
+	fewerPasses, _ := HashPassword("password123")
+
+	usePasswordParams(t, PasswordParams{Memory: 4096, Time: 3, Threads: 1})
+	stronger, _ := HashPassword("password123")
+
+	usePasswordParams(t, PasswordParams{Memory: 2048, Time: 2, Threads: 2})
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"current", current, false},
+		{"less memory", lessMemory, true},
+		{"fewer passes", fewerPasses, true},
+		{"stronger", stronger, false},
+		{"unreadable", "not a hash", false},
+	}
+	for _, tt := range tests {
+		if got := PasswordNeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSetPasswordParams_Invalid(t *testing.T) {
+	for _, params := range []PasswordParams{{Memory: 1024, Time: 0, Threads: 1}, {Memory: 1024, Time: 1, Threads: 0}, {Memory: 16, Time: 1, Threads: 4}, {Memory: 2 << 20, Time: 1, Threads: 4}, {Memory: 1024, Time: 11, Threads: 1}} {
+		if err := SetPasswordParams(params); err == nil {
+			t.Errorf("Expected %+v to be rejected", params)
+		}
+	}
+	if passwordParams != DefaultPasswordParams {
+		t.Errorf("Expected rejected params to be ignored, got %+v", passwordParams)
+	}
+}
+
+func TestVerifyPassword_ImportedFormats(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to generate bcrypt hash: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"bcrypt", string(bcryptHash)},
+		// PHP's password_hash writes $2y$
+		{"bcrypt 2y", "$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a"},
+		{"scrypt", "$scrypt$ln=10,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$BVMRKqdiVYikKAaPR1wucsKUKvw4TuPLkdEYtoSHas4"},
+		{"pbkdf2-sha256", "$pbkdf2-sha256$1000$....................AQ$av6WDQbvUrgDdoXe0aCTfZM//8p.XtAATx1KGtneIFI"},
+	}
+	for _, tt := range tests {
+		password := "password"
+		if tt.name == "bcrypt 2y" {
+			password = "rasmuslerdorf"
+		}
+
+		if err := CheckPasswordHash(tt.hash); err != nil {
+			t.Errorf("%s: expected the hash to be readable, got %v", tt.name, err)
+		}
+		if valid, err := VerifyPassword(password, tt.hash); err != nil || !valid {
+			t.Errorf("%s: expected the password to verify, got %v, %v", tt.name, valid, err)
+		}
+		if valid, err := VerifyPassword("wrongpassword", tt.hash); err != nil || valid {
+			t.Errorf("%s: expected a wrong password to fail, got %v, %v", tt.name, valid, err)
+		}
+		if !PasswordNeedsRehash(tt.hash) {
+			t.Errorf("%s: expected the hash to need rehashing", tt.name)
+		}
+	}
+
+	for _, invalid := range []string{"$md5$abc", "$2b$10$short", "$scrypt$ln=99,r=8,p=1$c2FsdA$aGFzaA", "$pbkdf2-sha256$0$c2FsdA$aGFzaA", "$pbkdf2-sha256$1000$c2FsdA"} {
+		if err := CheckPasswordHash(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+		if PasswordNeedsRehash(invalid) {
+			t.Errorf("Expected %q not to be rehashed", invalid)
+		}
+	}
+}
+
+func TestCheckPasswordHash_CostBounds(t *testing.T) {
+	argonHash, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	for _, costly := range []string{
+		strings.Replace(argonHash, "m=65536,", "m=4194304,", 1),
+		strings.Replace(argonHash, "t=1,", "t=100,", 1),
+		"$2y$17$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a",
+		"$scrypt$ln=22,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$BVMRKqdiVYikKAaPR1wucsKUKvw4TuPLkdEYtoSHas4",
+		"$scrypt$ln=16,r=8,p=64$c2FsdHNhbHRzYWx0c2FsdA$BVMRKqdiVYikKAaPR1wucsKUKvw4TuPLkdEYtoSHas4",
+		"$pbkdf2-sha256$10000001$....................AQ$av6WDQbvUrgDdoXe0aCTfZM//8p.XtAATx1KGtneIFI",
+	} {
+		if err := CheckPasswordHash(costly); err == nil {
+			t.Errorf("Expected %q to be rejected", costly)
+		}
+		if _, err := VerifyPassword("password", costly); err == nil {
+			t.Errorf("Expected %q not to be verified", costly)
+		}
+	}
+}
+
+// plainVerifier accepts the password after its prefix, for testing
+type plainVerifier struct{}
+
+func (plainVerifier) Check(encodedHash string) error { return nil }
+
+func (plainVerifier) Verify(password, encodedHash string) (bool, error) {
+	return encodedHash == "$plain$"+password, nil
+}
+
+func TestRegisterPasswordVerifier(t *testing.T) {
+	if _, err := VerifyPassword("password", "$plain$password"); err == nil {
+		t.Fatal("Expected an unknown format to be rejected")
+	}
+
+	RegisterPasswordVerifier("plain", plainVerifier{})
+	t.Cleanup(func() { delete(passwordVerifiers, "plain") })
+
+	if valid, err := VerifyPassword("password", "$plain$password"); err != nil || !valid {
+		t.Errorf("Expected the registered format to verify, got %v, %v", valid, err)
+	}
+	if !PasswordNeedsRehash("$plain$password") {
+		t.Error("Expected the registered format to need rehashing")
+	}
+}
+
+// writePepperFile writes a pepper file with a secret for each version
+func writePepperFile(t *testing.T, versions ...int) string {
+	t.Helper()
+	content := "# version:secret\n\n"
+	for _, version := range versions {
+		secret := strings.Repeat(string(rune('a'+version)), 32)
+		content += fmt.Sprintf("%d:%s\n", version, base64.StdEncoding.EncodeToString([]byte(secret)))
+	}
+	path := filepath.Join(t.TempDir(), "peppers")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write pepper file: %v", err)
+	}
+	return path
+}
+
+// usePasswordPeppers loads peppers for the rest of the test
+func usePasswordPeppers(t *testing.T, path string, active int) {
+	t.Helper()
+	peppers, err := LoadPasswordPeppers(path, active)
+	if err != nil {
+		t.Fatalf("Failed to load peppers: %v", err)
+	}
+	previous := passwordPeppers
+	SetPasswordPeppers(peppers)
+	t.Cleanup(func() { passwordPeppers = previous })
+}
+
+func TestHashPassword_Pepper(t *testing.T) {
+	usePasswordParams(t, PasswordParams{Memory: 1024, Time: 1, Threads: 1})
+	unpeppered, _ := HashPassword("password123")
+
+	path := writePepperFile(t, 1, 2)
+	usePasswordPeppers(t, path, 1)
+
+	hash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !strings.Contains(hash, "$m=1024,t=1,p=1,pepper=1$") {
+		t.Errorf("Expected the pepper version among the parameters, got %s", hash)
+	}
+	if valid, err := VerifyPassword("password123", hash); err != nil || !valid {
+		t.Errorf("Expected the peppered hash to verify, got %v, %v", valid, err)
+	}
+	if valid, err := VerifyPassword("wrongpassword", hash); err != nil || valid {
+		t.Errorf("Expected a wrong password to fail, got %v, %v", valid, err)
+	}
+	if PasswordNeedsRehash(hash) {
+		t.Error("Expected a hash with the active pepper to be kept")
+	}
+
+	// Hashes from before peppering still verify and are moved to the pepper
+	if valid, err := VerifyPassword("password123", unpeppered); err != nil || !valid {
+		t.Errorf("Expected the unpeppered hash to verify, got %v, %v", valid, err)
+	}
+	if !PasswordNeedsRehash(unpeppered) {
+		t.Error("Expected an unpeppered hash to need a rehash")
+	}
+
+	// Rotating keeps the old version working until its hashes are rehashed
+	usePasswordPeppers(t, path, 0)
+	if valid, err := VerifyPassword("password123", hash); err != nil || !valid {
+		t.Errorf("Expected the hash with the old pepper to verify, got %v, %v", valid, err)
+	}
+	if !PasswordNeedsRehash(hash) {
+		t.Error("Expected a hash with an old pepper to need a rehash")
+	}
+	rotated, _ := HashPassword("password123")
+	if !strings.Contains(rotated, ",pepper=2$") {
+		t.Errorf("Expected the highest version to be active, got %s", rotated)
+	}
+
+	// The same password under another pepper gives an unrelated hash
+	tampered := strings.Replace(hash, "pepper=1", "pepper=2", 1)
+	if valid, _ := VerifyPassword("password123", tampered); valid {
+		t.Error("Expected the hash not to verify with another pepper")
+	}
+
+	// Hashes with a pepper that was dropped cannot be verified
+	usePasswordPeppers(t, writePepperFile(t, 2), 0)
+	if _, err := VerifyPassword("password123", hash); err == nil {
+		t.Error("Expected an unknown pepper version to be an error")
+	}
+	if err := CheckPasswordHash(hash); err == nil || PasswordNeedsRehash(hash) {
+		t.Error("Expected an unknown pepper version to be unreadable")
+	}
+}
+
+func TestLoadPasswordPeppers_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	secret := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 32)))
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+
+	for name, content := range map[string]string{
+		"empty":     "# nothing\n",
+		"short":     "1:" + short + "\n",
+		"duplicate": "1:" + secret + "\n1:" + secret + "\n",
+		"version":   "v1:" + secret + "\n",
+		"base64":    "1:not base64!\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := LoadPasswordPeppers(path, 0); err == nil {
+			t.Errorf("%s: expected the pepper file to be rejected", name)
+		}
+	}
+
+	if _, err := LoadPasswordPeppers(writePepperFile(t, 1), 3); err == nil {
+		t.Error("Expected a missing active version to be rejected")
+	}
+}
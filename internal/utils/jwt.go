@@ -5,45 +5,117 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type JWTManager struct {
-	secret          string
+	keyring         *Keyring
 	accessTokenExp  time.Duration
 	refreshTokenExp time.Duration
+	// untypedUntil is when the last access token issued before the manager
+	// was created, possibly without a typ header, expires
+	untypedUntil time.Time
 }
 
+// Claims are carried by access tokens. Roles and Permissions are global;
+// OrgRoles and OrgPermissions only hold within the organization OrgID, and
+// must never be taken for global grants.
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
+	UserID         string   `json:"user_id"`
+	Username       string   `json:"username"`
+	SessionID      string   `json:"sid,omitempty"`
+	OrgID          string   `json:"org_id,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	Permissions    []string `json:"permissions,omitempty"`
+	OrgRoles       []string `json:"org_roles,omitempty"`
+	OrgPermissions []string `json:"org_permissions,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// AccessTokenType is the typ header of access tokens (RFC 9068). Every
+// token signed with the published keys says what it is, and services
+// verifying access tokens with the JWKS have to require this type; MFA
+// challenges are signed with the same keys.
+const AccessTokenType = "at+jwt"
+
+// MFAChallengeType is the typ header of MFA challenge tokens
+const MFAChallengeType = "mfa-challenge+jwt"
+
+// MFAChallengeAudience marks MFA challenge tokens. They are signed like
+// access tokens but are never accepted as one.
+const MFAChallengeAudience = "mfa-challenge"
+
+// MFAChallengeClaims are carried by the token Login hands out in place of
+// access and refresh tokens when the user has to complete a second factor
+type MFAChallengeClaims struct {
+	UserID      string `json:"user_id"`
+	DeviceLabel string `json:"device_label,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// AccessTokenSubject describes who an access token is issued to. SessionID
+// is the refresh token family the token belongs to and OrgID the
+// organization the session is working in, if any; Roles and Permissions are
+// what the user was granted globally when the token was issued, OrgRoles and
+// OrgPermissions what they were granted within OrgID.
+type AccessTokenSubject struct {
+	UserID         string
+	Username       string
+	SessionID      string
+	OrgID          string
+	Roles          []string
+	Permissions    []string
+	OrgRoles       []string
+	OrgPermissions []string
+}
+
+// NewJWTManager creates a manager signing HS256 tokens with a shared secret
 func NewJWTManager(secret string, accessTokenExp, refreshTokenExp time.Duration) *JWTManager {
+	return NewJWTManagerWithKey(NewHMACSigningKey("", []byte(secret)), accessTokenExp, refreshTokenExp)
+}
+
+// NewJWTManagerWithKey creates a manager signing tokens with the given key
+func NewJWTManagerWithKey(signingKey *SigningKey, accessTokenExp, refreshTokenExp time.Duration) *JWTManager {
+	return NewJWTManagerWithKeyring(NewKeyring(signingKey), accessTokenExp, refreshTokenExp)
+}
+
+// NewJWTManagerWithKeyring creates a manager signing with the active key of
+// keyring and verifying with whichever key a token's kid names. The keyring
+// may be updated while the manager is in use.
+func NewJWTManagerWithKeyring(keyring *Keyring, accessTokenExp, refreshTokenExp time.Duration) *JWTManager {
 	return &JWTManager{
-		secret:          secret,
+		keyring:         keyring,
 		accessTokenExp:  accessTokenExp,
 		refreshTokenExp: refreshTokenExp,
+		untypedUntil:    time.Now().Add(accessTokenExp),
 	}
 }
 
-func (j *JWTManager) GenerateAccessToken(userID, username string) (string, time.Time, error) {
+// GenerateAccessToken issues an access token for subject
+func (j *JWTManager) GenerateAccessToken(subject AccessTokenSubject) (string, time.Time, error) {
 	expirationTime := time.Now().Add(j.accessTokenExp)
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:         subject.UserID,
+		Username:       subject.Username,
+		SessionID:      subject.SessionID,
+		OrgID:          subject.OrgID,
+		Roles:          subject.Roles,
+		Permissions:    subject.Permissions,
+		OrgRoles:       subject.OrgRoles,
+		OrgPermissions: subject.OrgPermissions,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// The jti lets a single token be revoked before it expires
+			ID:        GenerateUUIDv7(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   userID,
+			Subject:   subject.UserID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(j.secret))
+	tokenString, err := j.sign(AccessTokenType, claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -51,18 +123,19 @@ func (j *JWTManager) GenerateAccessToken(userID, username string) (string, time.
 	return tokenString, expirationTime, nil
 }
 
+// ValidateAccessToken validates an access token. Only tokens whose typ
+// header is AccessTokenType are accepted, so no other kind of token signed
+// with the same keys can pass for one, apart from untyped legacy tokens.
 func (j *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(j.secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.verificationKey)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if typ, _ := token.Header["typ"].(string); typ != AccessTokenType && !j.untypedAccessToken(token) {
+		return nil, fmt.Errorf("invalid token type")
+	}
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 		return claims, nil
 	}
@@ -70,6 +143,112 @@ func (j *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// GenerateMFAChallenge issues a challenge token for a user who passed the
+// password check but still has to present a second factor
+func (j *JWTManager) GenerateMFAChallenge(userID, deviceLabel string, exp time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expirationTime := now.Add(exp)
+	claims := &MFAChallengeClaims{
+		UserID:      userID,
+		DeviceLabel: deviceLabel,
+		RegisteredClaims: jwt.RegisteredClaims{
+			// The jti lets attempts be counted per challenge
+			ID:        GenerateUUIDv7(),
+			Audience:  jwt.ClaimStrings{MFAChallengeAudience},
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   userID,
+		},
+	}
+
+	tokenString, err := j.sign(MFAChallengeType, claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// ValidateMFAChallenge validates a token issued by GenerateMFAChallenge.
+// Access tokens are rejected.
+func (j *JWTManager) ValidateMFAChallenge(tokenString string) (*MFAChallengeClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &MFAChallengeClaims{}, j.verificationKey, jwt.WithAudience(MFAChallengeAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if typ, _ := token.Header["typ"].(string); typ != MFAChallengeType {
+		return nil, fmt.Errorf("invalid token type")
+	}
+	if claims, ok := token.Claims.(*MFAChallengeClaims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, fmt.Errorf("invalid token")
+}
+
+// untypedAccessToken reports whether the token is an access token issued
+// before key IDs and typ headers, with neither a kid nor a typ other than the
+// default "JWT". Such tokens are accepted until they have all expired, one
+// access token lifetime after startup. MFA challenges of the time are told
+// apart by their audience.
+func (j *JWTManager) untypedAccessToken(token *jwt.Token) bool {
+	if _, ok := token.Header["kid"]; ok || time.Now().After(j.untypedUntil) {
+		return false
+	}
+	if typ, _ := token.Header["typ"].(string); typ != "" && typ != "JWT" {
+		return false
+	}
+	claims, ok := token.Claims.(*Claims)
+	return ok && !slices.Contains(claims.Audience, MFAChallengeAudience)
+}
+
+// sign signs claims with the active key, naming the key in the kid header
+// and the kind of token in the typ header
+func (j *JWTManager) sign(typ string, claims jwt.Claims) (string, error) {
+	signingKey := j.keyring.Active()
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+	token.Header["typ"] = typ
+	return token.SignedString(signingKey.signKey)
+}
+
+// verificationKey selects the key a token is verified with. Only the
+// algorithm of the selected key is accepted, which rules out algorithm
+// confusion such as an HS256 token "signed" with a public key.
+func (j *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	// Tokens issued before key IDs were introduced carry no kid
+	signingKey := j.keyring.Active()
+	if kid, ok := token.Header["kid"].(string); ok {
+		if signingKey, ok = j.keyring.Lookup(kid); !ok {
+			return nil, fmt.Errorf("unknown signing key: %s", kid)
+		}
+	}
+	if token.Method.Alg() != signingKey.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return signingKey.verifyKey, nil
+}
+
+// JWKS returns the public keys tokens can be verified with, including keys
+// that no longer sign but may still have unexpired tokens. Shared secrets are
+// never included.
+func (j *JWTManager) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range j.keyring.Keys() {
+		if jwk, ok := key.PublicJWK(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// GetAccessTokenExpiration returns the lifetime of access tokens, which is
+// also how long a retired signing key must remain verifiable
+func (j *JWTManager) GetAccessTokenExpiration() time.Duration {
+	return j.accessTokenExp
+}
+
 func (j *JWTManager) GetRefreshTokenExpiration() time.Duration {
 	return j.refreshTokenExp
 }
@@ -83,8 +262,10 @@ func GenerateRefreshToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-// HashRefreshToken hashes a refresh token for storage
-func HashRefreshToken(token string) string {
-	hash := sha256.Sum256([]byte(token))
+// HashSecret returns the hex SHA-256 of a short-lived or single-use secret,
+// such as a WebAuthn challenge or MFA recovery code, to store and look it up
+// by. Tokens are hashed with HashToken instead.
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
 	return hex.EncodeToString(hash[:])
 }
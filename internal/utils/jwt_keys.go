@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKey is a key access tokens are signed and verified with. The ID is
+// published as the "kid" header so verifiers can pick the right key.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSMaxAge is how long verifiers may cache the published JWKS
+const JWKSMaxAge = 5 * time.Minute
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACSigningKey creates a shared secret key. When id is empty it is
+// derived from the secret.
+func NewHMACSigningKey(id string, secret []byte) *SigningKey {
+	if id == "" {
+		sum := sha256.Sum256(append([]byte("kid:"), secret...))
+		id = hex.EncodeToString(sum[:8])
+	}
+	return &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// NewSigningKey wraps an asymmetric private key for the given algorithm. When
+// id is empty the RFC 7638 thumbprint of the public key is used.
+func NewSigningKey(id, algorithm string, privateKey crypto.PrivateKey) (*SigningKey, error) {
+	var (
+		method    jwt.SigningMethod
+		verifyKey interface{}
+	)
+
+	switch algorithm {
+	case AlgorithmRS256:
+		key, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an RSA private key", algorithm)
+		}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys must be at least 2048 bits")
+		}
+		method, verifyKey = jwt.SigningMethodRS256, &key.PublicKey
+	case AlgorithmES256:
+		key, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s requires a P-256 ECDSA private key", algorithm)
+		}
+		method, verifyKey = jwt.SigningMethodES256, &key.PublicKey
+	case AlgorithmEdDSA:
+		key, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an Ed25519 private key", algorithm)
+		}
+		method, verifyKey = jwt.SigningMethodEdDSA, key.Public()
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	key := &SigningKey{
+		ID:        id,
+		Method:    method,
+		signKey:   privateKey,
+		verifyKey: verifyKey,
+	}
+
+	if key.ID == "" {
+		thumbprint, err := key.thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+
+	return key, nil
+}
+
+// LoadSigningKey reads a PEM encoded private key from path
+func LoadSigningKey(algorithm, path, id string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key: %w", err)
+	}
+
+	privateKey, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(id, algorithm, privateKey)
+}
+
+// GenerateSigningKey creates a new random key for algorithm. When id is empty
+// a random one is assigned.
+func GenerateSigningKey(id, algorithm string) (*SigningKey, error) {
+	if id == "" {
+		id = GenerateUUIDv7()
+	}
+
+	var (
+		privateKey crypto.PrivateKey
+		err        error
+	)
+	switch algorithm {
+	case AlgorithmHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACSigningKey(id, secret), nil
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error generating signing key: %w", err)
+	}
+
+	return NewSigningKey(id, algorithm, privateKey)
+}
+
+// MarshalPrivate serialises the secret part of the key: the raw secret for
+// HMAC keys and a PKCS#8 PEM block otherwise
+func (k *SigningKey) MarshalPrivate() ([]byte, error) {
+	if !k.IsAsymmetric() {
+		return k.signKey.([]byte), nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParseSigningKey restores a key serialised with MarshalPrivate
+func ParseSigningKey(id, algorithm string, material []byte) (*SigningKey, error) {
+	if algorithm == AlgorithmHS256 {
+		return NewHMACSigningKey(id, material), nil
+	}
+
+	privateKey, err := ParsePrivateKeyPEM(material)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(id, algorithm, privateKey)
+}
+
+// ParsePrivateKeyPEM parses a PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) private key
+func ParsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in signing key")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+}
+
+// IsAsymmetric reports whether the key can be published for verification
+func (k *SigningKey) IsAsymmetric() bool {
+	_, isHMAC := k.Method.(*jwt.SigningMethodHMAC)
+	return !isHMAC
+}
+
+// PublicJWK returns the public half of the key. Shared secrets are never
+// published, so ok is false for HMAC keys.
+func (k *SigningKey) PublicJWK() (jwk JWK, ok bool) {
+	if !k.IsAsymmetric() {
+		return JWK{}, false
+	}
+
+	jwk = publicJWK(k.verifyKey)
+	jwk.Use = "sig"
+	jwk.Alg = k.Method.Alg()
+	jwk.Kid = k.ID
+	return jwk, true
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of the public key
+func (k *SigningKey) thumbprint() (string, error) {
+	jwk := publicJWK(k.verifyKey)
+
+	// Only the required members, in lexicographic order
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func publicJWK(publicKey interface{}) JWK {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}
+	}
+	return JWK{}
+}
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func generatePrivateKey(t *testing.T, algorithm string) crypto.PrivateKey {
+	var (
+		key crypto.PrivateKey
+		err error
+	)
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("Failed to generate %s key: %v", algorithm, err)
+	}
+	return key
+}
+
+// writePEM stores the key as PKCS#8, the format `openssl genpkey` produces
+func writePEM(t *testing.T, key crypto.PrivateKey) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+func TestJWTManager_AsymmetricAlgorithms(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			// Setup
+			path := writePEM(t, generatePrivateKey(t, algorithm))
+			signingKey, err := LoadSigningKey(algorithm, path, "")
+			if err != nil {
+				t.Fatalf("Failed to load signing key: %v", err)
+			}
+			jwtManager := NewJWTManagerWithKey(signingKey, 15*time.Minute, 7*24*time.Hour)
+
+			// Execute
+			tokenString, _, err := jwtManager.GenerateAccessToken(AccessTokenSubject{
+				UserID:    "user-1",
+				Username:  "testuser",
+				SessionID: "session-1",
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			claims, err := jwtManager.ValidateAccessToken(tokenString)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected token to validate, got %v", err)
+			}
+
+			if claims.UserID != "user-1" || claims.SessionID != "session-1" {
+				t.Errorf("Unexpected claims: %+v", claims)
+			}
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+			if err != nil {
+				t.Fatalf("Failed to parse token: %v", err)
+			}
+			if token.Header["alg"] != algorithm {
+				t.Errorf("Expected alg %s, got %v", algorithm, token.Header["alg"])
+			}
+			if token.Header["kid"] != signingKey.ID || signingKey.ID == "" {
+				t.Errorf("Expected kid %q, got %v", signingKey.ID, token.Header["kid"])
+			}
+
+			jwks := jwtManager.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("Expected 1 published key, got %d", len(jwks.Keys))
+			}
+			if jwks.Keys[0].Kid != signingKey.ID || jwks.Keys[0].Alg != algorithm || jwks.Keys[0].Use != "sig" {
+				t.Errorf("Unexpected JWK: %+v", jwks.Keys[0])
+			}
+		})
+	}
+}
+
+func TestJWTManager_HMACNotPublished(t *testing.T) {
+	jwtManager := NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	if keys := jwtManager.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("Expected shared secret not to be published, got %+v", keys)
+	}
+}
+
+func TestJWTManager_RejectsAlgorithmConfusion(t *testing.T) {
+	// Setup
+	privateKey := generatePrivateKey(t, AlgorithmRS256).(*rsa.PrivateKey)
+	signingKey, err := NewSigningKey("", AlgorithmRS256, privateKey)
+	if err != nil {
+		t.Fatalf("Failed to create signing key: %v", err)
+	}
+	jwtManager := NewJWTManagerWithKey(signingKey, 15*time.Minute, 7*24*time.Hour)
+
+	// An attacker signs an HS256 token using the published public key as secret
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	forged.Header["kid"] = signingKey.ID
+	tokenString, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatalf("Failed to sign forged token: %v", err)
+	}
+
+	// Execute
+	_, err = jwtManager.ValidateAccessToken(tokenString)
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected forged HS256 token to be rejected, got nil")
+	}
+}
+
+func TestJWTManager_MFAChallengeIsNotAnAccessToken(t *testing.T) {
+	// Setup
+	jwtManager := NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	challenge, _, err := jwtManager.GenerateMFAChallenge("user-1", "My phone", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate challenge: %v", err)
+	}
+	accessToken, _, err := jwtManager.GenerateAccessToken(AccessTokenSubject{UserID: "user-1", Username: "testuser"})
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+
+	// Assert
+	claims, err := jwtManager.ValidateMFAChallenge(challenge)
+	if err != nil {
+		t.Fatalf("Expected the challenge to validate, got %v", err)
+	}
+	if claims.UserID != "user-1" || claims.DeviceLabel != "My phone" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if _, err := jwtManager.ValidateAccessToken(challenge); err == nil {
+		t.Error("Expected a challenge to be rejected as an access token")
+	}
+	if _, err := jwtManager.ValidateMFAChallenge(accessToken); err == nil {
+		t.Error("Expected an access token to be rejected as a challenge")
+	}
+}
+
+func TestJWTManager_AccessTokenRequiresType(t *testing.T) {
+	// Setup
+	jwtManager := NewJWTManager("test-secret", 15*time.Minute, 7*24*time.Hour)
+
+	sign := func(header map[string]interface{}, audience ...string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+			UserID: "user-1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  audience,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		})
+		for name, value := range header {
+			token.Header[name] = value
+		}
+		tokenString, err := token.SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return tokenString
+	}
+
+	// A token signed with the right key but typed as another kind of token,
+	// and an untyped one naming a key as every token since key IDs does
+	for _, tokenString := range []string{
+		sign(map[string]interface{}{"kid": "", "typ": MFAChallengeType}),
+		sign(map[string]interface{}{"kid": ""}),
+	} {
+		// Execute
+		_, err := jwtManager.ValidateAccessToken(tokenString)
+
+		// Assert
+		if err == nil {
+			t.Error("Expected a token without the access token type to be rejected, got nil")
+		}
+	}
+
+	// Tokens from before key IDs are accepted until they have expired,
+	// unless they are MFA challenges
+	legacy := sign(nil)
+	if _, err := jwtManager.ValidateAccessToken(legacy); err != nil {
+		t.Errorf("Expected a token from before key IDs to be accepted, got %v", err)
+	}
+	if _, err := jwtManager.ValidateAccessToken(sign(nil, MFAChallengeAudience)); err == nil {
+		t.Error("Expected a challenge from before key IDs to be rejected, got nil")
+	}
+
+	jwtManager.untypedUntil = time.Now().Add(-time.Second)
+	if _, err := jwtManager.ValidateAccessToken(legacy); err == nil {
+		t.Error("Expected a token from before key IDs to be rejected once they have expired, got nil")
+	}
+}
+
+func TestNewSigningKey_MismatchedKeyType(t *testing.T) {
+	_, err := NewSigningKey("", AlgorithmES256, generatePrivateKey(t, AlgorithmEdDSA))
+
+	if err == nil {
+		t.Fatal("Expected error for Ed25519 key used with ES256, got nil")
+	}
+}
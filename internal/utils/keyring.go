@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Keyring holds the key new tokens are signed with together with older keys
+// that are still accepted for verification. Keys are selected by kid.
+type Keyring struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+
+	reloadMu       sync.Mutex
+	reload         func() error
+	reloadInterval time.Duration
+	lastReload     time.Time
+}
+
+// NewKeyring creates a keyring signing with active and additionally
+// accepting tokens signed by any of the verification keys
+func NewKeyring(active *SigningKey, verificationKeys ...*SigningKey) *Keyring {
+	k := &Keyring{}
+	k.Replace(active, verificationKeys)
+	return k
+}
+
+// Replace atomically swaps the contents of the keyring
+func (k *Keyring) Replace(active *SigningKey, verificationKeys []*SigningKey) {
+	keys := make(map[string]*SigningKey, len(verificationKeys)+1)
+	for _, key := range verificationKeys {
+		keys[key.ID] = key
+	}
+	keys[active.ID] = active
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active = active
+	k.keys = keys
+}
+
+// Active returns the key new tokens are signed with
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// SetReloader installs fn to be called when a token names an unknown kid, so
+// a key rotated in by another replica is picked up without waiting for the
+// next scheduled sync. Since anyone can send a token with a made up kid,
+// reloads happen at most once per minInterval whatever the kid, and lookups
+// in between, or while a reload is running, fail without waiting.
+func (k *Keyring) SetReloader(fn func() error, minInterval time.Duration) {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+	k.reload = fn
+	k.reloadInterval = minInterval
+}
+
+// Lookup returns the key with the given kid
+func (k *Keyring) Lookup(kid string) (*SigningKey, bool) {
+	if key, ok := k.lookup(kid); ok || !k.tryReload() {
+		return key, ok
+	}
+	return k.lookup(kid)
+}
+
+func (k *Keyring) lookup(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+func (k *Keyring) tryReload() bool {
+	if !k.reloadMu.TryLock() {
+		return false
+	}
+	defer k.reloadMu.Unlock()
+
+	if k.reload == nil || time.Since(k.lastReload) < k.reloadInterval {
+		return false
+	}
+	k.lastReload = time.Now()
+	return k.reload() == nil
+}
+
+// Keys returns every key in the keyring ordered by kid
+func (k *Keyring) Keys() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
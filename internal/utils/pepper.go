@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+// PasswordPeppers are the server side secrets passwords are peppered with
+// before hashing, by version. Unlike salts they are kept out of the
+// database, so a dump of the users table alone is not enough to crack its
+// hashes. Hashes record the version they were peppered with; every version
+// still in use has to be kept so that its hashes keep verifying.
+type PasswordPeppers struct {
+	// Active is the version new hashes are peppered with
+	Active  int
+	secrets map[int][]byte
+}
+
+// LoadPasswordPeppers reads a pepper file. Each line holds a version and its
+// base64 encoded secret of at least 32 bytes, separated by a colon, as in
+// "2:q8t1...". Blank lines and lines starting with # are skipped. The highest
+// version is active unless active names another one.
+func LoadPasswordPeppers(path string, active int) (*PasswordPeppers, error) {
+	secrets, err := readSecretVersions(path)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := activeSecretVersion(secrets, active)
+	if !ok {
+		return nil, fmt.Errorf("active pepper version %d is not in %s", active, path)
+	}
+	return &PasswordPeppers{Active: version, secrets: secrets}, nil
+}
+
+// Versions returns the versions of the peppers in ascending order
+func (p *PasswordPeppers) Versions() []int {
+	return secretVersions(p.secrets)
+}
+
+// passwordPeppers are the peppers set with SetPasswordPeppers, nil if
+// passwords are not peppered
+var passwordPeppers *PasswordPeppers
+
+// SetPasswordPeppers makes HashPassword pepper new hashes with the active
+// pepper and VerifyPassword accept hashes peppered with any of them. nil
+// stops peppering new hashes. It is meant to be called once at startup,
+// before any password is hashed.
+func SetPasswordPeppers(peppers *PasswordPeppers) {
+	passwordPeppers = peppers
+}
+
+// activePepperVersion returns the version new hashes are peppered with, 0
+// if they are not
+func activePepperVersion() int {
+	if passwordPeppers == nil {
+		return 0
+	}
+	return passwordPeppers.Active
+}
+
+// pepperPassword returns what is hashed for the password: the password
+// itself for version 0, otherwise its HMAC-SHA256 under the pepper of the
+// version
+func pepperPassword(password string, version int) ([]byte, error) {
+	if version == 0 {
+		return []byte(password), nil
+	}
+	var secret []byte
+	if passwordPeppers != nil {
+		secret = passwordPeppers.secrets[version]
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("unknown pepper version %d", version)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// minSecretLen is the least length of a versioned secret in bytes
+const minSecretLen = 32
+
+// readSecretVersions reads a file of versioned secrets, as used for password
+// peppers and token hash keys. Each line holds a version and its base64
+// encoded secret of at least 32 bytes, separated by a colon, as in
+// "2:q8t1...". Blank lines and lines starting with # are skipped.
+func readSecretVersions(path string) (map[int][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	secrets := make(map[int][]byte)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		versionStr, encoded, ok := strings.Cut(line, ":")
+		version, err := strconv.Atoi(strings.TrimSpace(versionStr))
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("%s:%d: expected a positive version and a secret separated by a colon", path, lineNo)
+		}
+		encoded = strings.TrimSpace(encoded)
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			secret, err = base64.RawStdEncoding.DecodeString(encoded)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: secret is not base64", path, lineNo)
+		}
+		if len(secret) < minSecretLen {
+			return nil, fmt.Errorf("%s:%d: secret must be at least %d bytes", path, lineNo, minSecretLen)
+		}
+		if _, ok := secrets[version]; ok {
+			return nil, fmt.Errorf("%s:%d: version %d appears twice", path, lineNo, version)
+		}
+		secrets[version] = secret
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("%s holds no secrets", path)
+	}
+	return secrets, nil
+}
+
+// activeSecretVersion returns active if secrets has it, or the highest
+// version if active is 0, and false otherwise
+func activeSecretVersion(secrets map[int][]byte, active int) (int, bool) {
+	if active == 0 {
+		versions := secretVersions(secrets)
+		return versions[len(versions)-1], true
+	}
+	_, ok := secrets[active]
+	return active, ok
+}
+
+// secretVersions returns the versions of the secrets in ascending order
+func secretVersions(secrets map[int][]byte) []int {
+	versions := make([]int, 0, len(secrets))
+	for version := range secrets {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TokenHashKeys are the server side keys refresh, password reset and email
+// verification tokens are hashed with, by version. With a bare hash anyone
+// who can read the database can check guessed tokens offline; keyed hashes
+// also need the key. Hashes record the version they were made with, and
+// every version still in use has to be kept so that its tokens keep
+// working.
+type TokenHashKeys struct {
+	// Active is the version new hashes are made with
+	Active int
+	// AcceptLegacy makes lookups also match the bare SHA-256 of tokens stored
+	// before keys were set. Anyone who can write to the database can store
+	// such hashes for tokens of their choosing, so it is meant to be turned
+	// off once those tokens have expired.
+	AcceptLegacy bool
+	keys         map[int][]byte
+}
+
+// LoadTokenHashKeys reads a key file in the format of LoadPasswordPeppers.
+// The highest version is active unless active names another one.
+func LoadTokenHashKeys(path string, active int) (*TokenHashKeys, error) {
+	keys, err := readSecretVersions(path)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := activeSecretVersion(keys, active)
+	if !ok {
+		return nil, fmt.Errorf("active token hash key version %d is not in %s", active, path)
+	}
+	return &TokenHashKeys{Active: version, keys: keys}, nil
+}
+
+// Versions returns the versions of the keys in ascending order
+func (k *TokenHashKeys) Versions() []int {
+	return secretVersions(k.keys)
+}
+
+// tokenHashKeys are the keys set with SetTokenHashKeys, nil if token hashes
+// are not keyed
+var tokenHashKeys *TokenHashKeys
+
+// SetTokenHashKeys makes HashToken key new hashes with the active key and
+// TokenHashes include the hashes under each of them. nil goes back to bare
+// SHA-256 hashes. It is meant to be called once at startup, before any token
+// is hashed.
+func SetTokenHashKeys(keys *TokenHashKeys) {
+	tokenHashKeys = keys
+}
+
+// HashToken hashes a token for storage: "v<version>$" followed by the hex
+// HMAC-SHA256 of the token under the active key, or the hex SHA-256 of the
+// token if no keys are set
+func HashToken(token string) string {
+	if tokenHashKeys == nil {
+		return legacyTokenHash(token)
+	}
+	return keyedTokenHash(token, tokenHashKeys.Active)
+}
+
+// TokenHashes returns every hash the token may be stored under: the one
+// HashToken gives first, then those under the other keys and, with
+// AcceptLegacy, the bare SHA-256 of tokens stored before keys were set.
+// Lookups match any of them, so tokens keep working while keys are rotated.
+func TokenHashes(token string) []string {
+	if tokenHashKeys == nil {
+		return []string{legacyTokenHash(token)}
+	}
+
+	hashes := []string{keyedTokenHash(token, tokenHashKeys.Active)}
+	for _, version := range tokenHashKeys.Versions() {
+		if version != tokenHashKeys.Active {
+			hashes = append(hashes, keyedTokenHash(token, version))
+		}
+	}
+	if tokenHashKeys.AcceptLegacy {
+		hashes = append(hashes, legacyTokenHash(token))
+	}
+	return hashes
+}
+
+// TokenHashNeedsRekey reports whether a stored token hash was not made with
+// the active key, so that it should be replaced by HashToken the next time
+// its token is presented
+func TokenHashNeedsRekey(hash string) bool {
+	return tokenHashVersion(hash) != activeTokenHashVersion()
+}
+
+// activeTokenHashVersion returns the version new hashes are made with, 0 if
+// they are not keyed
+func activeTokenHashVersion() int {
+	if tokenHashKeys == nil {
+		return 0
+	}
+	return tokenHashKeys.Active
+}
+
+// tokenHashVersion returns the key version of a stored hash, 0 for bare
+// SHA-256 hashes
+func tokenHashVersion(hash string) int {
+	versionStr, _, ok := strings.Cut(hash, "$")
+	if !ok || !strings.HasPrefix(versionStr, "v") {
+		return 0
+	}
+	version, err := strconv.Atoi(versionStr[1:])
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+func keyedTokenHash(token string, version int) string {
+	mac := hmac.New(sha256.New, tokenHashKeys.keys[version])
+	mac.Write([]byte(token))
+	return "v" + strconv.Itoa(version) + "$" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func legacyTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
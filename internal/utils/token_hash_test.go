@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// useTokenHashKeys loads token hash keys for the rest of the test
+func useTokenHashKeys(t *testing.T, path string, active int) {
+	t.Helper()
+	keys, err := LoadTokenHashKeys(path, active)
+	if err != nil {
+		t.Fatalf("Failed to load token hash keys: %v", err)
+	}
+	keys.AcceptLegacy = true
+	previous := tokenHashKeys
+	SetTokenHashKeys(keys)
+	t.Cleanup(func() { tokenHashKeys = previous })
+}
+
+func TestHashToken_Keys(t *testing.T) {
+	sum := sha256.Sum256([]byte("token"))
+	legacy := hex.EncodeToString(sum[:])
+	if hash := HashToken("token"); hash != legacy || TokenHashNeedsRekey(hash) {
+		t.Errorf("Expected a bare SHA-256 without keys, got %s", hash)
+	}
+
+	path := writePepperFile(t, 1, 2)
+	useTokenHashKeys(t, path, 1)
+	hash := HashToken("token")
+	if !strings.HasPrefix(hash, "v1$") || strings.Contains(hash, legacy) {
+		t.Errorf("Expected a keyed hash with its version, got %s", hash)
+	}
+	if TokenHashNeedsRekey(hash) || !TokenHashNeedsRekey(legacy) {
+		t.Error("Expected only hashes under other keys to need rekeying")
+	}
+
+	// Lookups try the active key first, then the others and the bare hash
+	useTokenHashKeys(t, path, 2)
+	hashes := TokenHashes("token")
+	if len(hashes) != 3 || hashes[0] != HashToken("token") || hashes[1] != hash || hashes[2] != legacy {
+		t.Errorf("Expected the hashes under versions 2 and 1 and the bare hash, got %v", hashes)
+	}
+	if !strings.HasPrefix(hashes[0], "v2$") || !TokenHashNeedsRekey(hash) {
+		t.Error("Expected hashes under version 1 to need rekeying once version 2 is active")
+	}
+
+	// The bare hash is no longer matched once legacy hashes are turned off
+	tokenHashKeys.AcceptLegacy = false
+	if hashes := TokenHashes("token"); len(hashes) != 2 || hashes[1] != hash {
+		t.Errorf("Expected only the keyed hashes, got %v", hashes)
+	}
+	tokenHashKeys.AcceptLegacy = true
+
+	// Another token never shares a hash
+	for _, other := range TokenHashes("other") {
+		for _, candidate := range hashes {
+			if other == candidate {
+				t.Fatalf("Expected distinct tokens to hash differently, both gave %s", other)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	// SHA-1 test vectors from RFC 6238 appendix B
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		counter := TOTPCounter(time.Unix(tt.unix, 0))
+		if got := hotp(secret, counter, 8); got != tt.code {
+			t.Errorf("At %d expected %s, got %s", tt.unix, tt.code, got)
+		}
+		if got := TOTPCode(secret, counter); got != tt.code[2:] {
+			t.Errorf("At %d expected 6 digit code %s, got %s", tt.unix, tt.code[2:], got)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+
+	now := time.Now()
+	current := TOTPCounter(now)
+
+	counter, ok := VerifyTOTP(secret, TOTPCode(secret, current), now, 1)
+	if !ok || counter != current {
+		t.Errorf("Expected the current code to match step %d, got %d, %v", current, counter, ok)
+	}
+	if counter, ok := VerifyTOTP(secret, TOTPCode(secret, current-1), now, 1); !ok || counter != current-1 {
+		t.Errorf("Expected the previous code to be accepted within the skew, got %d, %v", counter, ok)
+	}
+	if _, ok := VerifyTOTP(secret, TOTPCode(secret, current-2), now, 1); ok {
+		t.Error("Expected a code outside the skew to be rejected")
+	}
+	if _, ok := VerifyTOTP(secret, "12345", now, 1); ok {
+		t.Error("Expected a short code to be rejected")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	uri, err := url.Parse(TOTPProvisioningURI("Example Co", "john@example.com", secret))
+	if err != nil {
+		t.Fatalf("Invalid URI: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("Expected an otpauth://totp URI, got %s", uri)
+	}
+	if uri.Path != "/Example Co:john@example.com" {
+		t.Errorf("Expected the label to be issuer:account, got %q", uri.Path)
+	}
+	query := uri.Query()
+	if query.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("Expected the base32 secret without padding, got %s", query.Get("secret"))
+	}
+	if query.Get("issuer") != "Example Co" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("Unexpected parameters: %v", query)
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import "strings"
+
+// DescribeUserAgent turns a User-Agent header into a short human readable
+// device label such as "Chrome on macOS"
+func DescribeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := detect(userAgent, []uaPattern{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"PostmanRuntime/", "Postman"},
+		{"okhttp/", "Android app"},
+		{"CFNetwork/", "iOS app"},
+	})
+
+	os := detect(userAgent, []uaPattern{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
+
+type uaPattern struct {
+	token string
+	name  string
+}
+
+// detect returns the name of the first pattern found in userAgent. Order
+// matters: most browsers also advertise the engines they are compatible with.
+func detect(userAgent string, patterns []uaPattern) string {
+	for _, p := range patterns {
+		if strings.Contains(userAgent, p.token) {
+			return p.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// Attestation statement formats the relying party verifies
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// idFidoGenCeAAGUID is the certificate extension carrying the AAGUID of the
+// authenticator model in packed attestation certificates
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyAttestation checks the attestation statement of a new credential.
+// Packed statements signed by an attestation certificate are checked for
+// being well formed and correctly signed; the certificate is not chained to
+// a trust anchor, since the relying party does not restrict which
+// authenticator models may register.
+func verifyAttestation(format string, stmt map[interface{}]interface{}, authData []byte, parsed *AuthenticatorData, credentialKey *PublicKey, clientDataHash []byte) error {
+	switch format {
+	case FormatNone:
+		if len(stmt) != 0 {
+			return errors.New("none attestation must have an empty statement")
+		}
+		return nil
+	case FormatPacked:
+		return verifyPacked(stmt, authData, parsed, credentialKey, clientDataHash)
+	}
+	return fmt.Errorf("unsupported attestation format %q", format)
+}
+
+// verifyPacked verifies a packed attestation statement (WebAuthn §8.2)
+func verifyPacked(stmt map[interface{}]interface{}, authData []byte, parsed *AuthenticatorData, credentialKey *PublicKey, clientDataHash []byte) error {
+	alg, ok := stmt["alg"].(int64)
+	if !ok {
+		return errors.New("packed attestation has no algorithm")
+	}
+	sig, ok := stmt["sig"].([]byte)
+	if !ok {
+		return errors.New("packed attestation has no signature")
+	}
+	if _, ok := stmt["ecdaaKeyId"]; ok {
+		return errors.New("ECDAA attestation is not supported")
+	}
+
+	signed := append(append([]byte(nil), authData...), clientDataHash...)
+
+	x5c, ok := stmt["x5c"].([]interface{})
+	if !ok {
+		// Self attestation, signed with the credential key itself
+		if int(alg) != credentialKey.Algorithm {
+			return errors.New("self attestation algorithm does not match the credential key")
+		}
+		if err := credentialKey.Verify(signed, sig); err != nil {
+			return fmt.Errorf("invalid self attestation: %w", err)
+		}
+		return nil
+	}
+
+	if len(x5c) == 0 {
+		return errors.New("packed attestation has an empty certificate chain")
+	}
+	der, ok := x5c[0].([]byte)
+	if !ok {
+		return errors.New("invalid attestation certificate")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("invalid attestation certificate: %w", err)
+	}
+
+	if err := verifySignature(int(alg), cert.PublicKey, signed, sig); err != nil {
+		return fmt.Errorf("invalid packed attestation: %w", err)
+	}
+	return checkAttestationCertificate(cert, parsed.AAGUID)
+}
+
+// checkAttestationCertificate applies the packed attestation certificate
+// requirements (WebAuthn §8.2.1)
+func checkAttestationCertificate(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 {
+		return errors.New("attestation certificate must be version 3")
+	}
+	if cert.IsCA {
+		return errors.New("attestation certificate must not be a CA")
+	}
+
+	ou := cert.Subject.OrganizationalUnit
+	if len(ou) != 1 || ou[0] != "Authenticator Attestation" {
+		return errors.New("attestation certificate has the wrong subject OU")
+	}
+	if len(cert.Subject.Country) == 0 || len(cert.Subject.Organization) == 0 || cert.Subject.CommonName == "" {
+		return errors.New("attestation certificate subject is incomplete")
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		if ext.Critical {
+			return errors.New("AAGUID extension must not be critical")
+		}
+		var certAAGUID []byte
+		if _, err := asn1.Unmarshal(ext.Value, &certAAGUID); err != nil {
+			return fmt.Errorf("invalid AAGUID extension: %w", err)
+		}
+		if !bytes.Equal(certAAGUID, aaguid) {
+			return errors.New("attestation certificate AAGUID does not match the authenticator")
+		}
+	}
+	return nil
+}
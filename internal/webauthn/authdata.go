@@ -0,0 +1,85 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Authenticator data flags
+const (
+	FlagUserPresent    byte = 0x01
+	FlagUserVerified   byte = 0x04
+	FlagBackupEligible byte = 0x08
+	FlagBackedUp       byte = 0x10
+	FlagAttestedData   byte = 0x40
+	FlagExtensionData  byte = 0x80
+)
+
+// maxCredentialIDLength is the longest credential ID the specification
+// allows
+const maxCredentialIDLength = 1023
+
+// AuthenticatorData is the data an authenticator signs in both ceremonies
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Set when FlagAttestedData is, that is on registration
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// ParseAuthenticatorData decodes authenticator data. The credential public
+// key is kept in its COSE encoding.
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	data := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+
+	if data.Flags&FlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		data.AAGUID = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen > maxCredentialIDLength || idLen > len(rest) {
+			return nil, errors.New("invalid credential ID length")
+		}
+		data.CredentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		data.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if data.Flags&FlagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension data: %w", err)
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("authenticator data has %d trailing bytes", len(rest))
+	}
+	if data.Flags&FlagBackedUp != 0 && data.Flags&FlagBackupEligible == 0 {
+		return nil, errors.New("credential is backed up but not backup eligible")
+	}
+	return data, nil
+}
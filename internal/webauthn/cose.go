@@ -0,0 +1,147 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) the relying party accepts
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms lists the accepted algorithms in order of preference,
+// as offered to authenticators when registering
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9052, RFC 9053)
+const (
+	coseKeyType    = 1
+	coseKeyAlg     = 3
+	coseCurve      = -1
+	coseX          = -2
+	coseY          = -3
+	coseRSAN       = -1
+	coseRSAE       = -2
+	coseKtyOKP     = 1
+	coseKtyEC2     = 2
+	coseKtyRSA     = 3
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// minRSABits is the smallest RSA credential key accepted
+const minRSABits = 2048
+
+// PublicKey is a credential public key decoded from its COSE form
+type PublicKey struct {
+	Algorithm int
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as found in attested credential data and
+// stored for each credential
+func ParsePublicKey(cose []byte) (*PublicKey, error) {
+	m, err := decodeCBORMap(cose)
+	if err != nil {
+		return nil, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	return publicKeyFromMap(m)
+}
+
+func publicKeyFromMap(m map[interface{}]interface{}) (*PublicKey, error) {
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, ok := m[int64(coseKeyAlg)].(int64)
+	if !ok {
+		return nil, errors.New("COSE key has no algorithm")
+	}
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 COSE key")
+		}
+		uncompressed := append(append([]byte{4}, x...), y...)
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), uncompressed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid P-256 COSE key: %w", err)
+		}
+		return &PublicKey{Algorithm: AlgES256, Key: key}, nil
+
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 COSE key")
+		}
+		return &PublicKey{Algorithm: AlgEdDSA, Key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA COSE key")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSABits || key.E < 3 {
+			return nil, errors.New("RSA COSE key is too weak")
+		}
+		return &PublicKey{Algorithm: AlgRS256, Key: key}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported COSE key type %d with algorithm %d", kty, alg)
+}
+
+// Verify checks sig over data
+func (k *PublicKey) Verify(data, sig []byte) error {
+	return verifySignature(k.Algorithm, k.Key, data, sig)
+}
+
+// verifySignature checks a WebAuthn signature. ECDSA signatures are ASN.1
+// DER encoded, as authenticators produce them.
+func verifySignature(alg int, key crypto.PublicKey, data, sig []byte) error {
+	switch alg {
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm ES256")
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm EdDSA")
+		}
+		if !ed25519.Verify(pub, data, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm RS256")
+		}
+		digest := sha256.Sum256(data)
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %d", alg)
+}
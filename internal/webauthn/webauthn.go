@@ -0,0 +1,260 @@
+// Package webauthn verifies the WebAuthn ceremonies a relying party takes
+// part in: registering a new credential (a passkey or security key) and
+// authenticating with one. It covers what this service needs and no more:
+//
+//   - ES256, EdDSA and RS256 credential keys
+//   - "none" and "packed" attestation
+//   - sign counters, to notice cloned authenticators
+//
+// Challenges are generated with NewChallenge and must be stored by the
+// caller, which also stores the registered credentials:
+//
+//	rp, err := webauthn.NewRelyingParty("example.com", "Example", []string{"https://example.com"})
+//	cred, err := rp.VerifyRegistration(challenge, &webauthn.RegistrationResponse{...}, false)
+//	signCount, err := rp.VerifyAssertion(challenge, cred.PublicKey, cred.SignCount, &webauthn.AssertionResponse{...}, true)
+//
+// Binary values travel base64url encoded without padding between browsers
+// and the server; DecodeBase64URL decodes them.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChallengeSize is the number of random bytes in a challenge
+const ChallengeSize = 32
+
+// Client data types of the two ceremonies
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// ErrSignCountRegressed is returned when an authenticator reports a sign
+// count that is not above the stored one, which suggests the credential has
+// been cloned
+var ErrSignCountRegressed = errors.New("authenticator sign count did not increase")
+
+// RelyingParty verifies ceremonies for one relying party ID. Origins are
+// the exact origins (scheme, host and port) the browser may report.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+
+	idHash [32]byte
+}
+
+func NewRelyingParty(id, name string, origins []string) (*RelyingParty, error) {
+	if id == "" {
+		return nil, errors.New("relying party ID is required")
+	}
+	if len(origins) == 0 {
+		return nil, errors.New("at least one origin is required")
+	}
+	return &RelyingParty{
+		ID:      id,
+		Name:    name,
+		Origins: origins,
+		idHash:  sha256.Sum256([]byte(id)),
+	}, nil
+}
+
+// NewChallenge returns a random challenge
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// EncodeBase64URL encodes b the way browsers encode WebAuthn binary values
+func EncodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeBase64URL decodes a base64url value, with or without padding
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// ClientData is the collected client data the browser passes to the
+// authenticator (WebAuthn §5.8.1)
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// ParseClientData decodes clientDataJSON. Callers use the challenge to find
+// the ceremony it belongs to before verifying the response.
+func ParseClientData(raw []byte) (*ClientData, error) {
+	var clientData ClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return nil, fmt.Errorf("invalid client data: %w", err)
+	}
+	return &clientData, nil
+}
+
+// verifyClientData checks the client data of a ceremony of the given type
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	clientData, err := ParseClientData(raw)
+	if err != nil {
+		return err
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("client data type is %q, expected %q", clientData.Type, ceremony)
+	}
+
+	got, err := DecodeBase64URL(clientData.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return errors.New("client data challenge does not match")
+	}
+
+	if clientData.CrossOrigin {
+		return errors.New("cross-origin ceremonies are not allowed")
+	}
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", clientData.Origin)
+}
+
+// verifyAuthenticatorData checks the relying party ID hash and user flags
+func (rp *RelyingParty) verifyAuthenticatorData(data *AuthenticatorData, requireUV bool) error {
+	if !bytes.Equal(data.RPIDHash, rp.idHash[:]) {
+		return errors.New("authenticator data is for another relying party")
+	}
+	if data.Flags&FlagUserPresent == 0 {
+		return errors.New("user was not present")
+	}
+	if requireUV && data.Flags&FlagUserVerified == 0 {
+		return errors.New("user was not verified")
+	}
+	return nil
+}
+
+// RegistrationResponse is the authenticator's response to a create call
+type RegistrationResponse struct {
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// Credential is a credential that passed registration. PublicKey is its
+// COSE encoded public key, which VerifyAssertion takes.
+type Credential struct {
+	ID                []byte
+	PublicKey         []byte
+	Algorithm         int
+	SignCount         uint32
+	AAGUID            []byte
+	AttestationFormat string
+	BackupEligible    bool
+}
+
+// VerifyRegistration verifies a registration ceremony (WebAuthn §7.1) for
+// the given challenge and returns the new credential. With requireUV the
+// authenticator must have verified the user, by PIN or biometrics.
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, resp *RegistrationResponse, requireUV bool) (*Credential, error) {
+	if err := rp.verifyClientData(resp.ClientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(resp.ClientDataJSON)
+
+	attestation, err := decodeCBORMap(resp.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	format, _ := attestation["fmt"].(string)
+	stmt, ok := attestation["attStmt"].(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("attestation object has no statement")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUV); err != nil {
+		return nil, err
+	}
+	if authData.Flags&FlagAttestedData == 0 {
+		return nil, errors.New("authenticator data has no credential")
+	}
+
+	publicKey, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyAttestation(format, stmt, rawAuthData, authData, publicKey, clientDataHash[:]); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:                append([]byte(nil), authData.CredentialID...),
+		PublicKey:         append([]byte(nil), authData.PublicKey...),
+		Algorithm:         publicKey.Algorithm,
+		SignCount:         authData.SignCount,
+		AAGUID:            append([]byte(nil), authData.AAGUID...),
+		AttestationFormat: format,
+		BackupEligible:    authData.Flags&FlagBackupEligible != 0,
+	}, nil
+}
+
+// AssertionResponse is the authenticator's response to a get call
+type AssertionResponse struct {
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// VerifyAssertion verifies an authentication ceremony (WebAuthn §7.2) with a
+// credential whose stored COSE public key and sign count are given, and
+// returns the sign count to store. Authenticators that do not count report
+// zero every time, which is accepted; otherwise the count must increase, or
+// ErrSignCountRegressed is returned.
+func (rp *RelyingParty) VerifyAssertion(challenge, publicKey []byte, signCount uint32, resp *AssertionResponse, requireUV bool) (uint32, error) {
+	if err := rp.verifyClientData(resp.ClientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := ParseAuthenticatorData(resp.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUV); err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(resp.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.Verify(signed, resp.Signature); err != nil {
+		return 0, err
+	}
+
+	if (authData.SignCount != 0 || signCount != 0) && authData.SignCount <= signCount {
+		return 0, ErrSignCountRegressed
+	}
+	return authData.SignCount, nil
+}
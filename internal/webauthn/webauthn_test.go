@@ -0,0 +1,164 @@
+package webauthn
+
+import (
+	"authorization/internal/webauthn/webauthntest"
+	"errors"
+	"testing"
+)
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	rp, err := NewRelyingParty("example.com", "Example", []string{"https://example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create relying party: %v", err)
+	}
+	return rp
+}
+
+func register(t *testing.T, rp *RelyingParty, authenticator *webauthntest.Authenticator, format string) *Credential {
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+	_, clientDataJSON, attestationObject, err := authenticator.Create(challenge, []byte("user-1"), format)
+	if err != nil {
+		t.Fatalf("Failed to create credential: %v", err)
+	}
+
+	cred, err := rp.VerifyRegistration(challenge, &RegistrationResponse{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject}, true)
+	if err != nil {
+		t.Fatalf("Failed to verify %s registration: %v", format, err)
+	}
+	return cred
+}
+
+func assert(t *testing.T, rp *RelyingParty, authenticator *webauthntest.Authenticator, cred *Credential) (uint32, error) {
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+	clientDataJSON, authData, signature, _, err := authenticator.Get(cred.ID, challenge)
+	if err != nil {
+		t.Fatalf("Failed to get assertion: %v", err)
+	}
+	return rp.VerifyAssertion(challenge, cred.PublicKey, cred.SignCount, &AssertionResponse{
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         signature,
+	}, true)
+}
+
+func TestRelyingParty_Registration(t *testing.T) {
+	rp := newTestRelyingParty(t)
+
+	tests := []struct {
+		name   string
+		format string
+		full   bool
+	}{
+		{"none", webauthntest.FormatNone, false},
+		{"packed self attestation", webauthntest.FormatPacked, false},
+		{"packed full attestation", webauthntest.FormatPacked, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := webauthntest.New("example.com", "https://example.com")
+			authenticator.FullAttestation = tt.full
+
+			cred := register(t, rp, authenticator, tt.format)
+			if cred.Algorithm != AlgES256 || cred.AttestationFormat != tt.format || cred.SignCount != 1 {
+				t.Errorf("Unexpected credential %+v", cred)
+			}
+
+			signCount, err := assert(t, rp, authenticator, cred)
+			if err != nil {
+				t.Fatalf("Failed to verify assertion: %v", err)
+			}
+			if signCount != 2 {
+				t.Errorf("Expected sign count 2, got %d", signCount)
+			}
+		})
+	}
+}
+
+func TestRelyingParty_RejectsForeignCeremonies(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	challenge, _ := NewChallenge()
+
+	tests := []struct {
+		name          string
+		authenticator *webauthntest.Authenticator
+		challenge     []byte
+		requireUV     bool
+	}{
+		{"other origin", webauthntest.New("example.com", "https://evil.example"), challenge, false},
+		{"other relying party", webauthntest.New("evil.example", "https://example.com"), challenge, false},
+		{"other challenge", webauthntest.New("example.com", "https://example.com"), []byte("another challenge"), false},
+		{"user not verified", func() *webauthntest.Authenticator {
+			a := webauthntest.New("example.com", "https://example.com")
+			a.UserVerified = false
+			return a
+		}(), challenge, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, clientDataJSON, attestationObject, err := tt.authenticator.Create(tt.challenge, []byte("user-1"), webauthntest.FormatNone)
+			if err != nil {
+				t.Fatalf("Failed to create credential: %v", err)
+			}
+			_, err = rp.VerifyRegistration(challenge, &RegistrationResponse{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject}, tt.requireUV)
+			if err == nil {
+				t.Error("Expected registration to be rejected")
+			}
+		})
+	}
+}
+
+func TestRelyingParty_AssertionChecks(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.New("example.com", "https://example.com")
+	cred := register(t, rp, authenticator, webauthntest.FormatNone)
+
+	// A signature by another key is rejected
+	other := register(t, rp, webauthntest.New("example.com", "https://example.com"), webauthntest.FormatNone)
+	if _, err := assert(t, rp, authenticator, &Credential{ID: cred.ID, PublicKey: other.PublicKey, SignCount: cred.SignCount}); err == nil {
+		t.Error("Expected an assertion checked against another key to fail")
+	}
+
+	// A counter that goes backwards suggests a cloned authenticator
+	cred.SignCount = 10
+	authenticator.SetSignCount(cred.ID, 4)
+	if _, err := assert(t, rp, authenticator, cred); !errors.Is(err, ErrSignCountRegressed) {
+		t.Errorf("Expected ErrSignCountRegressed, got %v", err)
+	}
+
+	// Authenticators without a counter always report zero
+	cred.SignCount = 0
+	authenticator.SetSignCount(cred.ID, ^uint32(0)) // wraps to zero on the next assertion
+	if _, err := assert(t, rp, authenticator, cred); err != nil {
+		t.Errorf("Expected a zero counter to be accepted, got %v", err)
+	}
+}
+
+func TestDecodeCBOR_RejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated byte string", []byte{0x45, 0x01, 0x02}},
+		{"huge array", []byte{0x9a, 0xff, 0xff, 0xff, 0xff}},
+		{"duplicate key", []byte{0xa2, 0x01, 0x01, 0x01, 0x02}},
+		{"indefinite length", []byte{0x5f, 0x41, 0x00, 0xff}},
+		{"float", []byte{0xf9, 0x3c, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.data); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
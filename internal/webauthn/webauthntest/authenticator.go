@@ -0,0 +1,212 @@
+// Package webauthntest provides a software authenticator for testing code
+// that takes part in WebAuthn ceremonies. It produces the same client data,
+// attestation objects and assertions a browser and a security key would,
+// using ES256 credential keys.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// Attestation formats the authenticator can produce
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// Authenticator is an in-memory authenticator holding ES256 credentials
+type Authenticator struct {
+	RPID   string
+	Origin string
+	AAGUID []byte
+
+	// UserVerified sets the user verified flag, as after a PIN or biometric
+	// check
+	UserVerified bool
+
+	// FullAttestation makes packed attestation use an attestation
+	// certificate instead of self attestation
+	FullAttestation bool
+
+	credentials map[string]*credential
+}
+
+type credential struct {
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// New creates an authenticator used from the given origin for rpID
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		AAGUID:       make([]byte, 16),
+		UserVerified: true,
+		credentials:  make(map[string]*credential),
+	}
+}
+
+// Create makes a new discoverable credential for userHandle and returns its
+// ID with the clientDataJSON and attestationObject of the registration
+func (a *Authenticator) Create(challenge, userHandle []byte, format string) (credentialID, clientDataJSON, attestationObject []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	credentialID = make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, nil, nil, err
+	}
+
+	cred := &credential{key: key, userHandle: userHandle, signCount: 1}
+	a.credentials[string(credentialID)] = cred
+
+	clientDataJSON, err = a.clientData("webauthn.create", challenge)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	authData := a.authData(0x40, cred.signCount)
+	authData = binary.BigEndian.AppendUint16(append(authData, a.AAGUID...), uint16(len(credentialID)))
+	authData = append(authData, credentialID...)
+	authData = append(authData, coseKey(&key.PublicKey)...)
+
+	stmt := encodeMap()
+	if format == FormatPacked {
+		clientDataHash := sha256.Sum256(clientDataJSON)
+		signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+		if a.FullAttestation {
+			stmt, err = a.fullAttestation(signed)
+		} else {
+			var sig []byte
+			sig, err = sign(key, signed)
+			stmt = encodeMap(pair{"alg", int64(-7)}, pair{"sig", sig})
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	} else if format != FormatNone {
+		return nil, nil, nil, errors.New("unsupported attestation format")
+	}
+
+	attestationObject = encodeMap(
+		pair{"fmt", format},
+		pair{"attStmt", rawCBOR(stmt)},
+		pair{"authData", authData},
+	)
+	return credentialID, clientDataJSON, attestationObject, nil
+}
+
+// Get signs an assertion with the credential for challenge and returns the
+// clientDataJSON, authenticatorData, signature and user handle of the
+// response
+func (a *Authenticator) Get(credentialID, challenge []byte) (clientDataJSON, authenticatorData, signature, userHandle []byte, err error) {
+	cred, ok := a.credentials[string(credentialID)]
+	if !ok {
+		return nil, nil, nil, nil, errors.New("unknown credential")
+	}
+	cred.signCount++
+
+	clientDataJSON, err = a.clientData("webauthn.get", challenge)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	authenticatorData = a.authData(0, cred.signCount)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signature, err = sign(cred.key, append(append([]byte(nil), authenticatorData...), clientDataHash[:]...))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return clientDataJSON, authenticatorData, signature, cred.userHandle, nil
+}
+
+// SetSignCount sets the counter of a credential, for example to make it
+// look cloned
+func (a *Authenticator) SetSignCount(credentialID []byte, count uint32) {
+	if cred, ok := a.credentials[string(credentialID)]; ok {
+		cred.signCount = count
+	}
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authData(flags byte, signCount uint32) []byte {
+	flags |= 0x01
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+// fullAttestation signs with a freshly made attestation certificate
+func (a *Authenticator) fullAttestation(signed []byte) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Test Authenticators"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Test Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sign(key, signed)
+	if err != nil {
+		return nil, err
+	}
+	return encodeMap(pair{"alg", int64(-7)}, pair{"sig", sig}, pair{"x5c", []interface{}{der}}), nil
+}
+
+func sign(key *ecdsa.PrivateKey, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
+
+// coseKey encodes an ES256 public key as a COSE_Key
+func coseKey(pub *ecdsa.PublicKey) []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	pub.X.FillBytes(x)
+	pub.Y.FillBytes(y)
+	return encodeMap(
+		pair{int64(1), int64(2)},
+		pair{int64(3), int64(-7)},
+		pair{int64(-1), int64(1)},
+		pair{int64(-2), x},
+		pair{int64(-3), y},
+	)
+}
@@ -0,0 +1,62 @@
+package webauthntest
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// pair is a map entry; maps are encoded in the order of their pairs
+type pair struct {
+	key   interface{}
+	value interface{}
+}
+
+// rawCBOR is already encoded and is written as is
+type rawCBOR []byte
+
+func encodeMap(pairs ...pair) []byte {
+	out := encodeHead(5, uint64(len(pairs)))
+	for _, p := range pairs {
+		out = append(out, encode(p.key)...)
+		out = append(out, encode(p.value)...)
+	}
+	return out
+}
+
+func encode(value interface{}) []byte {
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return encodeHead(1, uint64(-1-v))
+		}
+		return encodeHead(0, uint64(v))
+	case []byte:
+		return append(encodeHead(2, uint64(len(v))), v...)
+	case string:
+		return append(encodeHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := encodeHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encode(item)...)
+		}
+		return out
+	case rawCBOR:
+		return v
+	}
+	panic(fmt.Sprintf("webauthntest: cannot encode %T", value))
+}
+
+func encodeHead(major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major | 27}, arg)
+}